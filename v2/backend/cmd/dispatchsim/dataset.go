@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	dispatchDomain "github.com/pitgo/backend/internal/domain/dispatch"
+	profileDomain "github.com/pitgo/backend/internal/domain/profile"
+)
+
+// Dataset is what a simulation replays: past service requests, the
+// providers that could have served them and how the offers actually sent
+// were answered. It is loaded from Postgres or from a JSON fixture of the
+// same shape.
+type Dataset struct {
+	Requests  []Request  `json:"requests"`
+	Providers []Provider `json:"providers"`
+	Outcomes  []Outcome  `json:"outcomes"`
+}
+
+// Request is a past service request, replayed in CreatedAt order.
+type Request struct {
+	ID        string    `json:"id"`
+	Category  string    `json:"category"`
+	Latitude  float64   `json:"latitude"`
+	Longitude float64   `json:"longitude"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Provider is a provider snapshot with the signals ranking reads.
+type Provider struct {
+	profileDomain.ProviderDetails
+	Signals dispatchDomain.ProviderSignals `json:"signals"`
+}
+
+// Outcome is how a provider answered an offer for a request.
+type Outcome struct {
+	RequestID   string                        `json:"request_id"`
+	ProviderID  string                        `json:"provider_id"`
+	Status      dispatchDomain.DispatchStatus `json:"status"`
+	Wave        int                           `json:"wave"`
+	DistanceKm  float64                       `json:"distance_km"`
+	OfferedAt   time.Time                     `json:"offered_at"`
+	RespondedAt time.Time                     `json:"responded_at"`
+}
+
+// ResponseTime is how long the provider took to answer, or let the offer expire.
+func (o Outcome) ResponseTime() time.Duration {
+	return o.RespondedAt.Sub(o.OfferedAt)
+}
+
+func loadFixture(path string) (*Dataset, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var data Dataset
+	if err := json.NewDecoder(f).Decode(&data); err != nil {
+		return nil, fmt.Errorf("decode %s: %w", path, err)
+	}
+	return &data, nil
+}
+
+func saveFixture(path string, data *Dataset) error {
+	b, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0o644)
+}
+
+// loadPostgres reads up to limit requests created in [from, to), every
+// provider with its current stats, and the answered offers of those
+// requests.
+func loadPostgres(ctx context.Context, pool *pgxpool.Pool, from, to time.Time, limit int) (*Dataset, error) {
+	data := &Dataset{}
+
+	rows, err := pool.Query(ctx, `SELECT id::text, COALESCE(category, ''), latitude::float8, longitude::float8, created_at
+	          FROM service_requests
+	          WHERE created_at >= $1 AND created_at < $2
+	          ORDER BY created_at LIMIT $3`, from, to, limit)
+	if err != nil {
+		return nil, fmt.Errorf("load requests: %w", err)
+	}
+	ids := make([]string, 0, limit)
+	for rows.Next() {
+		var r Request
+		if err := rows.Scan(&r.ID, &r.Category, &r.Latitude, &r.Longitude, &r.CreatedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		data.Requests = append(data.Requests, r)
+		ids = append(ids, r.ID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Presence is not recorded over time, so every provider is replayed at
+	// its profile location and offline.
+	rows, err = pool.Query(ctx, `SELECT d.profile_id::text, d.categories, d.service_area::float8, d.latitude::float8, d.longitude::float8,
+	                 d.rating::float8, d.total_jobs, d.is_verified,
+	                 COALESCE(s.offers_accepted + s.offers_rejected + s.offers_expired, 0),
+	                 COALESCE(s.offers_accepted, 0),
+	                 COALESCE(s.median_response_ms, 0),
+	                 COALESCE(s.jobs_accepted, 0),
+	                 COALESCE(s.cancelled_after_accept, 0)
+	          FROM provider_details d
+	          LEFT JOIN provider_stats s ON s.provider_id = d.profile_id`)
+	if err != nil {
+		return nil, fmt.Errorf("load providers: %w", err)
+	}
+	for rows.Next() {
+		var p Provider
+		err := rows.Scan(&p.ProfileID, &p.Categories, &p.ServiceArea, &p.Latitude, &p.Longitude,
+			&p.Rating, &p.TotalJobs, &p.IsVerified,
+			&p.Signals.Answered, &p.Signals.Accepted, &p.Signals.MedianResponseMs,
+			&p.Signals.JobsAccepted, &p.Signals.CancelledAfterAccept)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		data.Providers = append(data.Providers, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Withdrawn offers were never answered: the simulator models their answer
+	rows, err = pool.Query(ctx, `SELECT request_id::text, provider_id::text, status, wave, distance_km::float8, created_at, updated_at
+	          FROM dispatches
+	          WHERE request_id = ANY($1::uuid[]) AND status IN ('accepted', 'rejected', 'expired')`, ids)
+	if err != nil {
+		return nil, fmt.Errorf("load outcomes: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var o Outcome
+		if err := rows.Scan(&o.RequestID, &o.ProviderID, &o.Status, &o.Wave, &o.DistanceKm, &o.OfferedAt, &o.RespondedAt); err != nil {
+			return nil, err
+		}
+		data.Outcomes = append(data.Outcomes, o)
+	}
+	return data, rows.Err()
+}
@@ -0,0 +1,180 @@
+// Command dispatchsim replays past service requests through a dispatch
+// ranking strategy and reports how it would have fared against what
+// actually happened: acceptance, distance travelled, time-to-accept and how
+// evenly offers and jobs are spread across providers.
+//
+// Requests, provider snapshots and offer outcomes come from Postgres or from
+// a JSON fixture; waves are sent by the dispatch use case itself, so the
+// simulation follows the same wave, radius, service area and ranking rules
+// as the server.
+//
+//	dispatchsim -from 2025-03-01T00:00:00Z -save march.json
+//	dispatchsim -fixture march.json -ranking weighted:distance=0.5,acceptance=0.5
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pitgo/backend/internal/infrastructure/config"
+	"github.com/pitgo/backend/internal/infrastructure/database"
+	"github.com/pitgo/backend/internal/infrastructure/logger"
+	dispatchUC "github.com/pitgo/backend/internal/usecase/dispatch"
+)
+
+// Report compares the simulated strategy with history.
+type Report struct {
+	Ranking    string  `json:"ranking"`
+	Runs       int     `json:"runs"`
+	Historical Metrics `json:"historical"`
+	Simulated  Metrics `json:"simulated"`
+}
+
+// options are the command-line flags.
+type options struct {
+	fixture string
+	from    string
+	to      string
+	limit   int
+	save    string
+	ranking string
+	runs    int
+	seed    int64
+	asJSON  bool
+	verbose bool
+}
+
+func main() {
+	var o options
+	flag.StringVar(&o.fixture, "fixture", "", "JSON dataset to replay (default: load from Postgres)")
+	flag.StringVar(&o.from, "from", "", "Postgres: only requests created at or after this RFC3339 time (default: 30 days ago)")
+	flag.StringVar(&o.to, "to", "", "Postgres: only requests created before this RFC3339 time (default: now)")
+	flag.IntVar(&o.limit, "limit", 1000, "Postgres: maximum number of requests")
+	flag.StringVar(&o.save, "save", "", "write the loaded dataset to this JSON file, for use with -fixture")
+	flag.StringVar(&o.ranking, "ranking", "", `ranking to simulate, as in DISPATCH_RANKING, e.g. "weighted:distance=0.6,rating=0.4" (default: the configured rankings)`)
+	flag.IntVar(&o.runs, "runs", 100, "number of replays; offers without a recorded outcome are drawn at random each time")
+	flag.Int64Var(&o.seed, "seed", 1, "random seed")
+	flag.BoolVar(&o.asJSON, "json", false, "print the report as JSON")
+	flag.BoolVar(&o.verbose, "v", false, "log every simulated wave")
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	if err := run(ctx, o); err != nil {
+		fmt.Fprintln(os.Stderr, "dispatchsim:", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, o options) error {
+	if o.runs < 1 {
+		return errors.New("-runs must be at least 1")
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	if o.verbose {
+		logger.Init(cfg.App.Env)
+	}
+
+	dispatchCfg := cfg.Dispatch
+	label := "configured"
+	if o.ranking != "" {
+		rc, err := config.ParseRanking(o.ranking)
+		if err != nil {
+			return fmt.Errorf("invalid -ranking: %w", err)
+		}
+		dispatchCfg.Ranking, dispatchCfg.CategoryRanking = rc, nil
+		label = o.ranking
+	}
+	rk, err := dispatchUC.NewRanking(dispatchCfg)
+	if err != nil {
+		return err
+	}
+
+	var data *Dataset
+	if o.fixture != "" {
+		data, err = loadFixture(o.fixture)
+	} else {
+		data, err = loadFromPostgres(ctx, cfg.Database, o.from, o.to, o.limit)
+	}
+	if err != nil {
+		return err
+	}
+	if o.save != "" {
+		if err := saveFixture(o.save, data); err != nil {
+			return fmt.Errorf("save dataset: %w", err)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "%d request(s), %d provider(s), %d outcome(s)\n", len(data.Requests), len(data.Providers), len(data.Outcomes))
+
+	sim := NewSimulator(data, dispatchCfg, rk, o.seed)
+	simulated, err := sim.Run(ctx, o.runs)
+	if err != nil {
+		return err
+	}
+	report := Report{Ranking: label, Runs: o.runs, Historical: sim.Historical(), Simulated: simulated}
+
+	if o.asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	}
+	printReport(report)
+	return nil
+}
+
+func loadFromPostgres(ctx context.Context, dbCfg config.DatabaseConfig, from, to string, limit int) (*Dataset, error) {
+	now := time.Now()
+	fromT, toT := now.AddDate(0, 0, -30), now
+	var err error
+	if from != "" {
+		if fromT, err = time.Parse(time.RFC3339, from); err != nil {
+			return nil, fmt.Errorf("invalid -from: %w", err)
+		}
+	}
+	if to != "" {
+		if toT, err = time.Parse(time.RFC3339, to); err != nil {
+			return nil, fmt.Errorf("invalid -to: %w", err)
+		}
+	}
+
+	dbPool, err := database.NewPostgresPool(dbCfg)
+	if err != nil {
+		return nil, fmt.Errorf("connect to postgres: %w", err)
+	}
+	defer dbPool.Close()
+	return loadPostgres(ctx, dbPool, fromT, toT, limit)
+}
+
+func printReport(r Report) {
+	h, s := r.Historical, r.Simulated
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "ranking: %s (%d run(s))\n\n", r.Ranking, r.Runs)
+	fmt.Fprintln(w, "METRIC\tHISTORICAL\tSIMULATED")
+	fmt.Fprintf(w, "requests\t%d\t%d\n", h.Requests, s.Requests)
+	fmt.Fprintf(w, "acceptance rate\t%.1f%%\t%.1f%%\n", h.AcceptanceRate*100, s.AcceptanceRate*100)
+	fmt.Fprintf(w, "mean distance (km)\t%.2f\t%.2f\n", h.MeanDistanceKm, s.MeanDistanceKm)
+	fmt.Fprintf(w, "median time to accept\t%s\t%s\n", msDuration(h.MedianTimeToAcceptMs), msDuration(s.MedianTimeToAcceptMs))
+	fmt.Fprintf(w, "p90 time to accept\t%s\t%s\n", msDuration(h.P90TimeToAcceptMs), msDuration(s.P90TimeToAcceptMs))
+	fmt.Fprintf(w, "mean waves\t%.2f\t%.2f\n", h.MeanWaves, s.MeanWaves)
+	fmt.Fprintf(w, "offers per request\t%.2f\t%.2f\n", h.OffersPerRequest, s.OffersPerRequest)
+	fmt.Fprintf(w, "providers with jobs\t%.1f\t%.1f\n", h.ProvidersWithJobs, s.ProvidersWithJobs)
+	fmt.Fprintf(w, "offers gini\t%.3f\t%.3f\n", h.OffersGini, s.OffersGini)
+	fmt.Fprintf(w, "jobs gini\t%.3f\t%.3f\n", h.JobsGini, s.JobsGini)
+	w.Flush()
+}
+
+func msDuration(ms int64) time.Duration {
+	return (time.Duration(ms) * time.Millisecond).Round(time.Second)
+}
@@ -0,0 +1,429 @@
+package main
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+
+	dispatchDomain "github.com/pitgo/backend/internal/domain/dispatch"
+	"github.com/pitgo/backend/internal/domain/geo"
+	"github.com/pitgo/backend/internal/domain/outbox"
+	profileDomain "github.com/pitgo/backend/internal/domain/profile"
+	requestDomain "github.com/pitgo/backend/internal/domain/request"
+	"github.com/pitgo/backend/internal/infrastructure/config"
+	dispatchUC "github.com/pitgo/backend/internal/usecase/dispatch"
+)
+
+// Simulator replays a Dataset through the dispatch use case's own wave and
+// ranking code, backed by in-memory repositories. An offer a provider really
+// received is answered as it was; any other offer is accepted with the
+// provider's historical acceptance rate, after its median response time.
+type Simulator struct {
+	data     *Dataset
+	cfg      config.DispatchConfig
+	ranking  *dispatchUC.Ranking
+	rng      *rand.Rand
+	outcomes map[offerKey]Outcome
+	signals  map[string]dispatchDomain.ProviderSignals
+
+	// Used for providers without any history
+	baseAcceptance float64
+	baseResponse   time.Duration
+}
+
+type offerKey struct{ requestID, providerID string }
+
+func NewSimulator(data *Dataset, cfg config.DispatchConfig, ranking *dispatchUC.Ranking, seed int64) *Simulator {
+	if cfg.OfferTTL <= 0 {
+		cfg.OfferTTL = 5 * time.Minute
+	}
+	s := &Simulator{
+		data:     data,
+		cfg:      cfg,
+		ranking:  ranking,
+		rng:      rand.New(rand.NewSource(seed)),
+		outcomes: make(map[offerKey]Outcome, len(data.Outcomes)),
+		signals:  make(map[string]dispatchDomain.ProviderSignals, len(data.Providers)),
+	}
+	var accepted int
+	responses := make([]time.Duration, 0, len(data.Outcomes))
+	for _, o := range data.Outcomes {
+		s.outcomes[offerKey{o.RequestID, o.ProviderID}] = o
+		if o.Status == dispatchDomain.DispatchAccepted {
+			accepted++
+		}
+		if o.Status != dispatchDomain.DispatchExpired {
+			responses = append(responses, o.ResponseTime())
+		}
+	}
+	for _, p := range data.Providers {
+		s.signals[p.ProfileID] = p.Signals
+	}
+	s.baseAcceptance = 0.5
+	if len(data.Outcomes) > 0 {
+		s.baseAcceptance = float64(accepted) / float64(len(data.Outcomes))
+	}
+	s.baseResponse = cfg.OfferTTL / 2
+	if len(responses) > 0 {
+		s.baseResponse = percentile(responses, 0.5)
+	}
+	return s
+}
+
+// Run replays every request runs times and summarizes all the runs together.
+func (s *Simulator) Run(ctx context.Context, runs int) (Metrics, error) {
+	t := newTally()
+	for i := 0; i < runs; i++ {
+		offers := &offerStore{
+			offers:  make(map[string][]*dispatchDomain.Dispatch),
+			waves:   make(map[string]*dispatchDomain.WaveState),
+			signals: s.signals,
+		}
+		requests := newRequestStore(s.data.Requests)
+		uc := dispatchUC.New(offers, newProviderStore(s.data.Providers), requests, noTx{}, discardOutbox{}, s.cfg, s.ranking)
+
+		for _, req := range requests.ordered {
+			res, err := s.dispatch(ctx, uc, req.ID)
+			if err != nil {
+				return Metrics{}, err
+			}
+			t.add(res)
+		}
+		t.endRun()
+	}
+	return t.summarize(s.data.Providers, runs), nil
+}
+
+// Historical summarizes what actually happened to the dataset's requests.
+func (s *Simulator) Historical() Metrics {
+	byRequest := make(map[string][]Outcome)
+	for _, o := range s.data.Outcomes {
+		byRequest[o.RequestID] = append(byRequest[o.RequestID], o)
+	}
+
+	t := newTally()
+	for _, req := range s.data.Requests {
+		res := requestResult{}
+		var firstOffer time.Time
+		for _, o := range byRequest[req.ID] {
+			res.Offers = append(res.Offers, o.ProviderID)
+			res.Waves = max(res.Waves, o.Wave)
+			if firstOffer.IsZero() || o.OfferedAt.Before(firstOffer) {
+				firstOffer = o.OfferedAt
+			}
+			if o.Status == dispatchDomain.DispatchAccepted {
+				res.Accepted, res.ProviderID, res.DistanceKm = true, o.ProviderID, o.DistanceKm
+			}
+		}
+		if res.Accepted {
+			res.TimeToAccept = s.outcomes[offerKey{req.ID, res.ProviderID}].RespondedAt.Sub(firstOffer)
+		}
+		t.add(res)
+	}
+	t.endRun()
+	return t.summarize(s.data.Providers, 1)
+}
+
+// dispatch sends waves for one request until an offer is accepted or
+// NextWave sends nothing more. Waves follow each other as soon as the last
+// offer of the previous one is answered.
+func (s *Simulator) dispatch(ctx context.Context, uc *dispatchUC.UseCase, requestID string) (requestResult, error) {
+	res := requestResult{}
+	var elapsed time.Duration
+	for {
+		wave, err := uc.NextWave(ctx, requestID)
+		if err != nil {
+			return res, err
+		}
+		if wave == nil || wave.Exhausted {
+			return res, nil
+		}
+		res.Waves = wave.Wave
+
+		var winner *dispatchDomain.Dispatch
+		var winnerAfter, waveLen time.Duration
+		for _, d := range wave.Offers {
+			res.Offers = append(res.Offers, d.ProviderID)
+			accepted, after := s.answer(requestID, d.ProviderID)
+			if accepted && (winner == nil || after < winnerAfter) {
+				winner, winnerAfter = d, after
+			}
+			waveLen = max(waveLen, after)
+		}
+		for _, d := range wave.Offers {
+			d.Status = dispatchDomain.DispatchRejected
+		}
+		if winner != nil {
+			winner.Status = dispatchDomain.DispatchAccepted
+			res.Accepted, res.ProviderID, res.DistanceKm = true, winner.ProviderID, winner.Distance
+			res.TimeToAccept = elapsed + winnerAfter
+			return res, nil
+		}
+		elapsed += waveLen
+	}
+}
+
+// answer decides whether the provider accepts the request's offer, and after
+// how long it answers (or the offer expires).
+func (s *Simulator) answer(requestID, providerID string) (bool, time.Duration) {
+	if o, ok := s.outcomes[offerKey{requestID, providerID}]; ok {
+		if o.Status == dispatchDomain.DispatchExpired {
+			return false, s.cfg.OfferTTL
+		}
+		return o.Status == dispatchDomain.DispatchAccepted, min(o.ResponseTime(), s.cfg.OfferTTL)
+	}
+
+	sig := s.signals[providerID]
+	rate, ok := sig.AcceptanceRate()
+	if !ok {
+		rate = s.baseAcceptance
+	}
+	after := s.baseResponse
+	if sig.Answered > 0 {
+		after = time.Duration(sig.MedianResponseMs) * time.Millisecond
+	}
+	return s.rng.Float64() < rate, min(after, s.cfg.OfferTTL)
+}
+
+// --- Metrics ---
+
+// Metrics describe how a set of requests was dispatched. Times are from the
+// first offer sent to the accepting answer; fairness is the Gini coefficient
+// of offers and jobs across all providers, 0 meaning evenly spread.
+type Metrics struct {
+	Requests             int     `json:"requests"`
+	AcceptanceRate       float64 `json:"acceptance_rate"`
+	MeanDistanceKm       float64 `json:"mean_distance_km"`
+	MedianTimeToAcceptMs int64   `json:"median_time_to_accept_ms"`
+	P90TimeToAcceptMs    int64   `json:"p90_time_to_accept_ms"`
+	MeanWaves            float64 `json:"mean_waves"`
+	OffersPerRequest     float64 `json:"offers_per_request"`
+	ProvidersWithJobs    float64 `json:"providers_with_jobs"` // Per run
+	OffersGini           float64 `json:"offers_gini"`
+	JobsGini             float64 `json:"jobs_gini"`
+}
+
+type requestResult struct {
+	Accepted     bool
+	ProviderID   string
+	DistanceKm   float64
+	TimeToAccept time.Duration
+	Waves        int
+	Offers       []string // Providers offered the request
+}
+
+type tally struct {
+	results           []requestResult
+	offers            map[string]int
+	jobs              map[string]int
+	runJobs           map[string]bool // Providers with a job in the current run
+	providersWithJobs int             // Summed over finished runs
+}
+
+func newTally() *tally {
+	return &tally{offers: make(map[string]int), jobs: make(map[string]int), runJobs: make(map[string]bool)}
+}
+
+func (t *tally) add(res requestResult) {
+	t.results = append(t.results, res)
+	for _, id := range res.Offers {
+		t.offers[id]++
+	}
+	if res.Accepted {
+		t.jobs[res.ProviderID]++
+		t.runJobs[res.ProviderID] = true
+	}
+}
+
+func (t *tally) endRun() {
+	t.providersWithJobs += len(t.runJobs)
+	t.runJobs = make(map[string]bool)
+}
+
+func (t *tally) summarize(providers []Provider, runs int) Metrics {
+	m := Metrics{}
+	if len(t.results) == 0 {
+		return m
+	}
+	m.Requests = len(t.results) / runs
+
+	var accepted, waves, offers int
+	var distance float64
+	var times []time.Duration
+	for _, r := range t.results {
+		waves += r.Waves
+		offers += len(r.Offers)
+		if r.Accepted {
+			accepted++
+			distance += r.DistanceKm
+			times = append(times, r.TimeToAccept)
+		}
+	}
+	n := float64(len(t.results))
+	m.AcceptanceRate = float64(accepted) / n
+	m.MeanWaves = float64(waves) / n
+	m.OffersPerRequest = float64(offers) / n
+	if accepted > 0 {
+		m.MeanDistanceKm = distance / float64(accepted)
+		m.MedianTimeToAcceptMs = percentile(times, 0.5).Milliseconds()
+		m.P90TimeToAcceptMs = percentile(times, 0.9).Milliseconds()
+	}
+	m.ProvidersWithJobs = float64(t.providersWithJobs) / float64(runs)
+
+	offerCounts := make([]float64, len(providers))
+	jobCounts := make([]float64, len(providers))
+	for i, p := range providers {
+		offerCounts[i] = float64(t.offers[p.ProfileID])
+		jobCounts[i] = float64(t.jobs[p.ProfileID])
+	}
+	m.OffersGini = gini(offerCounts)
+	m.JobsGini = gini(jobCounts)
+	return m
+}
+
+// percentile returns the p-th (0 < p <= 1) percentile of ds, nearest rank.
+// It sorts ds.
+func percentile(ds []time.Duration, p float64) time.Duration {
+	sort.Slice(ds, func(i, j int) bool { return ds[i] < ds[j] })
+	i := int(math.Ceil(p*float64(len(ds)))) - 1
+	return ds[max(i, 0)]
+}
+
+// gini returns the Gini coefficient of xs: 0 when all are equal, close to 1
+// when one holds everything. It sorts xs.
+func gini(xs []float64) float64 {
+	sort.Float64s(xs)
+	var sum, weighted float64
+	for i, x := range xs {
+		sum += x
+		weighted += float64(i+1) * x
+	}
+	if sum == 0 {
+		return 0
+	}
+	n := float64(len(xs))
+	return 2*weighted/(n*sum) - (n+1)/n
+}
+
+// --- In-memory repositories ---
+
+// offerStore is the dispatch repository of one run. Providers have no open
+// offers or jobs beyond their snapshot signals.
+type offerStore struct {
+	dispatchDomain.Repository // Only what NextWave calls is implemented
+	offers                    map[string][]*dispatchDomain.Dispatch
+	waves                     map[string]*dispatchDomain.WaveState
+	signals                   map[string]dispatchDomain.ProviderSignals
+}
+
+func (s *offerStore) Create(_ context.Context, d *dispatchDomain.Dispatch) error {
+	s.offers[d.RequestID] = append(s.offers[d.RequestID], d)
+	return nil
+}
+
+func (s *offerStore) GetByRequestID(_ context.Context, requestID string) ([]*dispatchDomain.Dispatch, error) {
+	return s.offers[requestID], nil
+}
+
+func (s *offerStore) ProviderSignals(_ context.Context, providerIDs []string) (map[string]dispatchDomain.ProviderSignals, error) {
+	out := make(map[string]dispatchDomain.ProviderSignals, len(providerIDs))
+	for _, id := range providerIDs {
+		out[id] = s.signals[id]
+	}
+	return out, nil
+}
+
+func (s *offerStore) LockWave(_ context.Context, requestID string) (*dispatchDomain.WaveState, error) {
+	w, ok := s.waves[requestID]
+	if !ok {
+		w = &dispatchDomain.WaveState{RequestID: requestID}
+		s.waves[requestID] = w
+	}
+	cp := *w
+	return &cp, nil
+}
+
+func (s *offerStore) SaveWave(_ context.Context, w *dispatchDomain.WaveState) error {
+	s.waves[w.RequestID] = w
+	return nil
+}
+
+// providerStore finds providers the way the Postgres repository does, from
+// their snapshot location.
+type providerStore struct {
+	profileDomain.Repository
+	providers []*profileDomain.ProviderDetails
+}
+
+func newProviderStore(providers []Provider) *providerStore {
+	s := &providerStore{providers: make([]*profileDomain.ProviderDetails, len(providers))}
+	for i := range providers {
+		p := providers[i].ProviderDetails
+		s.providers[i] = &p
+	}
+	return s
+}
+
+func (s *providerStore) FindProvidersInRadius(_ context.Context, lat, lng, radiusKm float64, category string, _ time.Time) ([]*profileDomain.ProviderDetails, error) {
+	var out []*profileDomain.ProviderDetails
+	for _, p := range s.providers {
+		limit := radiusKm
+		if p.ServiceArea > 0 {
+			limit = math.Min(limit, p.ServiceArea)
+		}
+		if hasCategory(p, category) && geo.Distance(lat, lng, p.Latitude, p.Longitude) <= limit {
+			out = append(out, p)
+		}
+	}
+	return out, nil
+}
+
+func hasCategory(p *profileDomain.ProviderDetails, category string) bool {
+	for _, c := range p.Categories {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
+
+// requestStore holds the replayed requests, open and in CreatedAt order.
+type requestStore struct {
+	requestDomain.Repository
+	byID    map[string]*requestDomain.ServiceRequest
+	ordered []*requestDomain.ServiceRequest
+}
+
+func newRequestStore(requests []Request) *requestStore {
+	s := &requestStore{byID: make(map[string]*requestDomain.ServiceRequest, len(requests))}
+	for _, r := range requests {
+		req := &requestDomain.ServiceRequest{
+			ID:        r.ID,
+			Category:  r.Category,
+			Latitude:  r.Latitude,
+			Longitude: r.Longitude,
+			Status:    requestDomain.StatusOpen,
+			CreatedAt: r.CreatedAt,
+		}
+		s.byID[r.ID] = req
+		s.ordered = append(s.ordered, req)
+	}
+	sort.SliceStable(s.ordered, func(i, j int) bool { return s.ordered[i].CreatedAt.Before(s.ordered[j].CreatedAt) })
+	return s
+}
+
+func (s *requestStore) GetByIDForUpdate(_ context.Context, id string) (*requestDomain.ServiceRequest, error) {
+	return s.byID[id], nil
+}
+
+type noTx struct{}
+
+func (noTx) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+type discardOutbox struct{ outbox.Repository }
+
+func (discardOutbox) Add(context.Context, *outbox.Message) error { return nil }
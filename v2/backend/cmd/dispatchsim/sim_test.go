@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pitgo/backend/internal/infrastructure/config"
+	dispatchUC "github.com/pitgo/backend/internal/usecase/dispatch"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func simulate(t *testing.T, ranking string) (historical, simulated Metrics) {
+	t.Helper()
+	data, err := loadFixture("testdata/fixture.json")
+	require.NoError(t, err)
+	rc, err := config.ParseRanking(ranking)
+	require.NoError(t, err)
+	cfg := config.DispatchConfig{OfferTTL: 5 * time.Minute, WaveSize: 1, InitialRadiusKm: 10, MaxRadiusKm: 10, Ranking: rc}
+	rk, err := dispatchUC.NewRanking(cfg)
+	require.NoError(t, err)
+
+	sim := NewSimulator(data, cfg, rk, 1)
+	simulated, err = sim.Run(context.Background(), 3)
+	require.NoError(t, err)
+	return sim.Historical(), simulated
+}
+
+func TestSimulator_ReplaysRecordedOutcomes(t *testing.T) {
+	historical, simulated := simulate(t, "nearest")
+
+	assert.Equal(t, 2, simulated.Requests)
+	assert.Equal(t, 1.0, simulated.AcceptanceRate)
+	assert.Equal(t, 2.0, simulated.MeanWaves)
+	assert.InDelta(t, 6.67, simulated.MeanDistanceKm, 1e-9)
+	assert.Equal(t, int64(90000), simulated.MedianTimeToAcceptMs)
+	assert.Equal(t, int64(320000), simulated.P90TimeToAcceptMs)
+	assert.Equal(t, 1.0, simulated.ProvidersWithJobs)
+
+	// Nearest offers the flaky provider first, like production did, so the
+	// replay reproduces history
+	assert.InDelta(t, historical.MeanDistanceKm, simulated.MeanDistanceKm, 1e-9)
+	historical.MeanDistanceKm, simulated.MeanDistanceKm = 0, 0
+	assert.Equal(t, historical, simulated)
+}
+
+func TestSimulator_WeightedRankingSkipsFlakyProvider(t *testing.T) {
+	_, simulated := simulate(t, "weighted:acceptance=1")
+
+	assert.Equal(t, 1.0, simulated.AcceptanceRate)
+	assert.Equal(t, 1.0, simulated.MeanWaves)
+	assert.Equal(t, int64(20000), simulated.MedianTimeToAcceptMs)
+	assert.Equal(t, int64(60000), simulated.P90TimeToAcceptMs)
+	assert.InDelta(t, 2.0/3, simulated.OffersGini, 1e-9, "every offer goes to one of three providers")
+}
+
+func TestGini(t *testing.T) {
+	assert.Zero(t, gini([]float64{3, 3, 3}))
+	assert.Zero(t, gini([]float64{0, 0}))
+	assert.InDelta(t, 0.75, gini([]float64{0, 0, 0, 8}), 1e-9)
+}
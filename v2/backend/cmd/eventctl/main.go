@@ -0,0 +1,315 @@
+// Command eventctl inspects the event log and replays logged events, either
+// into the live queue or straight into one subscriber.
+//
+//	eventctl list   -aggregate-id <service-request-id>
+//	eventctl replay -topic request.created -from 2025-03-01T00:00:00Z -target subscriber -subscriber dispatch-worker
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	domain "github.com/pitgo/backend/internal/domain/eventlog"
+	"github.com/pitgo/backend/internal/domain/events"
+	"github.com/pitgo/backend/internal/infrastructure/cache"
+	"github.com/pitgo/backend/internal/infrastructure/config"
+	"github.com/pitgo/backend/internal/infrastructure/database"
+	"github.com/pitgo/backend/internal/infrastructure/logger"
+	"github.com/pitgo/backend/internal/infrastructure/push"
+	"github.com/pitgo/backend/internal/infrastructure/queue"
+	"github.com/pitgo/backend/internal/repository/postgres"
+	dispatchUC "github.com/pitgo/backend/internal/usecase/dispatch"
+	eventlogUC "github.com/pitgo/backend/internal/usecase/eventlog"
+	providerstatsUC "github.com/pitgo/backend/internal/usecase/providerstats"
+	dispatchWorker "github.com/pitgo/backend/internal/worker/dispatch"
+	workerMiddleware "github.com/pitgo/backend/internal/worker/middleware"
+	providerstatsWorker "github.com/pitgo/backend/internal/worker/providerstats"
+)
+
+const usage = `usage: eventctl <command> [flags]
+
+commands:
+  list     print logged events matching the filter
+  replay   re-deliver logged events to the queue or to one subscriber
+
+Run "eventctl <command> -h" for the command's flags.
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	var err error
+	switch os.Args[1] {
+	case "list":
+		err = runList(ctx, os.Args[2:])
+	case "replay":
+		err = runReplay(ctx, os.Args[2:])
+	case "-h", "-help", "--help", "help":
+		fmt.Print(usage)
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "eventctl:", err)
+		os.Exit(1)
+	}
+}
+
+// filterFlags registers the event log filter flags shared by every command.
+type filterFlags struct {
+	topics        string
+	correlationID string
+	aggregateID   string
+	from          string
+	to            string
+	afterSeq      int64
+	limit         int
+}
+
+func (f *filterFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.topics, "topic", "", "comma-separated topics (default: all)")
+	fs.StringVar(&f.correlationID, "correlation-id", "", "only events caused by this HTTP request (X-Request-ID)")
+	fs.StringVar(&f.aggregateID, "aggregate-id", "", "only events about this service request")
+	fs.StringVar(&f.from, "from", "", "only events that occurred at or after this RFC3339 time")
+	fs.StringVar(&f.to, "to", "", "only events that occurred before this RFC3339 time")
+	fs.Int64Var(&f.afterSeq, "after-seq", 0, "only events logged after this sequence number")
+	fs.IntVar(&f.limit, "limit", 0, "maximum number of events (0 = no limit)")
+}
+
+func (f *filterFlags) filter() (domain.Filter, error) {
+	filter := domain.Filter{
+		CorrelationID: f.correlationID,
+		AggregateID:   f.aggregateID,
+		AfterSeq:      f.afterSeq,
+		Limit:         f.limit,
+	}
+	for _, t := range strings.Split(f.topics, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			filter.Topics = append(filter.Topics, t)
+		}
+	}
+	var err error
+	if f.from != "" {
+		if filter.From, err = time.Parse(time.RFC3339, f.from); err != nil {
+			return filter, fmt.Errorf("invalid -from: %w", err)
+		}
+	}
+	if f.to != "" {
+		if filter.To, err = time.Parse(time.RFC3339, f.to); err != nil {
+			return filter, fmt.Errorf("invalid -to: %w", err)
+		}
+	}
+	return filter, nil
+}
+
+func runList(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	var ff filterFlags
+	ff.register(fs)
+	asJSON := fs.Bool("json", false, "print full envelopes as JSON lines")
+	_ = fs.Parse(args)
+
+	filter, err := ff.filter()
+	if err != nil {
+		return err
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	dbPool, err := database.NewPostgresPool(cfg.Database)
+	if err != nil {
+		return fmt.Errorf("connect to postgres: %w", err)
+	}
+	defer dbPool.Close()
+
+	replayer := eventlogUC.NewReplayer(postgres.NewEventLogRepository(dbPool))
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		_, err = replayer.Replay(ctx, filter, func(_ context.Context, e *domain.Entry) error {
+			return enc.Encode(e)
+		})
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "SEQ\tOCCURRED_AT\tTOPIC\tVERSION\tEVENT_ID\tAGGREGATE_ID\tCORRELATION_ID")
+	n, err := replayer.Replay(ctx, filter, func(_ context.Context, e *domain.Entry) error {
+		_, err := fmt.Fprintf(w, "%d\t%s\t%s\tv%d\t%s\t%s\t%s\n",
+			e.Seq, e.OccurredAt.Format(time.RFC3339), e.Topic, e.SchemaVersion, e.EventID, e.AggregateID, e.CorrelationID)
+		return err
+	})
+	w.Flush()
+	fmt.Fprintf(os.Stderr, "%d event(s)\n", n)
+	return err
+}
+
+func runReplay(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	var ff filterFlags
+	ff.register(fs)
+	target := fs.String("target", "queue", `where to deliver: "queue" (publish to the live queue) or "subscriber"`)
+	subscriber := fs.String("subscriber", "", "subscriber to drive when -target=subscriber (dispatch-worker, provider-stats-worker)")
+	newIDs := fs.Bool("new-event-ids", false, "give events replayed into a subscriber fresh IDs so it processes them again (-target=subscriber only)")
+	dryRun := fs.Bool("dry-run", false, "print what would be replayed without delivering")
+	_ = fs.Parse(args)
+
+	filter, err := ff.filter()
+	if err != nil {
+		return err
+	}
+	if filter.CorrelationID == "" && filter.AggregateID == "" && len(filter.Topics) == 0 && filter.From.IsZero() && filter.AfterSeq == 0 {
+		return errors.New("refusing to replay the whole log: set -topic, -aggregate-id, -correlation-id, -from or -after-seq")
+	}
+	if *target != "queue" && *target != "subscriber" {
+		return fmt.Errorf("unknown -target %q", *target)
+	}
+	if *target == "subscriber" && *subscriber == "" {
+		return errors.New("-target=subscriber requires -subscriber")
+	}
+	// Fresh IDs on the live queue would reach every consumer again and log
+	// each event a second time
+	if *newIDs && *target != "subscriber" {
+		return errors.New("-new-event-ids requires -target=subscriber")
+	}
+	if *newIDs && *subscriber == providerstatsWorker.Group {
+		return fmt.Errorf("-new-event-ids would count events twice in %s", providerstatsWorker.Group)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	logger.Init(cfg.App.Env)
+
+	dbPool, err := database.NewPostgresPool(cfg.Database)
+	if err != nil {
+		return fmt.Errorf("connect to postgres: %w", err)
+	}
+	defer dbPool.Close()
+
+	redisClient, err := cache.NewRedisClient(cfg.Redis)
+	if err != nil {
+		logger.Warn().Err(err).Msg("Failed to connect to Redis")
+	} else if redisClient != nil {
+		defer redisClient.Close()
+	}
+
+	q, err := queue.New(cfg.Queue, redisClient, dbPool)
+	if err != nil {
+		return fmt.Errorf("create queue: %w", err)
+	}
+	defer q.Close()
+	if cfg.Queue.Driver == "" || cfg.Queue.Driver == "memory" {
+		logger.Warn().Msg("QUEUE_DRIVER is memory: events published by eventctl are not seen by the server")
+	}
+
+	eventLogRepo := postgres.NewEventLogRepository(dbPool)
+	publisher := eventlogUC.NewRecordingPublisher(q, eventLogRepo)
+	replayer := eventlogUC.NewReplayer(eventLogRepo)
+
+	var deliver func(ctx context.Context, msg queue.Message) error
+	switch *target {
+	case "queue":
+		deliver = func(ctx context.Context, msg queue.Message) error {
+			return publisher.Publish(ctx, msg.Topic, msg.Payload)
+		}
+	case "subscriber":
+		direct := queue.NewDirectConsumer()
+		if err := registerSubscriber(*subscriber, direct, cfg.Dispatch, dbPool); err != nil {
+			return err
+		}
+		deliver = func(ctx context.Context, msg queue.Message) error {
+			if !direct.Handles(msg.Topic) {
+				return nil
+			}
+			return direct.Deliver(ctx, msg)
+		}
+	}
+
+	n, err := replayer.Replay(ctx, filter, func(ctx context.Context, e *domain.Entry) error {
+		payload := []byte(e.Envelope)
+		eventID := e.EventID
+		if *newIDs {
+			env, err := events.UnmarshalEnvelope(payload)
+			if err != nil {
+				return fmt.Errorf("unmarshal envelope: %w", err)
+			}
+			env.EventID = uuid.New().String()
+			if payload, err = json.Marshal(env); err != nil {
+				return err
+			}
+			eventID = env.EventID
+		}
+
+		logger.Info().
+			Int64("seq", e.Seq).
+			Str("topic", e.Topic).
+			Str("event_id", eventID).
+			Str("aggregate_id", e.AggregateID).
+			Str("correlation_id", e.CorrelationID).
+			Bool("dry_run", *dryRun).
+			Msg("Replaying event")
+		if *dryRun {
+			return nil
+		}
+		return deliver(ctx, queue.Message{ID: eventID, Topic: e.Topic, Payload: payload})
+	})
+	logger.Info().Int("count", n).Str("target", *target).Msg("Replay finished")
+	return err
+}
+
+// registerSubscriber subscribes the named worker to c. Replays into the
+// dispatch worker bypass the idempotency middleware on purpose: re-running a
+// handler is the point. The provider stats worker keeps counters, so it stays
+// behind the middleware and only counts events it has not seen yet.
+// Events recorded by the handlers go to the outbox and are published by the
+// server's relay.
+func registerSubscriber(name string, c queue.Consumer, cfg config.DispatchConfig, dbPool *pgxpool.Pool) error {
+	switch name {
+	case dispatchWorker.Group:
+		ranking, err := dispatchUC.NewRanking(cfg)
+		if err != nil {
+			return err
+		}
+		uc := dispatchUC.New(
+			postgres.NewDispatchRepository(dbPool),
+			postgres.NewProfileRepository(dbPool),
+			postgres.NewRequestRepository(dbPool),
+			database.NewTxManager(dbPool),
+			postgres.NewOutboxRepository(dbPool),
+			cfg,
+			ranking,
+		)
+		w := dispatchWorker.NewWorker(c, uc, push.NewLogNotifier())
+		return w.Register()
+	case providerstatsWorker.Group:
+		tx := database.NewTxManager(dbPool)
+		uc := providerstatsUC.New(postgres.NewProviderStatsRepository(dbPool), tx)
+		idempotent := workerMiddleware.Idempotent(postgres.NewProcessedEventRepository(dbPool), providerstatsWorker.Group, tx)
+		return providerstatsWorker.NewWorker(c, uc).Register(idempotent)
+	default:
+		return fmt.Errorf("unknown subscriber %q (known: %s, %s)", name, dispatchWorker.Group, providerstatsWorker.Group)
+	}
+}
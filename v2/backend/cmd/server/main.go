@@ -0,0 +1,336 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+	profileDomain "github.com/pitgo/backend/internal/domain/profile"
+	"github.com/pitgo/backend/internal/infrastructure/auth"
+	"github.com/pitgo/backend/internal/infrastructure/cache"
+	"github.com/pitgo/backend/internal/infrastructure/config"
+	"github.com/pitgo/backend/internal/infrastructure/database"
+	"github.com/pitgo/backend/internal/infrastructure/lifecycle"
+	"github.com/pitgo/backend/internal/infrastructure/live"
+	"github.com/pitgo/backend/internal/infrastructure/logger"
+	"github.com/pitgo/backend/internal/infrastructure/push"
+	"github.com/pitgo/backend/internal/infrastructure/queue"
+	webhookInfra "github.com/pitgo/backend/internal/infrastructure/webhook"
+	"github.com/pitgo/backend/internal/interfaces/http/handler"
+	"github.com/pitgo/backend/internal/interfaces/http/middleware"
+	"github.com/pitgo/backend/internal/interfaces/http/router"
+	"github.com/pitgo/backend/internal/repository/postgres"
+	catalogUC "github.com/pitgo/backend/internal/usecase/catalog"
+	deadletterUC "github.com/pitgo/backend/internal/usecase/deadletter"
+	dispatchUC "github.com/pitgo/backend/internal/usecase/dispatch"
+	eventlogUC "github.com/pitgo/backend/internal/usecase/eventlog"
+	identityUC "github.com/pitgo/backend/internal/usecase/identity"
+	profileUC "github.com/pitgo/backend/internal/usecase/profile"
+	providerstatsUC "github.com/pitgo/backend/internal/usecase/providerstats"
+	requestUC "github.com/pitgo/backend/internal/usecase/request"
+	webhookUC "github.com/pitgo/backend/internal/usecase/webhook"
+	deadletterWorker "github.com/pitgo/backend/internal/worker/deadletter"
+	dispatchWorker "github.com/pitgo/backend/internal/worker/dispatch"
+	liveWorker "github.com/pitgo/backend/internal/worker/live"
+	workerMiddleware "github.com/pitgo/backend/internal/worker/middleware"
+	outboxWorker "github.com/pitgo/backend/internal/worker/outbox"
+	providerstatsWorker "github.com/pitgo/backend/internal/worker/providerstats"
+	schedulerWorker "github.com/pitgo/backend/internal/worker/scheduler"
+	webhookWorker "github.com/pitgo/backend/internal/worker/webhook"
+)
+
+func main() {
+	// Load config
+	cfg, err := config.Load()
+	if err != nil {
+		panic(fmt.Sprintf("failed to load config: %v", err))
+	}
+
+	// Init logger
+	logger.Init(cfg.App.Env)
+	logger.Info().Str("env", cfg.App.Env).Msg("Starting Pitgo backend")
+
+	// Set Gin mode
+	if cfg.App.Env == "production" {
+		gin.SetMode(gin.ReleaseMode)
+	}
+
+	// Run migrations
+	if err := database.RunMigrations(cfg.Database.DSN()); err != nil {
+		logger.Fatal().Err(err).Msg("Failed to run migrations")
+		return
+	}
+	// Database
+	dbPool, err := database.NewPostgresPool(cfg.Database)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Failed to connect to PostgreSQL")
+		return
+	}
+	defer dbPool.Close()
+
+	// Redis
+	redisClient, err := cache.NewRedisClient(cfg.Redis)
+	if err != nil {
+		logger.Warn().Err(err).Msg("Failed to connect to Redis; cache disabled")
+	} else if redisClient != nil {
+		defer redisClient.Close()
+	}
+
+	// Queue
+	q, err := queue.New(cfg.Queue, redisClient, dbPool)
+	if err != nil {
+		logger.Fatal().Err(err).Str("driver", cfg.Queue.Driver).Msg("Failed to create queue")
+		return
+	}
+	logger.Info().Str("driver", cfg.Queue.Driver).Msg("Queue driver selected")
+	// Live stream events reach every replica through the fanout
+	fanout, err := queue.NewFanout(cfg.Queue, redisClient, dbPool)
+	if err != nil {
+		logger.Fatal().Err(err).Str("driver", cfg.Queue.Driver).Msg("Failed to create fanout")
+		return
+	}
+	hub := live.NewHub(live.DefaultBuffer)
+	// ctx scopes background jobs (relay, purgers); it is cancelled last on shutdown
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Auth
+	clerkAuth := auth.NewClerkAuth(cfg.Auth)
+
+	// --- Repositories ---
+	catalogRepo := postgres.NewCatalogRepository(dbPool)
+	identityRepo := postgres.NewIdentityRepository(dbPool)
+	profileRepo := postgres.NewProfileRepository(dbPool)
+	requestRepo := postgres.NewRequestRepository(dbPool)
+	dispatchRepo := postgres.NewDispatchRepository(dbPool)
+	outboxRepo := postgres.NewOutboxRepository(dbPool)
+	deadLetterRepo := postgres.NewDeadLetterRepository(dbPool)
+	eventLogRepo := postgres.NewEventLogRepository(dbPool)
+	webhookSubRepo := postgres.NewWebhookSubscriptionRepository(dbPool)
+	webhookDeliveryRepo := postgres.NewWebhookDeliveryRepository(dbPool)
+	providerStatsRepo := postgres.NewProviderStatsRepository(dbPool)
+	interventionRepo := postgres.NewInterventionRepository(dbPool)
+	txManager := database.NewTxManager(dbPool)
+
+	// Every envelope published by the backend is appended to event_log first
+	publisher := eventlogUC.NewRecordingPublisher(q, eventLogRepo)
+
+	// Live provider locations are matched in a Redis GEO set when Redis is up
+	var providerRepo profileDomain.Repository = profileRepo
+	if redisClient != nil {
+		providerRepo = profileUC.NewLiveLocations(profileRepo, cache.NewLocationIndex(redisClient, cfg.Dispatch.LocationTTL))
+	}
+
+	// --- Use Cases ---
+	catUC := catalogUC.New(catalogRepo)
+	idUC := identityUC.New(identityRepo)
+	profUC := profileUC.New(providerRepo)
+	reqUC := requestUC.New(requestRepo, txManager, outboxRepo, cfg.Scheduler)
+	ranking, err := dispatchUC.NewRanking(cfg.Dispatch)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Invalid dispatch ranking config")
+		return
+	}
+	dispUC := dispatchUC.New(dispatchRepo, providerRepo, requestRepo, txManager, outboxRepo, cfg.Dispatch, ranking)
+	dlUC := deadletterUC.New(deadLetterRepo, txManager, outboxRepo)
+	statsUC := providerstatsUC.New(providerStatsRepo, txManager)
+	whUC := webhookUC.New(webhookSubRepo, webhookDeliveryRepo, requestRepo, webhookInfra.NewSender(cfg.Webhook.Timeout), queue.RetryPolicy{
+		MaxAttempts:    cfg.Webhook.MaxAttempts,
+		InitialBackoff: cfg.Webhook.InitialBackoff,
+		MaxBackoff:     cfg.Webhook.MaxBackoff,
+		Multiplier:     2,
+		Jitter:         0.2,
+	})
+
+	// --- Workers ---
+	notifier := push.NewLogNotifier()
+	idempotent, err := newIdempotencyMiddleware(ctx, cfg.Idempotency, dbPool, redisClient, txManager)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Failed to set up idempotent consumers")
+		return
+	}
+	dw := dispatchWorker.NewWorker(q, dispUC, notifier)
+	if err := dw.Register(idempotent(dispatchWorker.Group)); err != nil {
+		logger.Fatal().Err(err).Msg("Failed to register dispatch worker")
+		return
+	}
+	logger.Info().Msg("Dispatch worker registered")
+
+	dlw := deadletterWorker.NewWorker(q, deadLetterRepo)
+	if err := dlw.Register(); err != nil {
+		logger.Fatal().Err(err).Msg("Failed to register dead-letter worker")
+		return
+	}
+	logger.Info().Msg("Dead-letter worker registered")
+
+	ww := webhookWorker.NewWorker(q, whUC, cfg.Webhook.PollInterval, cfg.Webhook.BatchSize)
+	if err := ww.Register(idempotent(webhookWorker.Group)); err != nil {
+		logger.Fatal().Err(err).Msg("Failed to register webhook worker")
+		return
+	}
+	logger.Info().Msg("Webhook worker registered")
+
+	psw := providerstatsWorker.NewWorker(q, statsUC)
+	if err := psw.Register(idempotent(providerstatsWorker.Group)); err != nil {
+		logger.Fatal().Err(err).Msg("Failed to register provider stats worker")
+		return
+	}
+	logger.Info().Msg("Provider stats worker registered")
+
+	lr := liveWorker.NewRelay(q, fanout)
+	if err := lr.Register(idempotent(liveWorker.Group)); err != nil {
+		logger.Fatal().Err(err).Msg("Failed to register live relay")
+		return
+	}
+	logger.Info().Msg("Live relay registered")
+
+	// Start queue AFTER all subscriptions are registered. Consumers get their
+	// own context so shutdown can let in-flight messages finish.
+	consumerCtx, abortConsumers := context.WithCancel(context.Background())
+	defer abortConsumers()
+	if err := q.Start(consumerCtx); err != nil {
+		logger.Fatal().Err(err).Msg("Failed to start queue")
+		return
+	}
+
+	// Outbox relay publishes events committed by the use cases
+	relay := outboxWorker.NewRelay(txManager, outboxRepo, publisher, cfg.Outbox)
+	relayCtx, stopRelay := context.WithCancel(ctx)
+	relayDone := make(chan struct{})
+	go func() {
+		defer close(relayDone)
+		relay.Run(relayCtx)
+	}()
+	logger.Info().Dur("poll_interval", cfg.Outbox.PollInterval).Msg("Outbox relay started")
+
+	// Webhook deliveries are sent until background jobs are stopped
+	go ww.Run(ctx)
+
+	// Stale offers are expired and next dispatch waves sent in the background
+	sweeper := dispatchWorker.NewSweeper(dispUC, notifier, cfg.Dispatch.SweepInterval)
+	go sweeper.Run(ctx)
+	logger.Info().Dur("sweep_interval", cfg.Dispatch.SweepInterval).Msg("Dispatch sweeper started")
+
+	// Scheduled requests are opened for dispatch when their time comes
+	scheduler := schedulerWorker.NewScheduler(reqUC, cfg.Scheduler.PollInterval, cfg.Scheduler.BatchSize)
+	go scheduler.Run(ctx)
+	logger.Info().Dur("poll_interval", cfg.Scheduler.PollInterval).Msg("Request scheduler started")
+
+	// Relayed events are pushed to the streams connected to this replica
+	go liveWorker.NewFeed(fanout, hub).Run(ctx)
+	logger.Info().Msg("Live feed started")
+
+	// Handlers
+	queueStats, _ := q.(queue.StatsReporter)
+	handlers := router.Handlers{
+		Health:        handler.NewHealthHandler(queueStats),
+		Identity:      handler.NewIdentityHandler(idUC),
+		Profile:       handler.NewProfileHandler(profUC),
+		Catalog:       handler.NewCatalogHandler(catUC),
+		Request:       handler.NewRequestHandler(reqUC, requestUC.NewUpdates(requestRepo, eventLogRepo), hub),
+		Dispatch:      handler.NewDispatchHandler(dispUC, hub),
+		Intervention:  handler.NewInterventionHandler(dispatchUC.NewInterventions(dispUC, interventionRepo)),
+		DeadLetter:    handler.NewDeadLetterHandler(dlUC),
+		Webhook:       handler.NewWebhookHandler(whUC),
+		ProviderStats: handler.NewProviderStatsHandler(statsUC),
+	}
+
+	// Router
+	r := gin.New()
+	rlCfg := middleware.RateLimiterConfig{
+		RPS:   cfg.Rate.RPS,
+		Burst: cfg.Rate.Burst,
+	}
+	router.Setup(r, clerkAuth, rlCfg, handlers)
+
+	// HTTP Server with graceful shutdown
+	srv := &http.Server{
+		Addr:         ":" + cfg.App.Port,
+		Handler:      r,
+		ReadTimeout:  15 * time.Second,
+		WriteTimeout: 15 * time.Second,
+		IdleTimeout:  60 * time.Second,
+	}
+
+	go func() {
+		logger.Info().Str("port", cfg.App.Port).Msg("HTTP server started")
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.Fatal().Err(err).Msg("Server failed")
+		}
+	}()
+
+	// Graceful shutdown: stop taking requests, stop publishing, drain the
+	// consumers, then stop background jobs. Postgres and Redis are closed by
+	// the deferred calls once main returns.
+	sigCtx, stopSignals := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stopSignals()
+	<-sigCtx.Done()
+
+	logger.Info().Dur("timeout", cfg.App.ShutdownTimeout).Msg("Shutting down server...")
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), cfg.App.ShutdownTimeout)
+	defer shutdownCancel()
+
+	shutdown := lifecycle.NewShutdown()
+	// Open streams would keep the HTTP server from shutting down
+	shutdown.Add("live streams", func(context.Context) error {
+		hub.Close()
+		return nil
+	})
+	shutdown.Add("http server", srv.Shutdown)
+	shutdown.Add("outbox relay", func(ctx context.Context) error {
+		stopRelay()
+		select {
+		case <-relayDone:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	shutdown.Add("queue consumers", func(ctx context.Context) error {
+		return queue.Drain(ctx, q, abortConsumers)
+	})
+	shutdown.Add("background jobs", func(context.Context) error {
+		cancel()
+		return nil
+	})
+
+	if err := shutdown.Run(shutdownCtx); err != nil {
+		logger.Error().Err(err).Msg("Server shut down with errors")
+		return
+	}
+	logger.Info().Msg("Server exited gracefully")
+}
+
+// newIdempotencyMiddleware returns a factory for per-group idempotency
+// middleware backed by the store selected in IDEMPOTENCY_STORE.
+func newIdempotencyMiddleware(
+	ctx context.Context,
+	cfg config.IdempotencyConfig,
+	dbPool *pgxpool.Pool,
+	redisClient *cache.RedisClient,
+	tx database.Transactor,
+) (func(group string) queue.Middleware, error) {
+	switch cfg.Store {
+	case "", "postgres":
+		store := postgres.NewProcessedEventRepository(dbPool)
+		go workerMiddleware.RunPurger(ctx, store, cfg.TTL, time.Hour)
+		return func(group string) queue.Middleware {
+			return workerMiddleware.Idempotent(store, group, tx)
+		}, nil
+	case "redis":
+		if redisClient == nil {
+			return nil, fmt.Errorf("idempotency store %q requires redis", cfg.Store)
+		}
+		store := cache.NewProcessedEventStore(redisClient, cfg.TTL)
+		return func(group string) queue.Middleware {
+			return workerMiddleware.Idempotent(store, group, nil)
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown idempotency store %q", cfg.Store)
+	}
+}
@@ -0,0 +1,29 @@
+package deadletter
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// DeadLetter is a message whose handler failed on every retry attempt.
+type DeadLetter struct {
+	ID            string          `json:"id"`
+	Topic         string          `json:"topic"` // Original topic the message was consumed from
+	MessageID     string          `json:"message_id,omitempty"`
+	EventID       string          `json:"event_id,omitempty"`
+	CorrelationID string          `json:"correlation_id,omitempty"`
+	Envelope      json.RawMessage `json:"envelope"`
+	Error         string          `json:"error"`
+	Attempts      int             `json:"attempts"`
+	FailedAt      time.Time       `json:"failed_at"`
+	RequeuedAt    *time.Time      `json:"requeued_at,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+}
+
+// ListFilter narrows dead-letter listings.
+type ListFilter struct {
+	Topic           string
+	IncludeRequeued bool
+	Limit           int
+	Offset          int
+}
@@ -0,0 +1,17 @@
+package deadletter
+
+import (
+	"context"
+	"time"
+)
+
+type Repository interface {
+	// Create stores dl unless its event already has a dead letter that was
+	// not requeued, and reports whether it did.
+	Create(ctx context.Context, dl *DeadLetter) (bool, error)
+	GetByID(ctx context.Context, id string) (*DeadLetter, error)
+	List(ctx context.Context, filter ListFilter) ([]*DeadLetter, error)
+	// MarkRequeued sets RequeuedAt unless the dead letter was requeued
+	// already, and reports whether it did.
+	MarkRequeued(ctx context.Context, id string, at time.Time) (bool, error)
+}
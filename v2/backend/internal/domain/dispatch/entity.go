@@ -5,11 +5,13 @@ import "time"
 type DispatchStatus string
 
 const (
-	DispatchPending  DispatchStatus = "pending"
-	DispatchSent     DispatchStatus = "sent"
-	DispatchAccepted DispatchStatus = "accepted"
-	DispatchRejected DispatchStatus = "rejected"
-	DispatchExpired  DispatchStatus = "expired"
+	DispatchPending   DispatchStatus = "pending"
+	DispatchSent      DispatchStatus = "sent"
+	DispatchAccepted  DispatchStatus = "accepted"
+	DispatchRejected  DispatchStatus = "rejected"
+	DispatchExpired   DispatchStatus = "expired"
+	DispatchRevoked   DispatchStatus = "revoked"   // Accepted, then taken back by an admin
+	DispatchWithdrawn DispatchStatus = "withdrawn" // Closed unanswered when the request was taken
 )
 
 // Dispatch represents a match attempt between a request and a provider.
@@ -19,11 +21,65 @@ type Dispatch struct {
 	ProviderID string         `json:"provider_id"`
 	Status     DispatchStatus `json:"status"`
 	Distance   float64        `json:"distance_km"`
+	Wave       int            `json:"wave"`
 	ExpiresAt  time.Time      `json:"expires_at"`
 	CreatedAt  time.Time      `json:"created_at"`
 	UpdatedAt  time.Time      `json:"updated_at"`
 }
 
+// IsOpen reports whether the offer still awaits the provider's answer.
+// Offers created by the dispatch worker are "sent"; older rows may be "pending".
+func (d *Dispatch) IsOpen() bool {
+	return d.Status == DispatchPending || d.Status == DispatchSent
+}
+
+// IsExpired reports whether the offer's deadline has passed at now, even if
+// the sweeper has not marked it expired yet.
+func (d *Dispatch) IsExpired(now time.Time) bool {
+	return !d.ExpiresAt.IsZero() && !now.Before(d.ExpiresAt)
+}
+
+// WaveState tracks how far dispatching a service request has progressed.
+// Wave is the last wave sent (0 before the first); ExhaustedAt is set once no
+// untried provider is left within the maximum radius.
+type WaveState struct {
+	RequestID   string     `json:"request_id"`
+	Wave        int        `json:"wave"`
+	RadiusKm    float64    `json:"radius_km"`
+	ExhaustedAt *time.Time `json:"exhausted_at,omitempty"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// ProviderSignals summarize how a provider has handled past offers and how
+// busy it is right now. Dispatch ranking reads them.
+type ProviderSignals struct {
+	Answered             int        `json:"answered"`               // Offers accepted, rejected or left to expire
+	Accepted             int        `json:"accepted"`               // Offers accepted
+	MedianResponseMs     int64      `json:"median_response_ms"`     // 0 without any answer yet
+	JobsAccepted         int        `json:"jobs_accepted"`          // Requests assigned to the provider
+	CancelledAfterAccept int        `json:"cancelled_after_accept"` // Of those, cancelled afterwards
+	OpenOffers           int        `json:"open_offers"`            // Offers awaiting an answer
+	ActiveJobs           int        `json:"active_jobs"`            // Requests in progress, or accepted and due
+	NextJobAt            *time.Time `json:"next_job_at,omitempty"`  // Earliest accepted request not due yet
+}
+
+// AcceptanceRate returns Accepted/Answered, and false without any answer yet.
+func (s ProviderSignals) AcceptanceRate() (float64, bool) {
+	if s.Answered == 0 {
+		return 0, false
+	}
+	return float64(s.Accepted) / float64(s.Answered), true
+}
+
+// CancellationRate returns CancelledAfterAccept/JobsAccepted, and false
+// without any job yet.
+func (s ProviderSignals) CancellationRate() (float64, bool) {
+	if s.JobsAccepted == 0 {
+		return 0, false
+	}
+	return float64(s.CancelledAfterAccept) / float64(s.JobsAccepted), true
+}
+
 // MatchCriteria are used to find suitable providers.
 type MatchCriteria struct {
 	Latitude  float64  `json:"latitude"`
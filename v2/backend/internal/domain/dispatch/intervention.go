@@ -0,0 +1,25 @@
+package dispatch
+
+import "time"
+
+// InterventionAction is what an admin did to the dispatch of a request.
+type InterventionAction string
+
+const (
+	InterventionAssign     InterventionAction = "assign"     // Handed to ProviderID, bypassing offers
+	InterventionUnassign   InterventionAction = "unassign"   // Taken from PreviousProviderID and reopened
+	InterventionRedispatch InterventionAction = "redispatch" // Dispatch restarted for an open request
+)
+
+// Intervention records an admin acting on the dispatch of a request, and why.
+// PreviousProviderID is the provider the request was taken from, if any.
+type Intervention struct {
+	ID                 string             `json:"id"`
+	RequestID          string             `json:"request_id"`
+	Action             InterventionAction `json:"action"`
+	AdminID            string             `json:"admin_id"`
+	ProviderID         string             `json:"provider_id,omitempty"`
+	PreviousProviderID string             `json:"previous_provider_id,omitempty"`
+	Reason             string             `json:"reason"`
+	CreatedAt          time.Time          `json:"created_at"`
+}
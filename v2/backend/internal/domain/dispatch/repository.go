@@ -5,8 +5,42 @@ import "context"
 type Repository interface {
 	Create(ctx context.Context, d *Dispatch) error
 	GetByID(ctx context.Context, id string) (*Dispatch, error)
+	// GetByIDForUpdate is GetByID that also locks the offer until the
+	// caller's transaction ends. Use it inside WithinTx.
+	GetByIDForUpdate(ctx context.Context, id string) (*Dispatch, error)
 	Update(ctx context.Context, d *Dispatch) error
 	GetByRequestID(ctx context.Context, requestID string) ([]*Dispatch, error)
+	// LockByRequestID is GetByRequestID that also locks the offers until the
+	// caller's transaction ends.
+	LockByRequestID(ctx context.Context, requestID string) ([]*Dispatch, error)
 	GetPendingByProvider(ctx context.Context, providerID string) ([]*Dispatch, error)
-	ExpireOld(ctx context.Context) (int64, error)
+	// ExpireOld marks open offers past ExpiresAt as expired and returns them.
+	ExpireOld(ctx context.Context) ([]*Dispatch, error)
+
+	// LockProvider holds a per-provider lock until the caller's transaction
+	// ends, so checks of the provider's jobs cannot race each other.
+	LockProvider(ctx context.Context, providerID string) error
+
+	// ProviderSignals returns ranking signals for the given providers, from
+	// their provider stats and their current offers and jobs.
+	ProviderSignals(ctx context.Context, providerIDs []string) (map[string]ProviderSignals, error)
+
+	// LockWave returns the wave state of a request, creating it if needed,
+	// and locks it until the caller's transaction ends.
+	LockWave(ctx context.Context, requestID string) (*WaveState, error)
+	SaveWave(ctx context.Context, w *WaveState) error
+	// ListStalled returns open requests that are not exhausted and have no
+	// open or accepted offer left, i.e. that are waiting for their next wave.
+	ListStalled(ctx context.Context, limit int) ([]string, error)
+	// ListStalledFor is ListStalled narrowed to requests providerID could
+	// take: in one of their categories, within both maxRadiusKm and their
+	// service area, and never offered to them.
+	ListStalledFor(ctx context.Context, providerID string, maxRadiusKm float64, limit int) ([]string, error)
+}
+
+// InterventionRepository stores the audit trail of admin interventions.
+type InterventionRepository interface {
+	Create(ctx context.Context, i *Intervention) error
+	// ListByRequest returns the interventions on a request, oldest first.
+	ListByRequest(ctx context.Context, requestID string) ([]*Intervention, error)
 }
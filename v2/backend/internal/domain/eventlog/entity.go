@@ -0,0 +1,32 @@
+package eventlog
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Entry is one published envelope, kept for auditing and replay.
+type Entry struct {
+	Seq           int64           `json:"seq"` // Insertion order
+	EventID       string          `json:"event_id"`
+	Topic         string          `json:"topic"`
+	CorrelationID string          `json:"correlation_id"`
+	AggregateID   string          `json:"aggregate_id"` // Service request ID
+	SchemaVersion int             `json:"schema_version"`
+	Envelope      json.RawMessage `json:"envelope"`
+	OccurredAt    time.Time       `json:"occurred_at"` // Envelope timestamp
+	RecordedAt    time.Time       `json:"recorded_at"`
+}
+
+// Filter selects log entries. Zero values match everything.
+type Filter struct {
+	Topics        []string
+	EventID       string
+	CorrelationID string
+	AggregateID   string
+	AggregateIDs  []string // Any of them
+	From          time.Time
+	To            time.Time
+	AfterSeq      int64
+	Limit         int
+}
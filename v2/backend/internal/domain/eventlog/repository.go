@@ -0,0 +1,10 @@
+package eventlog
+
+import "context"
+
+type Repository interface {
+	// Append stores the entry; entries whose EventID is already logged are ignored.
+	Append(ctx context.Context, e *Entry) error
+	// Query returns matching entries ordered by Seq ascending.
+	Query(ctx context.Context, f Filter) ([]*Entry, error)
+}
@@ -10,29 +10,61 @@ import (
 // Topic constants — single source of truth for event routing.
 // When migrating to Kafka, these become Kafka topic names.
 const (
-	TopicRequestCreated   = "request.created"
-	TopicRequestAccepted  = "request.accepted"
-	TopicRequestStarted   = "request.started"
-	TopicRequestCompleted = "request.completed"
-	TopicRequestCancelled = "request.cancelled"
-
-	TopicDispatchSent     = "dispatch.sent"
-	TopicDispatchAccepted = "dispatch.accepted"
-	TopicDispatchRejected = "dispatch.rejected"
-	TopicDispatchExpired  = "dispatch.expired"
+	TopicRequestCreated      = "request.created"
+	TopicRequestRescheduled  = "request.rescheduled"
+	TopicRequestOpened       = "request.opened"
+	TopicRequestAccepted     = "request.accepted"
+	TopicRequestStarted      = "request.started"
+	TopicRequestCompleted    = "request.completed"
+	TopicRequestCancelled    = "request.cancelled"
+	TopicRequestUnassigned   = "request.unassigned"
+	TopicRequestRedispatched = "request.redispatched"
+
+	TopicDispatchSent      = "dispatch.sent"
+	TopicDispatchAccepted  = "dispatch.accepted"
+	TopicDispatchRejected  = "dispatch.rejected"
+	TopicDispatchExpired   = "dispatch.expired"
+	TopicDispatchExhausted = "dispatch.exhausted"
+	TopicDispatchWithdrawn = "dispatch.withdrawn"
 )
 
+// Topics lists every declared topic.
+var Topics = []string{
+	TopicRequestCreated,
+	TopicRequestRescheduled,
+	TopicRequestOpened,
+	TopicRequestAccepted,
+	TopicRequestStarted,
+	TopicRequestCompleted,
+	TopicRequestCancelled,
+	TopicRequestUnassigned,
+	TopicRequestRedispatched,
+	TopicDispatchSent,
+	TopicDispatchAccepted,
+	TopicDispatchRejected,
+	TopicDispatchExpired,
+	TopicDispatchExhausted,
+	TopicDispatchWithdrawn,
+}
+
 // Envelope wraps every event with metadata for tracing and Kafka compatibility.
+// CorrelationID is the ID of the HTTP request (or job) that caused the event
+// and is carried over to every event it triggers downstream. AggregateID is the
+// service request the event is about. SchemaVersion identifies the payload
+// shape; envelopes written before versioning was introduced decode as version 1.
 type Envelope struct {
 	EventID       string          `json:"event_id"`
 	CorrelationID string          `json:"correlation_id"`
+	AggregateID   string          `json:"aggregate_id"`
 	Topic         string          `json:"topic"`
+	SchemaVersion int             `json:"schema_version"`
 	Timestamp     time.Time       `json:"timestamp"`
 	Payload       json.RawMessage `json:"payload"`
 }
 
-// NewEnvelope creates a traceable event envelope.
-func NewEnvelope(topic string, correlationID string, payload any) (*Envelope, error) {
+// NewEnvelope creates a traceable event envelope stamped with the topic's
+// latest schema version. An empty correlationID starts a new trace.
+func NewEnvelope(topic, aggregateID, correlationID string, payload any) (*Envelope, error) {
 	data, err := json.Marshal(payload)
 	if err != nil {
 		return nil, err
@@ -43,7 +75,9 @@ func NewEnvelope(topic string, correlationID string, payload any) (*Envelope, er
 	return &Envelope{
 		EventID:       uuid.New().String(),
 		CorrelationID: correlationID,
+		AggregateID:   aggregateID,
 		Topic:         topic,
+		SchemaVersion: DefaultRegistry.LatestVersion(topic),
 		Timestamp:     time.Now(),
 		Payload:       data,
 	}, nil
@@ -60,24 +94,188 @@ func UnmarshalEnvelope(data []byte) (*Envelope, error) {
 	if err := json.Unmarshal(data, &env); err != nil {
 		return nil, err
 	}
+	if env.SchemaVersion == 0 {
+		env.SchemaVersion = 1
+	}
+	// Older envelopes used the service request ID as their correlation ID.
+	if env.AggregateID == "" {
+		env.AggregateID = env.CorrelationID
+	}
 	return &env, nil
 }
 
 // --- Typed Event Payloads ---
 
 // RequestCreatedEvent is published when a customer creates a new request.
+// DispatchAt is set when the request is scheduled: dispatch waits for
+// request.opened.
 type RequestCreatedEvent struct {
-	RequestID   string  `json:"request_id"`
-	CustomerID  string  `json:"customer_id"`
-	Category    string  `json:"category"`
-	Description string  `json:"description"`
-	Latitude    float64 `json:"latitude"`
-	Longitude   float64 `json:"longitude"`
+	RequestID   string     `json:"request_id"`
+	CustomerID  string     `json:"customer_id"`
+	Category    string     `json:"category"`
+	Description string     `json:"description"`
+	Latitude    float64    `json:"latitude"`
+	Longitude   float64    `json:"longitude"`
+	ScheduledAt time.Time  `json:"scheduled_at"`
+	DispatchAt  *time.Time `json:"dispatch_at,omitempty"`
+}
+
+// RequestRescheduledEvent is published when the customer moves a scheduled
+// request to another time.
+type RequestRescheduledEvent struct {
+	RequestID           string    `json:"request_id"`
+	CustomerID          string    `json:"customer_id"`
+	PreviousScheduledAt time.Time `json:"previous_scheduled_at"`
+	ScheduledAt         time.Time `json:"scheduled_at"`
+	DispatchAt          time.Time `json:"dispatch_at"`
+}
+
+// RequestOpenedEvent is published when a scheduled request reaches its
+// dispatch time and opens for dispatch.
+type RequestOpenedEvent struct {
+	RequestID   string    `json:"request_id"`
+	CustomerID  string    `json:"customer_id"`
+	Category    string    `json:"category"`
+	ScheduledAt time.Time `json:"scheduled_at"`
+	OpenedAt    time.Time `json:"opened_at"`
+}
+
+// RequestAcceptedEvent is published when a provider takes a request, or an
+// admin assigns it to one: then AssignedBy and Reason are set.
+type RequestAcceptedEvent struct {
+	RequestID  string    `json:"request_id"`
+	CustomerID string    `json:"customer_id"`
+	ProviderID string    `json:"provider_id"`
+	Category   string    `json:"category"`
+	AcceptedAt time.Time `json:"accepted_at"`
+	AssignedBy string    `json:"assigned_by,omitempty"`
+	Reason     string    `json:"reason,omitempty"`
+}
+
+// RequestStartedEvent is published when the provider starts the job.
+type RequestStartedEvent struct {
+	RequestID  string    `json:"request_id"`
+	CustomerID string    `json:"customer_id"`
+	ProviderID string    `json:"provider_id"`
+	StartedAt  time.Time `json:"started_at"`
+}
+
+// RequestCompletedEvent is published when the provider finishes the job.
+type RequestCompletedEvent struct {
+	RequestID   string    `json:"request_id"`
+	CustomerID  string    `json:"customer_id"`
+	ProviderID  string    `json:"provider_id"`
+	TotalPrice  int64     `json:"total_price"`
+	CompletedAt time.Time `json:"completed_at"`
+}
+
+// RequestCancelledEvent is published when the customer cancels a request.
+// ProviderID is empty unless the request had already been accepted.
+type RequestCancelledEvent struct {
+	RequestID      string    `json:"request_id"`
+	CustomerID     string    `json:"customer_id"`
+	ProviderID     string    `json:"provider_id,omitempty"`
+	PreviousStatus string    `json:"previous_status"`
+	CancelledAt    time.Time `json:"cancelled_at"`
 }
 
-// DispatchSentEvent is published when dispatches are sent to providers.
+// RequestUnassignedEvent is published when an admin takes an accepted
+// request from its provider. Unless AssignedTo names the provider it was
+// reassigned to, the request is open again and dispatch resumes.
+type RequestUnassignedEvent struct {
+	RequestID    string    `json:"request_id"`
+	CustomerID   string    `json:"customer_id"`
+	ProviderID   string    `json:"provider_id"`
+	AssignedTo   string    `json:"assigned_to,omitempty"`
+	UnassignedBy string    `json:"unassigned_by"`
+	Reason       string    `json:"reason"`
+	UnassignedAt time.Time `json:"unassigned_at"`
+}
+
+// RequestRedispatchedEvent is published when an admin restarts dispatch for
+// an open request; the next wave follows.
+type RequestRedispatchedEvent struct {
+	RequestID      string    `json:"request_id"`
+	CustomerID     string    `json:"customer_id"`
+	RedispatchedBy string    `json:"redispatched_by"`
+	Reason         string    `json:"reason"`
+	RedispatchedAt time.Time `json:"redispatched_at"`
+}
+
+// DispatchSentEvent is published when a wave of offers is sent to providers.
+// Wave and RadiusKm are absent from events sent before waves were introduced,
+// Offers from events sent before the provider offer inbox, and CustomerID
+// from events sent before the customer request stream.
 type DispatchSentEvent struct {
-	RequestID   string   `json:"request_id"`
-	ProviderIDs []string `json:"provider_ids"`
-	Count       int      `json:"count"`
+	RequestID   string      `json:"request_id"`
+	CustomerID  string      `json:"customer_id,omitempty"`
+	ProviderIDs []string    `json:"provider_ids"`
+	Count       int         `json:"count"`
+	Wave        int         `json:"wave,omitempty"`
+	RadiusKm    float64     `json:"radius_km,omitempty"`
+	Offers      []SentOffer `json:"offers,omitempty"`
+}
+
+// SentOffer is one offer of a dispatch.sent wave.
+type SentOffer struct {
+	DispatchID string    `json:"dispatch_id"`
+	ProviderID string    `json:"provider_id"`
+	DistanceKm float64   `json:"distance_km"`
+	OfferedAt  time.Time `json:"offered_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// DispatchAcceptedEvent is published when a provider accepts an offer.
+// TimeToRespondMs is measured from OfferedAt to RespondedAt.
+type DispatchAcceptedEvent struct {
+	DispatchID      string    `json:"dispatch_id"`
+	RequestID       string    `json:"request_id"`
+	ProviderID      string    `json:"provider_id"`
+	DistanceKm      float64   `json:"distance_km"`
+	OfferedAt       time.Time `json:"offered_at"`
+	RespondedAt     time.Time `json:"responded_at"`
+	TimeToRespondMs int64     `json:"time_to_respond_ms"`
+}
+
+// DispatchRejectedEvent is published when a provider declines an offer.
+type DispatchRejectedEvent struct {
+	DispatchID      string    `json:"dispatch_id"`
+	RequestID       string    `json:"request_id"`
+	ProviderID      string    `json:"provider_id"`
+	DistanceKm      float64   `json:"distance_km"`
+	OfferedAt       time.Time `json:"offered_at"`
+	RespondedAt     time.Time `json:"responded_at"`
+	TimeToRespondMs int64     `json:"time_to_respond_ms"`
+}
+
+// DispatchExpiredEvent is published when an offer times out unanswered.
+type DispatchExpiredEvent struct {
+	DispatchID string    `json:"dispatch_id"`
+	RequestID  string    `json:"request_id"`
+	ProviderID string    `json:"provider_id"`
+	DistanceKm float64   `json:"distance_km"`
+	OfferedAt  time.Time `json:"offered_at"`
+	ExpiredAt  time.Time `json:"expired_at"`
+}
+
+// DispatchExhaustedEvent is published when every wave went unanswered and no
+// untried provider is left within the maximum radius. The request stays open.
+type DispatchExhaustedEvent struct {
+	RequestID      string    `json:"request_id"`
+	CustomerID     string    `json:"customer_id"`
+	Waves          int       `json:"waves"`
+	ProvidersTried int       `json:"providers_tried"`
+	RadiusKm       float64   `json:"radius_km"`
+	ExhaustedAt    time.Time `json:"exhausted_at"`
+}
+
+// DispatchWithdrawnEvent is published when a request is accepted or
+// cancelled while providers still hold open offers for it; those offers are
+// closed. TakenBy is empty when the request was cancelled.
+type DispatchWithdrawnEvent struct {
+	RequestID   string    `json:"request_id"`
+	TakenBy     string    `json:"taken_by"`
+	DispatchIDs []string  `json:"dispatch_ids"`
+	ProviderIDs []string  `json:"provider_ids"`
+	WithdrawnAt time.Time `json:"withdrawn_at"`
 }
@@ -0,0 +1,128 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// Upcaster converts a payload from one schema version to the next.
+type Upcaster func(payload json.RawMessage) (json.RawMessage, error)
+
+// Registry maps (topic, schema version) to the Go type of the payload and
+// holds the upcasters that move old payloads to the latest version.
+type Registry struct {
+	mu        sync.RWMutex
+	types     map[string]map[int]reflect.Type
+	latest    map[string]int
+	upcasters map[string]map[int]Upcaster // keyed by the version they upgrade from
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		types:     make(map[string]map[int]reflect.Type),
+		latest:    make(map[string]int),
+		upcasters: make(map[string]map[int]Upcaster),
+	}
+}
+
+// Register declares prototype's type as the payload of topic at version.
+// The highest registered version becomes the one new envelopes are written with.
+func (r *Registry) Register(topic string, version int, prototype any) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.types[topic] == nil {
+		r.types[topic] = make(map[int]reflect.Type)
+	}
+	r.types[topic][version] = reflect.TypeOf(prototype)
+	if version > r.latest[topic] {
+		r.latest[topic] = version
+	}
+}
+
+// RegisterUpcaster adds the conversion from version `from` to `from+1`.
+func (r *Registry) RegisterUpcaster(topic string, from int, fn Upcaster) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.upcasters[topic] == nil {
+		r.upcasters[topic] = make(map[int]Upcaster)
+	}
+	r.upcasters[topic][from] = fn
+}
+
+// LatestVersion returns the current schema version for topic, or 1 when the
+// topic is unknown.
+func (r *Registry) LatestVersion(topic string) int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if v, ok := r.latest[topic]; ok {
+		return v
+	}
+	return 1
+}
+
+// PayloadType returns the Go type registered for (topic, version).
+func (r *Registry) PayloadType(topic string, version int) (reflect.Type, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	t, ok := r.types[topic][version]
+	return t, ok
+}
+
+// Upcast rewrites env in place so its payload matches the latest schema.
+// Envelopes from a newer producer are left untouched; additive changes are
+// still readable by older consumers.
+func (r *Registry) Upcast(env *Envelope) error {
+	latest := r.LatestVersion(env.Topic)
+	version := env.SchemaVersion
+	if version == 0 {
+		version = 1
+	}
+	for version < latest {
+		r.mu.RLock()
+		fn, ok := r.upcasters[env.Topic][version]
+		r.mu.RUnlock()
+		if !ok {
+			return fmt.Errorf("no upcaster for %s v%d", env.Topic, version)
+		}
+		payload, err := fn(env.Payload)
+		if err != nil {
+			return fmt.Errorf("upcast %s v%d: %w", env.Topic, version, err)
+		}
+		env.Payload = payload
+		version++
+	}
+	env.SchemaVersion = version
+	return nil
+}
+
+// Decode upcasts env and unmarshals its payload into a new value of the
+// latest registered type, returned as a pointer.
+func (r *Registry) Decode(env *Envelope) (any, error) {
+	if err := r.Upcast(env); err != nil {
+		return nil, err
+	}
+	typ, ok := r.PayloadType(env.Topic, r.LatestVersion(env.Topic))
+	if !ok {
+		return nil, fmt.Errorf("no payload registered for %s", env.Topic)
+	}
+	v := reflect.New(typ)
+	if err := json.Unmarshal(env.Payload, v.Interface()); err != nil {
+		return nil, err
+	}
+	return v.Interface(), nil
+}
+
+// DefaultRegistry holds the schemas of every topic in this package.
+var DefaultRegistry = NewRegistry()
+
+// DecodePayload upcasts env with DefaultRegistry and unmarshals the payload into T.
+func DecodePayload[T any](env *Envelope) (T, error) {
+	var out T
+	if err := DefaultRegistry.Upcast(env); err != nil {
+		return out, err
+	}
+	err := json.Unmarshal(env.Payload, &out)
+	return out, err
+}
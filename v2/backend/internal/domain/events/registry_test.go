@@ -0,0 +1,84 @@
+package events
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEveryTopicHasRegisteredPayload(t *testing.T) {
+	for _, topic := range Topics {
+		t.Run(topic, func(t *testing.T) {
+			latest := DefaultRegistry.LatestVersion(topic)
+			for v := 1; v <= latest; v++ {
+				_, ok := DefaultRegistry.PayloadType(topic, v)
+				assert.Truef(t, ok, "%s v%d has no registered payload type", topic, v)
+			}
+			for v := 1; v < latest; v++ {
+				env := &Envelope{Topic: topic, SchemaVersion: v, Payload: json.RawMessage(`{}`)}
+				assert.NoErrorf(t, DefaultRegistry.Upcast(env), "%s v%d cannot be upcast", topic, v)
+			}
+		})
+	}
+}
+
+func TestNewEnvelopeUsesLatestVersion(t *testing.T) {
+	env, err := NewEnvelope(TopicRequestAccepted, "req-1", "", RequestAcceptedEvent{RequestID: "req-1"})
+	require.NoError(t, err)
+	assert.Equal(t, DefaultRegistry.LatestVersion(TopicRequestAccepted), env.SchemaVersion)
+}
+
+func TestDecodePayload_UpcastsLegacySnapshot(t *testing.T) {
+	acceptedAt := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	legacy := map[string]any{
+		"id":          "req-1",
+		"customer_id": "cust-1",
+		"provider_id": "prov-1",
+		"category":    "mecanica",
+		"status":      "accepted",
+		"accepted_at": acceptedAt,
+	}
+	payload, err := json.Marshal(legacy)
+	require.NoError(t, err)
+
+	// Envelopes produced before versioning carry no schema_version field.
+	raw, err := json.Marshal(map[string]any{
+		"event_id":       "evt-1",
+		"correlation_id": "req-1",
+		"topic":          TopicRequestAccepted,
+		"timestamp":      acceptedAt,
+		"payload":        json.RawMessage(payload),
+	})
+	require.NoError(t, err)
+
+	env, err := UnmarshalEnvelope(raw)
+	require.NoError(t, err)
+	assert.Equal(t, 1, env.SchemaVersion)
+
+	evt, err := DecodePayload[RequestAcceptedEvent](env)
+	require.NoError(t, err)
+	assert.Equal(t, RequestAcceptedEvent{
+		RequestID:  "req-1",
+		CustomerID: "cust-1",
+		ProviderID: "prov-1",
+		Category:   "mecanica",
+		AcceptedAt: acceptedAt,
+	}, evt)
+	assert.Equal(t, DefaultRegistry.LatestVersion(TopicRequestAccepted), env.SchemaVersion)
+
+	decoded, err := DefaultRegistry.Decode(env)
+	require.NoError(t, err)
+	assert.IsType(t, &RequestAcceptedEvent{}, decoded)
+}
+
+func TestUpcast_MissingUpcasterFails(t *testing.T) {
+	r := NewRegistry()
+	r.Register("demo.topic", 1, struct{}{})
+	r.Register("demo.topic", 2, struct{}{})
+
+	err := r.Upcast(&Envelope{Topic: "demo.topic", SchemaVersion: 1, Payload: json.RawMessage(`{}`)})
+	assert.Error(t, err)
+}
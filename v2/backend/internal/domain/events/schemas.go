@@ -0,0 +1,113 @@
+package events
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// RequestSnapshotV1 is the v1 payload of request.accepted/started/completed/
+// cancelled: a full copy of the service request as it was serialized at the
+// time. It is frozen here so old envelopes can still be read after the
+// request entity changes.
+type RequestSnapshotV1 struct {
+	ID          string     `json:"id"`
+	CustomerID  string     `json:"customer_id"`
+	ProviderID  string     `json:"provider_id,omitempty"`
+	ServiceID   string     `json:"service_id"`
+	Category    string     `json:"category"`
+	Status      string     `json:"status"`
+	Description string     `json:"description"`
+	TotalPrice  int64      `json:"total_price"`
+	ScheduledAt time.Time  `json:"scheduled_at"`
+	Latitude    float64    `json:"latitude"`
+	Longitude   float64    `json:"longitude"`
+	AcceptedAt  *time.Time `json:"accepted_at,omitempty"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	CancelledAt *time.Time `json:"cancelled_at,omitempty"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+func init() {
+	r := DefaultRegistry
+
+	r.Register(TopicRequestCreated, 1, RequestCreatedEvent{})
+	r.Register(TopicRequestRescheduled, 1, RequestRescheduledEvent{})
+	r.Register(TopicRequestOpened, 1, RequestOpenedEvent{})
+
+	r.Register(TopicRequestAccepted, 1, RequestSnapshotV1{})
+	r.Register(TopicRequestAccepted, 2, RequestAcceptedEvent{})
+	r.RegisterUpcaster(TopicRequestAccepted, 1, upcastSnapshot(func(s RequestSnapshotV1) any {
+		return RequestAcceptedEvent{
+			RequestID:  s.ID,
+			CustomerID: s.CustomerID,
+			ProviderID: s.ProviderID,
+			Category:   s.Category,
+			AcceptedAt: timeOr(s.AcceptedAt, s.UpdatedAt),
+		}
+	}))
+
+	r.Register(TopicRequestStarted, 1, RequestSnapshotV1{})
+	r.Register(TopicRequestStarted, 2, RequestStartedEvent{})
+	r.RegisterUpcaster(TopicRequestStarted, 1, upcastSnapshot(func(s RequestSnapshotV1) any {
+		return RequestStartedEvent{
+			RequestID:  s.ID,
+			CustomerID: s.CustomerID,
+			ProviderID: s.ProviderID,
+			StartedAt:  timeOr(s.StartedAt, s.UpdatedAt),
+		}
+	}))
+
+	r.Register(TopicRequestCompleted, 1, RequestSnapshotV1{})
+	r.Register(TopicRequestCompleted, 2, RequestCompletedEvent{})
+	r.RegisterUpcaster(TopicRequestCompleted, 1, upcastSnapshot(func(s RequestSnapshotV1) any {
+		return RequestCompletedEvent{
+			RequestID:   s.ID,
+			CustomerID:  s.CustomerID,
+			ProviderID:  s.ProviderID,
+			TotalPrice:  s.TotalPrice,
+			CompletedAt: timeOr(s.CompletedAt, s.UpdatedAt),
+		}
+	}))
+
+	r.Register(TopicRequestCancelled, 1, RequestSnapshotV1{})
+	r.Register(TopicRequestCancelled, 2, RequestCancelledEvent{})
+	r.RegisterUpcaster(TopicRequestCancelled, 1, upcastSnapshot(func(s RequestSnapshotV1) any {
+		// v1 snapshots were taken after the status change, so the previous
+		// status is only known to have been non-terminal.
+		return RequestCancelledEvent{
+			RequestID:   s.ID,
+			CustomerID:  s.CustomerID,
+			ProviderID:  s.ProviderID,
+			CancelledAt: timeOr(s.CancelledAt, s.UpdatedAt),
+		}
+	}))
+
+	r.Register(TopicRequestUnassigned, 1, RequestUnassignedEvent{})
+	r.Register(TopicRequestRedispatched, 1, RequestRedispatchedEvent{})
+
+	r.Register(TopicDispatchSent, 1, DispatchSentEvent{})
+	r.Register(TopicDispatchAccepted, 1, DispatchAcceptedEvent{})
+	r.Register(TopicDispatchRejected, 1, DispatchRejectedEvent{})
+	r.Register(TopicDispatchExpired, 1, DispatchExpiredEvent{})
+	r.Register(TopicDispatchExhausted, 1, DispatchExhaustedEvent{})
+	r.Register(TopicDispatchWithdrawn, 1, DispatchWithdrawnEvent{})
+}
+
+// upcastSnapshot builds an Upcaster from a v1 request snapshot to a typed payload.
+func upcastSnapshot(convert func(RequestSnapshotV1) any) Upcaster {
+	return func(payload json.RawMessage) (json.RawMessage, error) {
+		var s RequestSnapshotV1
+		if err := json.Unmarshal(payload, &s); err != nil {
+			return nil, err
+		}
+		return json.Marshal(convert(s))
+	}
+}
+
+func timeOr(t *time.Time, fallback time.Time) time.Time {
+	if t != nil {
+		return *t
+	}
+	return fallback
+}
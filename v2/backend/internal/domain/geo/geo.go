@@ -0,0 +1,23 @@
+// Package geo holds the distance math shared by dispatch matching and the
+// repositories' SQL filters.
+package geo
+
+import "math"
+
+// EarthRadiusKm is the mean Earth radius used by Distance.
+const EarthRadiusKm = 6371
+
+// Distance returns the great-circle distance in km between two points given in
+// decimal degrees (Haversine formula).
+func Distance(lat1, lng1, lat2, lng2 float64) float64 {
+	dLat := radians(lat2 - lat1)
+	dLng := radians(lng2 - lng1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(radians(lat1))*math.Cos(radians(lat2))*
+			math.Sin(dLng/2)*math.Sin(dLng/2)
+	return EarthRadiusKm * 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
+
+func radians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
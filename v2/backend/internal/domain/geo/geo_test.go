@@ -0,0 +1,14 @@
+package geo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDistance(t *testing.T) {
+	// São Paulo (Praça da Sé) to Rio de Janeiro (Cinelândia)
+	assert.InDelta(t, 360.4, Distance(-23.5503, -46.6339, -22.9110, -43.1759), 1)
+	assert.InDelta(t, 111.2, Distance(0, 0, 1, 0), 0.1, "one degree of latitude")
+	assert.Zero(t, Distance(-23.5, -46.6, -23.5, -46.6))
+}
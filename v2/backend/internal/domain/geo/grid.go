@@ -0,0 +1,114 @@
+package geo
+
+import "math"
+
+// Box is a latitude/longitude rectangle in decimal degrees. MinLng is
+// greater than MaxLng when the box crosses the antimeridian.
+type Box struct {
+	MinLat, MaxLat float64
+	MinLng, MaxLng float64
+}
+
+// boxMargin widens bounding boxes so rounding never leaves out a point that
+// is exactly at the radius.
+const boxMargin = 1e-6
+
+// BoundingBox returns the smallest box holding every point within radiusKm
+// of (lat, lng). Near a pole it spans every longitude.
+func BoundingBox(lat, lng, radiusKm float64) Box {
+	r := radiusKm / EarthRadiusKm // Angular radius
+	dLat := r*180/math.Pi + boxMargin
+	box := Box{MinLat: lat - dLat, MaxLat: lat + dLat, MinLng: -180, MaxLng: 180}
+	if box.MinLat <= -90 || box.MaxLat >= 90 {
+		box.MinLat, box.MaxLat = math.Max(box.MinLat, -90), math.Min(box.MaxLat, 90)
+		return box
+	}
+
+	dLng := math.Asin(math.Min(1, math.Sin(r)/math.Cos(radians(lat))))*180/math.Pi + boxMargin
+	if dLng >= 180 {
+		return box
+	}
+	box.MinLng, box.MaxLng = wrapLng(lng-dLng), wrapLng(lng+dLng)
+	return box
+}
+
+// Wraps reports whether b crosses the antimeridian.
+func (b Box) Wraps() bool { return b.MinLng > b.MaxLng }
+
+// Contains reports whether (lat, lng) lies in b.
+func (b Box) Contains(lat, lng float64) bool {
+	if lat < b.MinLat || lat > b.MaxLat {
+		return false
+	}
+	if b.Wraps() {
+		return lng >= b.MinLng || lng <= b.MaxLng
+	}
+	return lng >= b.MinLng && lng <= b.MaxLng
+}
+
+func wrapLng(lng float64) float64 {
+	switch {
+	case lng < -180:
+		return lng + 360
+	case lng > 180:
+		return lng - 360
+	}
+	return lng
+}
+
+// The grid splits the globe into CellSize-degree cells numbered
+// row*cellRowStride + column, counting rows from the south pole and columns
+// from the antimeridian. Cell must stay in step with the geo_cell columns of
+// migration 000015.
+const (
+	CellSize      = 0.1
+	cellsPerDeg   = 10
+	cellRowStride = 10000
+	lastRow       = 180 * cellsPerDeg
+	lastColumn    = 360 * cellsPerDeg
+)
+
+// MaxCells caps how many cells Cells enumerates. Larger searches filter on
+// the bounding box alone.
+const MaxCells = 2000
+
+// Cell returns the grid cell holding (lat, lng).
+func Cell(lat, lng float64) int {
+	return cellRow(lat)*cellRowStride + cellColumn(lng)
+}
+
+func cellRow(lat float64) int    { return int(math.Floor((lat + 90) * cellsPerDeg)) }
+func cellColumn(lng float64) int { return int(math.Floor((lng + 180) * cellsPerDeg)) }
+
+// Cells returns the grid cells overlapping b, or nil when there are more
+// than MaxCells. Every cell next to the box is included too, so a point in
+// b is in one of them however its cell was rounded.
+func (b Box) Cells() []int {
+	r0, r1 := max(cellRow(b.MinLat)-1, 0), min(cellRow(b.MaxLat)+1, lastRow)
+	type span struct{ from, to int }
+	var cols []span
+	if b.Wraps() {
+		cols = []span{{max(cellColumn(b.MinLng)-1, 0), lastColumn}, {0, min(cellColumn(b.MaxLng)+1, lastColumn)}}
+	} else {
+		cols = []span{{max(cellColumn(b.MinLng)-1, 0), min(cellColumn(b.MaxLng)+1, lastColumn)}}
+	}
+
+	n := 0
+	for _, c := range cols {
+		n += c.to - c.from + 1
+	}
+	n *= r1 - r0 + 1
+	if n > MaxCells {
+		return nil
+	}
+
+	cells := make([]int, 0, n)
+	for row := r0; row <= r1; row++ {
+		for _, c := range cols {
+			for col := c.from; col <= c.to; col++ {
+				cells = append(cells, row*cellRowStride+col)
+			}
+		}
+	}
+	return cells
+}
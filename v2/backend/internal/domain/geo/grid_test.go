@@ -0,0 +1,77 @@
+package geo
+
+import (
+	"math"
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// destination returns the point distanceKm away from (lat, lng) heading
+// bearing degrees.
+func destination(lat, lng, bearing, distanceKm float64) (float64, float64) {
+	d := distanceKm / EarthRadiusKm
+	φ1, λ1, θ := radians(lat), radians(lng), radians(bearing)
+	φ2 := math.Asin(math.Sin(φ1)*math.Cos(d) + math.Cos(φ1)*math.Sin(d)*math.Cos(θ))
+	λ2 := λ1 + math.Atan2(math.Sin(θ)*math.Sin(d)*math.Cos(φ1), math.Cos(d)-math.Sin(φ1)*math.Sin(φ2))
+	return φ2 * 180 / math.Pi, wrapLng(λ2 * 180 / math.Pi)
+}
+
+func TestBoundingBox_HoldsEveryPointInRadius(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	searches := []struct {
+		name          string
+		lat, lng, km  float64
+		wraps, allLng bool
+		noCells       bool // Filtered on the box alone
+	}{
+		{name: "São Paulo", lat: -23.5503, lng: -46.6339, km: 25},
+		{name: "antimeridian", lat: -17.7, lng: 179.95, km: 30, wraps: true},
+		{name: "near the pole", lat: 89.9, lng: 10, km: 50, allLng: true, noCells: true},
+		{name: "equator", lat: 0, lng: 0, km: 100},
+	}
+	for _, s := range searches {
+		t.Run(s.name, func(t *testing.T) {
+			box := BoundingBox(s.lat, s.lng, s.km)
+			assert.Equal(t, s.wraps, box.Wraps())
+			assert.Equal(t, s.allLng, box.MinLng == -180 && box.MaxLng == 180)
+			cells := box.Cells()
+			require.Equal(t, s.noCells, cells == nil)
+
+			for range 2000 {
+				lat, lng := destination(s.lat, s.lng, rng.Float64()*360, s.km*math.Sqrt(rng.Float64()))
+				require.True(t, box.Contains(lat, lng), "(%f, %f)", lat, lng)
+				require.True(t, cells == nil || slices.Contains(cells, Cell(lat, lng)), "(%f, %f)", lat, lng)
+			}
+			// Exactly at the radius, due north and east
+			lat, lng := destination(s.lat, s.lng, 0, s.km)
+			assert.True(t, box.Contains(lat, lng))
+			lat, lng = destination(s.lat, s.lng, 90, s.km)
+			assert.True(t, box.Contains(lat, lng))
+		})
+	}
+}
+
+func TestBoundingBox_IsTight(t *testing.T) {
+	box := BoundingBox(0, 0, 111.195)
+	assert.InDelta(t, -1, box.MinLat, 1e-3)
+	assert.InDelta(t, 1, box.MaxLat, 1e-3)
+	assert.InDelta(t, 1, box.MaxLng, 1e-3)
+	assert.False(t, box.Contains(1.01, 0))
+}
+
+func TestCell(t *testing.T) {
+	assert.Equal(t, 900*cellRowStride+1800, Cell(0, 0))
+	assert.Equal(t, 664*cellRowStride+1333, Cell(-23.5503, -46.6339))
+	assert.Equal(t, 0, Cell(-90, -180))
+	assert.Nil(t, BoundingBox(0, 0, 2000).Cells(), "too many cells to enumerate")
+}
+
+func BenchmarkBoundingBoxCells(b *testing.B) {
+	for range b.N {
+		BoundingBox(-23.5503, -46.6339, 50).Cells()
+	}
+}
@@ -0,0 +1,38 @@
+package outbox
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pitgo/backend/internal/domain/events"
+)
+
+// Message is a domain event waiting to be published. It is written in the
+// same transaction as the state change that produced it.
+type Message struct {
+	ID            string     `json:"id"`
+	EventID       string     `json:"event_id"`
+	Topic         string     `json:"topic"`
+	CorrelationID string     `json:"correlation_id"`
+	Payload       []byte     `json:"payload"` // Serialized events.Envelope
+	Attempts      int        `json:"attempts"`
+	LastError     string     `json:"last_error,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	SentAt        *time.Time `json:"sent_at,omitempty"`
+}
+
+// NewMessage serializes an envelope into an outbox message.
+func NewMessage(env *events.Envelope) (*Message, error) {
+	data, err := env.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	return &Message{
+		ID:            uuid.New().String(),
+		EventID:       env.EventID,
+		Topic:         env.Topic,
+		CorrelationID: env.CorrelationID,
+		Payload:       data,
+		CreatedAt:     time.Now(),
+	}, nil
+}
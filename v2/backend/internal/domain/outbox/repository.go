@@ -0,0 +1,23 @@
+package outbox
+
+import (
+	"context"
+	"time"
+)
+
+type Repository interface {
+	Add(ctx context.Context, msg *Message) error
+	// Requeue adds msg, or marks the message already stored under its
+	// EventID unsent again, so an event can be published once more without
+	// changing its EventID.
+	Requeue(ctx context.Context, msg *Message) error
+
+	// FetchPending locks up to limit unsent messages, oldest first, for the
+	// duration of the caller's transaction. Rows locked by another relay are skipped.
+	FetchPending(ctx context.Context, limit int) ([]*Message, error)
+	MarkSent(ctx context.Context, id string, sentAt time.Time) error
+	MarkFailed(ctx context.Context, id string, reason string) error
+
+	// PurgeSent deletes messages published before the given time.
+	PurgeSent(ctx context.Context, before time.Time) (int64, error)
+}
@@ -35,6 +35,15 @@ type ProviderDetails struct {
 	TotalJobs    int      `json:"total_jobs"`
 	IsVerified   bool     `json:"is_verified"`
 	IsOnline     bool     `json:"is_online"`
+	LocatedAt    *time.Time `json:"located_at,omitempty"` // Set when Latitude/Longitude come from a live location
+}
+
+// Location is the position a provider's app last reported.
+type Location struct {
+	ProfileID  string    `json:"profile_id"`
+	Latitude   float64   `json:"latitude"`
+	Longitude  float64   `json:"longitude"`
+	ReportedAt time.Time `json:"reported_at"`
 }
 
 // Address represents a customer address.
@@ -1,6 +1,9 @@
 package profile
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 type Repository interface {
 	Create(ctx context.Context, profile *Profile) error
@@ -13,10 +16,29 @@ type Repository interface {
 	CreateProviderDetails(ctx context.Context, details *ProviderDetails) error
 	GetProviderDetails(ctx context.Context, profileID string) (*ProviderDetails, error)
 	UpdateProviderDetails(ctx context.Context, details *ProviderDetails) error
-	FindProvidersInRadius(ctx context.Context, lat, lng, radiusKm float64, category string) ([]*ProviderDetails, error)
+	// FindProvidersInRadius returns providers of the category for whom
+	// (lat, lng) is within both radiusKm and their own service area. A
+	// provider's live location is used instead of the profile's when it was
+	// reported at or after locatedSince.
+	FindProvidersInRadius(ctx context.Context, lat, lng, radiusKm float64, category string, locatedSince time.Time) ([]*ProviderDetails, error)
+	// FindProvidersAt is FindProvidersInRadius with the live locations taken
+	// from live, all of them fresh, instead of the stored ones.
+	FindProvidersAt(ctx context.Context, lat, lng, radiusKm float64, category string, live []*Location) ([]*ProviderDetails, error)
+	SaveLocation(ctx context.Context, loc *Location) error
 
 	CreateAddress(ctx context.Context, address *Address) error
 	GetAddresses(ctx context.Context, profileID string) ([]*Address, error)
 	UpdateAddress(ctx context.Context, address *Address) error
 	DeleteAddress(ctx context.Context, id string) error
 }
+
+// LocationIndex keeps the latest live location of providers for proximity
+// queries, e.g. in a Redis GEO set. Locations reported before since are
+// treated as absent.
+type LocationIndex interface {
+	Save(ctx context.Context, loc *Location) error
+	// Nearby returns the locations within radiusKm of (lat, lng), nearest first.
+	Nearby(ctx context.Context, lat, lng, radiusKm float64, since time.Time) ([]*Location, error)
+	// Get returns the locations of the given providers, keyed by profile ID.
+	Get(ctx context.Context, profileIDs []string, since time.Time) (map[string]*Location, error)
+}
@@ -0,0 +1,74 @@
+package providerstats
+
+import (
+	"slices"
+	"time"
+)
+
+// RecentResponses bounds the response times kept for the median.
+const RecentResponses = 100
+
+// Stats describe how a provider responds to dispatch offers and how often the
+// jobs it accepts are cancelled afterwards.
+type Stats struct {
+	ProviderID           string    `json:"provider_id"`
+	OffersAccepted       int       `json:"offers_accepted"`
+	OffersRejected       int       `json:"offers_rejected"`
+	OffersExpired        int       `json:"offers_expired"` // Left unanswered until the deadline
+	JobsAccepted         int       `json:"jobs_accepted"`  // Requests assigned, with or without an offer
+	CancelledAfterAccept int       `json:"cancelled_after_accept"`
+	RecentResponseMs     []int64   `json:"-"` // Last RecentResponses times-to-respond, oldest first
+	MedianResponseMs     int64     `json:"median_response_ms"`
+	UpdatedAt            time.Time `json:"updated_at"`
+}
+
+// Offers returns the number of offers that got an outcome.
+func (s *Stats) Offers() int {
+	return s.OffersAccepted + s.OffersRejected + s.OffersExpired
+}
+
+// AcceptanceRate is the share of offers accepted, 0 without offers.
+func (s *Stats) AcceptanceRate() float64 {
+	return ratio(s.OffersAccepted, s.Offers())
+}
+
+// ExpiryRate is the share of offers left to expire, 0 without offers.
+func (s *Stats) ExpiryRate() float64 {
+	return ratio(s.OffersExpired, s.Offers())
+}
+
+// CancellationRate is the share of accepted jobs cancelled afterwards.
+func (s *Stats) CancellationRate() float64 {
+	return ratio(s.CancelledAfterAccept, s.JobsAccepted)
+}
+
+// RecordResponse adds a time-to-respond and updates the median over the most
+// recent ones.
+func (s *Stats) RecordResponse(ms int64) {
+	s.RecentResponseMs = append(s.RecentResponseMs, ms)
+	if n := len(s.RecentResponseMs); n > RecentResponses {
+		s.RecentResponseMs = s.RecentResponseMs[n-RecentResponses:]
+	}
+
+	sorted := slices.Clone(s.RecentResponseMs)
+	slices.Sort(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 1 {
+		s.MedianResponseMs = sorted[mid]
+	} else {
+		s.MedianResponseMs = (sorted[mid-1] + sorted[mid]) / 2
+	}
+}
+
+func ratio(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) / float64(total)
+}
+
+// ListFilter pages through stats, most recently updated first.
+type ListFilter struct {
+	Limit  int
+	Offset int
+}
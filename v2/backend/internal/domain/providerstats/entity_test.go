@@ -0,0 +1,31 @@
+package providerstats
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordResponse_MedianOverRecentResponses(t *testing.T) {
+	s := &Stats{}
+	s.RecordResponse(3000)
+	assert.Equal(t, int64(3000), s.MedianResponseMs)
+	s.RecordResponse(1000)
+	assert.Equal(t, int64(2000), s.MedianResponseMs)
+	s.RecordResponse(90000)
+	assert.Equal(t, int64(3000), s.MedianResponseMs)
+
+	for i := 0; i < RecentResponses; i++ {
+		s.RecordResponse(500)
+	}
+	assert.Len(t, s.RecentResponseMs, RecentResponses)
+	assert.Equal(t, int64(500), s.MedianResponseMs, "old responses age out")
+}
+
+func TestRates(t *testing.T) {
+	s := &Stats{OffersAccepted: 3, OffersRejected: 5, OffersExpired: 2, JobsAccepted: 4, CancelledAfterAccept: 1}
+	assert.InDelta(t, 0.3, s.AcceptanceRate(), 1e-9)
+	assert.InDelta(t, 0.2, s.ExpiryRate(), 1e-9)
+	assert.InDelta(t, 0.25, s.CancellationRate(), 1e-9)
+	assert.Zero(t, (&Stats{}).AcceptanceRate())
+}
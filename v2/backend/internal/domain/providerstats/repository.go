@@ -0,0 +1,12 @@
+package providerstats
+
+import "context"
+
+type Repository interface {
+	// Lock returns the provider's stats, creating empty ones if needed, and
+	// locks them until the caller's transaction ends.
+	Lock(ctx context.Context, providerID string) (*Stats, error)
+	Save(ctx context.Context, s *Stats) error
+	GetByProviderID(ctx context.Context, providerID string) (*Stats, error)
+	List(ctx context.Context, filter ListFilter) ([]*Stats, error)
+}
@@ -5,6 +5,7 @@ import "time"
 type Status string
 
 const (
+	StatusScheduled  Status = "scheduled" // Booked ahead, not dispatched before DispatchAt
 	StatusOpen       Status = "open"
 	StatusAccepted   Status = "accepted"
 	StatusInProgress Status = "in_progress"
@@ -14,17 +15,18 @@ const (
 
 // ServiceRequest represents a customer's request for a service.
 type ServiceRequest struct {
-	ID          string    `json:"id"`
-	CustomerID  string    `json:"customer_id"`
-	ProviderID  string    `json:"provider_id,omitempty"`
-	ServiceID   string    `json:"service_id"`
-	Category    string    `json:"category"`
-	Status      Status    `json:"status"`
-	Description string    `json:"description"`
-	PhotoURL    string    `json:"photo_url,omitempty"`
-	TotalPrice  int64     `json:"total_price"`
-	Notes       string    `json:"notes,omitempty"`
-	ScheduledAt time.Time `json:"scheduled_at"`
+	ID          string     `json:"id"`
+	CustomerID  string     `json:"customer_id"`
+	ProviderID  string     `json:"provider_id,omitempty"`
+	ServiceID   string     `json:"service_id"`
+	Category    string     `json:"category"`
+	Status      Status     `json:"status"`
+	Description string     `json:"description"`
+	PhotoURL    string     `json:"photo_url,omitempty"`
+	TotalPrice  int64      `json:"total_price"`
+	Notes       string     `json:"notes,omitempty"`
+	ScheduledAt time.Time  `json:"scheduled_at"`
+	DispatchAt  *time.Time `json:"dispatch_at,omitempty"` // When a scheduled request opens for dispatch
 
 	// Location
 	AddressID string  `json:"address_id,omitempty"`
@@ -1,10 +1,16 @@
 package request
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 type Repository interface {
 	Create(ctx context.Context, req *ServiceRequest) error
 	GetByID(ctx context.Context, id string) (*ServiceRequest, error)
+	// GetByIDForUpdate is GetByID that also locks the request until the
+	// caller's transaction ends. Use it inside WithinTx.
+	GetByIDForUpdate(ctx context.Context, id string) (*ServiceRequest, error)
 	Update(ctx context.Context, req *ServiceRequest) error
 	ListByCustomer(ctx context.Context, customerID string, status Status, limit, offset int) ([]*ServiceRequest, error)
 	ListByProvider(ctx context.Context, providerID string, status Status, limit, offset int) ([]*ServiceRequest, error)
@@ -13,6 +19,11 @@ type Repository interface {
 	// optionally filtered by category, ordered by distance (Haversine).
 	ListAvailable(ctx context.Context, lat, lng, radiusKm float64, category string, limit, offset int) ([]*ServiceRequest, error)
 
+	// LockDue returns up to limit scheduled requests whose DispatchAt is at
+	// or before now, locked until the caller's transaction ends. Requests
+	// locked by another transaction are skipped. Use it inside WithinTx.
+	LockDue(ctx context.Context, now time.Time, limit int) ([]*ServiceRequest, error)
+
 	// Items
 	CreateItem(ctx context.Context, item *RequestItem) error
 	GetItems(ctx context.Context, requestID string) ([]*RequestItem, error)
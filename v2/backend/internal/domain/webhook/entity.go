@@ -0,0 +1,77 @@
+package webhook
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Subscription is a partner endpoint that receives signed event deliveries
+// about the requests of CustomerIDs, the partner's customer accounts.
+type Subscription struct {
+	ID          string    `json:"id"`
+	URL         string    `json:"url"`
+	Topics      []string  `json:"topics"`
+	CustomerIDs []string  `json:"customer_ids"`
+	Secret      string    `json:"-"` // Only returned once, when the subscription is created
+	Description string    `json:"description,omitempty"`
+	Active      bool      `json:"active"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Wants reports whether the subscription is active and listens to topic.
+func (s *Subscription) Wants(topic string) bool {
+	if !s.Active {
+		return false
+	}
+	for _, t := range s.Topics {
+		if t == topic {
+			return true
+		}
+	}
+	return false
+}
+
+// Covers reports whether the subscription is scoped to customerID's requests.
+func (s *Subscription) Covers(customerID string) bool {
+	for _, id := range s.CustomerIDs {
+		if id == customerID {
+			return true
+		}
+	}
+	return false
+}
+
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliverySucceeded DeliveryStatus = "succeeded"
+	DeliveryFailed    DeliveryStatus = "failed"
+)
+
+// Delivery is one event sent (or to be sent) to one subscription. It doubles
+// as the endpoint's delivery log: the last response is kept on the row.
+type Delivery struct {
+	ID             string          `json:"id"`
+	SubscriptionID string          `json:"subscription_id"`
+	EventID        string          `json:"event_id"`
+	Topic          string          `json:"topic"`
+	Payload        json.RawMessage `json:"payload"` // Envelope as sent to the partner
+	Status         DeliveryStatus  `json:"status"`
+	Attempts       int             `json:"attempts"`
+	ResponseStatus int             `json:"response_status,omitempty"`
+	LastError      string          `json:"last_error,omitempty"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+}
+
+// DeliveryFilter narrows delivery log listings.
+type DeliveryFilter struct {
+	SubscriptionID string
+	Status         DeliveryStatus
+	Limit          int
+	Offset         int
+}
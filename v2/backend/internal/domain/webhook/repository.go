@@ -0,0 +1,31 @@
+package webhook
+
+import (
+	"context"
+	"time"
+)
+
+type SubscriptionRepository interface {
+	Create(ctx context.Context, s *Subscription) error
+	GetByID(ctx context.Context, id string) (*Subscription, error)
+	List(ctx context.Context) ([]*Subscription, error)
+	// ListActiveByTopic returns active subscriptions listening to topic for
+	// the requests of customerID.
+	ListActiveByTopic(ctx context.Context, topic, customerID string) ([]*Subscription, error)
+	Update(ctx context.Context, s *Subscription) error
+	Delete(ctx context.Context, id string) error
+}
+
+type DeliveryRepository interface {
+	// Create stores the delivery; a second delivery of the same event to the
+	// same subscription is ignored.
+	Create(ctx context.Context, d *Delivery) error
+	GetByID(ctx context.Context, id string) (*Delivery, error)
+	List(ctx context.Context, filter DeliveryFilter) ([]*Delivery, error)
+
+	// ClaimDue picks up to limit pending deliveries whose next attempt is due
+	// and pushes their next attempt to now+lease, so other replicas leave them
+	// alone while they are being sent. A crashed sender's claim simply expires.
+	ClaimDue(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*Delivery, error)
+	Update(ctx context.Context, d *Delivery) error
+}
@@ -0,0 +1,135 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/pitgo/backend/internal/domain/profile"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	locationGeoKey  = "pitgo:locations"      // GEO set of the latest location per provider
+	locationSeenKey = "pitgo:locations:seen" // Sorted set of when each was reported, in Unix ms
+)
+
+// LocationIndex keeps live provider locations in a Redis GEO set. A GEO set
+// can't expire its members, so report times are kept next to it: locations
+// older than ttl are pruned on writes and reads, and both keys expire when
+// no provider has reported for ttl.
+type LocationIndex struct {
+	redis *RedisClient
+	ttl   time.Duration
+}
+
+func NewLocationIndex(r *RedisClient, ttl time.Duration) *LocationIndex {
+	if ttl <= 0 {
+		ttl = 2 * time.Minute
+	}
+	return &LocationIndex{redis: r, ttl: ttl}
+}
+
+// saveLocation moves a provider only if the report is not older than the one
+// stored, like the reported_at guard of the Postgres upsert, so reports that
+// arrive out of order never overwrite a newer location.
+//
+// KEYS: geo set, seen set. ARGV: profile ID, longitude, latitude, reported
+// at, prune cutoff (both Unix ms), ttl in ms.
+var saveLocation = redis.NewScript(`
+local prev = redis.call('ZSCORE', KEYS[2], ARGV[1])
+if prev and tonumber(prev) > tonumber(ARGV[4]) then
+	return 0
+end
+redis.call('GEOADD', KEYS[1], ARGV[2], ARGV[3], ARGV[1])
+redis.call('ZADD', KEYS[2], ARGV[4], ARGV[1])
+redis.call('ZREMRANGEBYSCORE', KEYS[2], '-inf', '(' .. ARGV[5])
+redis.call('PEXPIRE', KEYS[1], ARGV[6])
+redis.call('PEXPIRE', KEYS[2], ARGV[6])
+return 1
+`)
+
+func (i *LocationIndex) Save(ctx context.Context, loc *profile.Location) error {
+	cutoff := time.Now().Add(-i.ttl).UnixMilli()
+	return saveLocation.Run(ctx, i.redis.Client, []string{locationGeoKey, locationSeenKey},
+		loc.ProfileID, loc.Longitude, loc.Latitude, loc.ReportedAt.UnixMilli(), cutoff, i.ttl.Milliseconds()).Err()
+}
+
+func (i *LocationIndex) Nearby(ctx context.Context, lat, lng, radiusKm float64, since time.Time) ([]*profile.Location, error) {
+	found, err := i.redis.Client.GeoSearchLocation(ctx, locationGeoKey, &redis.GeoSearchLocationQuery{
+		GeoSearchQuery: redis.GeoSearchQuery{
+			Longitude:  lng,
+			Latitude:   lat,
+			Radius:     radiusKm,
+			RadiusUnit: "km",
+			Sort:       "ASC",
+		},
+		WithCoord: true,
+	}).Result()
+	if err != nil || len(found) == 0 {
+		return nil, err
+	}
+	ids := make([]string, len(found))
+	for n, f := range found {
+		ids[n] = f.Name
+	}
+	seen, err := i.seen(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	var locs []*profile.Location
+	var stale []any
+	for n, f := range found {
+		reportedAt := seen[n]
+		if reportedAt.Before(since) {
+			if time.Since(reportedAt) > i.ttl {
+				stale = append(stale, f.Name)
+			}
+			continue
+		}
+		locs = append(locs, &profile.Location{ProfileID: f.Name, Latitude: f.Latitude, Longitude: f.Longitude, ReportedAt: reportedAt})
+	}
+	if len(stale) > 0 {
+		// Best effort: stale members are skipped anyway
+		i.redis.Client.ZRem(ctx, locationGeoKey, stale...)
+	}
+	return locs, nil
+}
+
+func (i *LocationIndex) Get(ctx context.Context, profileIDs []string, since time.Time) (map[string]*profile.Location, error) {
+	if len(profileIDs) == 0 {
+		return nil, nil
+	}
+	positions, err := i.redis.Client.GeoPos(ctx, locationGeoKey, profileIDs...).Result()
+	if err != nil {
+		return nil, err
+	}
+	seen, err := i.seen(ctx, profileIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	locs := make(map[string]*profile.Location)
+	for n, id := range profileIDs {
+		if positions[n] == nil || seen[n].Before(since) {
+			continue
+		}
+		locs[id] = &profile.Location{ProfileID: id, Latitude: positions[n].Latitude, Longitude: positions[n].Longitude, ReportedAt: seen[n]}
+	}
+	return locs, nil
+}
+
+// seen returns when each provider last reported; the zero time if never.
+func (i *LocationIndex) seen(ctx context.Context, profileIDs []string) ([]time.Time, error) {
+	scores, err := i.redis.Client.ZMScore(ctx, locationSeenKey, profileIDs...).Result()
+	if err != nil {
+		return nil, err
+	}
+	times := make([]time.Time, len(scores))
+	for n, ms := range scores {
+		if ms > 0 {
+			times[n] = time.UnixMilli(int64(ms))
+		}
+	}
+	return times, nil
+}
@@ -0,0 +1,30 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+const processedKeyPrefix = "pitgo:processed:"
+
+// ProcessedEventStore keeps idempotency markers in Redis; markers expire after ttl.
+type ProcessedEventStore struct {
+	redis *RedisClient
+	ttl   time.Duration
+}
+
+func NewProcessedEventStore(r *RedisClient, ttl time.Duration) *ProcessedEventStore {
+	return &ProcessedEventStore{redis: r, ttl: ttl}
+}
+
+func processedKey(group, eventID string) string {
+	return processedKeyPrefix + group + ":" + eventID
+}
+
+func (s *ProcessedEventStore) MarkProcessed(ctx context.Context, group, eventID string) (bool, error) {
+	return s.redis.Client.SetNX(ctx, processedKey(group, eventID), time.Now().Unix(), s.ttl).Result()
+}
+
+func (s *ProcessedEventStore) Unmark(ctx context.Context, group, eventID string) error {
+	return s.redis.Delete(ctx, processedKey(group, eventID))
+}
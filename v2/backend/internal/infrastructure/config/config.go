@@ -1,21 +1,32 @@
 package config
 
 import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/spf13/viper"
 )
 
 type Config struct {
-	App      AppConfig
-	Database DatabaseConfig
-	Redis    RedisConfig
-	Auth     AuthConfig
-	Queue    QueueConfig
-	Rate     RateConfig
+	App         AppConfig
+	Database    DatabaseConfig
+	Redis       RedisConfig
+	Auth        AuthConfig
+	Queue       QueueConfig
+	Outbox      OutboxConfig
+	Idempotency IdempotencyConfig
+	Dispatch    DispatchConfig
+	Scheduler   SchedulerConfig
+	Webhook     WebhookConfig
+	Rate        RateConfig
 }
 
 type AppConfig struct {
-	Port string
-	Env  string
+	Port            string
+	Env             string
+	ShutdownTimeout time.Duration // Deadline for draining HTTP and queue consumers on SIGTERM
 }
 
 type DatabaseConfig struct {
@@ -40,7 +51,76 @@ type AuthConfig struct {
 }
 
 type QueueConfig struct {
-	Driver string
+	Driver string // "memory", "redis" or "postgres"
+
+	// Handler worker pool (all drivers)
+	Workers        int           // Handler goroutines; messages are partitioned by correlation ID
+	BufferSize     int           // Messages buffered per worker
+	PublishTimeout time.Duration // In-memory driver: how long Publish waits when the buffer is full
+
+	// Redis Streams driver; BatchSize, BlockTimeout and ClaimMinIdle also
+	// apply to the postgres driver (jobs claimed per poll, poll interval
+	// without notifications, lease of a claimed job)
+	Group        string        // Consumer group shared by all replicas
+	Consumer     string        // Consumer name within the group (defaults to hostname)
+	StreamMaxLen int64         // Approximate MAXLEN applied on XADD
+	BatchSize    int64         // Messages read per XREADGROUP call
+	BlockTimeout time.Duration // How long XREADGROUP blocks waiting for new entries
+	ClaimMinIdle time.Duration // Pending entries idle longer than this are reclaimed
+}
+
+type OutboxConfig struct {
+	PollInterval time.Duration // How often the relay looks for unsent events
+	BatchSize    int           // Events published per relay transaction
+	Retention    time.Duration // Sent events older than this are purged
+}
+
+type IdempotencyConfig struct {
+	Store string        // "postgres" (transactional) or "redis"
+	TTL   time.Duration // How long processed event IDs are remembered
+}
+
+type DispatchConfig struct {
+	OfferTTL        time.Duration // How long a provider has to answer an offer
+	WaveSize        int           // Providers offered the request per wave
+	InitialRadiusKm float64       // Search radius of the first wave
+	RadiusStepKm    float64       // Radius added for every following wave
+	MaxRadiusKm     float64       // Waves stop once this radius has no untried provider
+	SweepInterval   time.Duration // How often stale offers are expired and next waves sent
+	LocationTTL     time.Duration // Live provider locations older than this are ignored
+
+	Capacity         int            // Concurrent jobs a provider may hold; 0 means unlimited
+	CategoryCapacity map[string]int // Per-category overrides of Capacity
+	BusyWindow       time.Duration  // Providers with a job starting within this window are ranked last
+
+	Ranking         RankingConfig            // How candidates are ordered unless their category overrides it
+	CategoryRanking map[string]RankingConfig // Per-category overrides, keyed by category
+}
+
+// RankingConfig selects a provider ranking strategy ("nearest" or "weighted")
+// and, for "weighted", the weight of each scorer by name. It is written as
+// "weighted:distance=0.5,rating=0.3,acceptance=0.2" or just "nearest".
+type RankingConfig struct {
+	Strategy string
+	Weights  map[string]float64
+}
+
+// SchedulerConfig controls when scheduled requests open for dispatch: at
+// their scheduled time minus the lead time of their category.
+type SchedulerConfig struct {
+	LeadTime         time.Duration            // Default time before ScheduledAt that dispatch starts
+	CategoryLeadTime map[string]time.Duration // Per-category overrides of LeadTime
+	PollInterval     time.Duration            // How often due requests are looked for
+	BatchSize        int                      // Requests opened per poll
+}
+
+type WebhookConfig struct {
+	PollInterval   time.Duration // How often the delivery worker looks for due deliveries
+	BatchSize      int           // Deliveries claimed per poll
+	Timeout        time.Duration // HTTP timeout per delivery attempt
+	MaxAttempts    int           // Attempts before a delivery is marked failed
+	InitialBackoff time.Duration // Delay after the first failed attempt
+	MaxBackoff     time.Duration // Upper bound between attempts
 }
 
 type RateConfig struct {
@@ -63,6 +143,7 @@ func Load() (*Config, error) {
 	// Set defaults
 	viper.SetDefault("APP_PORT", "8080")
 	viper.SetDefault("APP_ENV", "development")
+	viper.SetDefault("APP_SHUTDOWN_TIMEOUT", "25s")
 	viper.SetDefault("DB_HOST", "localhost")
 	viper.SetDefault("DB_PORT", "5432")
 	viper.SetDefault("DB_USER", "pitgo")
@@ -73,15 +154,69 @@ func Load() (*Config, error) {
 	viper.SetDefault("REDIS_PASSWORD", "")
 	viper.SetDefault("REDIS_DB", 0)
 	viper.SetDefault("QUEUE_DRIVER", "memory")
+	viper.SetDefault("QUEUE_WORKERS", 8)
+	viper.SetDefault("QUEUE_BUFFER_SIZE", 128)
+	viper.SetDefault("QUEUE_PUBLISH_TIMEOUT", "2s")
+	viper.SetDefault("QUEUE_GROUP", "pitgo-backend")
+	viper.SetDefault("QUEUE_CONSUMER", "")
+	viper.SetDefault("QUEUE_STREAM_MAXLEN", 100000)
+	viper.SetDefault("QUEUE_BATCH_SIZE", 10)
+	viper.SetDefault("QUEUE_BLOCK_TIMEOUT", "5s")
+	viper.SetDefault("QUEUE_CLAIM_MIN_IDLE", "1m")
+	viper.SetDefault("OUTBOX_POLL_INTERVAL", "500ms")
+	viper.SetDefault("OUTBOX_BATCH_SIZE", 100)
+	viper.SetDefault("OUTBOX_RETENTION", "72h")
+	viper.SetDefault("IDEMPOTENCY_STORE", "postgres")
+	viper.SetDefault("IDEMPOTENCY_TTL", "168h")
+	viper.SetDefault("DISPATCH_OFFER_TTL", "5m")
+	viper.SetDefault("DISPATCH_WAVE_SIZE", 5)
+	viper.SetDefault("DISPATCH_INITIAL_RADIUS_KM", 10)
+	viper.SetDefault("DISPATCH_RADIUS_STEP_KM", 10)
+	viper.SetDefault("DISPATCH_MAX_RADIUS_KM", 50)
+	viper.SetDefault("DISPATCH_SWEEP_INTERVAL", "15s")
+	viper.SetDefault("DISPATCH_LOCATION_TTL", "2m")
+	viper.SetDefault("DISPATCH_CAPACITY", 1)
+	viper.SetDefault("DISPATCH_CAPACITY_CATEGORIES", "")
+	viper.SetDefault("DISPATCH_BUSY_WINDOW", "1h")
+	viper.SetDefault("DISPATCH_RANKING", "nearest")
+	viper.SetDefault("DISPATCH_RANKING_CATEGORIES", "")
+	viper.SetDefault("SCHEDULER_LEAD_TIME", "1h")
+	viper.SetDefault("SCHEDULER_LEAD_TIME_CATEGORIES", "")
+	viper.SetDefault("SCHEDULER_POLL_INTERVAL", "30s")
+	viper.SetDefault("SCHEDULER_BATCH_SIZE", 50)
+	viper.SetDefault("WEBHOOK_POLL_INTERVAL", "1s")
+	viper.SetDefault("WEBHOOK_BATCH_SIZE", 20)
+	viper.SetDefault("WEBHOOK_TIMEOUT", "10s")
+	viper.SetDefault("WEBHOOK_MAX_ATTEMPTS", 8)
+	viper.SetDefault("WEBHOOK_INITIAL_BACKOFF", "30s")
+	viper.SetDefault("WEBHOOK_MAX_BACKOFF", "6h")
 	viper.SetDefault("RATE_LIMIT_RPS", 10)
 	viper.SetDefault("RATE_LIMIT_BURST", 20)
 
 	_ = viper.ReadInConfig() // Ignore error if .env doesn't exist
 
+	ranking, err := ParseRanking(viper.GetString("DISPATCH_RANKING"))
+	if err != nil {
+		return nil, fmt.Errorf("DISPATCH_RANKING: %w", err)
+	}
+	categoryRanking, err := ParseCategoryRanking(viper.GetString("DISPATCH_RANKING_CATEGORIES"))
+	if err != nil {
+		return nil, fmt.Errorf("DISPATCH_RANKING_CATEGORIES: %w", err)
+	}
+	categoryCapacity, err := ParseCategoryCapacity(viper.GetString("DISPATCH_CAPACITY_CATEGORIES"))
+	if err != nil {
+		return nil, fmt.Errorf("DISPATCH_CAPACITY_CATEGORIES: %w", err)
+	}
+	categoryLeadTime, err := ParseCategoryDurations(viper.GetString("SCHEDULER_LEAD_TIME_CATEGORIES"))
+	if err != nil {
+		return nil, fmt.Errorf("SCHEDULER_LEAD_TIME_CATEGORIES: %w", err)
+	}
+
 	cfg := &Config{
 		App: AppConfig{
-			Port: viper.GetString("APP_PORT"),
-			Env:  viper.GetString("APP_ENV"),
+			Port:            viper.GetString("APP_PORT"),
+			Env:             viper.GetString("APP_ENV"),
+			ShutdownTimeout: viper.GetDuration("APP_SHUTDOWN_TIMEOUT"),
 		},
 		Database: DatabaseConfig{
 			URL:      viper.GetString("DATABASE_URL"),
@@ -102,7 +237,53 @@ func Load() (*Config, error) {
 			Issuer:  viper.GetString("CLERK_ISSUER"),
 		},
 		Queue: QueueConfig{
-			Driver: viper.GetString("QUEUE_DRIVER"),
+			Driver:         viper.GetString("QUEUE_DRIVER"),
+			Workers:        viper.GetInt("QUEUE_WORKERS"),
+			BufferSize:     viper.GetInt("QUEUE_BUFFER_SIZE"),
+			PublishTimeout: viper.GetDuration("QUEUE_PUBLISH_TIMEOUT"),
+			Group:          viper.GetString("QUEUE_GROUP"),
+			Consumer:       viper.GetString("QUEUE_CONSUMER"),
+			StreamMaxLen:   viper.GetInt64("QUEUE_STREAM_MAXLEN"),
+			BatchSize:      viper.GetInt64("QUEUE_BATCH_SIZE"),
+			BlockTimeout:   viper.GetDuration("QUEUE_BLOCK_TIMEOUT"),
+			ClaimMinIdle:   viper.GetDuration("QUEUE_CLAIM_MIN_IDLE"),
+		},
+		Outbox: OutboxConfig{
+			PollInterval: viper.GetDuration("OUTBOX_POLL_INTERVAL"),
+			BatchSize:    viper.GetInt("OUTBOX_BATCH_SIZE"),
+			Retention:    viper.GetDuration("OUTBOX_RETENTION"),
+		},
+		Idempotency: IdempotencyConfig{
+			Store: viper.GetString("IDEMPOTENCY_STORE"),
+			TTL:   viper.GetDuration("IDEMPOTENCY_TTL"),
+		},
+		Dispatch: DispatchConfig{
+			OfferTTL:         viper.GetDuration("DISPATCH_OFFER_TTL"),
+			WaveSize:         viper.GetInt("DISPATCH_WAVE_SIZE"),
+			InitialRadiusKm:  viper.GetFloat64("DISPATCH_INITIAL_RADIUS_KM"),
+			RadiusStepKm:     viper.GetFloat64("DISPATCH_RADIUS_STEP_KM"),
+			MaxRadiusKm:      viper.GetFloat64("DISPATCH_MAX_RADIUS_KM"),
+			SweepInterval:    viper.GetDuration("DISPATCH_SWEEP_INTERVAL"),
+			LocationTTL:      viper.GetDuration("DISPATCH_LOCATION_TTL"),
+			Capacity:         viper.GetInt("DISPATCH_CAPACITY"),
+			CategoryCapacity: categoryCapacity,
+			BusyWindow:       viper.GetDuration("DISPATCH_BUSY_WINDOW"),
+			Ranking:          ranking,
+			CategoryRanking:  categoryRanking,
+		},
+		Scheduler: SchedulerConfig{
+			LeadTime:         viper.GetDuration("SCHEDULER_LEAD_TIME"),
+			CategoryLeadTime: categoryLeadTime,
+			PollInterval:     viper.GetDuration("SCHEDULER_POLL_INTERVAL"),
+			BatchSize:        viper.GetInt("SCHEDULER_BATCH_SIZE"),
+		},
+		Webhook: WebhookConfig{
+			PollInterval:   viper.GetDuration("WEBHOOK_POLL_INTERVAL"),
+			BatchSize:      viper.GetInt("WEBHOOK_BATCH_SIZE"),
+			Timeout:        viper.GetDuration("WEBHOOK_TIMEOUT"),
+			MaxAttempts:    viper.GetInt("WEBHOOK_MAX_ATTEMPTS"),
+			InitialBackoff: viper.GetDuration("WEBHOOK_INITIAL_BACKOFF"),
+			MaxBackoff:     viper.GetDuration("WEBHOOK_MAX_BACKOFF"),
 		},
 		Rate: RateConfig{
 			RPS:   viper.GetFloat64("RATE_LIMIT_RPS"),
@@ -112,3 +293,89 @@ func Load() (*Config, error) {
 
 	return cfg, nil
 }
+
+// ParseRanking parses "strategy[:name=weight,...]", e.g. "nearest" or
+// "weighted:distance=0.6,rating=0.4". Scorer names are checked by the
+// dispatch use case.
+func ParseRanking(s string) (RankingConfig, error) {
+	strategy, weights, _ := strings.Cut(strings.TrimSpace(s), ":")
+	rc := RankingConfig{Strategy: strings.TrimSpace(strategy)}
+	if weights == "" {
+		return rc, nil
+	}
+	rc.Weights = make(map[string]float64)
+	for _, pair := range strings.Split(weights, ",") {
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return rc, fmt.Errorf("weight %q: want name=value", pair)
+		}
+		w, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || w < 0 {
+			return rc, fmt.Errorf("weight %q: want a non-negative number", pair)
+		}
+		rc.Weights[strings.TrimSpace(name)] = w
+	}
+	return rc, nil
+}
+
+// ParseCategoryRanking parses "category=ranking;..." where each ranking is in
+// the ParseRanking format, e.g. "plumbing=nearest;cleaning=weighted:rating=1".
+func ParseCategoryRanking(s string) (map[string]RankingConfig, error) {
+	out := make(map[string]RankingConfig)
+	for _, entry := range strings.Split(s, ";") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		category, ranking, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("entry %q: want category=ranking", entry)
+		}
+		rc, err := ParseRanking(ranking)
+		if err != nil {
+			return nil, fmt.Errorf("category %q: %w", strings.TrimSpace(category), err)
+		}
+		out[strings.TrimSpace(category)] = rc
+	}
+	return out, nil
+}
+
+// ParseCategoryCapacity parses "category=jobs;...", e.g. "cleaning=2;moving=1".
+func ParseCategoryCapacity(s string) (map[string]int, error) {
+	out := make(map[string]int)
+	for _, entry := range strings.Split(s, ";") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		category, jobs, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("entry %q: want category=jobs", entry)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(jobs))
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("category %q: invalid capacity %q", strings.TrimSpace(category), jobs)
+		}
+		out[strings.TrimSpace(category)] = n
+	}
+	return out, nil
+}
+
+// ParseCategoryDurations parses "category=duration;...", e.g.
+// "cleaning=24h;plumbing=30m".
+func ParseCategoryDurations(s string) (map[string]time.Duration, error) {
+	out := make(map[string]time.Duration)
+	for _, entry := range strings.Split(s, ";") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		category, value, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("entry %q: want category=duration", entry)
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("category %q: invalid duration %q", strings.TrimSpace(category), value)
+		}
+		out[strings.TrimSpace(category)] = d
+	}
+	return out, nil
+}
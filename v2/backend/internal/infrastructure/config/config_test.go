@@ -0,0 +1,60 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRanking(t *testing.T) {
+	rc, err := ParseRanking("nearest")
+	require.NoError(t, err)
+	assert.Equal(t, RankingConfig{Strategy: "nearest"}, rc)
+
+	rc, err = ParseRanking(" weighted:distance=0.6, rating = 0.4")
+	require.NoError(t, err)
+	assert.Equal(t, RankingConfig{Strategy: "weighted", Weights: map[string]float64{"distance": 0.6, "rating": 0.4}}, rc)
+
+	_, err = ParseRanking("weighted:distance")
+	assert.Error(t, err)
+	_, err = ParseRanking("weighted:distance=-1")
+	assert.Error(t, err)
+}
+
+func TestParseCategoryRanking(t *testing.T) {
+	m, err := ParseCategoryRanking("plumbing=nearest; cleaning=weighted:rating=1;")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]RankingConfig{
+		"plumbing": {Strategy: "nearest"},
+		"cleaning": {Strategy: "weighted", Weights: map[string]float64{"rating": 1}},
+	}, m)
+
+	m, err = ParseCategoryRanking("")
+	require.NoError(t, err)
+	assert.Empty(t, m)
+
+	_, err = ParseCategoryRanking("plumbing")
+	assert.Error(t, err)
+}
+
+func TestParseCategoryCapacity(t *testing.T) {
+	m, err := ParseCategoryCapacity("cleaning=2; moving = 0;")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"cleaning": 2, "moving": 0}, m)
+
+	_, err = ParseCategoryCapacity("cleaning=two")
+	assert.Error(t, err)
+	_, err = ParseCategoryCapacity("cleaning=-1")
+	assert.Error(t, err)
+}
+
+func TestParseCategoryDurations(t *testing.T) {
+	m, err := ParseCategoryDurations("cleaning=24h; plumbing = 30m;")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]time.Duration{"cleaning": 24 * time.Hour, "plumbing": 30 * time.Minute}, m)
+
+	_, err = ParseCategoryDurations("cleaning=tomorrow")
+	assert.Error(t, err)
+}
@@ -0,0 +1,80 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type (
+	txKey          struct{}
+	afterCommitKey struct{}
+)
+
+// Transactor runs fn inside a database transaction. Repositories called with
+// the ctx passed to fn take part in that transaction.
+type Transactor interface {
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// TxManager is the pgx implementation of Transactor.
+type TxManager struct {
+	pool *pgxpool.Pool
+}
+
+func NewTxManager(pool *pgxpool.Pool) *TxManager {
+	return &TxManager{pool: pool}
+}
+
+// WithinTx commits when fn returns nil and rolls back otherwise. Nested calls
+// join the transaction that is already in the context. Functions passed to
+// AfterCommit run once the outermost call commits.
+func (m *TxManager) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := TxFromContext(ctx); ok {
+		return fn(ctx)
+	}
+
+	tx, err := m.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	var hooks []func()
+	txCtx := context.WithValue(context.WithValue(ctx, txKey{}, tx), afterCommitKey{}, &hooks)
+	if err := fn(txCtx); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit transaction: %w", err)
+	}
+	for _, hook := range hooks {
+		hook()
+	}
+	return nil
+}
+
+// WithoutTx returns ctx without its transaction, so that WithinTx called with
+// it starts and commits a transaction of its own.
+func WithoutTx(ctx context.Context) context.Context {
+	return context.WithValue(context.WithValue(ctx, txKey{}, nil), afterCommitKey{}, nil)
+}
+
+// AfterCommit runs fn once the transaction in ctx has committed, or right
+// away when ctx holds none. fn is dropped if the transaction rolls back. Use
+// it for side effects that cannot be undone, such as push notifications.
+func AfterCommit(ctx context.Context, fn func()) {
+	if hooks, ok := ctx.Value(afterCommitKey{}).(*[]func()); ok {
+		*hooks = append(*hooks, fn)
+		return
+	}
+	fn()
+}
+
+// TxFromContext returns the transaction started by WithinTx, if any.
+func TxFromContext(ctx context.Context) (pgx.Tx, bool) {
+	tx, ok := ctx.Value(txKey{}).(pgx.Tx)
+	return tx, ok
+}
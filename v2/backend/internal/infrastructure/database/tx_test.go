@@ -0,0 +1,48 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAfterCommit_WithoutTxRunsRightAway(t *testing.T) {
+	ran := false
+	AfterCommit(context.Background(), func() { ran = true })
+	assert.True(t, ran)
+}
+
+func TestAfterCommit_RunsOnlyOnCommit(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	pool, err := pgxpool.New(context.Background(), dsn)
+	require.NoError(t, err)
+	t.Cleanup(pool.Close)
+	tx := NewTxManager(pool)
+
+	var ran []string
+	err = tx.WithinTx(context.Background(), func(ctx context.Context) error {
+		// Nested calls join the outer transaction and its hooks
+		return tx.WithinTx(ctx, func(ctx context.Context) error {
+			AfterCommit(ctx, func() { ran = append(ran, "committed") })
+			assert.Empty(t, ran, "hooks wait for the commit")
+			return nil
+		})
+	})
+	require.NoError(t, err)
+
+	errRollback := errors.New("rollback")
+	err = tx.WithinTx(context.Background(), func(ctx context.Context) error {
+		AfterCommit(ctx, func() { ran = append(ran, "rolled back") })
+		return errRollback
+	})
+	require.ErrorIs(t, err, errRollback)
+	assert.Equal(t, []string{"committed"}, ran)
+}
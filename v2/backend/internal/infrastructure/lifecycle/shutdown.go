@@ -0,0 +1,47 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/pitgo/backend/internal/infrastructure/logger"
+)
+
+type step struct {
+	name string
+	stop func(ctx context.Context) error
+}
+
+// Shutdown stops components in the order they were added, all under the
+// deadline of the context passed to Run. Add producers before the consumers
+// they feed so nothing new is accepted while work drains.
+type Shutdown struct {
+	steps []step
+}
+
+func NewShutdown() *Shutdown {
+	return &Shutdown{}
+}
+
+// Add appends a step. stop should return once the component has stopped or
+// ctx is done, whichever comes first.
+func (s *Shutdown) Add(name string, stop func(ctx context.Context) error) {
+	s.steps = append(s.steps, step{name: name, stop: stop})
+}
+
+// Run executes every step, even after one fails, and returns the joined errors.
+func (s *Shutdown) Run(ctx context.Context) error {
+	var errs []error
+	for _, st := range s.steps {
+		start := time.Now()
+		if err := st.stop(ctx); err != nil {
+			logger.Error().Err(err).Str("step", st.name).Dur("took", time.Since(start)).Msg("Shutdown step failed")
+			errs = append(errs, fmt.Errorf("%s: %w", st.name, err))
+			continue
+		}
+		logger.Info().Str("step", st.name).Dur("took", time.Since(start)).Msg("Shutdown step completed")
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,117 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/pitgo/backend/internal/infrastructure/queue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const topic = "request.created"
+
+// startDispatch subscribes a handler that simulates a dispatch taking d and
+// publishes one message; it returns once the handler is running.
+func startDispatch(t *testing.T, q *queue.InMemoryQueue, d time.Duration, completed *atomic.Bool, cancelled *atomic.Bool) {
+	t.Helper()
+	started := make(chan struct{})
+	require.NoError(t, q.Subscribe(topic, func(ctx context.Context, _ queue.Message) error {
+		close(started)
+		select {
+		case <-time.After(d):
+			completed.Store(true)
+			return nil
+		case <-ctx.Done():
+			cancelled.Store(true)
+			return nil
+		}
+	}, queue.WithRetry(queue.RetryPolicy{MaxAttempts: 1})))
+	require.NoError(t, q.Publish(context.Background(), topic, []byte(`{"correlation_id":"req-1"}`)))
+
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatal("dispatch handler did not start")
+	}
+}
+
+func TestShutdown_SignalDuringDispatchDrainsInFlight(t *testing.T) {
+	sigCtx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM)
+	defer stop()
+
+	q := queue.NewInMemoryQueue()
+	consumerCtx, abort := context.WithCancel(context.Background())
+	defer abort()
+	require.NoError(t, q.Start(consumerCtx))
+
+	var completed, cancelled atomic.Bool
+	startDispatch(t, q, 150*time.Millisecond, &completed, &cancelled)
+
+	require.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGTERM))
+	select {
+	case <-sigCtx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("signal not received")
+	}
+
+	var order []string
+	s := NewShutdown()
+	s.Add("producers", func(context.Context) error {
+		order = append(order, "producers")
+		return nil
+	})
+	s.Add("queue consumers", func(ctx context.Context) error {
+		order = append(order, "queue consumers")
+		return queue.Drain(ctx, q, abort)
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	require.NoError(t, s.Run(ctx))
+
+	assert.Equal(t, []string{"producers", "queue consumers"}, order)
+	assert.True(t, completed.Load(), "in-flight dispatch should finish")
+	assert.False(t, cancelled.Load())
+	assert.ErrorIs(t, q.Publish(context.Background(), topic, []byte(`{}`)), queue.ErrClosed)
+}
+
+func TestShutdown_DeadlineCancelsStuckDispatch(t *testing.T) {
+	sigCtx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM)
+	defer stop()
+
+	q := queue.NewInMemoryQueue()
+	consumerCtx, abort := context.WithCancel(context.Background())
+	defer abort()
+	require.NoError(t, q.Start(consumerCtx))
+
+	var completed, cancelled atomic.Bool
+	startDispatch(t, q, time.Minute, &completed, &cancelled)
+
+	require.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGTERM))
+	<-sigCtx.Done()
+
+	s := NewShutdown()
+	s.Add("queue consumers", func(ctx context.Context) error {
+		return queue.Drain(ctx, q, abort)
+	})
+	ran := false
+	s.Add("background jobs", func(context.Context) error {
+		ran = true
+		return nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err := s.Run(ctx)
+
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.True(t, ran, "later steps still run after a failed one")
+	assert.True(t, cancelled.Load(), "stuck dispatch should see its context cancelled")
+	assert.False(t, completed.Load())
+}
@@ -0,0 +1,115 @@
+// Package live hands events to the clients streaming them from this replica,
+// e.g. over Server-Sent Events. Events reach every replica through a
+// queue.Fanout; the hub only knows its own subscribers.
+package live
+
+import "sync"
+
+// DefaultBuffer is how many events a subscriber may fall behind by default.
+const DefaultBuffer = 32
+
+// Event is one message of a stream. Clients can resume after ID, when set.
+type Event struct {
+	ID   string
+	Type string
+	Data any
+}
+
+// ProviderKey is the key of a provider's stream.
+func ProviderKey(providerID string) string { return "provider:" + providerID }
+
+// CustomerKey is the key of a customer's stream.
+func CustomerKey(customerID string) string { return "customer:" + customerID }
+
+// Hub delivers events to the subscribers of a key. Publish never blocks: a
+// subscriber whose buffer is full is dropped and its channel closed, so the
+// client reconnects and catches up from the current state.
+type Hub struct {
+	mu     sync.Mutex
+	subs   map[string]map[*Subscription]struct{}
+	buffer int
+	closed bool
+}
+
+func NewHub(buffer int) *Hub {
+	if buffer <= 0 {
+		buffer = DefaultBuffer
+	}
+	return &Hub{subs: make(map[string]map[*Subscription]struct{}), buffer: buffer}
+}
+
+// Subscription receives the events published to one key.
+type Subscription struct {
+	hub    *Hub
+	key    string
+	events chan Event
+}
+
+// Events is closed when the subscription is closed, dropped or the hub shuts down.
+func (s *Subscription) Events() <-chan Event { return s.events }
+
+// Close unsubscribes. It is safe to call more than once.
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.remove(s)
+}
+
+// Subscribe starts receiving the events of key. After Close the
+// subscription's channel is already closed.
+func (h *Hub) Subscribe(key string) *Subscription {
+	s := &Subscription{hub: h, key: key, events: make(chan Event, h.buffer)}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		close(s.events)
+		return s
+	}
+	if h.subs[key] == nil {
+		h.subs[key] = make(map[*Subscription]struct{})
+	}
+	h.subs[key][s] = struct{}{}
+	return s
+}
+
+// Publish sends evt to the subscribers of key and returns how many got it.
+func (h *Hub) Publish(key string, evt Event) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	n := 0
+	for s := range h.subs[key] {
+		select {
+		case s.events <- evt:
+			n++
+		default:
+			h.remove(s)
+		}
+	}
+	return n
+}
+
+// Close ends every subscription, so open streams finish, and refuses new
+// ones. Call it before shutting down the HTTP server.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for _, subs := range h.subs {
+		for s := range subs {
+			h.remove(s)
+		}
+	}
+}
+
+// remove closes s if it is still subscribed. Callers hold h.mu.
+func (h *Hub) remove(s *Subscription) {
+	subs := h.subs[s.key]
+	if _, ok := subs[s]; !ok {
+		return
+	}
+	delete(subs, s)
+	if len(subs) == 0 {
+		delete(h.subs, s.key)
+	}
+	close(s.events)
+}
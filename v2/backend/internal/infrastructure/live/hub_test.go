@@ -0,0 +1,53 @@
+package live
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHub_PublishesToSubscribersOfKey(t *testing.T) {
+	h := NewHub(4)
+	a := h.Subscribe(ProviderKey("prov-1"))
+	b := h.Subscribe(ProviderKey("prov-1"))
+	other := h.Subscribe(ProviderKey("prov-2"))
+
+	assert.Equal(t, 2, h.Publish(ProviderKey("prov-1"), Event{Type: "offer", Data: "d-1"}))
+	assert.Equal(t, "d-1", (<-a.Events()).Data)
+	assert.Equal(t, "d-1", (<-b.Events()).Data)
+	assert.Empty(t, other.Events())
+
+	a.Close()
+	a.Close()
+	_, open := <-a.Events()
+	assert.False(t, open)
+	assert.Equal(t, 1, h.Publish(ProviderKey("prov-1"), Event{Type: "offer"}))
+}
+
+func TestHub_DropsSubscriberThatFallsBehind(t *testing.T) {
+	h := NewHub(1)
+	slow := h.Subscribe("k")
+
+	assert.Equal(t, 1, h.Publish("k", Event{ID: "1"}))
+	assert.Equal(t, 0, h.Publish("k", Event{ID: "2"}), "never blocks on a full buffer")
+
+	evt, open := <-slow.Events()
+	require.True(t, open)
+	assert.Equal(t, "1", evt.ID)
+	_, open = <-slow.Events()
+	assert.False(t, open, "dropped so the client reconnects")
+	slow.Close()
+}
+
+func TestHub_CloseEndsStreams(t *testing.T) {
+	h := NewHub(0)
+	s := h.Subscribe("k")
+	h.Close()
+
+	_, open := <-s.Events()
+	assert.False(t, open)
+	_, open = <-h.Subscribe("k").Events()
+	assert.False(t, open, "no new subscriptions after close")
+	assert.Equal(t, 0, h.Publish("k", Event{}))
+}
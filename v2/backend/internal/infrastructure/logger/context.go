@@ -0,0 +1,37 @@
+package logger
+
+import (
+	"context"
+
+	"github.com/rs/zerolog"
+)
+
+type correlationKey struct{}
+
+// WithCorrelationID returns a copy of ctx carrying the correlation ID: the HTTP
+// request ID, or the ID restored from an event envelope by a consumer.
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	if id == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, correlationKey{}, id)
+}
+
+// CorrelationID returns the correlation ID carried by ctx, or "".
+func CorrelationID(ctx context.Context) string {
+	id, _ := ctx.Value(correlationKey{}).(string)
+	return id
+}
+
+// Ctx returns the global logger with a correlation_id field when ctx carries
+// one. Use it wherever a context is at hand:
+//
+//	logger.Ctx(ctx).Info().Str("request_id", id).Msg("Request created")
+func Ctx(ctx context.Context) *zerolog.Logger {
+	id := CorrelationID(ctx)
+	if id == "" {
+		return &Log
+	}
+	l := Log.With().Str("correlation_id", id).Logger()
+	return &l
+}
@@ -6,15 +6,17 @@ import (
 	"github.com/pitgo/backend/internal/infrastructure/logger"
 )
 
-// Notification represents a push notification payload.
+// Notification represents a push notification payload. It is addressed to a
+// provider or, when CustomerID is set instead, to a customer.
 type Notification struct {
-	ProviderID string            `json:"provider_id"`
+	ProviderID string            `json:"provider_id,omitempty"`
+	CustomerID string            `json:"customer_id,omitempty"`
 	Title      string            `json:"title"`
 	Body       string            `json:"body"`
 	Data       map[string]string `json:"data,omitempty"`
 }
 
-// Notifier sends push notifications to providers.
+// Notifier sends push notifications to providers and customers.
 // Implement this interface for Firebase Cloud Messaging, APNs, etc.
 type Notifier interface {
 	Send(ctx context.Context, notification Notification) error
@@ -34,6 +36,7 @@ func NewLogNotifier() *LogNotifier {
 func (n *LogNotifier) Send(_ context.Context, notif Notification) error {
 	logger.Info().
 		Str("provider_id", notif.ProviderID).
+		Str("customer_id", notif.CustomerID).
 		Str("title", notif.Title).
 		Str("body", notif.Body).
 		Interface("data", notif.Data).
@@ -0,0 +1,53 @@
+package queue
+
+import (
+	"context"
+	"sync"
+)
+
+// --- Direct Consumer (replay/tooling) ---
+
+// DirectConsumer collects subscriptions without reading from any broker.
+// Messages are handed to the handlers synchronously through Deliver, which
+// lets tools such as eventctl drive one subscriber in isolation. Retry
+// policies are ignored: Deliver returns the first handler error.
+type DirectConsumer struct {
+	mu       sync.RWMutex
+	handlers map[string][]Handler
+}
+
+func NewDirectConsumer() *DirectConsumer {
+	return &DirectConsumer{handlers: make(map[string][]Handler)}
+}
+
+func (c *DirectConsumer) Subscribe(topic string, handler Handler, _ ...SubscribeOption) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.handlers[topic] = append(c.handlers[topic], handler)
+	return nil
+}
+
+func (c *DirectConsumer) Start(context.Context) error { return nil }
+
+func (c *DirectConsumer) Close() error { return nil }
+
+// Handles reports whether any handler is subscribed to topic.
+func (c *DirectConsumer) Handles(topic string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.handlers[topic]) > 0
+}
+
+// Deliver runs every handler subscribed to msg.Topic in order.
+func (c *DirectConsumer) Deliver(ctx context.Context, msg Message) error {
+	c.mu.RLock()
+	handlers := c.handlers[msg.Topic]
+	c.mu.RUnlock()
+	ctx = withCorrelation(ctx, msg)
+	for _, h := range handlers {
+		if err := h(ctx, msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package queue
+
+import (
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pitgo/backend/internal/infrastructure/cache"
+	"github.com/pitgo/backend/internal/infrastructure/config"
+)
+
+// New builds the queue driver selected by QUEUE_DRIVER. Only the connection
+// the driver needs has to be non-nil.
+func New(cfg config.QueueConfig, redisClient *cache.RedisClient, db *pgxpool.Pool) (Queue, error) {
+	switch cfg.Driver {
+	case "", "memory":
+		return NewInMemoryQueueWithPool(PoolConfig{
+			Workers:        cfg.Workers,
+			BufferSize:     cfg.BufferSize,
+			PublishTimeout: cfg.PublishTimeout,
+			Key:            AggregateKey,
+		}), nil
+	case "redis":
+		return NewRedisStreamQueue(redisClient, cfg)
+	case "postgres":
+		return NewPostgresQueue(db, cfg)
+	default:
+		return nil, fmt.Errorf("unknown queue driver %q", cfg.Driver)
+	}
+}
+
+// NewFanout builds the Fanout matching QUEUE_DRIVER: Redis Pub/Sub, Postgres
+// NOTIFY, or in-process for the memory driver, which has a single replica.
+func NewFanout(cfg config.QueueConfig, redisClient *cache.RedisClient, db *pgxpool.Pool) (Fanout, error) {
+	switch cfg.Driver {
+	case "", "memory":
+		return NewInMemoryFanout(), nil
+	case "redis":
+		return NewRedisFanout(redisClient)
+	case "postgres":
+		return NewPostgresFanout(db)
+	default:
+		return nil, fmt.Errorf("unknown queue driver %q", cfg.Driver)
+	}
+}
@@ -0,0 +1,161 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pitgo/backend/internal/infrastructure/cache"
+	"github.com/pitgo/backend/internal/infrastructure/logger"
+	"github.com/redis/go-redis/v9"
+)
+
+// Fanout broadcasts messages to every replica listening on a channel, where
+// a Consumer hands each message to a single replica. Delivery is best
+// effort: nothing is stored, so replicas only see what is broadcast while
+// they listen. Use it for live updates that clients can recover from.
+type Fanout interface {
+	Broadcast(ctx context.Context, channel string, payload []byte) error
+	// Listen calls handler with every payload broadcast on channel until ctx
+	// is done, reconnecting as needed. handler must not block.
+	Listen(ctx context.Context, channel string, handler func(payload []byte)) error
+}
+
+// --- In-Memory Implementation (dev/test) ---
+
+// InMemoryFanout broadcasts to the listeners of this process only.
+type InMemoryFanout struct {
+	mu        sync.RWMutex
+	listeners map[string]map[*func([]byte)]struct{}
+}
+
+func NewInMemoryFanout() *InMemoryFanout {
+	return &InMemoryFanout{listeners: make(map[string]map[*func([]byte)]struct{})}
+}
+
+func (f *InMemoryFanout) Broadcast(_ context.Context, channel string, payload []byte) error {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	for h := range f.listeners[channel] {
+		(*h)(payload)
+	}
+	return nil
+}
+
+func (f *InMemoryFanout) Listen(ctx context.Context, channel string, handler func(payload []byte)) error {
+	h := &handler
+	f.mu.Lock()
+	if f.listeners[channel] == nil {
+		f.listeners[channel] = make(map[*func([]byte)]struct{})
+	}
+	f.listeners[channel][h] = struct{}{}
+	f.mu.Unlock()
+
+	<-ctx.Done()
+
+	f.mu.Lock()
+	delete(f.listeners[channel], h)
+	f.mu.Unlock()
+	return ctx.Err()
+}
+
+// --- Redis Implementation ---
+
+const fanoutKeyPrefix = "pitgo:fanout:"
+
+// RedisFanout broadcasts with Redis Pub/Sub.
+type RedisFanout struct {
+	client *redis.Client
+}
+
+func NewRedisFanout(rc *cache.RedisClient) (*RedisFanout, error) {
+	if rc == nil {
+		return nil, errors.New("redis fanout requires a redis connection")
+	}
+	return &RedisFanout{client: rc.Client}, nil
+}
+
+func (f *RedisFanout) Broadcast(ctx context.Context, channel string, payload []byte) error {
+	if err := f.client.Publish(ctx, fanoutKeyPrefix+channel, payload).Err(); err != nil {
+		return fmt.Errorf("publish %s: %w", channel, err)
+	}
+	return nil
+}
+
+// Listen relies on go-redis to resubscribe after a lost connection.
+func (f *RedisFanout) Listen(ctx context.Context, channel string, handler func(payload []byte)) error {
+	sub := f.client.Subscribe(ctx, fanoutKeyPrefix+channel)
+	defer sub.Close()
+	msgs := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case msg, ok := <-msgs:
+			if !ok {
+				return errors.New("redis fanout subscription closed")
+			}
+			handler([]byte(msg.Payload))
+		}
+	}
+}
+
+// --- Postgres Implementation ---
+
+const fanoutChannelPrefix = "pitgo_fanout_"
+
+// PostgresFanout broadcasts with NOTIFY, so payloads must stay under the
+// 8000-byte NOTIFY limit.
+type PostgresFanout struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresFanout(db *pgxpool.Pool) (*PostgresFanout, error) {
+	if db == nil {
+		return nil, errors.New("postgres fanout requires a database pool")
+	}
+	return &PostgresFanout{db: db}, nil
+}
+
+func (f *PostgresFanout) Broadcast(ctx context.Context, channel string, payload []byte) error {
+	if _, err := f.db.Exec(ctx, `SELECT pg_notify($1, $2)`, fanoutChannelPrefix+channel, string(payload)); err != nil {
+		return fmt.Errorf("notify %s: %w", channel, err)
+	}
+	return nil
+}
+
+// Listen holds one pool connection in LISTEN mode and opens another when it
+// is lost; notifications sent in between are missed.
+func (f *PostgresFanout) Listen(ctx context.Context, channel string, handler func(payload []byte)) error {
+	for ctx.Err() == nil {
+		if err := f.listen(ctx, fanoutChannelPrefix+channel, handler); err != nil && ctx.Err() == nil {
+			logger.Error().Err(err).Str("channel", channel).Msg("Postgres fanout listener failed; reconnecting")
+			sleepCtx(ctx, time.Second)
+		}
+	}
+	return ctx.Err()
+}
+
+func (f *PostgresFanout) listen(ctx context.Context, channel string, handler func(payload []byte)) error {
+	c, err := f.db.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	// The session still has LISTEN active, so don't hand it back to the pool.
+	defer c.Hijack().Close(context.Background())
+
+	if _, err := c.Exec(ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+		return err
+	}
+	for {
+		n, err := c.Conn().WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		handler([]byte(n.Payload))
+	}
+}
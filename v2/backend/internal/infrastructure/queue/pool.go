@@ -0,0 +1,225 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// ErrClosed is returned when publishing to a queue that has been closed.
+	ErrClosed = errors.New("queue closed")
+	// ErrPublishTimeout is returned when the queue stays full for longer than
+	// the publish timeout.
+	ErrPublishTimeout = errors.New("queue full: publish timed out")
+)
+
+// KeyFunc returns the partition key of a message. Messages with the same key
+// are handled one at a time, in the order they were submitted.
+type KeyFunc func(msg Message) string
+
+// AggregateKey partitions messages by the aggregate ID of their envelope
+// (the service request), so events of one request never race each other.
+// Payloads that are not envelopes fall back to the topic.
+func AggregateKey(msg Message) string {
+	h := parseHeader(msg.Payload)
+	if h.AggregateID != "" {
+		return h.AggregateID
+	}
+	return msg.Topic
+}
+
+// PoolConfig sizes a WorkerPool.
+type PoolConfig struct {
+	Workers        int           // Handler goroutines; each owns one partition
+	BufferSize     int           // Messages buffered per worker before Submit blocks
+	PublishTimeout time.Duration // How long Submit waits for space; 0 waits until ctx is done
+	Key            KeyFunc       // Defaults to AggregateKey
+}
+
+// DefaultPoolConfig is used by NewInMemoryQueue.
+var DefaultPoolConfig = PoolConfig{
+	Workers:        8,
+	BufferSize:     128,
+	PublishTimeout: 2 * time.Second,
+	Key:            AggregateKey,
+}
+
+// Stats is a point-in-time view of a queue's local workload.
+type Stats struct {
+	Workers  int   `json:"workers"`
+	Depth    int   `json:"depth"`     // Messages buffered and not yet picked up
+	InFlight int64 `json:"in_flight"` // Messages currently inside a handler
+}
+
+// StatsReporter is implemented by drivers that expose their workload.
+type StatsReporter interface {
+	Stats() Stats
+}
+
+// WorkerPool runs handlers on a fixed set of goroutines. Each message is
+// hashed by its key onto one worker, which keeps per-key ordering while
+// unrelated keys are processed concurrently.
+type WorkerPool struct {
+	cfg    PoolConfig
+	handle func(ctx context.Context, msg Message)
+	shards []chan Message
+
+	ctx      context.Context // Set by Start; workers stop when it is done
+	mu       sync.RWMutex
+	closed   bool
+	inFlight atomic.Int64
+	wg       sync.WaitGroup
+
+	// pending counts messages submitted but not yet handled, so Close can
+	// wait for the pool to go idle.
+	pendingMu sync.Mutex
+	pending   int
+	idle      *sync.Cond
+}
+
+func NewWorkerPool(cfg PoolConfig, handle func(ctx context.Context, msg Message)) *WorkerPool {
+	if cfg.Workers <= 0 {
+		cfg.Workers = DefaultPoolConfig.Workers
+	}
+	if cfg.BufferSize <= 0 {
+		cfg.BufferSize = DefaultPoolConfig.BufferSize
+	}
+	if cfg.Key == nil {
+		cfg.Key = AggregateKey
+	}
+	shards := make([]chan Message, cfg.Workers)
+	for i := range shards {
+		shards[i] = make(chan Message, cfg.BufferSize)
+	}
+	p := &WorkerPool{cfg: cfg, handle: handle, shards: shards}
+	p.idle = sync.NewCond(&p.pendingMu)
+	return p
+}
+
+// Start launches the workers. They stop when ctx is cancelled or, after
+// draining their buffer, when the pool is closed.
+func (p *WorkerPool) Start(ctx context.Context) {
+	p.pendingMu.Lock()
+	p.ctx = ctx
+	p.pendingMu.Unlock()
+	// Wake Close if the workers are cancelled while it waits for idle.
+	context.AfterFunc(ctx, func() {
+		p.pendingMu.Lock()
+		p.idle.Broadcast()
+		p.pendingMu.Unlock()
+	})
+	for _, shard := range p.shards {
+		p.wg.Add(1)
+		go p.work(ctx, shard)
+	}
+}
+
+func (p *WorkerPool) work(ctx context.Context, shard <-chan Message) {
+	defer p.wg.Done()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-shard:
+			if !ok {
+				return
+			}
+			p.inFlight.Add(1)
+			p.handle(ctx, msg)
+			p.inFlight.Add(-1)
+			p.done()
+		}
+	}
+}
+
+// Submit queues msg on its key's worker. When that worker's buffer is full it
+// waits up to PublishTimeout, then fails with ErrPublishTimeout.
+func (p *WorkerPool) Submit(ctx context.Context, msg Message) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return ErrClosed
+	}
+
+	p.addPending()
+	shard := p.shards[p.shardFor(msg)]
+	select {
+	case shard <- msg:
+		return nil
+	default:
+	}
+
+	var timeout <-chan time.Time
+	if p.cfg.PublishTimeout > 0 {
+		t := time.NewTimer(p.cfg.PublishTimeout)
+		defer t.Stop()
+		timeout = t.C
+	}
+	select {
+	case shard <- msg:
+		return nil
+	case <-timeout:
+		p.done()
+		return ErrPublishTimeout
+	case <-ctx.Done():
+		p.done()
+		return ctx.Err()
+	}
+}
+
+func (p *WorkerPool) addPending() {
+	p.pendingMu.Lock()
+	p.pending++
+	p.pendingMu.Unlock()
+}
+
+func (p *WorkerPool) done() {
+	p.pendingMu.Lock()
+	p.pending--
+	if p.pending == 0 {
+		p.idle.Broadcast()
+	}
+	p.pendingMu.Unlock()
+}
+
+func (p *WorkerPool) shardFor(msg Message) int {
+	h := fnv.New32a()
+	h.Write([]byte(p.cfg.Key(msg)))
+	return int(h.Sum32() % uint32(len(p.shards)))
+}
+
+func (p *WorkerPool) Stats() Stats {
+	depth := 0
+	for _, shard := range p.shards {
+		depth += len(shard)
+	}
+	return Stats{Workers: len(p.shards), Depth: depth, InFlight: p.inFlight.Load()}
+}
+
+// Close waits until every submitted message has been handled, including
+// messages that handlers publish while the pool drains, then stops the
+// workers. Submit returns ErrClosed afterwards. If the context given to Start
+// is cancelled first, buffered messages are dropped.
+func (p *WorkerPool) Close() {
+	p.pendingMu.Lock()
+	for p.ctx != nil && p.pending > 0 && p.ctx.Err() == nil {
+		p.idle.Wait()
+	}
+	p.pendingMu.Unlock()
+
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	for _, shard := range p.shards {
+		close(shard)
+	}
+	p.mu.Unlock()
+	p.wg.Wait()
+}
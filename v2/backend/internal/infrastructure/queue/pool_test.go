@@ -0,0 +1,103 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func keyByID(msg Message) string { return msg.ID[:1] }
+
+func TestWorkerPool_KeepsOrderPerKey(t *testing.T) {
+	var mu sync.Mutex
+	seen := map[string][]string{}
+	var wg sync.WaitGroup
+
+	p := NewWorkerPool(PoolConfig{Workers: 4, BufferSize: 64, Key: keyByID}, func(_ context.Context, msg Message) {
+		defer wg.Done()
+		mu.Lock()
+		defer mu.Unlock()
+		k := keyByID(msg)
+		seen[k] = append(seen[k], msg.ID)
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p.Start(ctx)
+
+	var want = map[string][]string{}
+	for i := 0; i < 20; i++ {
+		for _, k := range []string{"a", "b", "c"} {
+			id := fmt.Sprintf("%s%02d", k, i)
+			want[k] = append(want[k], id)
+			wg.Add(1)
+			require.NoError(t, p.Submit(ctx, Message{ID: id}))
+		}
+	}
+	wg.Wait()
+	p.Close()
+
+	assert.Equal(t, want, seen)
+}
+
+func TestWorkerPool_SlowKeyDoesNotBlockOthers(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan string, 1)
+	p := NewWorkerPool(PoolConfig{Workers: 8, BufferSize: 1, Key: keyByID}, func(_ context.Context, msg Message) {
+		if msg.ID == "slow" {
+			close(started)
+			<-release
+			return
+		}
+		done <- msg.ID
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p.Start(ctx)
+
+	require.NoError(t, p.Submit(ctx, Message{ID: "slow"}))
+	<-started
+	// Pick a key that hashes to another worker than "s".
+	fast := ""
+	for _, k := range []string{"a", "b", "c", "d", "e", "f"} {
+		if p.shardFor(Message{ID: k}) != p.shardFor(Message{ID: "slow"}) {
+			fast = k
+			break
+		}
+	}
+	require.NotEmpty(t, fast)
+	require.NoError(t, p.Submit(ctx, Message{ID: fast}))
+
+	select {
+	case id := <-done:
+		assert.Equal(t, fast, id)
+	case <-time.After(time.Second):
+		t.Fatal("fast key was blocked by the slow one")
+	}
+	assert.Equal(t, int64(1), p.Stats().InFlight)
+	close(release)
+	p.Close()
+}
+
+func TestWorkerPool_PublishTimeoutWhenFull(t *testing.T) {
+	p := NewWorkerPool(PoolConfig{Workers: 1, BufferSize: 1, PublishTimeout: 20 * time.Millisecond}, func(context.Context, Message) {})
+
+	// Not started: the single buffer slot fills up and stays full.
+	require.NoError(t, p.Submit(context.Background(), Message{Topic: "t"}))
+	assert.Equal(t, 1, p.Stats().Depth)
+	assert.ErrorIs(t, p.Submit(context.Background(), Message{Topic: "t"}), ErrPublishTimeout)
+
+	p.Close()
+	assert.ErrorIs(t, p.Submit(context.Background(), Message{Topic: "t"}), ErrClosed)
+}
+
+func TestAggregateKey(t *testing.T) {
+	assert.Equal(t, "req-1", AggregateKey(Message{Topic: "request.created", Payload: []byte(`{"correlation_id":"http-1","aggregate_id":"req-1"}`)}))
+	assert.Equal(t, "req-1", AggregateKey(Message{Topic: "request.created", Payload: []byte(`{"correlation_id":"req-1"}`)}), "legacy envelope")
+	assert.Equal(t, "request.created.dlq", AggregateKey(Message{Topic: "request.created.dlq", Payload: []byte(`{"topic":"x"}`)}))
+}
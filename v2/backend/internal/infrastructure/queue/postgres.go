@@ -0,0 +1,363 @@
+package queue
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pitgo/backend/internal/infrastructure/config"
+	"github.com/pitgo/backend/internal/infrastructure/database"
+	"github.com/pitgo/backend/internal/infrastructure/logger"
+)
+
+// jobsChannel is notified by the queue_jobs insert trigger with the job's topic.
+const jobsChannel = "queue_jobs"
+
+// --- Postgres Implementation ---
+
+// PostgresQueue stores messages as rows in queue_jobs. Replicas claim ready
+// rows with FOR UPDATE SKIP LOCKED, so each message is handled by exactly one
+// of them, and are woken by LISTEN/NOTIFY instead of polling in a tight loop.
+// A claim is a lease: rows are deleted once every handler succeeds, and rows
+// whose lease runs out (crashed replica, failed dead-lettering) are claimed
+// again after ClaimMinIdle. The lease of a job is renewed while it waits in
+// the worker pool or runs, however long its handlers retry.
+//
+// Publishing inside database.Transactor.WithinTx inserts the row in that
+// transaction, so the message only becomes visible if it commits.
+type PostgresQueue struct {
+	db  *pgxpool.Pool
+	cfg config.QueueConfig
+
+	mu        sync.RWMutex
+	handlers  map[string][]Handler
+	pool      *WorkerPool
+	wake      chan struct{}
+	stopReads context.CancelFunc
+	wg        sync.WaitGroup
+	closed    atomic.Bool
+
+	heldMu     sync.Mutex
+	held       map[int64]struct{} // Claimed jobs not handled yet
+	stopLeases context.CancelFunc
+	leases     sync.WaitGroup
+}
+
+func NewPostgresQueue(db *pgxpool.Pool, cfg config.QueueConfig) (*PostgresQueue, error) {
+	if db == nil {
+		return nil, errors.New("postgres queue requires a database pool")
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 10
+	}
+	if cfg.BlockTimeout <= 0 {
+		cfg.BlockTimeout = 5 * time.Second
+	}
+	if cfg.ClaimMinIdle <= 0 {
+		cfg.ClaimMinIdle = time.Minute
+	}
+	q := &PostgresQueue{
+		db:       db,
+		cfg:      cfg,
+		handlers: make(map[string][]Handler),
+		wake:     make(chan struct{}, 1),
+		held:     make(map[int64]struct{}),
+	}
+	// Like the Redis reader, the poller blocks on a full partition: unclaimed
+	// rows simply stay in the table.
+	q.pool = NewWorkerPool(PoolConfig{Workers: cfg.Workers, BufferSize: cfg.BufferSize, Key: AggregateKey}, q.process)
+	return q, nil
+}
+
+type execer interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+func (q *PostgresQueue) conn(ctx context.Context) execer {
+	if tx, ok := database.TxFromContext(ctx); ok {
+		return tx
+	}
+	return q.db
+}
+
+func (q *PostgresQueue) Publish(ctx context.Context, topic string, payload []byte) error {
+	if q.closed.Load() {
+		return ErrClosed
+	}
+	_, err := q.conn(ctx).Exec(ctx, `INSERT INTO queue_jobs (topic, payload) VALUES ($1, $2)`, topic, payload)
+	if err != nil {
+		return fmt.Errorf("insert job %s: %w", topic, err)
+	}
+	logger.Debug().Str("topic", topic).Msg("Message published to postgres queue")
+	return nil
+}
+
+func (q *PostgresQueue) Subscribe(topic string, handler Handler, opts ...SubscribeOption) error {
+	sub := newSubscription(opts)
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.handlers[topic] = append(q.handlers[topic], withRetry(q, topic, handler, sub.retry))
+	return nil
+}
+
+// Start launches the listener and the poller. Claimed jobs are handled on the
+// worker pool, ordered per aggregate ID.
+func (q *PostgresQueue) Start(ctx context.Context) error {
+	topics := q.topics()
+
+	readCtx, stopReads := context.WithCancel(ctx)
+	q.stopReads = stopReads
+	q.pool.Start(ctx)
+	q.wg.Add(2)
+	go q.listenLoop(readCtx, topics)
+	go q.pollLoop(readCtx, topics)
+	// Leases are renewed until the pool has drained on Close
+	leaseCtx, stopLeases := context.WithCancel(ctx)
+	q.stopLeases = stopLeases
+	q.leases.Add(1)
+	go q.leaseLoop(leaseCtx)
+
+	logger.Info().Strs("topics", topics).Msg("Postgres queue consumer started")
+	return nil
+}
+
+func (q *PostgresQueue) topics() []string {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	topics := make([]string, 0, len(q.handlers))
+	for topic := range q.handlers {
+		topics = append(topics, topic)
+	}
+	return topics
+}
+
+// listenLoop holds one pool connection in LISTEN mode and wakes the poller
+// whenever a job for a subscribed topic is committed.
+func (q *PostgresQueue) listenLoop(ctx context.Context, topics []string) {
+	defer q.wg.Done()
+	for ctx.Err() == nil {
+		if err := q.listen(ctx, topics); err != nil && ctx.Err() == nil {
+			logger.Error().Err(err).Msg("Postgres queue listener failed; falling back to polling")
+			sleepCtx(ctx, time.Second)
+		}
+	}
+}
+
+func (q *PostgresQueue) listen(ctx context.Context, topics []string) error {
+	c, err := q.db.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	// The session still has LISTEN active, so don't hand it back to the pool.
+	defer c.Hijack().Close(context.Background())
+
+	if _, err := c.Exec(ctx, "LISTEN "+jobsChannel); err != nil {
+		return err
+	}
+	// Jobs committed before LISTEN took effect would otherwise wait for the
+	// next poll.
+	q.signal()
+	for {
+		n, err := c.Conn().WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		if slices.Contains(topics, n.Payload) {
+			q.signal()
+		}
+	}
+}
+
+func (q *PostgresQueue) signal() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// pollLoop claims batches of ready jobs until none are left, then waits for a
+// notification or BlockTimeout, whichever comes first. The timeout also
+// picks up jobs whose lease expired.
+func (q *PostgresQueue) pollLoop(ctx context.Context, topics []string) {
+	defer q.wg.Done()
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-q.wake:
+		case <-timer.C:
+		}
+
+		for ctx.Err() == nil {
+			n, err := q.claim(ctx, topics)
+			if err != nil {
+				if ctx.Err() == nil {
+					logger.Error().Err(err).Msg("Failed to claim queue jobs")
+				}
+				break
+			}
+			if n < int(q.cfg.BatchSize) {
+				break
+			}
+		}
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(q.cfg.BlockTimeout)
+	}
+}
+
+// claim leases up to BatchSize ready jobs and submits them to the worker pool
+// in publish order.
+func (q *PostgresQueue) claim(ctx context.Context, topics []string) (int, error) {
+	rows, err := q.db.Query(ctx, `
+		UPDATE queue_jobs
+		SET locked_until = NOW() + make_interval(secs => $3), attempts = attempts + 1
+		WHERE id IN (
+		    SELECT id FROM queue_jobs
+		    WHERE topic = ANY($1) AND locked_until <= NOW()
+		    ORDER BY id
+		    LIMIT $2
+		    FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, topic, payload, attempts`,
+		topics, q.cfg.BatchSize, q.cfg.ClaimMinIdle.Seconds(),
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	type job struct {
+		id       int64
+		msg      Message
+		attempts int
+	}
+	jobs, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (job, error) {
+		var j job
+		err := row.Scan(&j.id, &j.msg.Topic, &j.msg.Payload, &j.attempts)
+		j.msg.ID = strconv.FormatInt(j.id, 10)
+		return j, err
+	})
+	if err != nil {
+		return 0, err
+	}
+	slices.SortFunc(jobs, func(a, b job) int { return cmp.Compare(a.id, b.id) })
+
+	q.heldMu.Lock()
+	for _, j := range jobs {
+		q.held[j.id] = struct{}{}
+	}
+	q.heldMu.Unlock()
+	for i, j := range jobs {
+		if j.attempts > 1 {
+			logger.Warn().Str("topic", j.msg.Topic).Str("message_id", j.msg.ID).Int("attempts", j.attempts).Msg("Reclaimed expired queue job")
+		}
+		if err := q.pool.Submit(ctx, j.msg); err != nil {
+			// Unsubmitted jobs are claimed again once their lease expires
+			for _, left := range jobs[i:] {
+				q.release(left.id)
+			}
+			return len(jobs), err
+		}
+	}
+	return len(jobs), nil
+}
+
+// leaseLoop renews the lease of the held jobs every third of ClaimMinIdle,
+// so no other replica reclaims a job this one is still going to handle.
+func (q *PostgresQueue) leaseLoop(ctx context.Context) {
+	defer q.leases.Done()
+	ticker := time.NewTicker(q.cfg.ClaimMinIdle / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		q.heldMu.Lock()
+		ids := make([]int64, 0, len(q.held))
+		for id := range q.held {
+			ids = append(ids, id)
+		}
+		q.heldMu.Unlock()
+		if len(ids) == 0 {
+			continue
+		}
+		_, err := q.db.Exec(ctx, `UPDATE queue_jobs SET locked_until = NOW() + make_interval(secs => $2) WHERE id = ANY($1)`,
+			ids, q.cfg.ClaimMinIdle.Seconds())
+		if err != nil && ctx.Err() == nil {
+			logger.Error().Err(err).Int("jobs", len(ids)).Msg("Failed to renew queue job leases")
+		}
+	}
+}
+
+// release stops renewing the lease of a job.
+func (q *PostgresQueue) release(id int64) {
+	q.heldMu.Lock()
+	delete(q.held, id)
+	q.heldMu.Unlock()
+}
+
+// process runs every handler for the job and deletes it only when all of them
+// succeed. Handlers retry and dead-letter on their own, so a job is left in
+// place only when dead-lettering fails; it is reclaimed after its lease.
+func (q *PostgresQueue) process(ctx context.Context, msg Message) {
+	topic := msg.Topic
+	q.mu.RLock()
+	handlers := q.handlers[topic]
+	q.mu.RUnlock()
+
+	id, _ := strconv.ParseInt(msg.ID, 10, 64)
+	// A failed job's lease then runs out and it is reclaimed
+	defer q.release(id)
+
+	ctx = withCorrelation(ctx, msg)
+	for _, h := range handlers {
+		if err := h(ctx, msg); err != nil {
+			logger.Ctx(ctx).Error().Err(err).Str("topic", topic).Str("message_id", msg.ID).Msg("Error processing message")
+			return
+		}
+	}
+
+	if _, err := q.db.Exec(ctx, `DELETE FROM queue_jobs WHERE id = $1`, id); err != nil {
+		logger.Error().Err(err).Str("topic", topic).Str("message_id", msg.ID).Msg("Failed to delete queue job")
+	}
+}
+
+func (q *PostgresQueue) Stats() Stats { return q.pool.Stats() }
+
+// Close stops claiming new jobs, waits for the claimed ones to be handled and
+// then rejects further publishes. The database pool is owned by the caller
+// and is not closed here.
+func (q *PostgresQueue) Close() error {
+	if q.stopReads != nil {
+		q.stopReads()
+	}
+	q.wg.Wait()
+	q.pool.Close()
+	if q.stopLeases != nil {
+		q.stopLeases()
+	}
+	q.leases.Wait()
+	q.closed.Store(true)
+	return nil
+}
@@ -0,0 +1,148 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pitgo/backend/internal/infrastructure/config"
+	"github.com/pitgo/backend/internal/infrastructure/database"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testPostgresPool connects to TEST_DATABASE_URL and recreates queue_jobs from
+// the migration files. Tests using it are skipped when the variable is unset.
+func testPostgresPool(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	pool, err := pgxpool.New(context.Background(), dsn)
+	require.NoError(t, err)
+	t.Cleanup(pool.Close)
+
+	for _, file := range []string{"000009_queue_jobs.down.sql", "000009_queue_jobs.up.sql"} {
+		sql, err := os.ReadFile("../../../migrations/" + file)
+		require.NoError(t, err)
+		_, err = pool.Exec(context.Background(), string(sql))
+		require.NoError(t, err)
+	}
+	return pool
+}
+
+func TestPostgresQueue_SharesJobsAcrossReplicas(t *testing.T) {
+	pool := testPostgresPool(t)
+	cfg := config.QueueConfig{Workers: 2, BatchSize: 5, BlockTimeout: time.Second, ClaimMinIdle: time.Minute}
+
+	var mu sync.Mutex
+	handled := map[string]int{}
+	var wg sync.WaitGroup
+	const perAggregate = 10
+	wg.Add(3 * perAggregate)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var replicas []*PostgresQueue
+	for i := 0; i < 2; i++ {
+		q, err := NewPostgresQueue(pool, cfg)
+		require.NoError(t, err)
+		require.NoError(t, q.Subscribe("request.created", func(_ context.Context, msg Message) error {
+			defer wg.Done()
+			mu.Lock()
+			handled[msg.ID]++
+			mu.Unlock()
+			return nil
+		}))
+		require.NoError(t, q.Start(ctx))
+		replicas = append(replicas, q)
+	}
+
+	for i := 0; i < perAggregate; i++ {
+		for _, agg := range []string{"req-a", "req-b", "req-c"} {
+			payload := fmt.Sprintf(`{"aggregate_id":%q,"seq":%d}`, agg, i)
+			require.NoError(t, replicas[i%2].Publish(ctx, "request.created", []byte(payload)))
+		}
+	}
+
+	done := make(chan struct{})
+	go func() { wg.Wait(); close(done) }()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("jobs not handled")
+	}
+	for _, q := range replicas {
+		require.NoError(t, q.Close())
+	}
+
+	assert.Len(t, handled, 3*perAggregate)
+	for id, n := range handled {
+		assert.Equal(t, 1, n, "job %s handled more than once", id)
+	}
+	var left int
+	require.NoError(t, pool.QueryRow(context.Background(), `SELECT COUNT(*) FROM queue_jobs`).Scan(&left))
+	assert.Zero(t, left, "handled jobs are deleted")
+}
+
+func TestPostgresQueue_RenewsLeaseOfSlowJob(t *testing.T) {
+	pool := testPostgresPool(t)
+	cfg := config.QueueConfig{Workers: 1, BatchSize: 1, BlockTimeout: 100 * time.Millisecond, ClaimMinIdle: 300 * time.Millisecond}
+
+	var mu sync.Mutex
+	handled := 0
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var replicas []*PostgresQueue
+	for i := 0; i < 2; i++ {
+		q, err := NewPostgresQueue(pool, cfg)
+		require.NoError(t, err)
+		require.NoError(t, q.Subscribe("request.created", func(_ context.Context, _ Message) error {
+			mu.Lock()
+			handled++
+			mu.Unlock()
+			// Runs several times longer than the lease
+			time.Sleep(time.Second)
+			return nil
+		}))
+		require.NoError(t, q.Start(ctx))
+		replicas = append(replicas, q)
+	}
+
+	require.NoError(t, replicas[0].Publish(ctx, "request.created", []byte(`{"aggregate_id":"req-1"}`)))
+	require.Eventually(t, func() bool {
+		var left int
+		require.NoError(t, pool.QueryRow(context.Background(), `SELECT COUNT(*) FROM queue_jobs`).Scan(&left))
+		return left == 0
+	}, 5*time.Second, 50*time.Millisecond)
+	for _, q := range replicas {
+		require.NoError(t, q.Close())
+	}
+
+	assert.Equal(t, 1, handled, "a job whose lease is renewed is not reclaimed")
+}
+
+func TestPostgresQueue_PublishJoinsTransaction(t *testing.T) {
+	pool := testPostgresPool(t)
+	q, err := NewPostgresQueue(pool, config.QueueConfig{})
+	require.NoError(t, err)
+
+	tx := database.NewTxManager(pool)
+	errRollback := errors.New("rollback")
+	err = tx.WithinTx(context.Background(), func(ctx context.Context) error {
+		require.NoError(t, q.Publish(ctx, "request.created", []byte(`{}`)))
+		return errRollback
+	})
+	require.ErrorIs(t, err, errRollback)
+
+	var n int
+	require.NoError(t, pool.QueryRow(context.Background(), `SELECT COUNT(*) FROM queue_jobs`).Scan(&n))
+	assert.Zero(t, n, "rolled back publish is not visible")
+	require.NoError(t, q.Close())
+}
@@ -2,6 +2,7 @@ package queue
 
 import (
 	"context"
+	"encoding/json"
 	"sync"
 
 	"github.com/pitgo/backend/internal/infrastructure/logger"
@@ -14,77 +15,144 @@ type Message struct {
 	Payload []byte
 }
 
+// envelopeHeader holds the tracing fields of an event envelope payload.
+type envelopeHeader struct {
+	CorrelationID string `json:"correlation_id"`
+	AggregateID   string `json:"aggregate_id"`
+}
+
+func parseHeader(payload []byte) envelopeHeader {
+	var h envelopeHeader
+	if err := json.Unmarshal(payload, &h); err != nil {
+		return envelopeHeader{}
+	}
+	// Older envelopes used the service request ID as their correlation ID.
+	if h.AggregateID == "" {
+		h.AggregateID = h.CorrelationID
+	}
+	return h
+}
+
+// withCorrelation restores the correlation ID of msg's envelope into ctx, so
+// handlers log and publish under the same ID as the request that caused it.
+func withCorrelation(ctx context.Context, msg Message) context.Context {
+	return logger.WithCorrelationID(ctx, parseHeader(msg.Payload).CorrelationID)
+}
+
 // Handler processes a queue message.
 type Handler func(ctx context.Context, msg Message) error
 
+// Middleware decorates a Handler, e.g. to skip duplicates or enrich the context.
+type Middleware func(Handler) Handler
+
+// Chain applies middlewares so that the first one is the outermost.
+func Chain(h Handler, mws ...Middleware) Handler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
+
 // Publisher sends messages to a queue.
 type Publisher interface {
 	Publish(ctx context.Context, topic string, payload []byte) error
 	Close() error
 }
 
-// Consumer receives messages from a queue.
+// Consumer receives messages from a queue. Handlers that keep failing are
+// retried per their RetryPolicy and then dead-lettered.
 type Consumer interface {
-	Subscribe(topic string, handler Handler) error
+	Subscribe(topic string, handler Handler, opts ...SubscribeOption) error
 	Start(ctx context.Context) error
 	Close() error
 }
 
+// Drain closes c, which lets in-flight messages finish. If ctx expires first,
+// abort is called to cancel the context the consumer was started with, and
+// Drain waits for c to wind down before returning ctx.Err().
+func Drain(ctx context.Context, c Consumer, abort context.CancelFunc) error {
+	done := make(chan error, 1)
+	go func() { done <- c.Close() }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		abort()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// Queue is a driver that both publishes and consumes messages.
+type Queue interface {
+	Publisher
+	Consumer
+}
+
 // --- In-Memory Implementation (dev/test) ---
 
+// InMemoryQueue hands published messages to a WorkerPool in the same process.
+// Messages are lost on restart and are not shared between replicas.
 type InMemoryQueue struct {
 	mu       sync.RWMutex
 	handlers map[string][]Handler
-	messages chan Message
+	pool     *WorkerPool
 }
 
 func NewInMemoryQueue() *InMemoryQueue {
-	return &InMemoryQueue{
-		handlers: make(map[string][]Handler),
-		messages: make(chan Message, 1000),
-	}
+	return NewInMemoryQueueWithPool(DefaultPoolConfig)
 }
 
-func (q *InMemoryQueue) Publish(_ context.Context, topic string, payload []byte) error {
+func NewInMemoryQueueWithPool(cfg PoolConfig) *InMemoryQueue {
+	q := &InMemoryQueue{handlers: make(map[string][]Handler)}
+	q.pool = NewWorkerPool(cfg, q.dispatch)
+	return q
+}
+
+// Publish blocks while the message's partition is full, up to the pool's
+// PublishTimeout, and then returns ErrPublishTimeout.
+func (q *InMemoryQueue) Publish(ctx context.Context, topic string, payload []byte) error {
 	msg := Message{
-		ID:      "", // Will be set by consumer
+		ID:      "", // In-memory messages have no broker ID
 		Topic:   topic,
 		Payload: payload,
 	}
-	q.messages <- msg
+	if err := q.pool.Submit(ctx, msg); err != nil {
+		return err
+	}
 	logger.Debug().Str("topic", topic).Msg("Message published to in-memory queue")
 	return nil
 }
 
-func (q *InMemoryQueue) Subscribe(topic string, handler Handler) error {
+func (q *InMemoryQueue) Subscribe(topic string, handler Handler, opts ...SubscribeOption) error {
+	sub := newSubscription(opts)
 	q.mu.Lock()
 	defer q.mu.Unlock()
-	q.handlers[topic] = append(q.handlers[topic], handler)
+	q.handlers[topic] = append(q.handlers[topic], withRetry(q, topic, handler, sub.retry))
 	return nil
 }
 
 func (q *InMemoryQueue) Start(ctx context.Context) error {
-	go func() {
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			case msg := <-q.messages:
-				q.mu.RLock()
-				handlers := q.handlers[msg.Topic]
-				q.mu.RUnlock()
-				for _, h := range handlers {
-					if err := h(ctx, msg); err != nil {
-						logger.Error().Err(err).Str("topic", msg.Topic).Msg("Error processing message")
-					}
-				}
-			}
-		}
-	}()
+	q.pool.Start(ctx)
 	return nil
 }
 
+func (q *InMemoryQueue) dispatch(ctx context.Context, msg Message) {
+	q.mu.RLock()
+	handlers := q.handlers[msg.Topic]
+	q.mu.RUnlock()
+	ctx = withCorrelation(ctx, msg)
+	for _, h := range handlers {
+		if err := h(ctx, msg); err != nil {
+			logger.Ctx(ctx).Error().Err(err).Str("topic", msg.Topic).Msg("Error processing message")
+		}
+	}
+}
+
+func (q *InMemoryQueue) Stats() Stats { return q.pool.Stats() }
+
+// Close stops accepting messages and waits for buffered ones to be handled.
 func (q *InMemoryQueue) Close() error {
-	close(q.messages)
+	q.pool.Close()
 	return nil
 }
@@ -0,0 +1,34 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pitgo/backend/internal/infrastructure/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInMemoryQueue_RestoresCorrelationID(t *testing.T) {
+	q := NewInMemoryQueue()
+	seen := make(chan string, 1)
+	require.NoError(t, q.Subscribe("request.created", func(ctx context.Context, _ Message) error {
+		seen <- logger.CorrelationID(ctx)
+		return nil
+	}))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	require.NoError(t, q.Start(ctx))
+
+	payload := []byte(`{"event_id":"evt-1","correlation_id":"http-1","aggregate_id":"req-1"}`)
+	require.NoError(t, q.Publish(context.Background(), "request.created", payload))
+
+	select {
+	case id := <-seen:
+		assert.Equal(t, "http-1", id)
+	case <-time.After(time.Second):
+		t.Fatal("handler not called")
+	}
+	require.NoError(t, q.Close())
+}
@@ -0,0 +1,368 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pitgo/backend/internal/infrastructure/cache"
+	"github.com/pitgo/backend/internal/infrastructure/config"
+	"github.com/pitgo/backend/internal/infrastructure/logger"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	streamKeyPrefix = "pitgo:stream:"
+	payloadField    = "payload"
+)
+
+// --- Redis Streams Implementation ---
+
+// RedisStreamQueue stores every topic in its own Redis Stream and consumes it
+// through a consumer group, so each message is handled by exactly one replica.
+// Messages are acknowledged only after all handlers succeed; failed or
+// orphaned entries stay in the pending list and are reclaimed with XAUTOCLAIM.
+// Entries a replica still holds in its worker pool are never reclaimed by it
+// and have their idle time reset, so other replicas do not take them over.
+type RedisStreamQueue struct {
+	client *redis.Client
+	cfg    config.QueueConfig
+
+	mu        sync.RWMutex
+	handlers  map[string][]Handler
+	pool      *WorkerPool
+	stopReads context.CancelFunc
+	wg        sync.WaitGroup
+	closed    atomic.Bool
+
+	heldMu sync.Mutex
+	held   map[string]map[string]struct{} // Entry IDs read but not handled yet, by topic
+}
+
+func NewRedisStreamQueue(rc *cache.RedisClient, cfg config.QueueConfig) (*RedisStreamQueue, error) {
+	if rc == nil {
+		return nil, errors.New("redis stream queue requires a redis connection")
+	}
+	if cfg.Group == "" {
+		return nil, errors.New("redis stream queue requires a consumer group")
+	}
+	if cfg.Consumer == "" {
+		host, err := os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("resolve consumer name: %w", err)
+		}
+		cfg.Consumer = host
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 10
+	}
+	if cfg.BlockTimeout <= 0 {
+		cfg.BlockTimeout = 5 * time.Second
+	}
+	if cfg.ClaimMinIdle <= 0 {
+		cfg.ClaimMinIdle = time.Minute
+	}
+	q := &RedisStreamQueue{
+		client:   rc.Client,
+		cfg:      cfg,
+		handlers: make(map[string][]Handler),
+		held:     make(map[string]map[string]struct{}),
+	}
+	// Readers block on a full partition instead of timing out: unread entries
+	// simply stay in the stream.
+	q.pool = NewWorkerPool(PoolConfig{Workers: cfg.Workers, BufferSize: cfg.BufferSize, Key: AggregateKey}, q.process)
+	return q, nil
+}
+
+func streamKey(topic string) string { return streamKeyPrefix + topic }
+
+func (q *RedisStreamQueue) Publish(ctx context.Context, topic string, payload []byte) error {
+	if q.closed.Load() {
+		return ErrClosed
+	}
+	args := &redis.XAddArgs{
+		Stream: streamKey(topic),
+		Values: map[string]any{payloadField: payload},
+	}
+	if q.cfg.StreamMaxLen > 0 {
+		args.MaxLen = q.cfg.StreamMaxLen
+		args.Approx = true
+	}
+	id, err := q.client.XAdd(ctx, args).Result()
+	if err != nil {
+		return fmt.Errorf("xadd %s: %w", topic, err)
+	}
+	logger.Debug().Str("topic", topic).Str("message_id", id).Msg("Message published to redis stream")
+	return nil
+}
+
+func (q *RedisStreamQueue) Subscribe(topic string, handler Handler, opts ...SubscribeOption) error {
+	sub := newSubscription(opts)
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.handlers[topic] = append(q.handlers[topic], withRetry(q, topic, handler, sub.retry))
+	return nil
+}
+
+// Start creates the consumer group for every subscribed topic and launches one
+// reader and one reclaimer goroutine per topic. Entries are handled on the
+// worker pool, ordered per correlation ID.
+func (q *RedisStreamQueue) Start(ctx context.Context) error {
+	q.mu.RLock()
+	topics := make([]string, 0, len(q.handlers))
+	for topic := range q.handlers {
+		topics = append(topics, topic)
+	}
+	q.mu.RUnlock()
+
+	for _, topic := range topics {
+		err := q.client.XGroupCreateMkStream(ctx, streamKey(topic), q.cfg.Group, "0").Err()
+		if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+			return fmt.Errorf("create consumer group for %s: %w", topic, err)
+		}
+	}
+
+	// Readers get their own context so Close can stop them while the pool
+	// keeps draining entries that were already read.
+	readCtx, stopReads := context.WithCancel(ctx)
+	q.stopReads = stopReads
+	q.pool.Start(ctx)
+	for _, topic := range topics {
+		q.wg.Add(2)
+		go q.readLoop(readCtx, topic)
+		go q.reclaimLoop(readCtx, topic)
+	}
+
+	logger.Info().
+		Str("group", q.cfg.Group).
+		Str("consumer", q.cfg.Consumer).
+		Strs("topics", topics).
+		Msg("Redis stream consumer started")
+	return nil
+}
+
+func (q *RedisStreamQueue) readLoop(ctx context.Context, topic string) {
+	defer q.wg.Done()
+	stream := streamKey(topic)
+	for ctx.Err() == nil {
+		res, err := q.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    q.cfg.Group,
+			Consumer: q.cfg.Consumer,
+			Streams:  []string{stream, ">"},
+			Count:    q.cfg.BatchSize,
+			Block:    q.cfg.BlockTimeout,
+		}).Result()
+		if err != nil {
+			if errors.Is(err, redis.Nil) || ctx.Err() != nil {
+				continue
+			}
+			logger.Error().Err(err).Str("topic", topic).Msg("Failed to read from redis stream")
+			sleepCtx(ctx, time.Second)
+			continue
+		}
+		for _, s := range res {
+			for _, xmsg := range s.Messages {
+				q.submit(ctx, topic, xmsg)
+			}
+		}
+	}
+}
+
+// reclaimLoop takes over entries that another consumer read but never
+// acknowledged (e.g. the pod crashed mid-handler) once they have been idle
+// for longer than ClaimMinIdle. Entries this consumer still holds are renewed
+// first and skipped when XAUTOCLAIM returns them.
+func (q *RedisStreamQueue) reclaimLoop(ctx context.Context, topic string) {
+	defer q.wg.Done()
+	stream := streamKey(topic)
+	ticker := time.NewTicker(q.cfg.ClaimMinIdle / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		q.renew(ctx, topic)
+		start := "0-0"
+		for ctx.Err() == nil {
+			msgs, next, err := q.client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+				Stream:   stream,
+				Group:    q.cfg.Group,
+				Consumer: q.cfg.Consumer,
+				MinIdle:  q.cfg.ClaimMinIdle,
+				Start:    start,
+				Count:    q.cfg.BatchSize,
+			}).Result()
+			if err != nil {
+				if ctx.Err() == nil {
+					logger.Error().Err(err).Str("topic", topic).Msg("Failed to reclaim pending entries")
+				}
+				break
+			}
+			for _, xmsg := range msgs {
+				if q.isHeld(topic, xmsg.ID) {
+					continue
+				}
+				logger.Warn().Str("topic", topic).Str("message_id", xmsg.ID).Msg("Reclaimed pending stream entry")
+				q.submit(ctx, topic, xmsg)
+			}
+			if next == "0-0" || len(msgs) == 0 {
+				break
+			}
+			start = next
+		}
+	}
+}
+
+// renew resets the idle time of the entries this consumer still holds, so
+// they stay below ClaimMinIdle for every other replica. Entries already
+// taken over by another consumer are left alone.
+func (q *RedisStreamQueue) renew(ctx context.Context, topic string) {
+	q.heldMu.Lock()
+	held := make(map[string]struct{}, len(q.held[topic]))
+	for id := range q.held[topic] {
+		held[id] = struct{}{}
+	}
+	q.heldMu.Unlock()
+	if len(held) == 0 {
+		return
+	}
+
+	stream := streamKey(topic)
+	var ids []string
+	start := "-"
+	for ctx.Err() == nil {
+		pending, err := q.client.XPendingExt(ctx, &redis.XPendingExtArgs{
+			Stream:   stream,
+			Group:    q.cfg.Group,
+			Consumer: q.cfg.Consumer,
+			Start:    start,
+			End:      "+",
+			Count:    100,
+		}).Result()
+		if err != nil {
+			if ctx.Err() == nil {
+				logger.Error().Err(err).Str("topic", topic).Msg("Failed to list pending entries")
+			}
+			return
+		}
+		for _, p := range pending {
+			if _, ok := held[p.ID]; ok {
+				ids = append(ids, p.ID)
+			}
+		}
+		if len(pending) < 100 {
+			break
+		}
+		start = "(" + pending[len(pending)-1].ID
+	}
+	if len(ids) == 0 {
+		return
+	}
+	err := q.client.XClaimJustID(ctx, &redis.XClaimArgs{
+		Stream:   stream,
+		Group:    q.cfg.Group,
+		Consumer: q.cfg.Consumer,
+		Messages: ids,
+	}).Err()
+	if err != nil && ctx.Err() == nil {
+		logger.Error().Err(err).Str("topic", topic).Int("entries", len(ids)).Msg("Failed to renew held stream entries")
+	}
+}
+
+func (q *RedisStreamQueue) hold(topic, id string) {
+	q.heldMu.Lock()
+	defer q.heldMu.Unlock()
+	if q.held[topic] == nil {
+		q.held[topic] = make(map[string]struct{})
+	}
+	q.held[topic][id] = struct{}{}
+}
+
+func (q *RedisStreamQueue) release(topic, id string) {
+	q.heldMu.Lock()
+	delete(q.held[topic], id)
+	q.heldMu.Unlock()
+}
+
+func (q *RedisStreamQueue) isHeld(topic, id string) bool {
+	q.heldMu.Lock()
+	defer q.heldMu.Unlock()
+	_, ok := q.held[topic][id]
+	return ok
+}
+
+func (q *RedisStreamQueue) submit(ctx context.Context, topic string, xmsg redis.XMessage) {
+	msg := Message{ID: xmsg.ID, Topic: topic}
+	switch v := xmsg.Values[payloadField].(type) {
+	case string:
+		msg.Payload = []byte(v)
+	case []byte:
+		msg.Payload = v
+	}
+	q.hold(topic, msg.ID)
+	if err := q.pool.Submit(ctx, msg); err != nil {
+		q.release(topic, msg.ID)
+		if ctx.Err() != nil {
+			return
+		}
+		logger.Error().Err(err).Str("topic", topic).Str("message_id", msg.ID).Msg("Failed to schedule stream entry")
+	}
+}
+
+// process runs every handler for the entry and acknowledges it only when all
+// of them succeed. Handlers retry and dead-letter on their own, so an entry is
+// left pending only when dead-lettering fails; reclaimLoop picks it up later.
+func (q *RedisStreamQueue) process(ctx context.Context, msg Message) {
+	topic := msg.Topic
+	q.mu.RLock()
+	handlers := q.handlers[topic]
+	q.mu.RUnlock()
+	// A failed entry then idles until reclaimLoop picks it up
+	defer q.release(topic, msg.ID)
+
+	ctx = withCorrelation(ctx, msg)
+	for _, h := range handlers {
+		if err := h(ctx, msg); err != nil {
+			logger.Ctx(ctx).Error().Err(err).Str("topic", topic).Str("message_id", msg.ID).Msg("Error processing message")
+			return
+		}
+	}
+
+	if err := q.client.XAck(ctx, streamKey(topic), q.cfg.Group, msg.ID).Err(); err != nil {
+		logger.Error().Err(err).Str("topic", topic).Str("message_id", msg.ID).Msg("Failed to ack stream entry")
+	}
+}
+
+func (q *RedisStreamQueue) Stats() Stats { return q.pool.Stats() }
+
+// Close stops reading new entries, waits for the ones already read to be
+// handled and then rejects further publishes. Handlers may still publish
+// while they drain. The underlying Redis client is owned by the caller and is
+// not closed here.
+func (q *RedisStreamQueue) Close() error {
+	if q.stopReads != nil {
+		q.stopReads()
+	}
+	q.wg.Wait()
+	q.pool.Close()
+	q.closed.Store(true)
+	return nil
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+	case <-t.C:
+	}
+}
@@ -0,0 +1,135 @@
+package queue
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pitgo/backend/internal/infrastructure/cache"
+	"github.com/pitgo/backend/internal/infrastructure/config"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testRedisClient connects to TEST_REDIS_URL and returns a topic whose stream
+// is deleted when the test ends. Tests using it are skipped when the variable
+// is unset.
+func testRedisClient(t *testing.T) (*cache.RedisClient, string) {
+	t.Helper()
+	url := os.Getenv("TEST_REDIS_URL")
+	if url == "" {
+		t.Skip("TEST_REDIS_URL not set")
+	}
+	opts, err := redis.ParseURL(url)
+	require.NoError(t, err)
+	client := redis.NewClient(opts)
+	t.Cleanup(func() { client.Close() })
+	require.NoError(t, client.Ping(context.Background()).Err())
+
+	topic := "test." + uuid.New().String()
+	t.Cleanup(func() { client.Del(context.Background(), streamKey(topic)) })
+	return &cache.RedisClient{Client: client}, topic
+}
+
+func waitFor(t *testing.T, ch <-chan Message) Message {
+	t.Helper()
+	select {
+	case msg := <-ch:
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("message not handled")
+		return Message{}
+	}
+}
+
+func TestRedisStreamQueue_PublishConsumeAck(t *testing.T) {
+	rc, topic := testRedisClient(t)
+	cfg := config.QueueConfig{Group: "test-group", Consumer: "consumer-1", BlockTimeout: 100 * time.Millisecond}
+	q, err := NewRedisStreamQueue(rc, cfg)
+	require.NoError(t, err)
+
+	handled := make(chan Message, 1)
+	require.NoError(t, q.Subscribe(topic, func(_ context.Context, msg Message) error {
+		handled <- msg
+		return nil
+	}))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	require.NoError(t, q.Start(ctx))
+
+	require.NoError(t, q.Publish(ctx, topic, []byte(`{"aggregate_id":"req-1"}`)))
+	msg := waitFor(t, handled)
+	assert.Equal(t, topic, msg.Topic)
+	assert.JSONEq(t, `{"aggregate_id":"req-1"}`, string(msg.Payload))
+	require.NoError(t, q.Close())
+
+	pending, err := rc.Client.XPending(context.Background(), streamKey(topic), cfg.Group).Result()
+	require.NoError(t, err)
+	assert.Zero(t, pending.Count, "handled entries are acknowledged")
+}
+
+func TestRedisStreamQueue_ReclaimsUnackedEntry(t *testing.T) {
+	rc, topic := testRedisClient(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// A consumer reads the entry and dies before acknowledging it
+	const group = "test-group"
+	require.NoError(t, rc.Client.XGroupCreateMkStream(ctx, streamKey(topic), group, "0").Err())
+	id, err := rc.Client.XAdd(ctx, &redis.XAddArgs{Stream: streamKey(topic), Values: map[string]any{payloadField: `{"aggregate_id":"req-1"}`}}).Result()
+	require.NoError(t, err)
+	_, err = rc.Client.XReadGroup(ctx, &redis.XReadGroupArgs{Group: group, Consumer: "crashed", Streams: []string{streamKey(topic), ">"}}).Result()
+	require.NoError(t, err)
+
+	cfg := config.QueueConfig{Group: group, Consumer: "consumer-1", BlockTimeout: 100 * time.Millisecond, ClaimMinIdle: 200 * time.Millisecond}
+	q, err := NewRedisStreamQueue(rc, cfg)
+	require.NoError(t, err)
+	handled := make(chan Message, 1)
+	require.NoError(t, q.Subscribe(topic, func(_ context.Context, msg Message) error {
+		handled <- msg
+		return nil
+	}))
+	require.NoError(t, q.Start(ctx))
+
+	msg := waitFor(t, handled)
+	assert.Equal(t, id, msg.ID)
+	require.NoError(t, q.Close())
+
+	pending, err := rc.Client.XPending(context.Background(), streamKey(topic), group).Result()
+	require.NoError(t, err)
+	assert.Zero(t, pending.Count, "the reclaimed entry is acknowledged")
+}
+
+func TestRedisStreamQueue_DoesNotReclaimHeldEntry(t *testing.T) {
+	rc, topic := testRedisClient(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cfg := config.QueueConfig{Group: "test-group", BlockTimeout: 100 * time.Millisecond, ClaimMinIdle: 200 * time.Millisecond}
+	var replicas []*RedisStreamQueue
+	handled := make(chan Message, 4)
+	for _, consumer := range []string{"consumer-1", "consumer-2"} {
+		cfg.Consumer = consumer
+		q, err := NewRedisStreamQueue(rc, cfg)
+		require.NoError(t, err)
+		require.NoError(t, q.Subscribe(topic, func(_ context.Context, msg Message) error {
+			handled <- msg
+			// Runs several times longer than ClaimMinIdle
+			time.Sleep(time.Second)
+			return nil
+		}))
+		require.NoError(t, q.Start(ctx))
+		replicas = append(replicas, q)
+	}
+
+	require.NoError(t, replicas[0].Publish(ctx, topic, []byte(`{"aggregate_id":"req-1"}`)))
+	waitFor(t, handled)
+	time.Sleep(1500 * time.Millisecond)
+	for _, q := range replicas {
+		require.NoError(t, q.Close())
+	}
+	assert.Empty(t, handled, "a held entry is handled once")
+}
@@ -0,0 +1,156 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"github.com/pitgo/backend/internal/infrastructure/logger"
+)
+
+const deadLetterSuffix = ".dlq"
+
+// RetryPolicy controls how a failing handler is retried before its message
+// is moved to the topic's dead-letter queue.
+type RetryPolicy struct {
+	MaxAttempts    int           // Total handler invocations, including the first
+	InitialBackoff time.Duration // Delay before the second attempt
+	MaxBackoff     time.Duration // Upper bound for a single delay
+	Multiplier     float64       // Growth factor between attempts
+	Jitter         float64       // Random spread applied to each delay, 0..1
+}
+
+// DefaultRetryPolicy is used for subscriptions that do not pass WithRetry.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    5,
+	InitialBackoff: 200 * time.Millisecond,
+	MaxBackoff:     30 * time.Second,
+	Multiplier:     2,
+	Jitter:         0.2,
+}
+
+// Backoff returns the delay to wait after the given failed attempt (1-based).
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	if attempt < 1 || p.InitialBackoff <= 0 {
+		return 0
+	}
+	mult := p.Multiplier
+	if mult < 1 {
+		mult = 1
+	}
+	d := float64(p.InitialBackoff) * math.Pow(mult, float64(attempt-1))
+	if p.MaxBackoff > 0 && d > float64(p.MaxBackoff) {
+		d = float64(p.MaxBackoff)
+	}
+	if p.Jitter > 0 {
+		d += d * p.Jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(d)
+}
+
+// SubscribeOption customizes a single subscription.
+type SubscribeOption func(*subscription)
+
+type subscription struct {
+	retry RetryPolicy
+}
+
+func newSubscription(opts []SubscribeOption) subscription {
+	s := subscription{retry: DefaultRetryPolicy}
+	for _, opt := range opts {
+		opt(&s)
+	}
+	if s.retry.MaxAttempts < 1 {
+		s.retry.MaxAttempts = 1
+	}
+	return s
+}
+
+// WithRetry overrides DefaultRetryPolicy for one subscription.
+func WithRetry(p RetryPolicy) SubscribeOption {
+	return func(s *subscription) { s.retry = p }
+}
+
+// DeadLetterTopic returns the topic that receives messages whose handler
+// kept failing on topic.
+func DeadLetterTopic(topic string) string { return topic + deadLetterSuffix }
+
+// IsDeadLetterTopic reports whether topic is a dead-letter topic.
+func IsDeadLetterTopic(topic string) bool { return strings.HasSuffix(topic, deadLetterSuffix) }
+
+// DeadLetter is published to DeadLetterTopic(Topic) once retries are exhausted.
+type DeadLetter struct {
+	Topic     string          `json:"topic"`
+	MessageID string          `json:"message_id,omitempty"`
+	Envelope  json.RawMessage `json:"envelope"` // Original payload, unchanged
+	Error     string          `json:"error"`
+	Attempts  int             `json:"attempts"`
+	FailedAt  time.Time       `json:"failed_at"`
+}
+
+// withRetry wraps h so it is retried according to policy. When every attempt
+// fails the message is published as a DeadLetter and the wrapper returns nil,
+// so the driver treats it as consumed. It only returns an error if the
+// dead-letter publish itself fails.
+func withRetry(pub Publisher, topic string, h Handler, policy RetryPolicy) Handler {
+	return func(ctx context.Context, msg Message) error {
+		var err error
+		for attempt := 1; attempt <= policy.MaxAttempts; attempt++ {
+			if err = h(ctx, msg); err == nil {
+				return nil
+			}
+			if attempt == policy.MaxAttempts || ctx.Err() != nil {
+				break
+			}
+			delay := policy.Backoff(attempt)
+			logger.Ctx(ctx).Warn().Err(err).
+				Str("topic", topic).
+				Int("attempt", attempt).
+				Dur("backoff", delay).
+				Msg("Handler failed, retrying")
+			sleepCtx(ctx, delay)
+		}
+
+		if ctx.Err() != nil {
+			return err
+		}
+		if IsDeadLetterTopic(topic) {
+			logger.Ctx(ctx).Error().Err(err).Str("topic", topic).Msg("Dead-letter handler failed; dropping message")
+			return nil
+		}
+
+		dl := DeadLetter{
+			Topic:     topic,
+			MessageID: msg.ID,
+			Envelope:  rawJSON(msg.Payload),
+			Error:     err.Error(),
+			Attempts:  policy.MaxAttempts,
+			FailedAt:  time.Now(),
+		}
+		data, mErr := json.Marshal(dl)
+		if mErr != nil {
+			return mErr
+		}
+		if pErr := pub.Publish(ctx, DeadLetterTopic(topic), data); pErr != nil {
+			logger.Ctx(ctx).Error().Err(pErr).Str("topic", topic).Msg("Failed to publish dead letter")
+			return pErr
+		}
+		logger.Ctx(ctx).Error().Err(err).
+			Str("topic", topic).
+			Int("attempts", policy.MaxAttempts).
+			Msg("Handler retries exhausted; message dead-lettered")
+		return nil
+	}
+}
+
+// rawJSON keeps JSON payloads verbatim and quotes anything else as a string.
+func rawJSON(payload []byte) json.RawMessage {
+	if json.Valid(payload) {
+		return json.RawMessage(payload)
+	}
+	quoted, _ := json.Marshal(string(payload))
+	return quoted
+}
@@ -0,0 +1,79 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingPublisher struct {
+	topics   []string
+	payloads [][]byte
+}
+
+func (p *recordingPublisher) Publish(_ context.Context, topic string, payload []byte) error {
+	p.topics = append(p.topics, topic)
+	p.payloads = append(p.payloads, payload)
+	return nil
+}
+
+func (p *recordingPublisher) Close() error { return nil }
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	p := RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second, Multiplier: 2}
+
+	assert.Equal(t, 100*time.Millisecond, p.Backoff(1))
+	assert.Equal(t, 200*time.Millisecond, p.Backoff(2))
+	assert.Equal(t, 400*time.Millisecond, p.Backoff(3))
+	assert.Equal(t, time.Second, p.Backoff(10), "capped at MaxBackoff")
+
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		d := p.Backoff(2)
+		assert.GreaterOrEqual(t, d, 100*time.Millisecond)
+		assert.LessOrEqual(t, d, 300*time.Millisecond)
+	}
+}
+
+func TestWithRetry_SucceedsAfterTransientFailure(t *testing.T) {
+	pub := &recordingPublisher{}
+	calls := 0
+	h := withRetry(pub, "request.created", func(context.Context, Message) error {
+		calls++
+		if calls < 3 {
+			return errors.New("transient")
+		}
+		return nil
+	}, RetryPolicy{MaxAttempts: 5})
+
+	require.NoError(t, h(context.Background(), Message{Topic: "request.created"}))
+	assert.Equal(t, 3, calls)
+	assert.Empty(t, pub.topics)
+}
+
+func TestWithRetry_DeadLettersAfterMaxAttempts(t *testing.T) {
+	pub := &recordingPublisher{}
+	envelope := []byte(`{"event_id":"evt-1","topic":"request.created"}`)
+	calls := 0
+	h := withRetry(pub, "request.created", func(context.Context, Message) error {
+		calls++
+		return errors.New("providers lookup failed")
+	}, RetryPolicy{MaxAttempts: 3})
+
+	require.NoError(t, h(context.Background(), Message{ID: "1-0", Topic: "request.created", Payload: envelope}))
+	assert.Equal(t, 3, calls)
+	require.Equal(t, []string{"request.created.dlq"}, pub.topics)
+
+	var dl DeadLetter
+	require.NoError(t, json.Unmarshal(pub.payloads[0], &dl))
+	assert.Equal(t, "request.created", dl.Topic)
+	assert.Equal(t, "1-0", dl.MessageID)
+	assert.Equal(t, "providers lookup failed", dl.Error)
+	assert.Equal(t, 3, dl.Attempts)
+	assert.JSONEq(t, string(envelope), string(dl.Envelope))
+}
@@ -0,0 +1,126 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	HeaderSignature = "X-Pitgo-Signature"
+	HeaderEvent     = "X-Pitgo-Event"
+	HeaderDelivery  = "X-Pitgo-Delivery"
+
+	maxResponseBody = 1 << 10
+)
+
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// Sign returns the signature header for body: "t=<unix>,v1=<hex>", where the
+// hex value is HMAC-SHA256(secret, "<unix>.<body>"). Signing the timestamp
+// lets receivers reject replayed deliveries.
+func Sign(secret string, ts time.Time, body []byte) string {
+	unix := strconv.FormatInt(ts.Unix(), 10)
+	return "t=" + unix + ",v1=" + computeMAC(secret, unix, body)
+}
+
+// Verify checks a signature header produced by Sign. Signatures older than
+// tolerance (relative to now) are rejected; a zero tolerance disables the check.
+func Verify(secret, header string, body []byte, tolerance time.Duration, now time.Time) error {
+	var unix, mac string
+	for _, part := range strings.Split(header, ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		switch k {
+		case "t":
+			unix = v
+		case "v1":
+			mac = v
+		}
+	}
+	ts, err := strconv.ParseInt(unix, 10, 64)
+	if err != nil || mac == "" {
+		return ErrInvalidSignature
+	}
+	if tolerance > 0 && now.Sub(time.Unix(ts, 0)).Abs() > tolerance {
+		return fmt.Errorf("%w: timestamp outside tolerance", ErrInvalidSignature)
+	}
+	if !hmac.Equal([]byte(mac), []byte(computeMAC(secret, unix, body))) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func computeMAC(secret, unix string, body []byte) string {
+	m := hmac.New(sha256.New, []byte(secret))
+	m.Write([]byte(unix))
+	m.Write([]byte("."))
+	m.Write(body)
+	return hex.EncodeToString(m.Sum(nil))
+}
+
+// Request is one signed POST to a partner endpoint.
+type Request struct {
+	URL        string
+	Secret     string
+	Topic      string
+	DeliveryID string
+	Body       []byte
+}
+
+// HTTPError is returned for responses outside the 2xx range.
+type HTTPError struct {
+	Status int
+	Body   string
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("endpoint responded %d: %s", e.Status, e.Body)
+}
+
+// Sender posts signed webhook payloads over HTTP.
+type Sender struct {
+	client *http.Client
+}
+
+func NewSender(timeout time.Duration) *Sender {
+	return &Sender{client: &http.Client{Timeout: timeout}}
+}
+
+// Send posts the request and returns the response status. Non-2xx responses
+// are reported as *HTTPError.
+func (s *Sender) Send(ctx context.Context, r Request) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.URL, bytes.NewReader(r.Body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Pitgo-Webhooks/1.0")
+	req.Header.Set(HeaderEvent, r.Topic)
+	req.Header.Set(HeaderDelivery, r.DeliveryID)
+	req.Header.Set(HeaderSignature, Sign(r.Secret, time.Now(), r.Body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+		return resp.StatusCode, &HTTPError{Status: resp.StatusCode, Body: string(body)}
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseBody))
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,59 @@
+package webhook
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignVerify_RoundTripAndTolerance(t *testing.T) {
+	body := []byte(`{"event_id":"evt-1"}`)
+	ts := time.Unix(1_700_000_000, 0)
+	sig := Sign("whsec_test", ts, body)
+
+	assert.NoError(t, Verify("whsec_test", sig, body, 5*time.Minute, ts.Add(time.Minute)))
+	assert.ErrorIs(t, Verify("whsec_other", sig, body, 5*time.Minute, ts), ErrInvalidSignature)
+	assert.ErrorIs(t, Verify("whsec_test", sig, []byte(`{"event_id":"evt-2"}`), 5*time.Minute, ts), ErrInvalidSignature)
+	assert.ErrorIs(t, Verify("whsec_test", sig, body, 5*time.Minute, ts.Add(10*time.Minute)), ErrInvalidSignature, "replayed too late")
+	assert.ErrorIs(t, Verify("whsec_test", "garbage", body, 0, ts), ErrInvalidSignature)
+}
+
+func TestSender_SignsRequestAndReportsNon2xx(t *testing.T) {
+	status := http.StatusNoContent
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if err := Verify("whsec_test", r.Header.Get(HeaderSignature), body, time.Minute, time.Now()); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		assert.Equal(t, "request.accepted", r.Header.Get(HeaderEvent))
+		assert.Equal(t, "del-1", r.Header.Get(HeaderDelivery))
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte("partner says no"))
+	}))
+	defer srv.Close()
+
+	s := NewSender(time.Second)
+	req := Request{URL: srv.URL, Secret: "whsec_test", Topic: "request.accepted", DeliveryID: "del-1", Body: []byte(`{}`)}
+
+	code, err := s.Send(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, code)
+
+	status = http.StatusServiceUnavailable
+	code, err = s.Send(context.Background(), req)
+	var httpErr *HTTPError
+	require.ErrorAs(t, err, &httpErr)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "partner says no", httpErr.Body)
+
+	req.Secret = "whsec_wrong"
+	code, _ = s.Send(context.Background(), req)
+	assert.Equal(t, http.StatusUnauthorized, code)
+}
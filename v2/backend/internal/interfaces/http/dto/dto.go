@@ -39,6 +39,11 @@ type UpdateProfileRequest struct {
 	AvatarURL string `json:"avatar_url"`
 }
 
+type ReportLocationRequest struct {
+	Latitude  float64 `json:"latitude" binding:"required,min=-90,max=90"`
+	Longitude float64 `json:"longitude" binding:"required,min=-180,max=180"`
+}
+
 // --- Catalog ---
 
 type CreateCategoryRequest struct {
@@ -71,6 +76,10 @@ type CreateServiceRequestDTO struct {
 	TotalPrice  int64     `json:"total_price" binding:"required,min=0"`
 }
 
+type RescheduleRequestDTO struct {
+	ScheduledAt time.Time `json:"scheduled_at" binding:"required"`
+}
+
 type AvailableRequestsQuery struct {
 	Latitude  float64 `form:"lat" binding:"required"`
 	Longitude float64 `form:"lng" binding:"required"`
@@ -89,3 +98,47 @@ type DispatchMatchRequest struct {
 	RadiusKm  float64 `json:"radius_km" binding:"required,min=1"`
 	Category  string  `json:"category" binding:"required"`
 }
+
+// AssignProviderRequest hands a request to a provider, bypassing offers.
+type AssignProviderRequest struct {
+	ProviderID string `json:"provider_id" binding:"required"`
+	Reason     string `json:"reason" binding:"required"`
+}
+
+// InterventionRequest carries why an admin unassigns or redispatches a request.
+type InterventionRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+// --- Dead Letters ---
+
+type DeadLetterQuery struct {
+	Topic           string `form:"topic"`
+	IncludeRequeued bool   `form:"include_requeued"`
+	Limit           int    `form:"limit,default=20"`
+	Offset          int    `form:"offset,default=0"`
+}
+
+// --- Webhooks ---
+
+type CreateWebhookRequest struct {
+	URL         string   `json:"url" binding:"required,url"`
+	Topics      []string `json:"topics" binding:"required,min=1"`
+	CustomerIDs []string `json:"customer_ids" binding:"required,min=1"`
+	Description string   `json:"description"`
+}
+
+type UpdateWebhookRequest struct {
+	URL          *string  `json:"url" binding:"omitempty,url"`
+	Topics       []string `json:"topics" binding:"omitempty,min=1"`
+	CustomerIDs  []string `json:"customer_ids" binding:"omitempty,min=1"`
+	Description  *string  `json:"description"`
+	Active       *bool    `json:"active"`
+	RotateSecret bool     `json:"rotate_secret"`
+}
+
+type WebhookDeliveryQuery struct {
+	Status string `form:"status" binding:"omitempty,oneof=pending succeeded failed"`
+	Limit  int    `form:"limit,default=20"`
+	Offset int    `form:"offset,default=0"`
+}
@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pitgo/backend/internal/domain/deadletter"
+	"github.com/pitgo/backend/internal/interfaces/http/dto"
+	deadletterUC "github.com/pitgo/backend/internal/usecase/deadletter"
+)
+
+type DeadLetterHandler struct {
+	uc *deadletterUC.UseCase
+}
+
+func NewDeadLetterHandler(uc *deadletterUC.UseCase) *DeadLetterHandler {
+	return &DeadLetterHandler{uc: uc}
+}
+
+func (h *DeadLetterHandler) List(c *gin.Context) {
+	var q dto.DeadLetterQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: "validation_error", Message: err.Error()})
+		return
+	}
+	letters, err := h.uc.List(c.Request.Context(), deadletter.ListFilter{
+		Topic:           q.Topic,
+		IncludeRequeued: q.IncludeRequeued,
+		Limit:           q.Limit,
+		Offset:          q.Offset,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: "list_failed", Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"dead_letters": letters, "count": len(letters)})
+}
+
+func (h *DeadLetterHandler) GetByID(c *gin.Context) {
+	dl, err := h.uc.GetByID(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, dto.ErrorResponse{Error: "not_found", Message: "dead letter not found"})
+		return
+	}
+	c.JSON(http.StatusOK, dl)
+}
+
+func (h *DeadLetterHandler) Requeue(c *gin.Context) {
+	dl, err := h.uc.Requeue(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, deadletterUC.ErrAlreadyRequeued) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, dto.ErrorResponse{Error: "requeue_failed", Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, dl)
+}
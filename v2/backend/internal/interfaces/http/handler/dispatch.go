@@ -1,20 +1,23 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"github.com/pitgo/backend/internal/domain/dispatch"
+	"github.com/pitgo/backend/internal/infrastructure/live"
 	"github.com/pitgo/backend/internal/interfaces/http/dto"
 	dispatchUC "github.com/pitgo/backend/internal/usecase/dispatch"
 )
 
 type DispatchHandler struct {
-	uc *dispatchUC.UseCase
+	uc  *dispatchUC.UseCase
+	hub *live.Hub
 }
 
-func NewDispatchHandler(uc *dispatchUC.UseCase) *DispatchHandler {
-	return &DispatchHandler{uc: uc}
+func NewDispatchHandler(uc *dispatchUC.UseCase, hub *live.Hub) *DispatchHandler {
+	return &DispatchHandler{uc: uc, hub: hub}
 }
 
 func (h *DispatchHandler) Match(c *gin.Context) {
@@ -53,18 +56,89 @@ func (h *DispatchHandler) Accept(c *gin.Context) {
 	userID, _ := c.Get("user_id")
 	d, err := h.uc.AcceptDispatch(c.Request.Context(), c.Param("id"), userID.(string))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: "accept_failed", Message: err.Error()})
+		writeDispatchError(c, "accept_failed", err)
 		return
 	}
 	c.JSON(http.StatusOK, d)
 }
 
+// AcceptRequest takes an open request directly; see dispatchUC.AcceptRequest.
+func (h *DispatchHandler) AcceptRequest(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	sr, err := h.uc.AcceptRequest(c.Request.Context(), c.Param("id"), userID.(string))
+	if err != nil {
+		writeDispatchError(c, "accept_failed", err)
+		return
+	}
+	c.JSON(http.StatusOK, sr)
+}
+
 func (h *DispatchHandler) Reject(c *gin.Context) {
 	userID, _ := c.Get("user_id")
 	d, err := h.uc.RejectDispatch(c.Request.Context(), c.Param("id"), userID.(string))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: "reject_failed", Message: err.Error()})
+		writeDispatchError(c, "reject_failed", err)
 		return
 	}
 	c.JSON(http.StatusOK, d)
 }
+
+// PendingOffers lists the provider's open offers, soonest deadline first,
+// with the seconds left to answer each.
+func (h *DispatchHandler) PendingOffers(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	offers, err := h.uc.PendingOffers(c.Request.Context(), userID.(string))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: "fetch_failed", Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"offers": offers, "count": len(offers)})
+}
+
+// StreamOffers streams the provider's offers as Server-Sent Events: an
+// "offer" event for every pending offer, then "offer", "expired" and "taken"
+// events as they happen. An offer sent while the stream opens may come
+// twice; clients key offers by dispatch_id.
+func (h *DispatchHandler) StreamOffers(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	providerID := userID.(string)
+
+	// Subscribe first so no offer slips in between
+	sub := h.hub.Subscribe(live.ProviderKey(providerID))
+	defer sub.Close()
+	offers, err := h.uc.PendingOffers(c.Request.Context(), providerID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: "fetch_failed", Message: err.Error()})
+		return
+	}
+
+	initial := make([]live.Event, len(offers))
+	for i := range offers {
+		initial[i] = live.Event{Type: dispatchUC.NoticeOffer, Data: dispatchUC.OfferNotice{
+			Type:       dispatchUC.NoticeOffer,
+			DispatchID: offers[i].ID,
+			RequestID:  offers[i].RequestID,
+			Offer:      &offers[i],
+		}}
+	}
+	streamEvents(c, initial, sub)
+}
+
+// writeDispatchError answers 409 to providers who lost the race for a request
+// or are at capacity, and 410 for offers past their deadline, so clients can tell both apart from
+// offers that were never theirs to answer.
+func writeDispatchError(c *gin.Context, code string, err error) {
+	status := http.StatusBadRequest
+	switch {
+	case errors.Is(err, dispatchUC.ErrAlreadyTaken):
+		status = http.StatusConflict
+		code = "already_taken"
+	case errors.Is(err, dispatchUC.ErrAtCapacity):
+		status = http.StatusConflict
+		code = "at_capacity"
+	case errors.Is(err, dispatchUC.ErrOfferExpired):
+		status = http.StatusGone
+		code = "offer_expired"
+	}
+	c.JSON(status, dto.ErrorResponse{Error: code, Message: err.Error()})
+}
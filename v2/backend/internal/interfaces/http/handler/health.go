@@ -4,17 +4,24 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/pitgo/backend/internal/infrastructure/queue"
 )
 
-type HealthHandler struct{}
+type HealthHandler struct {
+	queue queue.StatsReporter // optional
+}
 
-func NewHealthHandler() *HealthHandler {
-	return &HealthHandler{}
+func NewHealthHandler(q queue.StatsReporter) *HealthHandler {
+	return &HealthHandler{queue: q}
 }
 
 func (h *HealthHandler) Check(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{
+	resp := gin.H{
 		"status":  "ok",
 		"service": "pitgo-backend",
-	})
+	}
+	if h.queue != nil {
+		resp["queue"] = h.queue.Stats()
+	}
+	c.JSON(http.StatusOK, resp)
 }
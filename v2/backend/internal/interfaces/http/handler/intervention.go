@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pitgo/backend/internal/interfaces/http/dto"
+	"github.com/pitgo/backend/internal/interfaces/http/middleware"
+	dispatchUC "github.com/pitgo/backend/internal/usecase/dispatch"
+)
+
+// InterventionHandler serves the admin overrides of dispatch. The admin's
+// user ID is recorded with every intervention.
+type InterventionHandler struct {
+	uc *dispatchUC.Interventions
+}
+
+func NewInterventionHandler(uc *dispatchUC.Interventions) *InterventionHandler {
+	return &InterventionHandler{uc: uc}
+}
+
+func (h *InterventionHandler) Assign(c *gin.Context) {
+	var req dto.AssignProviderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: "validation_error", Message: err.Error()})
+		return
+	}
+	sr, err := h.uc.Assign(c.Request.Context(), c.Param("id"), req.ProviderID, c.GetString(middleware.ContextKeyUserID), req.Reason)
+	if err != nil {
+		writeInterventionError(c, "assign_failed", err)
+		return
+	}
+	c.JSON(http.StatusOK, sr)
+}
+
+func (h *InterventionHandler) Unassign(c *gin.Context) {
+	var req dto.InterventionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: "validation_error", Message: err.Error()})
+		return
+	}
+	sr, err := h.uc.Unassign(c.Request.Context(), c.Param("id"), c.GetString(middleware.ContextKeyUserID), req.Reason)
+	if err != nil {
+		writeInterventionError(c, "unassign_failed", err)
+		return
+	}
+	c.JSON(http.StatusOK, sr)
+}
+
+// Redispatch answers 202: the next wave is sent by the dispatch worker.
+func (h *InterventionHandler) Redispatch(c *gin.Context) {
+	var req dto.InterventionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: "validation_error", Message: err.Error()})
+		return
+	}
+	sr, err := h.uc.Redispatch(c.Request.Context(), c.Param("id"), c.GetString(middleware.ContextKeyUserID), req.Reason)
+	if err != nil {
+		writeInterventionError(c, "redispatch_failed", err)
+		return
+	}
+	c.JSON(http.StatusAccepted, sr)
+}
+
+func (h *InterventionHandler) List(c *gin.Context) {
+	interventions, err := h.uc.List(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: "list_failed", Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"interventions": interventions, "count": len(interventions)})
+}
+
+// writeInterventionError answers 409 when the request's status doesn't allow
+// the intervention, and 400 for providers that don't exist.
+func writeInterventionError(c *gin.Context, code string, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, dispatchUC.ErrInvalidAction):
+		status = http.StatusConflict
+		code = "invalid_status"
+	case errors.Is(err, dispatchUC.ErrOffersOpen):
+		status = http.StatusConflict
+		code = "offers_open"
+	case errors.Is(err, dispatchUC.ErrUnknownProvider):
+		status = http.StatusBadRequest
+		code = "unknown_provider"
+	}
+	c.JSON(status, dto.ErrorResponse{Error: code, Message: err.Error()})
+}
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -80,3 +81,29 @@ func (h *ProfileHandler) UpdateProfile(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, updated)
 }
+
+func (h *ProfileHandler) ReportLocation(c *gin.Context) {
+	var req dto.ReportLocationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: "validation_error", Message: err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	p, err := h.uc.GetByUserID(c.Request.Context(), userID.(string))
+	if err != nil {
+		c.JSON(http.StatusNotFound, dto.ErrorResponse{Error: "not_found", Message: "profile not found"})
+		return
+	}
+
+	loc, err := h.uc.ReportLocation(c.Request.Context(), p, req.Latitude, req.Longitude)
+	if errors.Is(err, profileUC.ErrNotProvider) {
+		c.JSON(http.StatusForbidden, dto.ErrorResponse{Error: "not_provider", Message: err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: "update_failed", Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, loc)
+}
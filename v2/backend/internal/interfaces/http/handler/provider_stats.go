@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pitgo/backend/internal/domain/providerstats"
+	"github.com/pitgo/backend/internal/interfaces/http/dto"
+	providerstatsUC "github.com/pitgo/backend/internal/usecase/providerstats"
+)
+
+type ProviderStatsHandler struct {
+	uc *providerstatsUC.UseCase
+}
+
+func NewProviderStatsHandler(uc *providerstatsUC.UseCase) *ProviderStatsHandler {
+	return &ProviderStatsHandler{uc: uc}
+}
+
+// providerStatsView adds the derived rates to the stored counters.
+type providerStatsView struct {
+	*providerstats.Stats
+	AcceptanceRate   float64 `json:"acceptance_rate"`
+	ExpiryRate       float64 `json:"expiry_rate"`
+	CancellationRate float64 `json:"cancellation_rate"`
+}
+
+func newProviderStatsView(s *providerstats.Stats) providerStatsView {
+	return providerStatsView{
+		Stats:            s,
+		AcceptanceRate:   s.AcceptanceRate(),
+		ExpiryRate:       s.ExpiryRate(),
+		CancellationRate: s.CancellationRate(),
+	}
+}
+
+func (h *ProviderStatsHandler) List(c *gin.Context) {
+	var q dto.PaginationQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: "validation_error", Message: err.Error()})
+		return
+	}
+	stats, err := h.uc.List(c.Request.Context(), providerstats.ListFilter{Limit: q.Limit, Offset: q.Offset})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: "list_failed", Message: err.Error()})
+		return
+	}
+	views := make([]providerStatsView, len(stats))
+	for i, s := range stats {
+		views[i] = newProviderStatsView(s)
+	}
+	c.JSON(http.StatusOK, gin.H{"provider_stats": views, "count": len(views)})
+}
+
+func (h *ProviderStatsHandler) GetByProviderID(c *gin.Context) {
+	s, err := h.uc.GetByProviderID(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, dto.ErrorResponse{Error: "not_found", Message: "provider stats not found"})
+		return
+	}
+	c.JSON(http.StatusOK, newProviderStatsView(s))
+}
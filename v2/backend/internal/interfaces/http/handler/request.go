@@ -1,21 +1,26 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/pitgo/backend/internal/domain/request"
+	"github.com/pitgo/backend/internal/infrastructure/live"
 	"github.com/pitgo/backend/internal/interfaces/http/dto"
 	"github.com/pitgo/backend/internal/interfaces/http/middleware"
 	requestUC "github.com/pitgo/backend/internal/usecase/request"
 )
 
 type RequestHandler struct {
-	uc *requestUC.UseCase
+	uc      *requestUC.UseCase
+	updates *requestUC.Updates
+	hub     *live.Hub
 }
 
-func NewRequestHandler(uc *requestUC.UseCase) *RequestHandler {
-	return &RequestHandler{uc: uc}
+func NewRequestHandler(uc *requestUC.UseCase, updates *requestUC.Updates, hub *live.Hub) *RequestHandler {
+	return &RequestHandler{uc: uc, updates: updates, hub: hub}
 }
 
 func (h *RequestHandler) CreateRequest(c *gin.Context) {
@@ -82,16 +87,6 @@ func (h *RequestHandler) ListAvailable(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"requests": requests, "count": len(requests)})
 }
 
-func (h *RequestHandler) AcceptRequest(c *gin.Context) {
-	userID, _ := c.Get(middleware.ContextKeyUserID)
-	sr, err := h.uc.AcceptRequest(c.Request.Context(), c.Param("id"), userID.(string))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: "accept_failed", Message: err.Error()})
-		return
-	}
-	c.JSON(http.StatusOK, sr)
-}
-
 func (h *RequestHandler) StartRequest(c *gin.Context) {
 	userID, _ := c.Get(middleware.ContextKeyUserID)
 	sr, err := h.uc.StartRequest(c.Request.Context(), c.Param("id"), userID.(string))
@@ -121,3 +116,57 @@ func (h *RequestHandler) CancelRequest(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, sr)
 }
+
+func (h *RequestHandler) RescheduleRequest(c *gin.Context) {
+	var req dto.RescheduleRequestDTO
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: "validation_error", Message: err.Error()})
+		return
+	}
+	if !req.ScheduledAt.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: "validation_error", Message: "scheduled_at must be in the future"})
+		return
+	}
+
+	userID, _ := c.Get(middleware.ContextKeyUserID)
+	sr, err := h.uc.RescheduleRequest(c.Request.Context(), c.Param("id"), userID.(string), req.ScheduledAt)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: "reschedule_failed", Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, sr)
+}
+
+// StreamUpdates streams the lifecycle of the customer's requests as
+// Server-Sent Events, each with the ID of the event it comes from. A
+// reconnecting client sends the last ID it got in the Last-Event-ID header
+// (or the last_event_id query parameter) to get what it missed first; when
+// that can't be replayed, a "reset" event tells it to refetch its requests.
+func (h *RequestHandler) StreamUpdates(c *gin.Context) {
+	userID, _ := c.Get(middleware.ContextKeyUserID)
+	customerID := userID.(string)
+
+	// Subscribe first so no update slips in between
+	sub := h.hub.Subscribe(live.CustomerKey(customerID))
+	defer sub.Close()
+
+	var initial []live.Event
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
+	}
+	if lastEventID != "" {
+		updates, err := h.updates.Since(c.Request.Context(), customerID, lastEventID)
+		switch {
+		case errors.Is(err, requestUC.ErrCannotResume):
+			initial = append(initial, live.Event{Type: requestUC.UpdateReset, Data: gin.H{"type": requestUC.UpdateReset}})
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: "fetch_failed", Message: err.Error()})
+			return
+		}
+		for _, u := range updates {
+			initial = append(initial, live.Event{ID: u.EventID, Type: u.Type, Data: u})
+		}
+	}
+	streamEvents(c, initial, sub)
+}
@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pitgo/backend/internal/infrastructure/live"
+)
+
+// heartbeatInterval keeps idle streams open through proxies and load balancers.
+const heartbeatInterval = 25 * time.Second
+
+// streamEvents answers with Server-Sent Events: first initial, then the
+// events of sub until the client goes away or sub ends (hub shutdown, or
+// the client fell too far behind and must reconnect). Events of sub already
+// sent in initial, going by ID, are skipped.
+func streamEvents(c *gin.Context, initial []live.Event, sub *live.Subscription) {
+	// Streams outlive the server's WriteTimeout
+	_ = http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	sent := make(map[string]struct{})
+	for _, evt := range initial {
+		if err := writeEvent(c.Writer, evt); err != nil {
+			return
+		}
+		if evt.ID != "" {
+			sent[evt.ID] = struct{}{}
+		}
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case evt, ok := <-sub.Events():
+			if !ok {
+				return
+			}
+			if _, dup := sent[evt.ID]; dup {
+				continue
+			}
+			if err := writeEvent(c.Writer, evt); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := io.WriteString(c.Writer, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		}
+		c.Writer.Flush()
+	}
+}
+
+func writeEvent(w io.Writer, evt live.Event) error {
+	data, err := json.Marshal(evt.Data)
+	if err != nil {
+		return err
+	}
+	if evt.ID != "" {
+		if _, err := fmt.Fprintf(w, "id: %s\n", evt.ID); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", evt.Type, data)
+	return err
+}
@@ -0,0 +1,158 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pitgo/backend/internal/domain/webhook"
+	"github.com/pitgo/backend/internal/interfaces/http/dto"
+	webhookUC "github.com/pitgo/backend/internal/usecase/webhook"
+)
+
+type WebhookHandler struct {
+	uc *webhookUC.UseCase
+}
+
+func NewWebhookHandler(uc *webhookUC.UseCase) *WebhookHandler {
+	return &WebhookHandler{uc: uc}
+}
+
+// webhookWithSecret exposes the signing secret, which is otherwise never
+// serialized. Only returned on create and on secret rotation.
+type webhookWithSecret struct {
+	*webhook.Subscription
+	Secret string `json:"secret"`
+}
+
+func (h *WebhookHandler) Create(c *gin.Context) {
+	var req dto.CreateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: "validation_error", Message: err.Error()})
+		return
+	}
+	sub, err := h.uc.CreateSubscription(c.Request.Context(), req.URL, req.Topics, req.CustomerIDs, req.Description)
+	if err != nil {
+		writeWebhookError(c, "create_failed", err)
+		return
+	}
+	c.JSON(http.StatusCreated, webhookWithSecret{Subscription: sub, Secret: sub.Secret})
+}
+
+func (h *WebhookHandler) List(c *gin.Context) {
+	subs, err := h.uc.ListSubscriptions(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: "list_failed", Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"webhooks": subs, "count": len(subs)})
+}
+
+func (h *WebhookHandler) GetByID(c *gin.Context) {
+	sub, err := h.uc.GetSubscription(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, dto.ErrorResponse{Error: "not_found", Message: "webhook not found"})
+		return
+	}
+	c.JSON(http.StatusOK, sub)
+}
+
+func (h *WebhookHandler) Update(c *gin.Context) {
+	var req dto.UpdateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: "validation_error", Message: err.Error()})
+		return
+	}
+
+	existing, err := h.uc.GetSubscription(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, dto.ErrorResponse{Error: "not_found", Message: "webhook not found"})
+		return
+	}
+
+	if req.URL != nil {
+		existing.URL = *req.URL
+	}
+	if req.Topics != nil {
+		existing.Topics = req.Topics
+	}
+	if req.CustomerIDs != nil {
+		existing.CustomerIDs = req.CustomerIDs
+	}
+	if req.Description != nil {
+		existing.Description = *req.Description
+	}
+	if req.Active != nil {
+		existing.Active = *req.Active
+	}
+
+	sub, err := h.uc.UpdateSubscription(c.Request.Context(), existing, req.RotateSecret)
+	if err != nil {
+		writeWebhookError(c, "update_failed", err)
+		return
+	}
+	if req.RotateSecret {
+		c.JSON(http.StatusOK, webhookWithSecret{Subscription: sub, Secret: sub.Secret})
+		return
+	}
+	c.JSON(http.StatusOK, sub)
+}
+
+func (h *WebhookHandler) Delete(c *gin.Context) {
+	if err := h.uc.DeleteSubscription(c.Request.Context(), c.Param("id")); err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: "delete_failed", Message: err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *WebhookHandler) ListDeliveries(c *gin.Context) {
+	var q dto.WebhookDeliveryQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: "validation_error", Message: err.Error()})
+		return
+	}
+	deliveries, err := h.uc.ListDeliveries(c.Request.Context(), webhook.DeliveryFilter{
+		SubscriptionID: c.Param("id"),
+		Status:         webhook.DeliveryStatus(q.Status),
+		Limit:          q.Limit,
+		Offset:         q.Offset,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: "list_failed", Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"deliveries": deliveries, "count": len(deliveries)})
+}
+
+func (h *WebhookHandler) GetDelivery(c *gin.Context) {
+	d, err := h.uc.GetDelivery(c.Request.Context(), c.Param("id"), c.Param("deliveryId"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, dto.ErrorResponse{Error: "not_found", Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, d)
+}
+
+// Redeliver sends the delivery again synchronously and returns its updated
+// state; a failing endpoint is reported in the body, not as an HTTP error.
+func (h *WebhookHandler) Redeliver(c *gin.Context) {
+	d, err := h.uc.Redeliver(c.Request.Context(), c.Param("id"), c.Param("deliveryId"))
+	if err != nil {
+		writeWebhookError(c, "redeliver_failed", err)
+		return
+	}
+	c.JSON(http.StatusOK, d)
+}
+
+func writeWebhookError(c *gin.Context, code string, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, webhookUC.ErrInvalidURL), errors.Is(err, webhookUC.ErrInvalidTopic), errors.Is(err, webhookUC.ErrNoTopics),
+		errors.Is(err, webhookUC.ErrNoCustomers):
+		status = http.StatusBadRequest
+	case errors.Is(err, webhookUC.ErrDeliveryNotFound):
+		status = http.StatusNotFound
+	}
+	c.JSON(status, dto.ErrorResponse{Error: code, Message: err.Error()})
+}
@@ -37,7 +37,7 @@ func AuthMiddleware(clerkAuth *auth.ClerkAuth) gin.HandlerFunc {
 
 		claims, err := clerkAuth.ValidateToken(tokenString)
 		if err != nil {
-			logger.Warn().Err(err).Msg("JWT validation failed")
+			logger.Ctx(c.Request.Context()).Warn().Err(err).Msg("JWT validation failed")
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
 				"error":   "unauthorized",
 				"message": "invalid or expired token",
@@ -13,7 +13,7 @@ func ErrorHandler() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		defer func() {
 			if err := recover(); err != nil {
-				logger.Error().
+				logger.Ctx(c.Request.Context()).Error().
 					Interface("error", err).
 					Str("stack", string(debug.Stack())).
 					Str("path", c.Request.URL.Path).
@@ -32,7 +32,7 @@ func ErrorHandler() gin.HandlerFunc {
 		// Handle errors set during request processing
 		if len(c.Errors) > 0 {
 			lastErr := c.Errors.Last()
-			logger.Error().
+			logger.Ctx(c.Request.Context()).Error().
 				Err(lastErr.Err).
 				Str("path", c.Request.URL.Path).
 				Str("method", c.Request.Method).
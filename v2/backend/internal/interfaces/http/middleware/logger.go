@@ -19,11 +19,12 @@ func LoggerMiddleware() gin.HandlerFunc {
 		latency := time.Since(start)
 		status := c.Writer.Status()
 
-		event := logger.Info()
+		log := logger.Ctx(c.Request.Context())
+		event := log.Info()
 		if status >= 500 {
-			event = logger.Error()
+			event = log.Error()
 		} else if status >= 400 {
-			event = logger.Warn()
+			event = log.Warn()
 		}
 
 		event.
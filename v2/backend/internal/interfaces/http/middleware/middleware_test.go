@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/gin-gonic/gin"
+	"github.com/pitgo/backend/internal/infrastructure/logger"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -92,3 +93,23 @@ func TestRequireAdmin(t *testing.T) {
 
 	assert.Equal(t, http.StatusOK, w.Code)
 }
+
+func TestRequestIDMiddleware_PropagatesCorrelationID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var seen string
+	r := gin.New()
+	r.Use(RequestIDMiddleware())
+	r.GET("/test", func(c *gin.Context) {
+		seen = logger.CorrelationID(c.Request.Context())
+		c.Status(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/test", nil)
+	req.Header.Set("X-Request-ID", "req-abc")
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, "req-abc", seen)
+	assert.Equal(t, "req-abc", w.Header().Get("X-Request-ID"))
+}
@@ -3,11 +3,14 @@ package middleware
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/pitgo/backend/internal/infrastructure/logger"
 )
 
 const ContextKeyRequestID = "request_id"
 
 // RequestIDMiddleware injects a unique request ID into the context and response header.
+// The ID is also stored on the request context as the correlation ID, so it is
+// logged by every layer and stamped on the events the request produces.
 func RequestIDMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		requestID := c.GetHeader("X-Request-ID")
@@ -15,6 +18,7 @@ func RequestIDMiddleware() gin.HandlerFunc {
 			requestID = uuid.New().String()
 		}
 		c.Set(ContextKeyRequestID, requestID)
+		c.Request = c.Request.WithContext(logger.WithCorrelationID(c.Request.Context(), requestID))
 		c.Header("X-Request-ID", requestID)
 		c.Next()
 	}
@@ -8,12 +8,16 @@ import (
 )
 
 type Handlers struct {
-	Health   *handler.HealthHandler
-	Identity *handler.IdentityHandler
-	Profile  *handler.ProfileHandler
-	Catalog  *handler.CatalogHandler
-	Request  *handler.RequestHandler
-	Dispatch *handler.DispatchHandler
+	Health        *handler.HealthHandler
+	Identity      *handler.IdentityHandler
+	Profile       *handler.ProfileHandler
+	Catalog       *handler.CatalogHandler
+	Request       *handler.RequestHandler
+	Dispatch      *handler.DispatchHandler
+	Intervention  *handler.InterventionHandler
+	DeadLetter    *handler.DeadLetterHandler
+	Webhook       *handler.WebhookHandler
+	ProviderStats *handler.ProviderStatsHandler
 }
 
 func Setup(r *gin.Engine, clerkAuth *auth.ClerkAuth, rlCfg middleware.RateLimiterConfig, h Handlers) {
@@ -60,7 +64,9 @@ func Setup(r *gin.Engine, clerkAuth *auth.ClerkAuth, rlCfg middleware.RateLimite
 		{
 			customerRoutes.POST("/requests", h.Request.CreateRequest)
 			customerRoutes.GET("/requests", h.Request.ListByCustomer)
+			customerRoutes.GET("/requests/stream", h.Request.StreamUpdates)
 			customerRoutes.POST("/requests/:id/cancel", h.Request.CancelRequest)
+			customerRoutes.POST("/requests/:id/reschedule", h.Request.RescheduleRequest)
 		}
 
 		// Provider routes
@@ -68,11 +74,14 @@ func Setup(r *gin.Engine, clerkAuth *auth.ClerkAuth, rlCfg middleware.RateLimite
 		providerRoutes.Use(middleware.RequireRole("provider", "admin"))
 		{
 			providerRoutes.GET("/requests/available", h.Request.ListAvailable)
-			providerRoutes.POST("/requests/:id/accept", h.Request.AcceptRequest)
+			providerRoutes.POST("/requests/:id/accept", h.Dispatch.AcceptRequest)
 			providerRoutes.POST("/requests/:id/start", h.Request.StartRequest)
 			providerRoutes.POST("/requests/:id/complete", h.Request.CompleteRequest)
+			providerRoutes.GET("/dispatches/pending", h.Dispatch.PendingOffers)
+			providerRoutes.GET("/dispatches/stream", h.Dispatch.StreamOffers)
 			providerRoutes.POST("/dispatches/:id/accept", h.Dispatch.Accept)
 			providerRoutes.POST("/dispatches/:id/reject", h.Dispatch.Reject)
+			providerRoutes.PUT("/providers/me/location", h.Profile.ReportLocation)
 		}
 
 		// Admin routes
@@ -84,6 +93,23 @@ func Setup(r *gin.Engine, clerkAuth *auth.ClerkAuth, rlCfg middleware.RateLimite
 			adminRoutes.POST("/catalog/categories", h.Catalog.CreateCategory)
 			adminRoutes.POST("/catalog/services", h.Catalog.CreateService)
 			adminRoutes.POST("/dispatch/match", h.Dispatch.Match)
+			adminRoutes.POST("/requests/:id/assign", h.Intervention.Assign)
+			adminRoutes.POST("/requests/:id/unassign", h.Intervention.Unassign)
+			adminRoutes.POST("/requests/:id/redispatch", h.Intervention.Redispatch)
+			adminRoutes.GET("/requests/:id/interventions", h.Intervention.List)
+			adminRoutes.GET("/providers/stats", h.ProviderStats.List)
+			adminRoutes.GET("/providers/:id/stats", h.ProviderStats.GetByProviderID)
+			adminRoutes.GET("/dead-letters", h.DeadLetter.List)
+			adminRoutes.GET("/dead-letters/:id", h.DeadLetter.GetByID)
+			adminRoutes.POST("/dead-letters/:id/requeue", h.DeadLetter.Requeue)
+			adminRoutes.POST("/webhooks", h.Webhook.Create)
+			adminRoutes.GET("/webhooks", h.Webhook.List)
+			adminRoutes.GET("/webhooks/:id", h.Webhook.GetByID)
+			adminRoutes.PUT("/webhooks/:id", h.Webhook.Update)
+			adminRoutes.DELETE("/webhooks/:id", h.Webhook.Delete)
+			adminRoutes.GET("/webhooks/:id/deliveries", h.Webhook.ListDeliveries)
+			adminRoutes.GET("/webhooks/:id/deliveries/:deliveryId", h.Webhook.GetDelivery)
+			adminRoutes.POST("/webhooks/:id/deliveries/:deliveryId/redeliver", h.Webhook.Redeliver)
 		}
 	}
 }
@@ -0,0 +1,25 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pitgo/backend/internal/infrastructure/database"
+)
+
+// querier is the subset of pgxpool.Pool and pgx.Tx used by the repositories.
+type querier interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// conn returns the transaction carried by ctx, falling back to the pool.
+func conn(ctx context.Context, pool *pgxpool.Pool) querier {
+	if tx, ok := database.TxFromContext(ctx); ok {
+		return tx
+	}
+	return pool
+}
@@ -0,0 +1,92 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	domain "github.com/pitgo/backend/internal/domain/deadletter"
+)
+
+type DeadLetterRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewDeadLetterRepository(pool *pgxpool.Pool) *DeadLetterRepository {
+	return &DeadLetterRepository{pool: pool}
+}
+
+const deadLetterColumns = `id, topic, message_id, event_id, correlation_id, envelope, error, attempts, failed_at, requeued_at, created_at`
+
+func scanDeadLetter(scanner interface{ Scan(dest ...any) error }) (*domain.DeadLetter, error) {
+	var dl domain.DeadLetter
+	err := scanner.Scan(
+		&dl.ID, &dl.Topic, &dl.MessageID, &dl.EventID, &dl.CorrelationID, &dl.Envelope,
+		&dl.Error, &dl.Attempts, &dl.FailedAt, &dl.RequeuedAt, &dl.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &dl, nil
+}
+
+func (r *DeadLetterRepository) Create(ctx context.Context, dl *domain.DeadLetter) (bool, error) {
+	query := `INSERT INTO dead_letters (id, topic, message_id, event_id, correlation_id, envelope, error, attempts, failed_at, created_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+			  ON CONFLICT (topic, event_id) WHERE event_id <> '' AND requeued_at IS NULL DO NOTHING`
+	tag, err := conn(ctx, r.pool).Exec(ctx, query,
+		dl.ID, dl.Topic, dl.MessageID, dl.EventID, dl.CorrelationID, []byte(dl.Envelope),
+		dl.Error, dl.Attempts, dl.FailedAt, dl.CreatedAt,
+	)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+func (r *DeadLetterRepository) GetByID(ctx context.Context, id string) (*domain.DeadLetter, error) {
+	query := fmt.Sprintf(`SELECT %s FROM dead_letters WHERE id = $1`, deadLetterColumns)
+	return scanDeadLetter(conn(ctx, r.pool).QueryRow(ctx, query, id))
+}
+
+func (r *DeadLetterRepository) List(ctx context.Context, f domain.ListFilter) ([]*domain.DeadLetter, error) {
+	query := fmt.Sprintf(`SELECT %s FROM dead_letters WHERE 1 = 1`, deadLetterColumns)
+	var args []any
+	argIdx := 1
+	if f.Topic != "" {
+		query += fmt.Sprintf(" AND topic = $%d", argIdx)
+		args = append(args, f.Topic)
+		argIdx++
+	}
+	if !f.IncludeRequeued {
+		query += " AND requeued_at IS NULL"
+	}
+	query += fmt.Sprintf(" ORDER BY failed_at DESC LIMIT $%d OFFSET $%d", argIdx, argIdx+1)
+	args = append(args, f.Limit, f.Offset)
+
+	rows, err := conn(ctx, r.pool).Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var letters []*domain.DeadLetter
+	for rows.Next() {
+		dl, err := scanDeadLetter(rows)
+		if err != nil {
+			return nil, err
+		}
+		letters = append(letters, dl)
+	}
+	return letters, nil
+}
+
+func (r *DeadLetterRepository) MarkRequeued(ctx context.Context, id string, at time.Time) (bool, error) {
+	query := `UPDATE dead_letters SET requeued_at = $2 WHERE id = $1 AND requeued_at IS NULL`
+	tag, err := conn(ctx, r.pool).Exec(ctx, query, id, at)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
@@ -0,0 +1,45 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	domain "github.com/pitgo/backend/internal/domain/deadletter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeadLetterCreate_StoresOneOpenLetterPerEvent(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+	repo := NewDeadLetterRepository(pool)
+	letter := func(eventID string) *domain.DeadLetter {
+		return &domain.DeadLetter{
+			ID: uuid.New().String(), Topic: "request.created", EventID: eventID,
+			Envelope: json.RawMessage(`{}`), Error: "boom", Attempts: 3, FailedAt: time.Now(), CreatedAt: time.Now(),
+		}
+	}
+
+	first := letter("evt-1")
+	stored, err := repo.Create(ctx, first)
+	require.NoError(t, err)
+	assert.True(t, stored)
+	stored, err = repo.Create(ctx, letter("evt-1"))
+	require.NoError(t, err)
+	assert.False(t, stored, "a redelivered dead letter is not stored twice")
+
+	_, err = repo.MarkRequeued(ctx, first.ID, time.Now())
+	require.NoError(t, err)
+	stored, err = repo.Create(ctx, letter("evt-1"))
+	require.NoError(t, err)
+	assert.True(t, stored, "a requeued event that fails again is stored again")
+
+	for range 2 {
+		stored, err = repo.Create(ctx, letter(""))
+		require.NoError(t, err)
+		assert.True(t, stored, "letters without an event ID are always stored")
+	}
+}
@@ -2,6 +2,7 @@ package postgres
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 	domain "github.com/pitgo/backend/internal/domain/dispatch"
@@ -15,32 +16,72 @@ func NewDispatchRepository(pool *pgxpool.Pool) *DispatchRepository {
 	return &DispatchRepository{pool: pool}
 }
 
-func (r *DispatchRepository) Create(ctx context.Context, d *domain.Dispatch) error {
-	query := `INSERT INTO dispatches (id, request_id, provider_id, status, distance_km, expires_at, created_at, updated_at)
-			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
-	_, err := r.pool.Exec(ctx, query, d.ID, d.RequestID, d.ProviderID, d.Status, d.Distance, d.ExpiresAt, d.CreatedAt, d.UpdatedAt)
-	return err
-}
+const dispatchColumns = `id, request_id, provider_id, status, distance_km, wave, expires_at, created_at, updated_at`
 
-func (r *DispatchRepository) GetByID(ctx context.Context, id string) (*domain.Dispatch, error) {
-	query := `SELECT id, request_id, provider_id, status, distance_km, expires_at, created_at, updated_at FROM dispatches WHERE id = $1`
+func scanDispatch(scanner interface{ Scan(dest ...any) error }) (*domain.Dispatch, error) {
 	var d domain.Dispatch
-	err := r.pool.QueryRow(ctx, query, id).Scan(&d.ID, &d.RequestID, &d.ProviderID, &d.Status, &d.Distance, &d.ExpiresAt, &d.CreatedAt, &d.UpdatedAt)
+	err := scanner.Scan(&d.ID, &d.RequestID, &d.ProviderID, &d.Status, &d.Distance, &d.Wave, &d.ExpiresAt, &d.CreatedAt, &d.UpdatedAt)
 	if err != nil {
 		return nil, err
 	}
 	return &d, nil
 }
 
+func (r *DispatchRepository) Create(ctx context.Context, d *domain.Dispatch) error {
+	if d.Wave == 0 {
+		d.Wave = 1
+	}
+	query := `INSERT INTO dispatches (id, request_id, provider_id, status, distance_km, wave, expires_at, created_at, updated_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+	_, err := conn(ctx, r.pool).Exec(ctx, query, d.ID, d.RequestID, d.ProviderID, d.Status, d.Distance, d.Wave, d.ExpiresAt, d.CreatedAt, d.UpdatedAt)
+	return err
+}
+
+func (r *DispatchRepository) GetByID(ctx context.Context, id string) (*domain.Dispatch, error) {
+	query := fmt.Sprintf(`SELECT %s FROM dispatches WHERE id = $1`, dispatchColumns)
+	return scanDispatch(conn(ctx, r.pool).QueryRow(ctx, query, id))
+}
+
+// GetByIDForUpdate locks the offer until the caller's transaction ends.
+func (r *DispatchRepository) GetByIDForUpdate(ctx context.Context, id string) (*domain.Dispatch, error) {
+	query := fmt.Sprintf(`SELECT %s FROM dispatches WHERE id = $1 FOR UPDATE`, dispatchColumns)
+	return scanDispatch(conn(ctx, r.pool).QueryRow(ctx, query, id))
+}
+
 func (r *DispatchRepository) Update(ctx context.Context, d *domain.Dispatch) error {
 	query := `UPDATE dispatches SET status = $2, updated_at = $3 WHERE id = $1`
-	_, err := r.pool.Exec(ctx, query, d.ID, d.Status, d.UpdatedAt)
+	_, err := conn(ctx, r.pool).Exec(ctx, query, d.ID, d.Status, d.UpdatedAt)
 	return err
 }
 
 func (r *DispatchRepository) GetByRequestID(ctx context.Context, requestID string) ([]*domain.Dispatch, error) {
-	query := `SELECT id, request_id, provider_id, status, distance_km, expires_at, created_at, updated_at FROM dispatches WHERE request_id = $1`
-	rows, err := r.pool.Query(ctx, query, requestID)
+	query := fmt.Sprintf(`SELECT %s FROM dispatches WHERE request_id = $1 ORDER BY created_at`, dispatchColumns)
+	return r.query(ctx, query, requestID)
+}
+
+func (r *DispatchRepository) LockByRequestID(ctx context.Context, requestID string) ([]*domain.Dispatch, error) {
+	query := fmt.Sprintf(`SELECT %s FROM dispatches WHERE request_id = $1 ORDER BY created_at FOR UPDATE`, dispatchColumns)
+	return r.query(ctx, query, requestID)
+}
+
+func (r *DispatchRepository) GetPendingByProvider(ctx context.Context, providerID string) ([]*domain.Dispatch, error) {
+	// Offers of a request cancelled a moment ago stay open until the
+	// dispatch worker withdraws them
+	query := fmt.Sprintf(`SELECT %s FROM dispatches
+			  WHERE provider_id = $1 AND status IN ('pending', 'sent') AND expires_at > NOW()
+			    AND EXISTS (SELECT 1 FROM service_requests sr WHERE sr.id = dispatches.request_id AND sr.status = 'open')`, dispatchColumns)
+	return r.query(ctx, query, providerID)
+}
+
+func (r *DispatchRepository) ExpireOld(ctx context.Context) ([]*domain.Dispatch, error) {
+	query := fmt.Sprintf(`UPDATE dispatches SET status = 'expired', updated_at = NOW()
+			  WHERE status IN ('pending', 'sent') AND expires_at <= NOW()
+			  RETURNING %s`, dispatchColumns)
+	return r.query(ctx, query)
+}
+
+func (r *DispatchRepository) query(ctx context.Context, query string, args ...any) ([]*domain.Dispatch, error) {
+	rows, err := conn(ctx, r.pool).Query(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -48,39 +89,129 @@ func (r *DispatchRepository) GetByRequestID(ctx context.Context, requestID strin
 
 	var dispatches []*domain.Dispatch
 	for rows.Next() {
-		var d domain.Dispatch
-		if err := rows.Scan(&d.ID, &d.RequestID, &d.ProviderID, &d.Status, &d.Distance, &d.ExpiresAt, &d.CreatedAt, &d.UpdatedAt); err != nil {
+		d, err := scanDispatch(rows)
+		if err != nil {
 			return nil, err
 		}
-		dispatches = append(dispatches, &d)
+		dispatches = append(dispatches, d)
 	}
 	return dispatches, nil
 }
 
-func (r *DispatchRepository) GetPendingByProvider(ctx context.Context, providerID string) ([]*domain.Dispatch, error) {
-	query := `SELECT id, request_id, provider_id, status, distance_km, expires_at, created_at, updated_at FROM dispatches WHERE provider_id = $1 AND status = 'pending' AND expires_at > NOW()`
-	rows, err := r.pool.Query(ctx, query, providerID)
+// LockProvider takes a transaction-level advisory lock keyed by the provider,
+// which leaves their profile rows free for location and availability updates.
+func (r *DispatchRepository) LockProvider(ctx context.Context, providerID string) error {
+	_, err := conn(ctx, r.pool).Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext('provider:' || $1))`, providerID)
+	return err
+}
+
+func (r *DispatchRepository) ProviderSignals(ctx context.Context, providerIDs []string) (map[string]domain.ProviderSignals, error) {
+	query := `SELECT p.id::text,
+			         COALESCE(s.offers_accepted + s.offers_rejected + s.offers_expired, 0),
+			         COALESCE(s.offers_accepted, 0),
+			         COALESCE(s.median_response_ms, 0),
+			         COALESCE(s.jobs_accepted, 0),
+			         COALESCE(s.cancelled_after_accept, 0),
+			         (SELECT COUNT(*) FROM dispatches d
+			          WHERE d.provider_id = p.id AND d.status IN ('pending', 'sent') AND d.expires_at > NOW())::int,
+			         (SELECT COUNT(*) FROM service_requests sr
+			          WHERE sr.provider_id = p.id
+			            AND (sr.status = 'in_progress' OR sr.status = 'accepted' AND sr.scheduled_at <= NOW()))::int,
+			         (SELECT MIN(sr.scheduled_at) FROM service_requests sr
+			          WHERE sr.provider_id = p.id AND sr.status = 'accepted' AND sr.scheduled_at > NOW())
+			  FROM unnest($1::uuid[]) AS p(id)
+			  LEFT JOIN provider_stats s ON s.provider_id = p.id`
+	rows, err := conn(ctx, r.pool).Query(ctx, query, providerIDs)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var dispatches []*domain.Dispatch
+	signals := make(map[string]domain.ProviderSignals, len(providerIDs))
 	for rows.Next() {
-		var d domain.Dispatch
-		if err := rows.Scan(&d.ID, &d.RequestID, &d.ProviderID, &d.Status, &d.Distance, &d.ExpiresAt, &d.CreatedAt, &d.UpdatedAt); err != nil {
+		var id string
+		var s domain.ProviderSignals
+		err := rows.Scan(&id, &s.Answered, &s.Accepted, &s.MedianResponseMs, &s.JobsAccepted,
+			&s.CancelledAfterAccept, &s.OpenOffers, &s.ActiveJobs, &s.NextJobAt)
+		if err != nil {
 			return nil, err
 		}
-		dispatches = append(dispatches, &d)
+		signals[id] = s
 	}
-	return dispatches, nil
+	return signals, rows.Err()
 }
 
-func (r *DispatchRepository) ExpireOld(ctx context.Context) (int64, error) {
-	query := `UPDATE dispatches SET status = 'expired', updated_at = NOW() WHERE status = 'pending' AND expires_at <= NOW()`
-	tag, err := r.pool.Exec(ctx, query)
+// --- Waves ---
+
+func (r *DispatchRepository) LockWave(ctx context.Context, requestID string) (*domain.WaveState, error) {
+	q := conn(ctx, r.pool)
+	if _, err := q.Exec(ctx, `INSERT INTO dispatch_waves (request_id) VALUES ($1) ON CONFLICT DO NOTHING`, requestID); err != nil {
+		return nil, err
+	}
+	var w domain.WaveState
+	err := q.QueryRow(ctx,
+		`SELECT request_id, wave, radius_km, exhausted_at, updated_at FROM dispatch_waves WHERE request_id = $1 FOR UPDATE`,
+		requestID,
+	).Scan(&w.RequestID, &w.Wave, &w.RadiusKm, &w.ExhaustedAt, &w.UpdatedAt)
 	if err != nil {
-		return 0, err
+		return nil, err
+	}
+	return &w, nil
+}
+
+func (r *DispatchRepository) SaveWave(ctx context.Context, w *domain.WaveState) error {
+	query := `UPDATE dispatch_waves SET wave = $2, radius_km = $3, exhausted_at = $4, updated_at = $5 WHERE request_id = $1`
+	_, err := conn(ctx, r.pool).Exec(ctx, query, w.RequestID, w.Wave, w.RadiusKm, w.ExhaustedAt, w.UpdatedAt)
+	return err
+}
+
+func (r *DispatchRepository) ListStalled(ctx context.Context, limit int) ([]string, error) {
+	query := `SELECT w.request_id FROM dispatch_waves w
+			  JOIN service_requests sr ON sr.id = w.request_id AND sr.status = 'open'
+			  WHERE w.exhausted_at IS NULL
+			    AND NOT EXISTS (
+			        SELECT 1 FROM dispatches d
+			        WHERE d.request_id = w.request_id AND d.status IN ('pending', 'sent', 'accepted')
+			    )
+			  ORDER BY w.updated_at
+			  LIMIT $1`
+	return r.requestIDs(ctx, query, limit)
+}
+
+// ListStalledFor measures distances from the provider's last reported
+// location, else their profile's; an unset service area leaves maxRadiusKm.
+func (r *DispatchRepository) ListStalledFor(ctx context.Context, providerID string, maxRadiusKm float64, limit int) ([]string, error) {
+	query := `SELECT w.request_id FROM dispatch_waves w
+			  JOIN service_requests sr ON sr.id = w.request_id AND sr.status = 'open'
+			  JOIN provider_details p ON p.profile_id = $1 AND sr.category = ANY(p.categories)
+			  LEFT JOIN provider_locations l ON l.provider_id = p.profile_id
+			  WHERE w.exhausted_at IS NULL
+			    AND NOT EXISTS (
+			        SELECT 1 FROM dispatches d
+			        WHERE d.request_id = w.request_id
+			          AND (d.status IN ('pending', 'sent', 'accepted') OR d.provider_id = $1)
+			    )
+			    AND ` + distanceSQL("sr.latitude::float8", "sr.longitude::float8",
+		"COALESCE(l.latitude, p.latitude::float8)", "COALESCE(l.longitude, p.longitude::float8)") + ` <= LEAST($2, NULLIF(p.service_area::float8, 0))
+			  ORDER BY w.updated_at
+			  LIMIT $3`
+	return r.requestIDs(ctx, query, providerID, maxRadiusKm, limit)
+}
+
+func (r *DispatchRepository) requestIDs(ctx context.Context, query string, args ...any) ([]string, error) {
+	rows, err := conn(ctx, r.pool).Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
 	}
-	return tag.RowsAffected(), nil
+	return ids, rows.Err()
 }
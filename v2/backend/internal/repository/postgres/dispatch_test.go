@@ -0,0 +1,55 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListStalledFor_OnlyRequestsTheProviderCanTake(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+	exec := func(sql string, args ...any) {
+		t.Helper()
+		_, err := pool.Exec(ctx, sql, args...)
+		require.NoError(t, err)
+	}
+	profile := func(kind string) string {
+		userID, profileID := uuid.New().String(), uuid.New().String()
+		exec(`INSERT INTO users (id, clerk_id, email, role) VALUES ($1, $1, $1 || '@pitgo.test', $2)`, userID, kind)
+		exec(`INSERT INTO profiles (id, user_id, type, first_name, last_name, phone) VALUES ($1, $2, $3, 'Test', 'User', '0')`, profileID, userID, kind)
+		return profileID
+	}
+
+	customerID, providerID := profile("customer"), profile("provider")
+	exec(`INSERT INTO provider_details (profile_id, categories, service_area, latitude, longitude) VALUES ($1, '{plumbing}', 10, 0, 0)`, providerID)
+	categoryID, serviceID := uuid.New().String(), uuid.New().String()
+	exec(`INSERT INTO categories (id, name, slug) VALUES ($1, 'Plumbing', $1)`, categoryID)
+	exec(`INSERT INTO services (id, category_id, name, slug) VALUES ($1, $2, 'Leak', $1)`, serviceID, categoryID)
+	stalled := func(category string, lat float64) string {
+		id := uuid.New().String()
+		exec(`INSERT INTO service_requests (id, customer_id, service_id, category, status, scheduled_at, latitude, longitude)
+		      VALUES ($1, $2, $3, $4, 'open', NOW(), $5, 0)`, id, customerID, serviceID, category, lat)
+		exec(`INSERT INTO dispatch_waves (request_id, wave, radius_km) VALUES ($1, 1, 10)`, id)
+		return id
+	}
+
+	near := stalled("plumbing", 0.05)
+	stalled("cleaning", 0.05)
+	stalled("plumbing", 0.5) // About 55 km away, outside the service area
+	offered := stalled("plumbing", 0.05)
+	exec(`INSERT INTO dispatches (id, request_id, provider_id, status, expires_at) VALUES ($1, $2, $3, 'rejected', NOW())`,
+		uuid.New().String(), offered, providerID)
+
+	repo := NewDispatchRepository(pool)
+	ids, err := repo.ListStalledFor(ctx, providerID, 30, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{near}, ids)
+
+	all, err := repo.ListStalled(ctx, 10)
+	require.NoError(t, err)
+	assert.Len(t, all, 4)
+}
@@ -0,0 +1,91 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	domain "github.com/pitgo/backend/internal/domain/eventlog"
+)
+
+type EventLogRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewEventLogRepository(pool *pgxpool.Pool) *EventLogRepository {
+	return &EventLogRepository{pool: pool}
+}
+
+func (r *EventLogRepository) Append(ctx context.Context, e *domain.Entry) error {
+	query := `INSERT INTO event_log (event_id, topic, correlation_id, aggregate_id, schema_version, envelope, occurred_at, recorded_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			  ON CONFLICT (event_id) DO NOTHING`
+	_, err := conn(ctx, r.pool).Exec(ctx, query,
+		e.EventID, e.Topic, e.CorrelationID, e.AggregateID, e.SchemaVersion, []byte(e.Envelope), e.OccurredAt, e.RecordedAt,
+	)
+	return err
+}
+
+func (r *EventLogRepository) Query(ctx context.Context, f domain.Filter) ([]*domain.Entry, error) {
+	query := `SELECT seq, event_id, topic, correlation_id, aggregate_id, schema_version, envelope, occurred_at, recorded_at
+			  FROM event_log WHERE seq > $1`
+	args := []any{f.AfterSeq}
+	argIdx := 2
+
+	if len(f.Topics) > 0 {
+		query += fmt.Sprintf(" AND topic = ANY($%d)", argIdx)
+		args = append(args, f.Topics)
+		argIdx++
+	}
+	if f.EventID != "" {
+		query += fmt.Sprintf(" AND event_id = $%d", argIdx)
+		args = append(args, f.EventID)
+		argIdx++
+	}
+	if f.CorrelationID != "" {
+		query += fmt.Sprintf(" AND correlation_id = $%d", argIdx)
+		args = append(args, f.CorrelationID)
+		argIdx++
+	}
+	if f.AggregateID != "" {
+		query += fmt.Sprintf(" AND aggregate_id = $%d", argIdx)
+		args = append(args, f.AggregateID)
+		argIdx++
+	}
+	if len(f.AggregateIDs) > 0 {
+		query += fmt.Sprintf(" AND aggregate_id = ANY($%d)", argIdx)
+		args = append(args, f.AggregateIDs)
+		argIdx++
+	}
+	if !f.From.IsZero() {
+		query += fmt.Sprintf(" AND occurred_at >= $%d", argIdx)
+		args = append(args, f.From)
+		argIdx++
+	}
+	if !f.To.IsZero() {
+		query += fmt.Sprintf(" AND occurred_at < $%d", argIdx)
+		args = append(args, f.To)
+		argIdx++
+	}
+	query += " ORDER BY seq ASC"
+	if f.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT $%d", argIdx)
+		args = append(args, f.Limit)
+	}
+
+	rows, err := conn(ctx, r.pool).Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []*domain.Entry
+	for rows.Next() {
+		var e domain.Entry
+		if err := rows.Scan(&e.Seq, &e.EventID, &e.Topic, &e.CorrelationID, &e.AggregateID, &e.SchemaVersion, &e.Envelope, &e.OccurredAt, &e.RecordedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, &e)
+	}
+	return entries, nil
+}
@@ -0,0 +1,34 @@
+package postgres
+
+import (
+	"fmt"
+
+	"github.com/pitgo/backend/internal/domain/geo"
+)
+
+// distanceSQL is the great-circle distance in km from (lat, lng) to
+// (latCol, lngCol), by the spherical law of cosines. The cosine is clamped
+// to [-1, 1]: rounding can push it past 1 for nearby points, where acos
+// would return NaN.
+func distanceSQL(lat, lng, latCol, lngCol string) string {
+	return fmt.Sprintf(`(%[5]d * acos(GREATEST(-1, LEAST(1, cos(radians(%[1]s)) * cos(radians(%[3]s)) * cos(radians(%[4]s) - radians(%[2]s)) + sin(radians(%[1]s)) * sin(radians(%[3]s))))))`,
+		lat, lng, latCol, lngCol, geo.EarthRadiusKm)
+}
+
+// nearSQL is a condition that (latCol, lngCol) lies in box and, unless
+// cellCol is empty, that cellCol is one of the grid cells covering it, so an
+// index on cellCol can serve it. Its arguments are numbered from argIdx.
+func nearSQL(box geo.Box, latCol, lngCol, cellCol string, argIdx int) (string, []any) {
+	cond := fmt.Sprintf("%s BETWEEN $%d AND $%d", latCol, argIdx, argIdx+1)
+	args := []any{box.MinLat, box.MaxLat, box.MinLng, box.MaxLng}
+	if box.Wraps() {
+		cond += fmt.Sprintf(" AND (%s >= $%d OR %s <= $%d)", lngCol, argIdx+2, lngCol, argIdx+3)
+	} else {
+		cond += fmt.Sprintf(" AND %s BETWEEN $%d AND $%d", lngCol, argIdx+2, argIdx+3)
+	}
+	if cells := box.Cells(); cellCol != "" && cells != nil {
+		cond = fmt.Sprintf("%s = ANY($%d::int[]) AND ", cellCol, argIdx+4) + cond
+		args = append(args, cells)
+	}
+	return cond, args
+}
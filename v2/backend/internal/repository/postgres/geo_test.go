@@ -0,0 +1,184 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testPool connects to TEST_DATABASE_URL with a fresh schema holding every
+// migration. Tests using it are skipped when the variable is unset.
+func testPool(tb testing.TB) *pgxpool.Pool {
+	tb.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		tb.Skip("TEST_DATABASE_URL not set")
+	}
+	ctx := context.Background()
+
+	admin, err := pgxpool.New(ctx, dsn)
+	require.NoError(tb, err)
+	tb.Cleanup(admin.Close)
+	schema := fmt.Sprintf("test_geo_%d", time.Now().UnixNano())
+	_, err = admin.Exec(ctx, "CREATE SCHEMA "+schema)
+	require.NoError(tb, err)
+	tb.Cleanup(func() { _, _ = admin.Exec(context.Background(), "DROP SCHEMA "+schema+" CASCADE") })
+
+	cfg, err := pgxpool.ParseConfig(dsn)
+	require.NoError(tb, err)
+	cfg.ConnConfig.RuntimeParams["search_path"] = schema
+	pool, err := pgxpool.NewWithConfig(ctx, cfg)
+	require.NoError(tb, err)
+	tb.Cleanup(pool.Close)
+
+	files, err := filepath.Glob("../../../migrations/*.up.sql")
+	require.NoError(tb, err)
+	for _, file := range files {
+		sql, err := os.ReadFile(file)
+		require.NoError(tb, err)
+		_, err = pool.Exec(ctx, string(sql))
+		require.NoError(tb, err, file)
+	}
+	return pool
+}
+
+// seedLocations creates n open plumbing requests and n plumbing providers,
+// a third of them in São Paulo and the rest spread over Brazil.
+func seedLocations(tb testing.TB, pool *pgxpool.Pool, n int) {
+	tb.Helper()
+	ctx := context.Background()
+	// One session, for the random seed and the temporary table
+	c, err := pool.Acquire(ctx)
+	require.NoError(tb, err)
+	defer c.Release()
+	exec := func(sql string, args ...any) {
+		tb.Helper()
+		_, err := c.Exec(ctx, sql, args...)
+		require.NoError(tb, err)
+	}
+
+	userID, customerID := uuid.New().String(), uuid.New().String()
+	exec(`INSERT INTO users (id, clerk_id, email, role) VALUES ($1, $1, $1 || '@pitgo.test', 'customer')`, userID)
+	exec(`INSERT INTO profiles (id, user_id, type, first_name, last_name, phone) VALUES ($1, $2, 'customer', 'Test', 'User', '0')`, customerID, userID)
+	categoryID, serviceID := uuid.New().String(), uuid.New().String()
+	exec(`INSERT INTO categories (id, name, slug) VALUES ($1, 'Plumbing', $1)`, categoryID)
+	exec(`INSERT INTO services (id, category_id, name, slug) VALUES ($1, $2, 'Leak', $1)`, serviceID, categoryID)
+
+	exec(`SELECT setseed(0.42)`)
+	exec(`CREATE TEMP TABLE seed_points AS
+	      SELECT i, CASE WHEN i % 3 = 0 THEN -23.75 + random() * 0.5 ELSE -33 + random() * 30 END AS lat,
+	                CASE WHEN i % 3 = 0 THEN -46.85 + random() * 0.5 ELSE -73 + random() * 38 END AS lng
+	      FROM generate_series(1, $1) AS i`, n)
+	exec(`INSERT INTO service_requests (customer_id, service_id, category, status, scheduled_at, latitude, longitude)
+	      SELECT $1, $2, 'plumbing', 'open', NOW(), lat, lng FROM seed_points`, customerID, serviceID)
+	exec(`INSERT INTO users (id, clerk_id, email, role)
+	      SELECT gen_random_uuid(), 'provider-' || i, 'provider-' || i || '@pitgo.test', 'provider' FROM seed_points`)
+	exec(`INSERT INTO profiles (id, user_id, type, first_name, last_name, phone)
+	      SELECT gen_random_uuid(), id, 'provider', 'Test', 'Provider', '0' FROM users WHERE role = 'provider'`)
+	exec(`INSERT INTO provider_details (profile_id, categories, service_area, latitude, longitude)
+	      SELECT p.id, '{plumbing}', 5 + (s.i % 20), s.lat, s.lng
+	      FROM (SELECT id, row_number() OVER () AS i FROM profiles WHERE type = 'provider') p
+	      JOIN seed_points s ON s.i = p.i`)
+	exec(`ANALYZE service_requests`)
+	exec(`ANALYZE provider_details`)
+}
+
+// Radius searches as they were before the grid prefilter: the distance to
+// every row of the category.
+var (
+	fullScanRequests = `SELECT id FROM service_requests
+	                    WHERE status = 'open' AND ` + distanceSQL("$1", "$2", "latitude", "longitude") + ` <= $3
+	                    ORDER BY ` + distanceSQL("$1", "$2", "latitude", "longitude") + ` ASC LIMIT $4`
+	fullScanProviders = `SELECT profile_id FROM provider_details
+	                     WHERE 'plumbing' = ANY(categories) AND ` + distanceSQL("$1", "$2", "latitude", "longitude") + ` <= LEAST($3, NULLIF(service_area, 0))
+	                     ORDER BY profile_id`
+)
+
+var searches = []struct {
+	lat, lng, radiusKm float64
+}{
+	{-23.5503, -46.6339, 10},
+	{-23.5503, -46.6339, 50},
+	{-22.9110, -43.1759, 100},
+	{-3.7319, -38.5267, 400},
+	{-23.75, -46.85, 0.5},
+}
+
+func queryIDs(tb testing.TB, pool *pgxpool.Pool, query string, args ...any) []string {
+	tb.Helper()
+	rows, err := pool.Query(context.Background(), query, args...)
+	require.NoError(tb, err)
+	defer rows.Close()
+	var ids []string
+	for rows.Next() {
+		var id string
+		require.NoError(tb, rows.Scan(&id))
+		ids = append(ids, id)
+	}
+	require.NoError(tb, rows.Err())
+	return ids
+}
+
+func TestRadiusSearch_MatchesFullScan(t *testing.T) {
+	pool := testPool(t)
+	seedLocations(t, pool, 20000)
+	requests, profiles := NewRequestRepository(pool), NewProfileRepository(pool)
+	ctx := context.Background()
+
+	for _, s := range searches {
+		t.Run(fmt.Sprintf("%.2f,%.2f/%gkm", s.lat, s.lng, s.radiusKm), func(t *testing.T) {
+			available, err := requests.ListAvailable(ctx, s.lat, s.lng, s.radiusKm, "", 5000, 0)
+			require.NoError(t, err)
+			ids := make([]string, len(available))
+			for i, req := range available {
+				ids[i] = req.ID
+			}
+			assert.Equal(t, queryIDs(t, pool, fullScanRequests, s.lat, s.lng, s.radiusKm, 5000), ids, "same requests in the same order")
+
+			providers, err := profiles.FindProvidersInRadius(ctx, s.lat, s.lng, s.radiusKm, "plumbing", time.Now())
+			require.NoError(t, err)
+			found := make(map[string]bool, len(providers))
+			for _, p := range providers {
+				found[p.ProfileID] = true
+			}
+			want := queryIDs(t, pool, fullScanProviders, s.lat, s.lng, s.radiusKm)
+			assert.Len(t, found, len(want))
+			for _, id := range want {
+				assert.True(t, found[id], id)
+			}
+		})
+	}
+}
+
+// BenchmarkListAvailable compares radius searches over 200k open requests
+// with and without the grid prefilter:
+//
+//	TEST_DATABASE_URL=... go test ./internal/repository/postgres -run '^$' -bench ListAvailable
+func BenchmarkListAvailable(b *testing.B) {
+	pool := testPool(b)
+	seedLocations(b, pool, 200000)
+	repo := NewRequestRepository(pool)
+	ctx := context.Background()
+
+	b.Run("grid", func(b *testing.B) {
+		for i := range b.N {
+			s := searches[i%len(searches)]
+			_, err := repo.ListAvailable(ctx, s.lat, s.lng, s.radiusKm, "", 20, 0)
+			require.NoError(b, err)
+		}
+	})
+	b.Run("full_scan", func(b *testing.B) {
+		for i := range b.N {
+			s := searches[i%len(searches)]
+			queryIDs(b, pool, fullScanRequests, s.lat, s.lng, s.radiusKm, 20)
+		}
+	})
+}
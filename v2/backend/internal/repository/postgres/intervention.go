@@ -0,0 +1,43 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	domain "github.com/pitgo/backend/internal/domain/dispatch"
+)
+
+type InterventionRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewInterventionRepository(pool *pgxpool.Pool) *InterventionRepository {
+	return &InterventionRepository{pool: pool}
+}
+
+func (r *InterventionRepository) Create(ctx context.Context, i *domain.Intervention) error {
+	query := `INSERT INTO dispatch_interventions (id, request_id, action, admin_id, provider_id, previous_provider_id, reason, created_at)
+			  VALUES ($1, $2, $3, $4, NULLIF($5, '')::uuid, NULLIF($6, '')::uuid, $7, $8)`
+	_, err := conn(ctx, r.pool).Exec(ctx, query, i.ID, i.RequestID, i.Action, i.AdminID, i.ProviderID, i.PreviousProviderID, i.Reason, i.CreatedAt)
+	return err
+}
+
+func (r *InterventionRepository) ListByRequest(ctx context.Context, requestID string) ([]*domain.Intervention, error) {
+	query := `SELECT id, request_id, action, admin_id, COALESCE(provider_id::text, ''), COALESCE(previous_provider_id::text, ''), reason, created_at
+			  FROM dispatch_interventions WHERE request_id = $1 ORDER BY created_at`
+	rows, err := conn(ctx, r.pool).Query(ctx, query, requestID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var interventions []*domain.Intervention
+	for rows.Next() {
+		var i domain.Intervention
+		if err := rows.Scan(&i.ID, &i.RequestID, &i.Action, &i.AdminID, &i.ProviderID, &i.PreviousProviderID, &i.Reason, &i.CreatedAt); err != nil {
+			return nil, err
+		}
+		interventions = append(interventions, &i)
+	}
+	return interventions, rows.Err()
+}
@@ -0,0 +1,79 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	domain "github.com/pitgo/backend/internal/domain/outbox"
+)
+
+type OutboxRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewOutboxRepository(pool *pgxpool.Pool) *OutboxRepository {
+	return &OutboxRepository{pool: pool}
+}
+
+func (r *OutboxRepository) Add(ctx context.Context, m *domain.Message) error {
+	query := `INSERT INTO outbox (id, event_id, topic, correlation_id, payload, created_at)
+			  VALUES ($1, $2, $3, $4, $5, $6)`
+	_, err := conn(ctx, r.pool).Exec(ctx, query, m.ID, m.EventID, m.Topic, m.CorrelationID, m.Payload, m.CreatedAt)
+	return err
+}
+
+func (r *OutboxRepository) Requeue(ctx context.Context, m *domain.Message) error {
+	query := `INSERT INTO outbox (id, event_id, topic, correlation_id, payload, created_at)
+			  VALUES ($1, $2, $3, $4, $5, $6)
+			  ON CONFLICT (event_id) DO UPDATE
+			  SET topic = EXCLUDED.topic, payload = EXCLUDED.payload, attempts = 0,
+			      last_error = NULL, created_at = EXCLUDED.created_at, sent_at = NULL`
+	_, err := conn(ctx, r.pool).Exec(ctx, query, m.ID, m.EventID, m.Topic, m.CorrelationID, m.Payload, m.CreatedAt)
+	return err
+}
+
+func (r *OutboxRepository) FetchPending(ctx context.Context, limit int) ([]*domain.Message, error) {
+	query := `SELECT id, event_id, topic, correlation_id, payload, attempts, COALESCE(last_error, ''), created_at
+			  FROM outbox
+			  WHERE sent_at IS NULL
+			  ORDER BY created_at ASC
+			  LIMIT $1
+			  FOR UPDATE SKIP LOCKED`
+	rows, err := conn(ctx, r.pool).Query(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []*domain.Message
+	for rows.Next() {
+		var m domain.Message
+		if err := rows.Scan(&m.ID, &m.EventID, &m.Topic, &m.CorrelationID, &m.Payload, &m.Attempts, &m.LastError, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		messages = append(messages, &m)
+	}
+	return messages, rows.Err()
+}
+
+func (r *OutboxRepository) MarkSent(ctx context.Context, id string, sentAt time.Time) error {
+	query := `UPDATE outbox SET sent_at = $2, attempts = attempts + 1 WHERE id = $1`
+	_, err := conn(ctx, r.pool).Exec(ctx, query, id, sentAt)
+	return err
+}
+
+func (r *OutboxRepository) MarkFailed(ctx context.Context, id string, reason string) error {
+	query := `UPDATE outbox SET attempts = attempts + 1, last_error = $2 WHERE id = $1`
+	_, err := conn(ctx, r.pool).Exec(ctx, query, id, reason)
+	return err
+}
+
+func (r *OutboxRepository) PurgeSent(ctx context.Context, before time.Time) (int64, error) {
+	query := `DELETE FROM outbox WHERE sent_at IS NOT NULL AND sent_at < $1`
+	tag, err := conn(ctx, r.pool).Exec(ctx, query, before)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
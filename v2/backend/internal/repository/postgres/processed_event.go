@@ -0,0 +1,44 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ProcessedEventRepository stores idempotency markers for event consumers.
+// Calls made inside a transaction share it with the handler's own writes.
+type ProcessedEventRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewProcessedEventRepository(pool *pgxpool.Pool) *ProcessedEventRepository {
+	return &ProcessedEventRepository{pool: pool}
+}
+
+func (r *ProcessedEventRepository) MarkProcessed(ctx context.Context, group, eventID string) (bool, error) {
+	query := `INSERT INTO processed_events (group_name, event_id, processed_at)
+			  VALUES ($1, $2, NOW())
+			  ON CONFLICT (group_name, event_id) DO NOTHING`
+	tag, err := conn(ctx, r.pool).Exec(ctx, query, group, eventID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+func (r *ProcessedEventRepository) Unmark(ctx context.Context, group, eventID string) error {
+	query := `DELETE FROM processed_events WHERE group_name = $1 AND event_id = $2`
+	_, err := conn(ctx, r.pool).Exec(ctx, query, group, eventID)
+	return err
+}
+
+func (r *ProcessedEventRepository) PurgeProcessed(ctx context.Context, before time.Time) (int64, error) {
+	query := `DELETE FROM processed_events WHERE processed_at < $1`
+	tag, err := conn(ctx, r.pool).Exec(ctx, query, before)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
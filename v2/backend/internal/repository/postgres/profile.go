@@ -2,8 +2,10 @@ package postgres
 
 import (
 	"context"
+	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pitgo/backend/internal/domain/geo"
 	domain "github.com/pitgo/backend/internal/domain/profile"
 )
 
@@ -108,15 +110,56 @@ func (r *ProfileRepository) UpdateProviderDetails(ctx context.Context, d *domain
 	return err
 }
 
-func (r *ProfileRepository) FindProvidersInRadius(ctx context.Context, lat, lng, radiusKm float64, category string) ([]*domain.ProviderDetails, error) {
-    // Basic Haversine Implementation or Postgres PostGIS if available.
-    // Assuming raw SQL with earth_distance or haversine for now.
-	query := `SELECT profile_id, category, service_radius_km, latitude, longitude, rating, total_jobs, is_verified 
-	          FROM provider_details 
-	          WHERE category = $1 
-	          AND (6371 * acos(cos(radians($2)) * cos(radians(latitude)) * cos(radians(longitude) - radians($3)) + sin(radians($2)) * sin(radians(latitude)))) <= $4`
-	
-	rows, err := r.pool.Query(ctx, query, category, lat, lng, radiusKm)
+func (r *ProfileRepository) FindProvidersInRadius(ctx context.Context, lat, lng, radiusKm float64, category string, locatedSince time.Time) ([]*domain.ProviderDetails, error) {
+	live := `SELECT provider_id, latitude, longitude, reported_at FROM provider_locations WHERE reported_at >= $5`
+	return r.findProviders(ctx, live, []any{category, lat, lng, radiusKm, locatedSince}, lat, lng, radiusKm)
+}
+
+func (r *ProfileRepository) FindProvidersAt(ctx context.Context, lat, lng, radiusKm float64, category string, live []*domain.Location) ([]*domain.ProviderDetails, error) {
+	ids := make([]string, len(live))
+	lats := make([]float64, len(live))
+	lngs := make([]float64, len(live))
+	reported := make([]time.Time, len(live))
+	for i, loc := range live {
+		ids[i], lats[i], lngs[i], reported[i] = loc.ProfileID, loc.Latitude, loc.Longitude, loc.ReportedAt
+	}
+	given := `SELECT * FROM unnest($5::uuid[], $6::float8[], $7::float8[], $8::timestamptz[]) AS u(provider_id, latitude, longitude, reported_at)`
+	return r.findProviders(ctx, given, []any{category, lat, lng, radiusKm, ids, lats, lngs, reported}, lat, lng, radiusKm)
+}
+
+// findProviders selects the providers of category $1 for whom ($2, $3) is
+// within both $4 km and their service area. Distances use the location in
+// live, a query of (provider_id, latitude, longitude, reported_at) over the
+// rest of args, when it has one, else the profile's; LEAST ignores a NULL
+// (unset) service area. Only providers located in the grid cells around the
+// search, by either location, have their distance computed.
+func (r *ProfileRepository) findProviders(ctx context.Context, live string, args []any, lat, lng, radiusKm float64) ([]*domain.ProviderDetails, error) {
+	box := geo.BoundingBox(lat, lng, radiusKm)
+	near, nearArgs := nearSQL(box, "latitude", "longitude", "geo_cell", len(args)+1)
+	args = append(args, nearArgs...)
+	liveNear, liveArgs := nearSQL(box, "latitude", "longitude", "", len(args)+1)
+	args = append(args, liveArgs...)
+
+	query := `WITH l AS (` + live + `)
+	          SELECT profile_id, categories, service_area, latitude, longitude, rating, total_jobs, is_verified, reported_at
+	          FROM (
+	              SELECT d.profile_id, d.categories, d.service_area::float8 AS service_area,
+	                     COALESCE(l.latitude, d.latitude::float8) AS latitude,
+	                     COALESCE(l.longitude, d.longitude::float8) AS longitude,
+	                     d.rating::float8 AS rating, d.total_jobs, d.is_verified, l.reported_at
+	              FROM provider_details d
+	              LEFT JOIN l ON l.provider_id = d.profile_id
+	              WHERE d.profile_id IN (
+	                        SELECT profile_id FROM provider_details WHERE ` + near + `
+	                        UNION SELECT provider_id FROM l WHERE ` + liveNear + `)
+	                AND $1 = ANY(d.categories)
+	          ) p
+	          WHERE ` + distanceSQL("$2", "$3", "latitude", "longitude") + ` <= LEAST($4, NULLIF(service_area, 0))`
+	return r.queryProviders(ctx, query, args...)
+}
+
+func (r *ProfileRepository) queryProviders(ctx context.Context, query string, args ...any) ([]*domain.ProviderDetails, error) {
+	rows, err := r.pool.Query(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -125,14 +168,22 @@ func (r *ProfileRepository) FindProvidersInRadius(ctx context.Context, lat, lng,
 	var providers []*domain.ProviderDetails
 	for rows.Next() {
 		var d domain.ProviderDetails
-		var cat string
-		if err := rows.Scan(&d.ProfileID, &cat, &d.ServiceArea, &d.Latitude, &d.Longitude, &d.Rating, &d.TotalJobs, &d.IsVerified); err != nil {
+		if err := rows.Scan(&d.ProfileID, &d.Categories, &d.ServiceArea, &d.Latitude, &d.Longitude, &d.Rating, &d.TotalJobs, &d.IsVerified, &d.LocatedAt); err != nil {
 			return nil, err
 		}
-		d.Categories = []string{cat}
+		d.IsOnline = d.LocatedAt != nil
 		providers = append(providers, &d)
 	}
-	return providers, nil
+	return providers, rows.Err()
+}
+
+func (r *ProfileRepository) SaveLocation(ctx context.Context, loc *domain.Location) error {
+	query := `INSERT INTO provider_locations (provider_id, latitude, longitude, reported_at)
+			  VALUES ($1, $2, $3, $4)
+			  ON CONFLICT (provider_id) DO UPDATE SET latitude = EXCLUDED.latitude, longitude = EXCLUDED.longitude, reported_at = EXCLUDED.reported_at
+			  WHERE provider_locations.reported_at <= EXCLUDED.reported_at`
+	_, err := r.pool.Exec(ctx, query, loc.ProfileID, loc.Latitude, loc.Longitude, loc.ReportedAt)
+	return err
 }
 
 func (r *ProfileRepository) CreateAddress(ctx context.Context, a *domain.Address) error {
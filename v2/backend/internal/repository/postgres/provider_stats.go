@@ -0,0 +1,76 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	domain "github.com/pitgo/backend/internal/domain/providerstats"
+)
+
+type ProviderStatsRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewProviderStatsRepository(pool *pgxpool.Pool) *ProviderStatsRepository {
+	return &ProviderStatsRepository{pool: pool}
+}
+
+const providerStatsColumns = `provider_id, offers_accepted, offers_rejected, offers_expired, jobs_accepted, cancelled_after_accept, recent_response_ms, median_response_ms, updated_at`
+
+func scanProviderStats(scanner interface{ Scan(dest ...any) error }) (*domain.Stats, error) {
+	var s domain.Stats
+	err := scanner.Scan(
+		&s.ProviderID, &s.OffersAccepted, &s.OffersRejected, &s.OffersExpired, &s.JobsAccepted,
+		&s.CancelledAfterAccept, &s.RecentResponseMs, &s.MedianResponseMs, &s.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (r *ProviderStatsRepository) Lock(ctx context.Context, providerID string) (*domain.Stats, error) {
+	q := conn(ctx, r.pool)
+	if _, err := q.Exec(ctx, `INSERT INTO provider_stats (provider_id) VALUES ($1) ON CONFLICT DO NOTHING`, providerID); err != nil {
+		return nil, err
+	}
+	query := fmt.Sprintf(`SELECT %s FROM provider_stats WHERE provider_id = $1 FOR UPDATE`, providerStatsColumns)
+	return scanProviderStats(q.QueryRow(ctx, query, providerID))
+}
+
+func (r *ProviderStatsRepository) Save(ctx context.Context, s *domain.Stats) error {
+	query := `UPDATE provider_stats SET
+		offers_accepted = $2, offers_rejected = $3, offers_expired = $4, jobs_accepted = $5,
+		cancelled_after_accept = $6, recent_response_ms = $7, median_response_ms = $8, updated_at = $9
+		WHERE provider_id = $1`
+	_, err := conn(ctx, r.pool).Exec(ctx, query,
+		s.ProviderID, s.OffersAccepted, s.OffersRejected, s.OffersExpired, s.JobsAccepted,
+		s.CancelledAfterAccept, s.RecentResponseMs, s.MedianResponseMs, s.UpdatedAt,
+	)
+	return err
+}
+
+func (r *ProviderStatsRepository) GetByProviderID(ctx context.Context, providerID string) (*domain.Stats, error) {
+	query := fmt.Sprintf(`SELECT %s FROM provider_stats WHERE provider_id = $1`, providerStatsColumns)
+	return scanProviderStats(conn(ctx, r.pool).QueryRow(ctx, query, providerID))
+}
+
+func (r *ProviderStatsRepository) List(ctx context.Context, f domain.ListFilter) ([]*domain.Stats, error) {
+	query := fmt.Sprintf(`SELECT %s FROM provider_stats ORDER BY updated_at DESC LIMIT $1 OFFSET $2`, providerStatsColumns)
+	rows, err := conn(ctx, r.pool).Query(ctx, query, f.Limit, f.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []*domain.Stats
+	for rows.Next() {
+		s, err := scanProviderStats(rows)
+		if err != nil {
+			return nil, err
+		}
+		stats = append(stats, s)
+	}
+	return stats, nil
+}
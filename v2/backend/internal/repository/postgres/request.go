@@ -3,8 +3,10 @@ package postgres
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pitgo/backend/internal/domain/geo"
 	domain "github.com/pitgo/backend/internal/domain/request"
 )
 
@@ -16,14 +18,15 @@ func NewRequestRepository(pool *pgxpool.Pool) *RequestRepository {
 	return &RequestRepository{pool: pool}
 }
 
-const baseColumns = `id, customer_id, provider_id, service_id, category, status, description, photo_url, total_price, notes, scheduled_at, address_id, latitude, longitude, accepted_at, started_at, completed_at, cancelled_at, created_at, updated_at`
+// Nullable columns are coalesced to the zero values the entity uses.
+const baseColumns = `id, customer_id, COALESCE(provider_id::text, ''), service_id, category, status, description, photo_url, total_price, COALESCE(notes, ''), scheduled_at, dispatch_at, COALESCE(address_id::text, ''), latitude, longitude, accepted_at, started_at, completed_at, cancelled_at, created_at, updated_at`
 
 func scanRequest(scanner interface{ Scan(dest ...any) error }) (*domain.ServiceRequest, error) {
 	var req domain.ServiceRequest
 	err := scanner.Scan(
 		&req.ID, &req.CustomerID, &req.ProviderID, &req.ServiceID, &req.Category,
 		&req.Status, &req.Description, &req.PhotoURL, &req.TotalPrice, &req.Notes,
-		&req.ScheduledAt, &req.AddressID, &req.Latitude, &req.Longitude,
+		&req.ScheduledAt, &req.DispatchAt, &req.AddressID, &req.Latitude, &req.Longitude,
 		&req.AcceptedAt, &req.StartedAt, &req.CompletedAt, &req.CancelledAt,
 		&req.CreatedAt, &req.UpdatedAt,
 	)
@@ -34,12 +37,12 @@ func scanRequest(scanner interface{ Scan(dest ...any) error }) (*domain.ServiceR
 }
 
 func (r *RequestRepository) Create(ctx context.Context, req *domain.ServiceRequest) error {
-	query := `INSERT INTO service_requests (id, customer_id, provider_id, service_id, category, status, description, photo_url, total_price, notes, scheduled_at, address_id, latitude, longitude, created_at, updated_at)
-			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)`
-	_, err := r.pool.Exec(ctx, query,
+	query := `INSERT INTO service_requests (id, customer_id, provider_id, service_id, category, status, description, photo_url, total_price, notes, scheduled_at, dispatch_at, address_id, latitude, longitude, created_at, updated_at)
+			  VALUES ($1, $2, NULLIF($3, '')::uuid, $4, $5, $6, $7, $8, $9, $10, $11, $12, NULLIF($13, '')::uuid, $14, $15, $16, $17)`
+	_, err := conn(ctx, r.pool).Exec(ctx, query,
 		req.ID, req.CustomerID, req.ProviderID, req.ServiceID, req.Category,
 		req.Status, req.Description, req.PhotoURL, req.TotalPrice, req.Notes,
-		req.ScheduledAt, req.AddressID, req.Latitude, req.Longitude,
+		req.ScheduledAt, req.DispatchAt, req.AddressID, req.Latitude, req.Longitude,
 		req.CreatedAt, req.UpdatedAt,
 	)
 	return err
@@ -47,19 +50,27 @@ func (r *RequestRepository) Create(ctx context.Context, req *domain.ServiceReque
 
 func (r *RequestRepository) GetByID(ctx context.Context, id string) (*domain.ServiceRequest, error) {
 	query := fmt.Sprintf(`SELECT %s FROM service_requests WHERE id = $1`, baseColumns)
-	return scanRequest(r.pool.QueryRow(ctx, query, id))
+	return scanRequest(conn(ctx, r.pool).QueryRow(ctx, query, id))
+}
+
+// GetByIDForUpdate locks the request until the caller's transaction ends.
+func (r *RequestRepository) GetByIDForUpdate(ctx context.Context, id string) (*domain.ServiceRequest, error) {
+	query := fmt.Sprintf(`SELECT %s FROM service_requests WHERE id = $1 FOR UPDATE`, baseColumns)
+	return scanRequest(conn(ctx, r.pool).QueryRow(ctx, query, id))
 }
 
 func (r *RequestRepository) Update(ctx context.Context, req *domain.ServiceRequest) error {
 	query := `UPDATE service_requests SET
-		provider_id = $2, status = $3, description = $4, photo_url = $5,
+		provider_id = NULLIF($2, '')::uuid, status = $3, description = $4, photo_url = $5,
 		total_price = $6, notes = $7, accepted_at = $8, started_at = $9,
-		completed_at = $10, cancelled_at = $11, updated_at = $12
+		completed_at = $10, cancelled_at = $11, updated_at = $12,
+		scheduled_at = $13, dispatch_at = $14
 		WHERE id = $1`
-	_, err := r.pool.Exec(ctx, query,
+	_, err := conn(ctx, r.pool).Exec(ctx, query,
 		req.ID, req.ProviderID, req.Status, req.Description, req.PhotoURL,
 		req.TotalPrice, req.Notes, req.AcceptedAt, req.StartedAt,
 		req.CompletedAt, req.CancelledAt, req.UpdatedAt,
+		req.ScheduledAt, req.DispatchAt,
 	)
 	return err
 }
@@ -76,7 +87,7 @@ func (r *RequestRepository) ListByCustomer(ctx context.Context, customerID strin
 	query += fmt.Sprintf(" ORDER BY created_at DESC LIMIT $%d OFFSET $%d", argIdx, argIdx+1)
 	args = append(args, limit, offset)
 
-	rows, err := r.pool.Query(ctx, query, args...)
+	rows, err := conn(ctx, r.pool).Query(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -105,7 +116,7 @@ func (r *RequestRepository) ListByProvider(ctx context.Context, providerID strin
 	query += fmt.Sprintf(" ORDER BY created_at DESC LIMIT $%d OFFSET $%d", argIdx, argIdx+1)
 	args = append(args, limit, offset)
 
-	rows, err := r.pool.Query(ctx, query, args...)
+	rows, err := conn(ctx, r.pool).Query(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -122,22 +133,19 @@ func (r *RequestRepository) ListByProvider(ctx context.Context, providerID strin
 	return requests, nil
 }
 
-// ListAvailable finds open requests within radiusKm using the Haversine formula,
-// optionally filtered by category, ordered by distance ascending.
+// ListAvailable finds open requests within radiusKm, optionally filtered by
+// category, ordered by distance ascending. Only requests in the grid cells
+// around the search have their distance computed.
 func (r *RequestRepository) ListAvailable(ctx context.Context, lat, lng, radiusKm float64, category string, limit, offset int) ([]*domain.ServiceRequest, error) {
-	// Haversine distance in km
-	haversine := `(6371 * acos(
-		cos(radians($1)) * cos(radians(latitude)) *
-		cos(radians(longitude) - radians($2)) +
-		sin(radians($1)) * sin(radians(latitude))
-	))`
+	distance := distanceSQL("$1", "$2", "latitude", "longitude")
+	near, nearArgs := nearSQL(geo.BoundingBox(lat, lng, radiusKm), "latitude", "longitude", "geo_cell", 4)
 
 	query := fmt.Sprintf(`SELECT %s, %s AS distance_km
 		FROM service_requests
-		WHERE status = 'open' AND %s <= $3`, baseColumns, haversine, haversine)
+		WHERE status = 'open' AND %s AND %s <= $3`, baseColumns, distance, near, distance)
 
-	args := []any{lat, lng, radiusKm}
-	argIdx := 4
+	args := append([]any{lat, lng, radiusKm}, nearArgs...)
+	argIdx := len(args) + 1
 
 	if category != "" {
 		query += fmt.Sprintf(" AND category = $%d", argIdx)
@@ -148,7 +156,7 @@ func (r *RequestRepository) ListAvailable(ctx context.Context, lat, lng, radiusK
 	query += fmt.Sprintf(" ORDER BY distance_km ASC LIMIT $%d OFFSET $%d", argIdx, argIdx+1)
 	args = append(args, limit, offset)
 
-	rows, err := r.pool.Query(ctx, query, args...)
+	rows, err := conn(ctx, r.pool).Query(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -160,7 +168,7 @@ func (r *RequestRepository) ListAvailable(ctx context.Context, lat, lng, radiusK
 		err := rows.Scan(
 			&req.ID, &req.CustomerID, &req.ProviderID, &req.ServiceID, &req.Category,
 			&req.Status, &req.Description, &req.PhotoURL, &req.TotalPrice, &req.Notes,
-			&req.ScheduledAt, &req.AddressID, &req.Latitude, &req.Longitude,
+			&req.ScheduledAt, &req.DispatchAt, &req.AddressID, &req.Latitude, &req.Longitude,
 			&req.AcceptedAt, &req.StartedAt, &req.CompletedAt, &req.CancelledAt,
 			&req.CreatedAt, &req.UpdatedAt,
 			&req.DistanceKm,
@@ -173,16 +181,39 @@ func (r *RequestRepository) ListAvailable(ctx context.Context, lat, lng, radiusK
 	return requests, nil
 }
 
+func (r *RequestRepository) LockDue(ctx context.Context, now time.Time, limit int) ([]*domain.ServiceRequest, error) {
+	query := fmt.Sprintf(`SELECT %s FROM service_requests
+			  WHERE status = 'scheduled' AND dispatch_at <= $1
+			  ORDER BY dispatch_at
+			  LIMIT $2
+			  FOR UPDATE SKIP LOCKED`, baseColumns)
+	rows, err := conn(ctx, r.pool).Query(ctx, query, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var requests []*domain.ServiceRequest
+	for rows.Next() {
+		req, err := scanRequest(rows)
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, req)
+	}
+	return requests, rows.Err()
+}
+
 func (r *RequestRepository) CreateItem(ctx context.Context, item *domain.RequestItem) error {
 	query := `INSERT INTO request_items (id, request_id, service_id, service_name, quantity, unit_price, total_price)
 			  VALUES ($1, $2, $3, $4, $5, $6, $7)`
-	_, err := r.pool.Exec(ctx, query, item.ID, item.RequestID, item.ServiceID, item.ServiceName, item.Quantity, item.UnitPrice, item.TotalPrice)
+	_, err := conn(ctx, r.pool).Exec(ctx, query, item.ID, item.RequestID, item.ServiceID, item.ServiceName, item.Quantity, item.UnitPrice, item.TotalPrice)
 	return err
 }
 
 func (r *RequestRepository) GetItems(ctx context.Context, requestID string) ([]*domain.RequestItem, error) {
 	query := `SELECT id, request_id, service_id, service_name, quantity, unit_price, total_price FROM request_items WHERE request_id = $1`
-	rows, err := r.pool.Query(ctx, query, requestID)
+	rows, err := conn(ctx, r.pool).Query(ctx, query, requestID)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,186 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	domain "github.com/pitgo/backend/internal/domain/webhook"
+)
+
+// --- Subscriptions ---
+
+type WebhookSubscriptionRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewWebhookSubscriptionRepository(pool *pgxpool.Pool) *WebhookSubscriptionRepository {
+	return &WebhookSubscriptionRepository{pool: pool}
+}
+
+const webhookSubscriptionColumns = `id, url, topics, customer_ids::text[], secret, description, active, created_at, updated_at`
+
+func scanWebhookSubscription(scanner interface{ Scan(dest ...any) error }) (*domain.Subscription, error) {
+	var s domain.Subscription
+	err := scanner.Scan(&s.ID, &s.URL, &s.Topics, &s.CustomerIDs, &s.Secret, &s.Description, &s.Active, &s.CreatedAt, &s.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (r *WebhookSubscriptionRepository) Create(ctx context.Context, s *domain.Subscription) error {
+	query := `INSERT INTO webhook_subscriptions (id, url, topics, customer_ids, secret, description, active, created_at, updated_at)
+			  VALUES ($1, $2, $3, $4::uuid[], $5, $6, $7, $8, $9)`
+	_, err := conn(ctx, r.pool).Exec(ctx, query, s.ID, s.URL, s.Topics, s.CustomerIDs, s.Secret, s.Description, s.Active, s.CreatedAt, s.UpdatedAt)
+	return err
+}
+
+func (r *WebhookSubscriptionRepository) GetByID(ctx context.Context, id string) (*domain.Subscription, error) {
+	query := fmt.Sprintf(`SELECT %s FROM webhook_subscriptions WHERE id = $1`, webhookSubscriptionColumns)
+	return scanWebhookSubscription(conn(ctx, r.pool).QueryRow(ctx, query, id))
+}
+
+func (r *WebhookSubscriptionRepository) List(ctx context.Context) ([]*domain.Subscription, error) {
+	query := fmt.Sprintf(`SELECT %s FROM webhook_subscriptions ORDER BY created_at`, webhookSubscriptionColumns)
+	return r.query(ctx, query)
+}
+
+func (r *WebhookSubscriptionRepository) ListActiveByTopic(ctx context.Context, topic, customerID string) ([]*domain.Subscription, error) {
+	query := fmt.Sprintf(`SELECT %s FROM webhook_subscriptions
+			  WHERE active AND $1 = ANY(topics) AND $2::uuid = ANY(customer_ids)
+			  ORDER BY created_at`, webhookSubscriptionColumns)
+	return r.query(ctx, query, topic, customerID)
+}
+
+func (r *WebhookSubscriptionRepository) query(ctx context.Context, query string, args ...any) ([]*domain.Subscription, error) {
+	rows, err := conn(ctx, r.pool).Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var subs []*domain.Subscription
+	for rows.Next() {
+		s, err := scanWebhookSubscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		subs = append(subs, s)
+	}
+	return subs, nil
+}
+
+func (r *WebhookSubscriptionRepository) Update(ctx context.Context, s *domain.Subscription) error {
+	query := `UPDATE webhook_subscriptions SET url = $2, topics = $3, customer_ids = $4::uuid[], secret = $5, description = $6,
+			  active = $7, updated_at = $8
+			  WHERE id = $1`
+	_, err := conn(ctx, r.pool).Exec(ctx, query, s.ID, s.URL, s.Topics, s.CustomerIDs, s.Secret, s.Description, s.Active, s.UpdatedAt)
+	return err
+}
+
+func (r *WebhookSubscriptionRepository) Delete(ctx context.Context, id string) error {
+	_, err := conn(ctx, r.pool).Exec(ctx, `DELETE FROM webhook_subscriptions WHERE id = $1`, id)
+	return err
+}
+
+// --- Deliveries ---
+
+type WebhookDeliveryRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewWebhookDeliveryRepository(pool *pgxpool.Pool) *WebhookDeliveryRepository {
+	return &WebhookDeliveryRepository{pool: pool}
+}
+
+const webhookDeliveryColumns = `id, subscription_id, event_id, topic, payload, status, attempts, response_status, last_error,
+	next_attempt_at, delivered_at, created_at, updated_at`
+
+func scanWebhookDelivery(scanner interface{ Scan(dest ...any) error }) (*domain.Delivery, error) {
+	var d domain.Delivery
+	err := scanner.Scan(
+		&d.ID, &d.SubscriptionID, &d.EventID, &d.Topic, &d.Payload, &d.Status, &d.Attempts, &d.ResponseStatus, &d.LastError,
+		&d.NextAttemptAt, &d.DeliveredAt, &d.CreatedAt, &d.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+func (r *WebhookDeliveryRepository) Create(ctx context.Context, d *domain.Delivery) error {
+	query := `INSERT INTO webhook_deliveries (id, subscription_id, event_id, topic, payload, status, next_attempt_at, created_at, updated_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+			  ON CONFLICT (subscription_id, event_id) DO NOTHING`
+	_, err := conn(ctx, r.pool).Exec(ctx, query,
+		d.ID, d.SubscriptionID, d.EventID, d.Topic, []byte(d.Payload), d.Status, d.NextAttemptAt, d.CreatedAt, d.UpdatedAt,
+	)
+	return err
+}
+
+func (r *WebhookDeliveryRepository) GetByID(ctx context.Context, id string) (*domain.Delivery, error) {
+	query := fmt.Sprintf(`SELECT %s FROM webhook_deliveries WHERE id = $1`, webhookDeliveryColumns)
+	return scanWebhookDelivery(conn(ctx, r.pool).QueryRow(ctx, query, id))
+}
+
+func (r *WebhookDeliveryRepository) List(ctx context.Context, f domain.DeliveryFilter) ([]*domain.Delivery, error) {
+	query := fmt.Sprintf(`SELECT %s FROM webhook_deliveries WHERE 1 = 1`, webhookDeliveryColumns)
+	var args []any
+	argIdx := 1
+	if f.SubscriptionID != "" {
+		query += fmt.Sprintf(" AND subscription_id = $%d", argIdx)
+		args = append(args, f.SubscriptionID)
+		argIdx++
+	}
+	if f.Status != "" {
+		query += fmt.Sprintf(" AND status = $%d", argIdx)
+		args = append(args, f.Status)
+		argIdx++
+	}
+	query += fmt.Sprintf(" ORDER BY created_at DESC LIMIT $%d OFFSET $%d", argIdx, argIdx+1)
+	args = append(args, f.Limit, f.Offset)
+	return r.query(ctx, query, args...)
+}
+
+func (r *WebhookDeliveryRepository) ClaimDue(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*domain.Delivery, error) {
+	query := fmt.Sprintf(`UPDATE webhook_deliveries SET next_attempt_at = $2
+			  WHERE id IN (
+			      SELECT id FROM webhook_deliveries
+			      WHERE status = 'pending' AND next_attempt_at <= $1
+			      ORDER BY next_attempt_at
+			      LIMIT $3
+			      FOR UPDATE SKIP LOCKED
+			  )
+			  RETURNING %s`, webhookDeliveryColumns)
+	return r.query(ctx, query, now, now.Add(lease), limit)
+}
+
+func (r *WebhookDeliveryRepository) query(ctx context.Context, query string, args ...any) ([]*domain.Delivery, error) {
+	rows, err := conn(ctx, r.pool).Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []*domain.Delivery
+	for rows.Next() {
+		d, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, nil
+}
+
+func (r *WebhookDeliveryRepository) Update(ctx context.Context, d *domain.Delivery) error {
+	query := `UPDATE webhook_deliveries
+			  SET status = $2, attempts = $3, response_status = $4, last_error = $5, next_attempt_at = $6, delivered_at = $7, updated_at = $8
+			  WHERE id = $1`
+	_, err := conn(ctx, r.pool).Exec(ctx, query,
+		d.ID, d.Status, d.Attempts, d.ResponseStatus, d.LastError, d.NextAttemptAt, d.DeliveredAt, d.UpdatedAt,
+	)
+	return err
+}
@@ -0,0 +1,80 @@
+package deadletter
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	domain "github.com/pitgo/backend/internal/domain/deadletter"
+	"github.com/pitgo/backend/internal/domain/outbox"
+	"github.com/pitgo/backend/internal/infrastructure/database"
+	"github.com/pitgo/backend/internal/infrastructure/logger"
+)
+
+var ErrAlreadyRequeued = errors.New("dead letter already requeued")
+
+type UseCase struct {
+	repo   domain.Repository
+	tx     database.Transactor
+	outbox outbox.Repository
+}
+
+func New(repo domain.Repository, tx database.Transactor, outboxRepo outbox.Repository) *UseCase {
+	return &UseCase{repo: repo, tx: tx, outbox: outboxRepo}
+}
+
+func (uc *UseCase) List(ctx context.Context, filter domain.ListFilter) ([]*domain.DeadLetter, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = 20
+	}
+	return uc.repo.List(ctx, filter)
+}
+
+func (uc *UseCase) GetByID(ctx context.Context, id string) (*domain.DeadLetter, error) {
+	return uc.repo.GetByID(ctx, id)
+}
+
+// Requeue publishes the original envelope back to its topic, unchanged, so
+// subscribers see the same EventID they failed on. The dead letter is
+// claimed and the envelope stored in the outbox in one transaction, so of two
+// concurrent requeues only one publishes it, through the outbox relay.
+func (uc *UseCase) Requeue(ctx context.Context, id string) (*domain.DeadLetter, error) {
+	var dl *domain.DeadLetter
+	err := uc.tx.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		dl, err = uc.repo.GetByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		claimed, err := uc.repo.MarkRequeued(ctx, dl.ID, now)
+		if err != nil {
+			return err
+		}
+		if !claimed {
+			return ErrAlreadyRequeued
+		}
+		dl.RequeuedAt = &now
+
+		// Envelopes that could not be parsed have no EventID of their own
+		eventID := dl.EventID
+		if eventID == "" {
+			eventID = uuid.New().String()
+		}
+		return uc.outbox.Requeue(ctx, &outbox.Message{
+			ID:            uuid.New().String(),
+			EventID:       eventID,
+			Topic:         dl.Topic,
+			CorrelationID: dl.CorrelationID,
+			Payload:       dl.Envelope,
+			CreatedAt:     now,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Ctx(ctx).Info().Str("dead_letter_id", dl.ID).Str("topic", dl.Topic).Str("event_id", dl.EventID).Msg("Dead letter requeued")
+	return dl, nil
+}
@@ -0,0 +1,76 @@
+package deadletter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	domain "github.com/pitgo/backend/internal/domain/deadletter"
+	"github.com/pitgo/backend/internal/domain/outbox"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeRepo struct {
+	domain.Repository
+	byID map[string]*domain.DeadLetter
+}
+
+func (f *fakeRepo) GetByID(_ context.Context, id string) (*domain.DeadLetter, error) {
+	dl, ok := f.byID[id]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	cp := *dl
+	return &cp, nil
+}
+
+func (f *fakeRepo) MarkRequeued(_ context.Context, id string, at time.Time) (bool, error) {
+	dl := f.byID[id]
+	if dl.RequeuedAt != nil {
+		return false, nil
+	}
+	dl.RequeuedAt = &at
+	return true, nil
+}
+
+type fakeOutbox struct {
+	outbox.Repository
+	msgs []*outbox.Message
+}
+
+func (f *fakeOutbox) Requeue(_ context.Context, msg *outbox.Message) error {
+	f.msgs = append(f.msgs, msg)
+	return nil
+}
+
+type passthroughTx struct{}
+
+func (passthroughTx) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+func TestRequeue_PublishesOriginalEnvelopeOnce(t *testing.T) {
+	envelope := json.RawMessage(`{"event_id":"evt-1","topic":"request.created"}`)
+	repo := &fakeRepo{byID: map[string]*domain.DeadLetter{
+		"dl-1": {ID: "dl-1", Topic: "request.created", EventID: "evt-1", CorrelationID: "corr-1", Envelope: envelope},
+	}}
+	ob := &fakeOutbox{}
+	uc := New(repo, passthroughTx{}, ob)
+
+	dl, err := uc.Requeue(context.Background(), "dl-1")
+	require.NoError(t, err)
+	assert.NotNil(t, dl.RequeuedAt)
+
+	require.Len(t, ob.msgs, 1)
+	assert.Equal(t, "evt-1", ob.msgs[0].EventID)
+	assert.Equal(t, "request.created", ob.msgs[0].Topic)
+	assert.Equal(t, "corr-1", ob.msgs[0].CorrelationID)
+	assert.JSONEq(t, string(envelope), string(ob.msgs[0].Payload))
+
+	_, err = uc.Requeue(context.Background(), "dl-1")
+	assert.ErrorIs(t, err, ErrAlreadyRequeued)
+	assert.Len(t, ob.msgs, 1)
+}
@@ -0,0 +1,193 @@
+package dispatch
+
+import (
+	"context"
+	"time"
+
+	domain "github.com/pitgo/backend/internal/domain/dispatch"
+	"github.com/pitgo/backend/internal/domain/events"
+	requestDomain "github.com/pitgo/backend/internal/domain/request"
+	"github.com/pitgo/backend/internal/infrastructure/logger"
+)
+
+// AcceptDispatch accepts an offer on behalf of its provider. See accept.
+func (uc *UseCase) AcceptDispatch(ctx context.Context, id, providerID string) (*domain.Dispatch, error) {
+	d, err := uc.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if d.ProviderID != providerID {
+		return nil, ErrInvalidAction
+	}
+	accepted, _, err := uc.accept(ctx, d.RequestID, providerID, id)
+	if err != nil {
+		return nil, err
+	}
+	return accepted, nil
+}
+
+// AcceptRequest lets a provider take an open request directly, e.g. from the
+// list of available requests. An open offer the provider holds for it is
+// accepted along the way. See accept.
+func (uc *UseCase) AcceptRequest(ctx context.Context, requestID, providerID string) (*requestDomain.ServiceRequest, error) {
+	_, req, err := uc.accept(ctx, requestID, providerID, "")
+	if err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// accept assigns the request to providerID in one transaction: the offer
+// (dispatchID, or the provider's open offer when empty) becomes accepted, the
+// request moves to accepted with the provider, and every other open offer is
+// withdrawn and reported in dispatch.withdrawn. The request row is locked
+// first, so of two concurrent accepts the second one waits and then fails
+// with ErrAlreadyTaken. Providers at their category's capacity get
+// ErrAtCapacity, as offers sent before they took other jobs may still be open.
+func (uc *UseCase) accept(ctx context.Context, requestID, providerID, dispatchID string) (*domain.Dispatch, *requestDomain.ServiceRequest, error) {
+	var (
+		accepted  *domain.Dispatch
+		req       *requestDomain.ServiceRequest
+		withdrawn []*domain.Dispatch
+	)
+	err := uc.tx.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		req, err = uc.requestRepo.GetByIDForUpdate(ctx, requestID)
+		if err != nil {
+			return err
+		}
+		switch req.Status {
+		case requestDomain.StatusOpen:
+		case requestDomain.StatusCancelled:
+			return ErrInvalidAction
+		default:
+			return ErrAlreadyTaken
+		}
+		if capacity := uc.capacityFor(req.Category); capacity > 0 {
+			// Accepts of other requests by the same provider lock other
+			// request rows, so they wait on the provider's lock instead.
+			if err := uc.repo.LockProvider(ctx, providerID); err != nil {
+				return err
+			}
+			signals, err := uc.repo.ProviderSignals(ctx, []string{providerID})
+			if err != nil {
+				return err
+			}
+			if signals[providerID].ActiveJobs >= capacity {
+				return ErrAtCapacity
+			}
+		}
+
+		offers, err := uc.repo.LockByRequestID(ctx, requestID)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		for _, o := range offers {
+			if dispatchID != "" && o.ID == dispatchID || dispatchID == "" && o.ProviderID == providerID && o.IsOpen() {
+				accepted = o
+			}
+		}
+		if dispatchID != "" {
+			if accepted == nil || !accepted.IsOpen() {
+				return ErrInvalidAction
+			}
+			if accepted.IsExpired(now) {
+				return ErrOfferExpired
+			}
+		}
+
+		for _, o := range offers {
+			if o != accepted && o.IsOpen() {
+				withdrawn = append(withdrawn, o)
+			}
+		}
+
+		if accepted != nil {
+			accepted.Status = domain.DispatchAccepted
+			accepted.UpdatedAt = now
+			if err := uc.repo.Update(ctx, accepted); err != nil {
+				return err
+			}
+			err := uc.recordEvent(ctx, events.TopicDispatchAccepted, requestID, events.DispatchAcceptedEvent{
+				DispatchID:      accepted.ID,
+				RequestID:       requestID,
+				ProviderID:      providerID,
+				DistanceKm:      accepted.Distance,
+				OfferedAt:       accepted.CreatedAt,
+				RespondedAt:     now,
+				TimeToRespondMs: now.Sub(accepted.CreatedAt).Milliseconds(),
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		if err := uc.withdraw(ctx, requestID, providerID, withdrawn, now); err != nil {
+			return err
+		}
+
+		req.Status = requestDomain.StatusAccepted
+		req.ProviderID = providerID
+		req.AcceptedAt = &now
+		req.UpdatedAt = now
+		if err := uc.requestRepo.Update(ctx, req); err != nil {
+			return err
+		}
+		return uc.recordEvent(ctx, events.TopicRequestAccepted, requestID, events.RequestAcceptedEvent{
+			RequestID:  requestID,
+			CustomerID: req.CustomerID,
+			ProviderID: providerID,
+			Category:   req.Category,
+			AcceptedAt: now,
+		})
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	logger.Ctx(ctx).Info().
+		Str("request_id", requestID).
+		Str("provider_id", providerID).
+		Int("withdrawn_offers", len(withdrawn)).
+		Msg("Request accepted")
+	return accepted, req, nil
+}
+
+// RequestCancelled withdraws the open offers of a cancelled request, so they
+// leave their providers' inboxes now rather than expire, which would count
+// against the providers in their stats. It returns how many it withdrew.
+func (uc *UseCase) RequestCancelled(ctx context.Context, requestID string) (int, error) {
+	var withdrawn []*domain.Dispatch
+	err := uc.tx.WithinTx(ctx, func(ctx context.Context) error {
+		offers, err := uc.repo.LockByRequestID(ctx, requestID)
+		if err != nil {
+			return err
+		}
+		withdrawn = openOffers(offers)
+		return uc.withdraw(ctx, requestID, "", withdrawn, time.Now())
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(withdrawn), nil
+}
+
+// withdraw closes the given open offers of a request taken by takenBy, or
+// cancelled when takenBy is empty, and reports them in dispatch.withdrawn.
+func (uc *UseCase) withdraw(ctx context.Context, requestID, takenBy string, offers []*domain.Dispatch, now time.Time) error {
+	if len(offers) == 0 {
+		return nil
+	}
+	evt := events.DispatchWithdrawnEvent{RequestID: requestID, TakenBy: takenBy, WithdrawnAt: now}
+	for _, o := range offers {
+		o.Status = domain.DispatchWithdrawn
+		o.UpdatedAt = now
+		if err := uc.repo.Update(ctx, o); err != nil {
+			return err
+		}
+		evt.DispatchIDs = append(evt.DispatchIDs, o.ID)
+		evt.ProviderIDs = append(evt.ProviderIDs, o.ProviderID)
+	}
+	return uc.recordEvent(ctx, events.TopicDispatchWithdrawn, requestID, evt)
+}
@@ -0,0 +1,215 @@
+package dispatch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	requestDomain "github.com/pitgo/backend/internal/domain/request"
+	"github.com/pitgo/backend/internal/infrastructure/config"
+	"github.com/pitgo/backend/internal/infrastructure/database"
+	"github.com/pitgo/backend/internal/repository/postgres"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testPostgresPool connects to TEST_DATABASE_URL with a fresh schema holding
+// every migration. Tests using it are skipped when the variable is unset.
+func testPostgresPool(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	ctx := context.Background()
+
+	admin, err := pgxpool.New(ctx, dsn)
+	require.NoError(t, err)
+	t.Cleanup(admin.Close)
+	schema := fmt.Sprintf("test_accept_%d", time.Now().UnixNano())
+	_, err = admin.Exec(ctx, "CREATE SCHEMA "+schema)
+	require.NoError(t, err)
+	t.Cleanup(func() { _, _ = admin.Exec(context.Background(), "DROP SCHEMA "+schema+" CASCADE") })
+
+	cfg, err := pgxpool.ParseConfig(dsn)
+	require.NoError(t, err)
+	cfg.ConnConfig.RuntimeParams["search_path"] = schema
+	pool, err := pgxpool.NewWithConfig(ctx, cfg)
+	require.NoError(t, err)
+	t.Cleanup(pool.Close)
+
+	files, err := filepath.Glob("../../../migrations/*.up.sql")
+	require.NoError(t, err)
+	for _, file := range files {
+		sql, err := os.ReadFile(file)
+		require.NoError(t, err)
+		_, err = pool.Exec(ctx, string(sql))
+		require.NoError(t, err, file)
+	}
+	return pool
+}
+
+// seedOffers creates an open request and one open offer per provider.
+func seedOffers(t *testing.T, pool *pgxpool.Pool, providers int) (requestID string, offers map[string]string) {
+	t.Helper()
+	ctx := context.Background()
+	exec := func(sql string, args ...any) {
+		t.Helper()
+		_, err := pool.Exec(ctx, sql, args...)
+		require.NoError(t, err)
+	}
+	profile := func(kind string) string {
+		userID, profileID := uuid.New().String(), uuid.New().String()
+		exec(`INSERT INTO users (id, clerk_id, email, role) VALUES ($1, $1, $1 || '@pitgo.test', $2)`, userID, kind)
+		exec(`INSERT INTO profiles (id, user_id, type, first_name, last_name, phone) VALUES ($1, $2, $3, 'Test', 'User', '0')`, profileID, userID, kind)
+		return profileID
+	}
+
+	categoryID, serviceID := uuid.New().String(), uuid.New().String()
+	exec(`INSERT INTO categories (id, name, slug) VALUES ($1, 'Plumbing', $1)`, categoryID)
+	exec(`INSERT INTO services (id, category_id, name, slug) VALUES ($1, $2, 'Leak', $1)`, serviceID, categoryID)
+
+	requestID = uuid.New().String()
+	exec(`INSERT INTO service_requests (id, customer_id, service_id, category, status, scheduled_at, latitude, longitude)
+		  VALUES ($1, $2, $3, 'plumbing', 'open', NOW(), 0, 0)`, requestID, profile("customer"), serviceID)
+
+	offers = make(map[string]string, providers)
+	for i := 0; i < providers; i++ {
+		providerID, dispatchID := profile("provider"), uuid.New().String()
+		exec(`INSERT INTO dispatches (id, request_id, provider_id, status, expires_at) VALUES ($1, $2, $3, 'sent', NOW() + INTERVAL '5 minutes')`,
+			dispatchID, requestID, providerID)
+		offers[providerID] = dispatchID
+	}
+	return requestID, offers
+}
+
+func TestAcceptDispatch_ConcurrentProvidersOneWinner(t *testing.T) {
+	pool := testPostgresPool(t)
+	const providers = 8
+	requestID, offers := seedOffers(t, pool, providers)
+
+	uc := New(
+		postgres.NewDispatchRepository(pool),
+		postgres.NewProfileRepository(pool),
+		postgres.NewRequestRepository(pool),
+		database.NewTxManager(pool),
+		postgres.NewOutboxRepository(pool),
+		config.DispatchConfig{},
+		nil,
+	)
+
+	var (
+		mu      sync.Mutex
+		winners []string
+		taken   int
+		wg      sync.WaitGroup
+	)
+	start := make(chan struct{})
+	for providerID, dispatchID := range offers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			_, err := uc.AcceptDispatch(context.Background(), dispatchID, providerID)
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				winners = append(winners, providerID)
+			case errors.Is(err, ErrAlreadyTaken):
+				taken++
+			default:
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	require.Len(t, winners, 1)
+	assert.Equal(t, providers-1, taken)
+
+	req, err := postgres.NewRequestRepository(pool).GetByID(context.Background(), requestID)
+	require.NoError(t, err)
+	assert.Equal(t, requestDomain.StatusAccepted, req.Status)
+	assert.Equal(t, winners[0], req.ProviderID)
+
+	statuses := map[string]int{}
+	rows, err := pool.Query(context.Background(), `SELECT status, COUNT(*) FROM dispatches WHERE request_id = $1 GROUP BY status`, requestID)
+	require.NoError(t, err)
+	for rows.Next() {
+		var status string
+		var n int
+		require.NoError(t, rows.Scan(&status, &n))
+		statuses[status] = n
+	}
+	require.NoError(t, rows.Err())
+	assert.Equal(t, map[string]int{"accepted": 1, "withdrawn": providers - 1}, statuses)
+
+	var accepted int
+	require.NoError(t, pool.QueryRow(context.Background(), `SELECT COUNT(*) FROM outbox WHERE topic = 'request.accepted'`).Scan(&accepted))
+	assert.Equal(t, 1, accepted)
+}
+
+func TestAcceptDispatch_ConcurrentAcceptsRespectCapacity(t *testing.T) {
+	pool := testPostgresPool(t)
+	requestA, offersA := seedOffers(t, pool, 1)
+	requestB, _ := seedOffers(t, pool, 1)
+	var providerID, dispatchA string
+	for p, d := range offersA {
+		providerID, dispatchA = p, d
+	}
+	// The provider also holds an offer for the other request
+	dispatchB := uuid.New().String()
+	_, err := pool.Exec(context.Background(),
+		`INSERT INTO dispatches (id, request_id, provider_id, status, expires_at) VALUES ($1, $2, $3, 'sent', NOW() + INTERVAL '5 minutes')`,
+		dispatchB, requestB, providerID)
+	require.NoError(t, err)
+
+	uc := New(
+		postgres.NewDispatchRepository(pool),
+		postgres.NewProfileRepository(pool),
+		postgres.NewRequestRepository(pool),
+		database.NewTxManager(pool),
+		postgres.NewOutboxRepository(pool),
+		config.DispatchConfig{Capacity: 1},
+		nil,
+	)
+
+	errs := make(chan error, 2)
+	start := make(chan struct{})
+	for _, dispatchID := range []string{dispatchA, dispatchB} {
+		go func() {
+			<-start
+			_, err := uc.AcceptDispatch(context.Background(), dispatchID, providerID)
+			errs <- err
+		}()
+	}
+	close(start)
+
+	var accepted, atCapacity int
+	for range 2 {
+		switch err := <-errs; {
+		case err == nil:
+			accepted++
+		case errors.Is(err, ErrAtCapacity):
+			atCapacity++
+		default:
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	assert.Equal(t, 1, accepted)
+	assert.Equal(t, 1, atCapacity)
+
+	var taken int
+	require.NoError(t, pool.QueryRow(context.Background(),
+		`SELECT COUNT(*) FROM service_requests WHERE id IN ($1, $2) AND provider_id = $3`, requestA, requestB, providerID).Scan(&taken))
+	assert.Equal(t, 1, taken)
+}
@@ -0,0 +1,299 @@
+package dispatch
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	domain "github.com/pitgo/backend/internal/domain/dispatch"
+	"github.com/pitgo/backend/internal/domain/events"
+	profileDomain "github.com/pitgo/backend/internal/domain/profile"
+	requestDomain "github.com/pitgo/backend/internal/domain/request"
+	"github.com/pitgo/backend/internal/infrastructure/logger"
+)
+
+var (
+	ErrUnknownProvider = errors.New("unknown provider")
+	ErrOffersOpen      = errors.New("request still has open offers")
+)
+
+// Interventions lets admins override dispatch: hand a request to a provider,
+// take it back from one, or restart dispatch. Every intervention is stored
+// with the admin and their reason, in the transaction that records its events.
+type Interventions struct {
+	uc   *UseCase
+	repo domain.InterventionRepository
+}
+
+func NewInterventions(uc *UseCase, repo domain.InterventionRepository) *Interventions {
+	return &Interventions{uc: uc, repo: repo}
+}
+
+// Assign gives an open, scheduled or accepted request to providerID,
+// bypassing offers. Open offers are closed and reported in
+// dispatch.withdrawn, and a provider who had accepted the request loses it
+// in request.unassigned before request.accepted names the admin.
+func (iv *Interventions) Assign(ctx context.Context, requestID, providerID, adminID, reason string) (*requestDomain.ServiceRequest, error) {
+	p, err := iv.uc.profileRepo.GetByID(ctx, providerID)
+	if err != nil || p.Type != profileDomain.TypeProvider {
+		return nil, ErrUnknownProvider
+	}
+
+	var req *requestDomain.ServiceRequest
+	err = iv.uc.tx.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		req, err = iv.uc.requestRepo.GetByIDForUpdate(ctx, requestID)
+		if err != nil {
+			return err
+		}
+		var previous string
+		switch req.Status {
+		case requestDomain.StatusOpen, requestDomain.StatusScheduled:
+		case requestDomain.StatusAccepted:
+			if req.ProviderID == providerID {
+				return ErrInvalidAction
+			}
+			previous = req.ProviderID
+		default:
+			return ErrInvalidAction
+		}
+
+		offers, err := iv.uc.repo.LockByRequestID(ctx, requestID)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		if err := iv.uc.withdraw(ctx, requestID, providerID, openOffers(offers), now); err != nil {
+			return err
+		}
+		if previous != "" {
+			if err := iv.revoke(ctx, requestID, offers, previous, now); err != nil {
+				return err
+			}
+			err := iv.uc.recordEvent(ctx, events.TopicRequestUnassigned, requestID, events.RequestUnassignedEvent{
+				RequestID:    requestID,
+				CustomerID:   req.CustomerID,
+				ProviderID:   previous,
+				AssignedTo:   providerID,
+				UnassignedBy: adminID,
+				Reason:       reason,
+				UnassignedAt: now,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		req.Status = requestDomain.StatusAccepted
+		req.ProviderID = providerID
+		req.AcceptedAt = &now
+		req.UpdatedAt = now
+		if err := iv.uc.requestRepo.Update(ctx, req); err != nil {
+			return err
+		}
+		err = iv.uc.recordEvent(ctx, events.TopicRequestAccepted, requestID, events.RequestAcceptedEvent{
+			RequestID:  requestID,
+			CustomerID: req.CustomerID,
+			ProviderID: providerID,
+			Category:   req.Category,
+			AcceptedAt: now,
+			AssignedBy: adminID,
+			Reason:     reason,
+		})
+		if err != nil {
+			return err
+		}
+		return iv.record(ctx, requestID, domain.InterventionAssign, adminID, providerID, previous, reason, now)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Ctx(ctx).Info().
+		Str("request_id", requestID).
+		Str("provider_id", providerID).
+		Str("admin_id", adminID).
+		Msg("Request assigned by admin")
+	return req, nil
+}
+
+// Unassign takes an accepted request from its provider and reopens it,
+// recording request.unassigned. The dispatch worker then sends it the next
+// wave, which skips that provider, even if earlier waves were exhausted.
+func (iv *Interventions) Unassign(ctx context.Context, requestID, adminID, reason string) (*requestDomain.ServiceRequest, error) {
+	var req *requestDomain.ServiceRequest
+	var previous string
+	err := iv.uc.tx.WithinTx(ctx, func(ctx context.Context) error {
+		// Same lock order as NextWave
+		w, err := iv.uc.repo.LockWave(ctx, requestID)
+		if err != nil {
+			return err
+		}
+		req, err = iv.uc.requestRepo.GetByIDForUpdate(ctx, requestID)
+		if err != nil {
+			return err
+		}
+		if req.Status != requestDomain.StatusAccepted {
+			return ErrInvalidAction
+		}
+		previous = req.ProviderID
+
+		offers, err := iv.uc.repo.LockByRequestID(ctx, requestID)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		if err := iv.revoke(ctx, requestID, offers, previous, now); err != nil {
+			return err
+		}
+		req.Status = requestDomain.StatusOpen
+		req.ProviderID = ""
+		req.AcceptedAt = nil
+		req.UpdatedAt = now
+		if err := iv.uc.requestRepo.Update(ctx, req); err != nil {
+			return err
+		}
+		w.ExhaustedAt = nil
+		w.UpdatedAt = now
+		if err := iv.uc.repo.SaveWave(ctx, w); err != nil {
+			return err
+		}
+
+		err = iv.uc.recordEvent(ctx, events.TopicRequestUnassigned, requestID, events.RequestUnassignedEvent{
+			RequestID:    requestID,
+			CustomerID:   req.CustomerID,
+			ProviderID:   previous,
+			UnassignedBy: adminID,
+			Reason:       reason,
+			UnassignedAt: now,
+		})
+		if err != nil {
+			return err
+		}
+		return iv.record(ctx, requestID, domain.InterventionUnassign, adminID, "", previous, reason, now)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Ctx(ctx).Info().
+		Str("request_id", requestID).
+		Str("provider_id", previous).
+		Str("admin_id", adminID).
+		Msg("Request unassigned by admin")
+	return req, nil
+}
+
+// Redispatch restarts dispatch for an open request, e.g. after its waves
+// were exhausted or failed: it clears the exhaustion and records
+// request.redispatched, upon which the dispatch worker sends the next wave
+// to providers not offered the request yet. It fails with ErrOffersOpen while
+// offers still await an answer.
+func (iv *Interventions) Redispatch(ctx context.Context, requestID, adminID, reason string) (*requestDomain.ServiceRequest, error) {
+	var req *requestDomain.ServiceRequest
+	err := iv.uc.tx.WithinTx(ctx, func(ctx context.Context) error {
+		w, err := iv.uc.repo.LockWave(ctx, requestID)
+		if err != nil {
+			return err
+		}
+		req, err = iv.uc.requestRepo.GetByIDForUpdate(ctx, requestID)
+		if err != nil {
+			return err
+		}
+		if req.Status != requestDomain.StatusOpen {
+			return ErrInvalidAction
+		}
+		offers, err := iv.uc.repo.GetByRequestID(ctx, requestID)
+		if err != nil {
+			return err
+		}
+		if len(openOffers(offers)) > 0 {
+			return ErrOffersOpen
+		}
+
+		now := time.Now()
+		w.ExhaustedAt = nil
+		w.UpdatedAt = now
+		if err := iv.uc.repo.SaveWave(ctx, w); err != nil {
+			return err
+		}
+		err = iv.uc.recordEvent(ctx, events.TopicRequestRedispatched, requestID, events.RequestRedispatchedEvent{
+			RequestID:      requestID,
+			CustomerID:     req.CustomerID,
+			RedispatchedBy: adminID,
+			Reason:         reason,
+			RedispatchedAt: now,
+		})
+		if err != nil {
+			return err
+		}
+		return iv.record(ctx, requestID, domain.InterventionRedispatch, adminID, "", "", reason, now)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Ctx(ctx).Info().
+		Str("request_id", requestID).
+		Str("admin_id", adminID).
+		Msg("Request redispatched by admin")
+	return req, nil
+}
+
+// List returns the interventions on a request, oldest first.
+func (iv *Interventions) List(ctx context.Context, requestID string) ([]*domain.Intervention, error) {
+	return iv.repo.ListByRequest(ctx, requestID)
+}
+
+// revoke takes back the offer providerID accepted, so it no longer counts as
+// the request's accepted offer. Providers who got the request without an
+// offer, from an admin or by taking it directly, get a revoked one instead:
+// NextWave skips every provider with an offer for the request.
+func (iv *Interventions) revoke(ctx context.Context, requestID string, offers []*domain.Dispatch, providerID string, now time.Time) error {
+	offered := false
+	for _, o := range offers {
+		if o.ProviderID != providerID {
+			continue
+		}
+		offered = true
+		if o.Status != domain.DispatchAccepted {
+			continue
+		}
+		o.Status = domain.DispatchRevoked
+		o.UpdatedAt = now
+		if err := iv.uc.repo.Update(ctx, o); err != nil {
+			return err
+		}
+	}
+	if offered {
+		return nil
+	}
+	return iv.uc.repo.Create(ctx, &domain.Dispatch{
+		ID:         uuid.New().String(),
+		RequestID:  requestID,
+		ProviderID: providerID,
+		Status:     domain.DispatchRevoked,
+		ExpiresAt:  now,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	})
+}
+
+func (iv *Interventions) record(ctx context.Context, requestID string, action domain.InterventionAction, adminID, providerID, previousProviderID, reason string, now time.Time) error {
+	return iv.repo.Create(ctx, &domain.Intervention{
+		ID:                 uuid.New().String(),
+		RequestID:          requestID,
+		Action:             action,
+		AdminID:            adminID,
+		ProviderID:         providerID,
+		PreviousProviderID: previousProviderID,
+		Reason:             reason,
+		CreatedAt:          now,
+	})
+}
+
+func openOffers(offers []*domain.Dispatch) []*domain.Dispatch {
+	return slices.DeleteFunc(slices.Clone(offers), func(o *domain.Dispatch) bool { return !o.IsOpen() })
+}
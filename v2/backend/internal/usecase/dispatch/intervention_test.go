@@ -0,0 +1,187 @@
+package dispatch
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	domain "github.com/pitgo/backend/internal/domain/dispatch"
+	"github.com/pitgo/backend/internal/domain/events"
+	profileDomain "github.com/pitgo/backend/internal/domain/profile"
+	requestDomain "github.com/pitgo/backend/internal/domain/request"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (f *fakeProfileRepo) GetByID(_ context.Context, id string) (*profileDomain.Profile, error) {
+	for _, p := range f.providers {
+		if p.ProfileID == id {
+			return &profileDomain.Profile{ID: id, Type: profileDomain.TypeProvider}, nil
+		}
+	}
+	return nil, errors.New("not found")
+}
+
+type memoryInterventions struct {
+	saved []*domain.Intervention
+}
+
+func (m *memoryInterventions) Create(_ context.Context, i *domain.Intervention) error {
+	m.saved = append(m.saved, i)
+	return nil
+}
+
+func (m *memoryInterventions) ListByRequest(context.Context, string) ([]*domain.Intervention, error) {
+	return m.saved, nil
+}
+
+func setupInterventions(latitudes map[string]float64) (*Interventions, *memoryInterventions, *fakeRequestRepo, *fakeDispatchRepo, *fakeOutbox) {
+	uc, repo, ob := setupWaves(latitudes)
+	log := &memoryInterventions{}
+	return NewInterventions(uc, log), log, uc.requestRepo.(*fakeRequestRepo), repo, ob
+}
+
+func TestAssign_WithdrawsOpenOffers(t *testing.T) {
+	iv, log, requests, repo, ob := setupInterventions(map[string]float64{"prov-1": 0.02, "prov-2": 0.05, "prov-3": 0.5})
+	repo.byID["d-1"] = &domain.Dispatch{ID: "d-1", RequestID: "req-1", ProviderID: "prov-1", Status: domain.DispatchSent}
+	repo.byID["d-2"] = &domain.Dispatch{ID: "d-2", RequestID: "req-1", ProviderID: "prov-2", Status: domain.DispatchSent}
+
+	req, err := iv.Assign(context.Background(), "req-1", "prov-3", "admin-1", "customer asked for them")
+	require.NoError(t, err)
+	assert.Equal(t, requestDomain.StatusAccepted, req.Status)
+	assert.Equal(t, "prov-3", requests.req.ProviderID)
+	assert.Equal(t, domain.DispatchWithdrawn, repo.byID["d-1"].Status)
+	assert.Equal(t, domain.DispatchWithdrawn, repo.byID["d-2"].Status)
+
+	require.Len(t, ob.msgs, 2)
+	withdrawn := decodeAt[events.DispatchWithdrawnEvent](t, ob.msgs, 0, events.TopicDispatchWithdrawn)
+	assert.ElementsMatch(t, []string{"prov-1", "prov-2"}, withdrawn.ProviderIDs)
+	assert.Equal(t, "prov-3", withdrawn.TakenBy)
+	accepted := decodeAt[events.RequestAcceptedEvent](t, ob.msgs, 1, events.TopicRequestAccepted)
+	assert.Equal(t, "prov-3", accepted.ProviderID)
+	assert.Equal(t, "admin-1", accepted.AssignedBy)
+	assert.Equal(t, "customer asked for them", accepted.Reason)
+
+	require.Len(t, log.saved, 1)
+	assert.Equal(t, domain.InterventionAssign, log.saved[0].Action)
+	assert.Equal(t, "admin-1", log.saved[0].AdminID)
+	assert.Equal(t, "prov-3", log.saved[0].ProviderID)
+	assert.Empty(t, log.saved[0].PreviousProviderID)
+}
+
+func TestAssign_ReassignsAcceptedRequest(t *testing.T) {
+	iv, log, requests, repo, ob := setupInterventions(map[string]float64{"prov-1": 0.02, "prov-2": 0.05})
+	requests.req.Status, requests.req.ProviderID = requestDomain.StatusAccepted, "prov-1"
+	repo.byID["d-1"] = &domain.Dispatch{ID: "d-1", RequestID: "req-1", ProviderID: "prov-1", Status: domain.DispatchAccepted}
+
+	_, err := iv.Assign(context.Background(), "req-1", "prov-1", "admin-1", "again")
+	assert.ErrorIs(t, err, ErrInvalidAction, "already theirs")
+
+	_, err = iv.Assign(context.Background(), "req-1", "prov-2", "admin-1", "prov-1 went silent")
+	require.NoError(t, err)
+	assert.Equal(t, "prov-2", requests.req.ProviderID)
+	assert.Equal(t, domain.DispatchRevoked, repo.byID["d-1"].Status)
+
+	require.Len(t, ob.msgs, 2)
+	unassigned := decodeAt[events.RequestUnassignedEvent](t, ob.msgs, 0, events.TopicRequestUnassigned)
+	assert.Equal(t, "prov-1", unassigned.ProviderID)
+	assert.Equal(t, "prov-2", unassigned.AssignedTo)
+	assert.Equal(t, "admin-1", unassigned.UnassignedBy)
+	decodeAt[events.RequestAcceptedEvent](t, ob.msgs, 1, events.TopicRequestAccepted)
+
+	require.Len(t, log.saved, 1)
+	assert.Equal(t, "prov-1", log.saved[0].PreviousProviderID)
+}
+
+func TestAssign_UnknownProviderOrClosedRequest(t *testing.T) {
+	iv, log, requests, _, ob := setupInterventions(map[string]float64{"prov-1": 0.02})
+
+	_, err := iv.Assign(context.Background(), "req-1", "nobody", "admin-1", "typo")
+	assert.ErrorIs(t, err, ErrUnknownProvider)
+
+	requests.req.Status = requestDomain.StatusCompleted
+	_, err = iv.Assign(context.Background(), "req-1", "prov-1", "admin-1", "too late")
+	assert.ErrorIs(t, err, ErrInvalidAction)
+
+	assert.Empty(t, ob.msgs)
+	assert.Empty(t, log.saved)
+}
+
+func TestUnassign_ReopensRequestForNextWave(t *testing.T) {
+	iv, log, requests, repo, ob := setupInterventions(map[string]float64{"prov-1": 0.02, "prov-2": 0.05})
+	ctx := context.Background()
+
+	_, err := iv.Unassign(ctx, "req-1", "admin-1", "not accepted yet")
+	assert.ErrorIs(t, err, ErrInvalidAction)
+
+	now := time.Now()
+	requests.req.Status, requests.req.ProviderID, requests.req.AcceptedAt = requestDomain.StatusAccepted, "prov-1", &now
+	repo.byID["d-1"] = &domain.Dispatch{ID: "d-1", RequestID: "req-1", ProviderID: "prov-1", Status: domain.DispatchAccepted}
+	repo.waves["req-1"] = &domain.WaveState{RequestID: "req-1", Wave: 1, RadiusKm: 30, ExhaustedAt: &now}
+
+	req, err := iv.Unassign(ctx, "req-1", "admin-1", "no show")
+	require.NoError(t, err)
+	assert.Equal(t, requestDomain.StatusOpen, req.Status)
+	assert.Empty(t, req.ProviderID)
+	assert.Nil(t, req.AcceptedAt)
+	assert.Equal(t, domain.DispatchRevoked, repo.byID["d-1"].Status)
+	assert.Nil(t, repo.waves["req-1"].ExhaustedAt)
+
+	evt := decodeOnly[events.RequestUnassignedEvent](t, ob.msgs, events.TopicRequestUnassigned)
+	assert.Equal(t, "prov-1", evt.ProviderID)
+	assert.Empty(t, evt.AssignedTo)
+	assert.Equal(t, "no show", evt.Reason)
+	require.Len(t, log.saved, 1)
+	assert.Equal(t, domain.InterventionUnassign, log.saved[0].Action)
+
+	res, err := iv.uc.NextWave(ctx, "req-1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"prov-2"}, offeredTo(res), "the unassigned provider is not offered it again")
+}
+
+func TestRedispatch_ClearsExhaustionUnlessOffersOpen(t *testing.T) {
+	iv, log, _, repo, ob := setupInterventions(map[string]float64{"prov-1": 0.02})
+	ctx := context.Background()
+
+	repo.byID["d-1"] = &domain.Dispatch{ID: "d-1", RequestID: "req-1", ProviderID: "prov-1", Status: domain.DispatchSent}
+	_, err := iv.Redispatch(ctx, "req-1", "admin-1", "stuck")
+	assert.ErrorIs(t, err, ErrOffersOpen)
+	assert.Empty(t, ob.msgs)
+
+	now := time.Now()
+	repo.rejectAll("req-1")
+	repo.waves["req-1"] = &domain.WaveState{RequestID: "req-1", Wave: 3, RadiusKm: 30, ExhaustedAt: &now}
+	_, err = iv.Redispatch(ctx, "req-1", "admin-1", "new providers joined")
+	require.NoError(t, err)
+	assert.Nil(t, repo.waves["req-1"].ExhaustedAt)
+	assert.Equal(t, 3, repo.waves["req-1"].Wave)
+
+	evt := decodeOnly[events.RequestRedispatchedEvent](t, ob.msgs, events.TopicRequestRedispatched)
+	assert.Equal(t, "admin-1", evt.RedispatchedBy)
+	assert.Equal(t, "cust-1", evt.CustomerID)
+	require.Len(t, log.saved, 1)
+	assert.Equal(t, domain.InterventionRedispatch, log.saved[0].Action)
+	assert.Equal(t, "new providers joined", log.saved[0].Reason)
+}
+
+func TestUnassign_AfterAssignSkipsProviderWithoutOffer(t *testing.T) {
+	iv, _, _, repo, _ := setupInterventions(map[string]float64{"prov-1": 0.02, "prov-2": 0.05})
+	ctx := context.Background()
+
+	// prov-1 never got an offer for the request
+	_, err := iv.Assign(ctx, "req-1", "prov-1", "admin-1", "regular of this customer")
+	require.NoError(t, err)
+	_, err = iv.Unassign(ctx, "req-1", "admin-1", "went silent")
+	require.NoError(t, err)
+
+	offers, err := repo.GetByRequestID(ctx, "req-1")
+	require.NoError(t, err)
+	require.Len(t, offers, 1)
+	assert.Equal(t, "prov-1", offers[0].ProviderID)
+	assert.Equal(t, domain.DispatchRevoked, offers[0].Status)
+
+	res, err := iv.uc.NextWave(ctx, "req-1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"prov-2"}, offeredTo(res), "the unassigned provider is not offered it again")
+}
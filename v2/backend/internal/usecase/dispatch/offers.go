@@ -0,0 +1,122 @@
+package dispatch
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	domain "github.com/pitgo/backend/internal/domain/dispatch"
+	"github.com/pitgo/backend/internal/domain/events"
+)
+
+// Offer is an open offer as its provider sees it, with the whole seconds left
+// to answer it at the time it was built. Clients count down from there.
+type Offer struct {
+	*domain.Dispatch
+	RemainingSeconds int `json:"remaining_seconds"`
+}
+
+func NewOffer(d *domain.Dispatch, now time.Time) Offer {
+	remaining := math.Ceil(d.ExpiresAt.Sub(now).Seconds())
+	return Offer{Dispatch: d, RemainingSeconds: int(math.Max(remaining, 0))}
+}
+
+// PendingOffers returns the provider's offers that still await an answer,
+// soonest deadline first.
+func (uc *UseCase) PendingOffers(ctx context.Context, providerID string) ([]Offer, error) {
+	pending, err := uc.repo.GetPendingByProvider(ctx, providerID)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(pending, func(i, j int) bool { return pending[i].ExpiresAt.Before(pending[j].ExpiresAt) })
+
+	now := time.Now()
+	offers := make([]Offer, 0, len(pending))
+	for _, d := range pending {
+		if d.IsExpired(now) {
+			continue
+		}
+		offers = append(offers, NewOffer(d, now))
+	}
+	return offers, nil
+}
+
+// Offer notice types, as streamed to providers
+const (
+	NoticeOffer     = "offer"     // A new offer; Offer is set
+	NoticeExpired   = "expired"   // The offer timed out unanswered
+	NoticeTaken     = "taken"     // Another provider accepted the request first
+	NoticeCancelled = "cancelled" // The customer cancelled the request
+)
+
+// OfferNotice tells one provider that an offer of theirs appeared or closed.
+type OfferNotice struct {
+	ProviderID string `json:"-"`
+	Type       string `json:"type"`
+	DispatchID string `json:"dispatch_id"`
+	RequestID  string `json:"request_id"`
+	Offer      *Offer `json:"offer,omitempty"`
+}
+
+// OfferNotices returns what the providers concerned by env must be told:
+// new offers for dispatch.sent, and closed ones for dispatch.expired and
+// dispatch.withdrawn. Other topics, and dispatch.sent events recorded
+// without their offers, concern nobody.
+func OfferNotices(env *events.Envelope, now time.Time) ([]OfferNotice, error) {
+	switch env.Topic {
+	case events.TopicDispatchSent:
+		evt, err := events.DecodePayload[events.DispatchSentEvent](env)
+		if err != nil {
+			return nil, err
+		}
+		notices := make([]OfferNotice, 0, len(evt.Offers))
+		for _, o := range evt.Offers {
+			offer := NewOffer(&domain.Dispatch{
+				ID:         o.DispatchID,
+				RequestID:  evt.RequestID,
+				ProviderID: o.ProviderID,
+				Status:     domain.DispatchSent,
+				Distance:   o.DistanceKm,
+				Wave:       evt.Wave,
+				ExpiresAt:  o.ExpiresAt,
+				CreatedAt:  o.OfferedAt,
+				UpdatedAt:  o.OfferedAt,
+			}, now)
+			if offer.RemainingSeconds == 0 {
+				continue
+			}
+			notices = append(notices, OfferNotice{
+				ProviderID: o.ProviderID,
+				Type:       NoticeOffer,
+				DispatchID: o.DispatchID,
+				RequestID:  evt.RequestID,
+				Offer:      &offer,
+			})
+		}
+		return notices, nil
+	case events.TopicDispatchExpired:
+		evt, err := events.DecodePayload[events.DispatchExpiredEvent](env)
+		if err != nil {
+			return nil, err
+		}
+		return []OfferNotice{{ProviderID: evt.ProviderID, Type: NoticeExpired, DispatchID: evt.DispatchID, RequestID: evt.RequestID}}, nil
+	case events.TopicDispatchWithdrawn:
+		evt, err := events.DecodePayload[events.DispatchWithdrawnEvent](env)
+		if err != nil {
+			return nil, err
+		}
+		notice := NoticeTaken
+		if evt.TakenBy == "" {
+			notice = NoticeCancelled
+		}
+		n := min(len(evt.DispatchIDs), len(evt.ProviderIDs))
+		notices := make([]OfferNotice, n)
+		for i := range n {
+			notices[i] = OfferNotice{ProviderID: evt.ProviderIDs[i], Type: notice, DispatchID: evt.DispatchIDs[i], RequestID: evt.RequestID}
+		}
+		return notices, nil
+	default:
+		return nil, nil
+	}
+}
@@ -0,0 +1,97 @@
+package dispatch
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	domain "github.com/pitgo/backend/internal/domain/dispatch"
+	"github.com/pitgo/backend/internal/domain/events"
+	"github.com/pitgo/backend/internal/infrastructure/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (f *fakeDispatchRepo) GetPendingByProvider(_ context.Context, providerID string) ([]*domain.Dispatch, error) {
+	var out []*domain.Dispatch
+	for _, d := range f.byID {
+		if d.ProviderID == providerID && d.IsOpen() {
+			out = append(out, d)
+		}
+	}
+	return out, nil
+}
+
+func TestPendingOffers_SoonestDeadlineFirstWithoutExpired(t *testing.T) {
+	now := time.Now()
+	repo := &fakeDispatchRepo{byID: map[string]*domain.Dispatch{
+		"d-1": {ID: "d-1", RequestID: "req-1", ProviderID: "prov-1", Status: domain.DispatchSent, ExpiresAt: now.Add(90 * time.Second)},
+		"d-2": {ID: "d-2", RequestID: "req-2", ProviderID: "prov-1", Status: domain.DispatchSent, ExpiresAt: now.Add(30 * time.Second)},
+		"d-3": {ID: "d-3", RequestID: "req-3", ProviderID: "prov-1", Status: domain.DispatchSent, ExpiresAt: now.Add(-time.Second)},
+		"d-4": {ID: "d-4", RequestID: "req-1", ProviderID: "prov-2", Status: domain.DispatchSent, ExpiresAt: now.Add(time.Minute)},
+	}}
+	uc := New(repo, nil, nil, passthroughTx{}, &fakeOutbox{}, config.DispatchConfig{}, nil)
+
+	offers, err := uc.PendingOffers(context.Background(), "prov-1")
+	require.NoError(t, err)
+	require.Len(t, offers, 2, "the lapsed offer is left out")
+	assert.Equal(t, "d-2", offers[0].ID)
+	assert.Equal(t, 30, offers[0].RemainingSeconds)
+	assert.Equal(t, "d-1", offers[1].ID)
+	assert.Equal(t, 90, offers[1].RemainingSeconds)
+}
+
+func noticesFor(t *testing.T, topic string, payload any, now time.Time) []OfferNotice {
+	t.Helper()
+	env, err := events.NewEnvelope(topic, "req-1", "", payload)
+	require.NoError(t, err)
+	notices, err := OfferNotices(env, now)
+	require.NoError(t, err)
+	return notices
+}
+
+func TestOfferNotices(t *testing.T) {
+	now := time.Now()
+
+	sent := noticesFor(t, events.TopicDispatchSent, events.DispatchSentEvent{
+		RequestID: "req-1",
+		Wave:      2,
+		Offers: []events.SentOffer{
+			{DispatchID: "d-1", ProviderID: "prov-1", DistanceKm: 3.4, OfferedAt: now, ExpiresAt: now.Add(45 * time.Second)},
+			{DispatchID: "d-2", ProviderID: "prov-2", OfferedAt: now.Add(-time.Minute), ExpiresAt: now.Add(-time.Second)},
+		},
+	}, now)
+	require.Len(t, sent, 1, "offers that lapsed before delivery are not announced")
+	assert.Equal(t, "prov-1", sent[0].ProviderID)
+	assert.Equal(t, NoticeOffer, sent[0].Type)
+	require.NotNil(t, sent[0].Offer)
+	assert.Equal(t, 45, sent[0].Offer.RemainingSeconds)
+	assert.Equal(t, 2, sent[0].Offer.Wave)
+	assert.Equal(t, 3.4, sent[0].Offer.Distance)
+
+	assert.Empty(t, noticesFor(t, events.TopicDispatchSent, events.DispatchSentEvent{RequestID: "req-1"}, now),
+		"events recorded before offers were included concern nobody")
+
+	expired := noticesFor(t, events.TopicDispatchExpired, events.DispatchExpiredEvent{DispatchID: "d-1", RequestID: "req-1", ProviderID: "prov-1"}, now)
+	assert.Equal(t, []OfferNotice{{ProviderID: "prov-1", Type: NoticeExpired, DispatchID: "d-1", RequestID: "req-1"}}, expired)
+
+	taken := noticesFor(t, events.TopicDispatchWithdrawn, events.DispatchWithdrawnEvent{
+		RequestID:   "req-1",
+		TakenBy:     "prov-9",
+		DispatchIDs: []string{"d-1", "d-2"},
+		ProviderIDs: []string{"prov-1", "prov-2"},
+	}, now)
+	assert.Equal(t, []OfferNotice{
+		{ProviderID: "prov-1", Type: NoticeTaken, DispatchID: "d-1", RequestID: "req-1"},
+		{ProviderID: "prov-2", Type: NoticeTaken, DispatchID: "d-2", RequestID: "req-1"},
+	}, taken)
+
+	cancelled := noticesFor(t, events.TopicDispatchWithdrawn, events.DispatchWithdrawnEvent{
+		RequestID:   "req-1",
+		DispatchIDs: []string{"d-1"},
+		ProviderIDs: []string{"prov-1"},
+	}, now)
+	assert.Equal(t, []OfferNotice{{ProviderID: "prov-1", Type: NoticeCancelled, DispatchID: "d-1", RequestID: "req-1"}}, cancelled)
+
+	assert.Empty(t, noticesFor(t, events.TopicRequestCreated, map[string]string{}, now))
+}
@@ -0,0 +1,239 @@
+package dispatch
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	domain "github.com/pitgo/backend/internal/domain/dispatch"
+	profileDomain "github.com/pitgo/backend/internal/domain/profile"
+	"github.com/pitgo/backend/internal/infrastructure/config"
+)
+
+// Ranking strategies
+const (
+	StrategyNearest  = "nearest"
+	StrategyWeighted = "weighted"
+)
+
+var ErrInvalidRanking = errors.New("invalid ranking config")
+
+// Candidate is a provider eligible for an offer, with what ranking knows
+// about it. Score is set by the weighted strategy.
+type Candidate struct {
+	Provider   *profileDomain.ProviderDetails
+	DistanceKm float64
+	Signals    domain.ProviderSignals
+	Score      float64
+}
+
+// hasJobBefore reports whether the provider has an accepted job starting
+// before t.
+func (c Candidate) hasJobBefore(t time.Time) bool {
+	return c.Signals.NextJobAt != nil && c.Signals.NextJobAt.Before(t)
+}
+
+// Ranker orders candidates best first, in place. radiusKm is the search
+// radius the candidates were found in.
+type Ranker interface {
+	Rank(candidates []Candidate, radiusKm float64)
+	// UsesSignals reports whether Candidate.Signals must be loaded first.
+	UsesSignals() bool
+}
+
+// Scorer rates one aspect of a candidate between 0 (worst) and 1 (best).
+type Scorer func(c Candidate, radiusKm float64) float64
+
+// Scorers are the scorers a weighted ranking can combine, by config name.
+var Scorers = map[string]Scorer{
+	"distance": scoreDistance,
+	"rating":   scoreRating,
+	"jobs":     scoreJobs,
+	"verified": scoreVerified,
+	"acceptance": func(c Candidate, _ float64) float64 {
+		rate, ok := c.Signals.AcceptanceRate()
+		if !ok {
+			return 0.5 // No history yet: neither rewarded nor penalized
+		}
+		return rate
+	},
+	"load": func(c Candidate, _ float64) float64 {
+		return 1 / float64(1+c.Signals.OpenOffers+c.Signals.ActiveJobs)
+	},
+	// responsiveness halves at a one-minute median time-to-respond.
+	"responsiveness": func(c Candidate, _ float64) float64 {
+		if c.Signals.Answered == 0 {
+			return 0.5
+		}
+		return 1 / (1 + float64(c.Signals.MedianResponseMs)/60000)
+	},
+	"reliability": func(c Candidate, _ float64) float64 {
+		rate, ok := c.Signals.CancellationRate()
+		if !ok {
+			return 0.5
+		}
+		return 1 - rate
+	},
+}
+
+// signalScorers are the scorers that read Candidate.Signals.
+var signalScorers = map[string]bool{"acceptance": true, "load": true, "responsiveness": true, "reliability": true}
+
+// DefaultWeights apply to a weighted ranking configured without weights.
+var DefaultWeights = map[string]float64{
+	"distance":   0.4,
+	"rating":     0.2,
+	"jobs":       0.1,
+	"verified":   0.1,
+	"acceptance": 0.1,
+	"load":       0.1,
+}
+
+func scoreDistance(c Candidate, radiusKm float64) float64 {
+	if radiusKm <= 0 {
+		return 1 / (1 + c.DistanceKm)
+	}
+	return clamp01(1 - c.DistanceKm/radiusKm)
+}
+
+func scoreRating(c Candidate, _ float64) float64 {
+	return clamp01(c.Provider.Rating / 5)
+}
+
+// scoreJobs grows with experience and saturates at 100 jobs.
+func scoreJobs(c Candidate, _ float64) float64 {
+	return clamp01(math.Log1p(float64(c.Provider.TotalJobs)) / math.Log1p(100))
+}
+
+func scoreVerified(c Candidate, _ float64) float64 {
+	if c.Provider.IsVerified {
+		return 1
+	}
+	return 0
+}
+
+func clamp01(v float64) float64 {
+	return math.Max(0, math.Min(1, v))
+}
+
+// NearestRanker puts online providers first, then the closest ones.
+type NearestRanker struct{}
+
+func (NearestRanker) UsesSignals() bool { return false }
+
+func (NearestRanker) Rank(candidates []Candidate, _ float64) {
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].Provider.IsOnline != candidates[j].Provider.IsOnline {
+			return candidates[i].Provider.IsOnline
+		}
+		return candidates[i].DistanceKm < candidates[j].DistanceKm
+	})
+}
+
+type weightedScorer struct {
+	score  Scorer
+	weight float64
+}
+
+// WeightedRanker orders candidates by the weighted mean of its scorers;
+// ties go to the closest provider.
+type WeightedRanker struct {
+	scorers []weightedScorer
+	total   float64
+	signals bool
+}
+
+// NewWeightedRanker combines the named Scorers. Unknown names and weights
+// that are all zero are rejected.
+func NewWeightedRanker(weights map[string]float64) (*WeightedRanker, error) {
+	names := make([]string, 0, len(weights))
+	for name := range weights {
+		names = append(names, name)
+	}
+	sort.Strings(names) // deterministic float sums
+
+	r := &WeightedRanker{}
+	for _, name := range names {
+		score, ok := Scorers[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown scorer %q", ErrInvalidRanking, name)
+		}
+		w := weights[name]
+		if w <= 0 {
+			continue
+		}
+		r.scorers = append(r.scorers, weightedScorer{score: score, weight: w})
+		r.total += w
+		r.signals = r.signals || signalScorers[name]
+	}
+	if r.total == 0 {
+		return nil, fmt.Errorf("%w: no positive weight", ErrInvalidRanking)
+	}
+	return r, nil
+}
+
+func (r *WeightedRanker) UsesSignals() bool { return r.signals }
+
+func (r *WeightedRanker) Rank(candidates []Candidate, radiusKm float64) {
+	for i := range candidates {
+		var sum float64
+		for _, s := range r.scorers {
+			sum += s.weight * s.score(candidates[i], radiusKm)
+		}
+		candidates[i].Score = sum / r.total
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].Score != candidates[j].Score {
+			return candidates[i].Score > candidates[j].Score
+		}
+		return candidates[i].DistanceKm < candidates[j].DistanceKm
+	})
+}
+
+// NewRanker builds the Ranker a RankingConfig describes. An empty strategy
+// means nearest.
+func NewRanker(rc config.RankingConfig) (Ranker, error) {
+	switch rc.Strategy {
+	case "", StrategyNearest:
+		return NearestRanker{}, nil
+	case StrategyWeighted:
+		weights := rc.Weights
+		if len(weights) == 0 {
+			weights = DefaultWeights
+		}
+		return NewWeightedRanker(weights)
+	default:
+		return nil, fmt.Errorf("%w: unknown strategy %q", ErrInvalidRanking, rc.Strategy)
+	}
+}
+
+// Ranking picks the Ranker for a request's category.
+type Ranking struct {
+	fallback   Ranker
+	byCategory map[string]Ranker
+}
+
+// NewRanking builds the default and per-category rankers of cfg.
+func NewRanking(cfg config.DispatchConfig) (*Ranking, error) {
+	fallback, err := NewRanker(cfg.Ranking)
+	if err != nil {
+		return nil, err
+	}
+	r := &Ranking{fallback: fallback, byCategory: make(map[string]Ranker, len(cfg.CategoryRanking))}
+	for category, rc := range cfg.CategoryRanking {
+		if r.byCategory[category], err = NewRanker(rc); err != nil {
+			return nil, fmt.Errorf("category %q: %w", category, err)
+		}
+	}
+	return r, nil
+}
+
+// For returns the category's ranker, or the default one.
+func (r *Ranking) For(category string) Ranker {
+	if rk, ok := r.byCategory[category]; ok {
+		return rk
+	}
+	return r.fallback
+}
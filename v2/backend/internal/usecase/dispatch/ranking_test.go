@@ -0,0 +1,126 @@
+package dispatch
+
+import (
+	"context"
+	"testing"
+
+	domain "github.com/pitgo/backend/internal/domain/dispatch"
+	profileDomain "github.com/pitgo/backend/internal/domain/profile"
+	"github.com/pitgo/backend/internal/infrastructure/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func providerIDs(candidates []Candidate) []string {
+	ids := make([]string, len(candidates))
+	for i, c := range candidates {
+		ids[i] = c.Provider.ProfileID
+	}
+	return ids
+}
+
+func TestNearestRanker_OnlineFirstThenDistance(t *testing.T) {
+	candidates := []Candidate{
+		{Provider: &profileDomain.ProviderDetails{ProfileID: "far-online", IsOnline: true}, DistanceKm: 9},
+		{Provider: &profileDomain.ProviderDetails{ProfileID: "near-offline"}, DistanceKm: 1},
+		{Provider: &profileDomain.ProviderDetails{ProfileID: "near-online", IsOnline: true}, DistanceKm: 2},
+	}
+	NearestRanker{}.Rank(candidates, 10)
+	assert.Equal(t, []string{"near-online", "far-online", "near-offline"}, providerIDs(candidates))
+}
+
+func TestWeightedRanker_CombinesScorers(t *testing.T) {
+	r, err := NewWeightedRanker(map[string]float64{"distance": 1, "rating": 1, "load": 1})
+	require.NoError(t, err)
+	assert.True(t, r.UsesSignals())
+
+	candidates := []Candidate{
+		{Provider: &profileDomain.ProviderDetails{ProfileID: "close-poor", Rating: 2}, DistanceKm: 1},
+		{Provider: &profileDomain.ProviderDetails{ProfileID: "mid-great", Rating: 5}, DistanceKm: 4},
+		{Provider: &profileDomain.ProviderDetails{ProfileID: "mid-great-busy", Rating: 5}, DistanceKm: 4, Signals: domain.ProviderSignals{OpenOffers: 2, ActiveJobs: 1}},
+	}
+	r.Rank(candidates, 10)
+	assert.Equal(t, []string{"mid-great", "close-poor", "mid-great-busy"}, providerIDs(candidates))
+	// (0.6 + 1 + 1) / 3
+	assert.InDelta(t, 0.867, candidates[0].Score, 0.001)
+}
+
+func TestWeightedRanker_AcceptanceIsNeutralWithoutHistory(t *testing.T) {
+	r, err := NewWeightedRanker(map[string]float64{"acceptance": 1})
+	require.NoError(t, err)
+	candidates := []Candidate{
+		{Provider: &profileDomain.ProviderDetails{ProfileID: "flaky"}, Signals: domain.ProviderSignals{Answered: 10, Accepted: 2}},
+		{Provider: &profileDomain.ProviderDetails{ProfileID: "new"}},
+		{Provider: &profileDomain.ProviderDetails{ProfileID: "reliable"}, Signals: domain.ProviderSignals{Answered: 10, Accepted: 9}},
+	}
+	r.Rank(candidates, 10)
+	assert.Equal(t, []string{"reliable", "new", "flaky"}, providerIDs(candidates))
+}
+
+func TestWeightedRanker_ResponsivenessAndReliability(t *testing.T) {
+	r, err := NewWeightedRanker(map[string]float64{"responsiveness": 1, "reliability": 1})
+	require.NoError(t, err)
+	assert.True(t, r.UsesSignals())
+	candidates := []Candidate{
+		{Provider: &profileDomain.ProviderDetails{ProfileID: "slow"}, Signals: domain.ProviderSignals{Answered: 5, MedianResponseMs: 240000}},
+		{Provider: &profileDomain.ProviderDetails{ProfileID: "new"}},
+		{Provider: &profileDomain.ProviderDetails{ProfileID: "quick"}, Signals: domain.ProviderSignals{Answered: 5, MedianResponseMs: 6000, JobsAccepted: 4}},
+		{Provider: &profileDomain.ProviderDetails{ProfileID: "quick-cancels"}, Signals: domain.ProviderSignals{Answered: 5, MedianResponseMs: 6000, JobsAccepted: 4, CancelledAfterAccept: 3}},
+	}
+	r.Rank(candidates, 10)
+	assert.Equal(t, []string{"quick", "quick-cancels", "new", "slow"}, providerIDs(candidates))
+}
+
+func TestNewRanking_ValidatesAndPicksByCategory(t *testing.T) {
+	_, err := NewRanking(config.DispatchConfig{Ranking: config.RankingConfig{Strategy: "random"}})
+	assert.ErrorIs(t, err, ErrInvalidRanking)
+	_, err = NewRanking(config.DispatchConfig{Ranking: config.RankingConfig{Strategy: StrategyWeighted, Weights: map[string]float64{"charisma": 1}}})
+	assert.ErrorIs(t, err, ErrInvalidRanking)
+	_, err = NewRanking(config.DispatchConfig{Ranking: config.RankingConfig{Strategy: StrategyWeighted, Weights: map[string]float64{"rating": 0}}})
+	assert.ErrorIs(t, err, ErrInvalidRanking)
+
+	ranking, err := NewRanking(config.DispatchConfig{
+		CategoryRanking: map[string]config.RankingConfig{"cleaning": {Strategy: StrategyWeighted}},
+	})
+	require.NoError(t, err)
+	assert.IsType(t, NearestRanker{}, ranking.For("plumbing"))
+	assert.IsType(t, &WeightedRanker{}, ranking.For("cleaning"))
+}
+
+type signalsRepo struct {
+	fakeDispatchRepo
+	signals map[string]domain.ProviderSignals
+	asked   []string
+}
+
+func (s *signalsRepo) ProviderSignals(_ context.Context, providerIDs []string) (map[string]domain.ProviderSignals, error) {
+	s.asked = providerIDs
+	return s.signals, nil
+}
+
+func TestMatchProviders_UsesCategoryRankingWithSignals(t *testing.T) {
+	repo := &signalsRepo{signals: map[string]domain.ProviderSignals{
+		"near": {Answered: 10, Accepted: 1},
+		"far":  {Answered: 10, Accepted: 10},
+	}}
+	profiles := &fakeProfileRepo{providers: []*profileDomain.ProviderDetails{
+		{ProfileID: "near", Latitude: 0.01},
+		{ProfileID: "far", Latitude: 0.05},
+		{ProfileID: "outside", Latitude: 0.5},
+	}}
+	ranking, err := NewRanking(config.DispatchConfig{
+		CategoryRanking: map[string]config.RankingConfig{"plumbing": {Strategy: StrategyWeighted, Weights: map[string]float64{"acceptance": 1}}},
+	})
+	require.NoError(t, err)
+	uc := New(repo, profiles, nil, passthroughTx{}, &fakeOutbox{}, config.DispatchConfig{}, ranking)
+
+	matched, err := uc.MatchProviders(context.Background(), domain.MatchCriteria{RadiusKm: 10, Category: "plumbing"})
+	require.NoError(t, err)
+	require.Len(t, matched, 2)
+	assert.Equal(t, "far", matched[0].ProviderID)
+	assert.ElementsMatch(t, []string{"near", "far"}, repo.asked)
+
+	matched, err = uc.MatchProviders(context.Background(), domain.MatchCriteria{RadiusKm: 10, Category: "cleaning"})
+	require.NoError(t, err)
+	assert.Equal(t, "near", matched[0].ProviderID, "default ranking is nearest")
+}
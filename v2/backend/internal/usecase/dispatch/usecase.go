@@ -9,60 +9,108 @@ import (
 
 	"github.com/google/uuid"
 	domain "github.com/pitgo/backend/internal/domain/dispatch"
+	"github.com/pitgo/backend/internal/domain/events"
+	"github.com/pitgo/backend/internal/domain/geo"
+	"github.com/pitgo/backend/internal/domain/outbox"
 	profileDomain "github.com/pitgo/backend/internal/domain/profile"
+	requestDomain "github.com/pitgo/backend/internal/domain/request"
+	"github.com/pitgo/backend/internal/infrastructure/config"
+	"github.com/pitgo/backend/internal/infrastructure/database"
 	"github.com/pitgo/backend/internal/infrastructure/logger"
 )
 
-var ErrInvalidAction = errors.New("invalid dispatch action")
+var (
+	ErrInvalidAction = errors.New("invalid dispatch action")
+	ErrOfferExpired  = errors.New("dispatch offer expired")
+	ErrAlreadyTaken  = errors.New("request already taken by another provider")
+	ErrAtCapacity    = errors.New("provider has reached their job capacity")
+)
 
 type UseCase struct {
 	repo        domain.Repository
 	profileRepo profileDomain.Repository
+	requestRepo requestDomain.Repository
+	tx          database.Transactor
+	outbox      outbox.Repository
+	cfg         config.DispatchConfig
+	ranking     *Ranking
 }
 
-func New(repo domain.Repository, profileRepo profileDomain.Repository) *UseCase {
-	return &UseCase{repo: repo, profileRepo: profileRepo}
+func New(
+	repo domain.Repository,
+	profileRepo profileDomain.Repository,
+	requestRepo requestDomain.Repository,
+	tx database.Transactor,
+	outboxRepo outbox.Repository,
+	cfg config.DispatchConfig,
+	ranking *Ranking,
+) *UseCase {
+	if cfg.OfferTTL <= 0 {
+		cfg.OfferTTL = 5 * time.Minute
+	}
+	if cfg.WaveSize <= 0 {
+		cfg.WaveSize = 5
+	}
+	if cfg.InitialRadiusKm <= 0 {
+		cfg.InitialRadiusKm = 10
+	}
+	if cfg.MaxRadiusKm < cfg.InitialRadiusKm {
+		cfg.MaxRadiusKm = cfg.InitialRadiusKm
+	}
+	// A non-positive step would never reach MaxRadiusKm
+	if cfg.RadiusStepKm <= 0 {
+		cfg.RadiusStepKm = cfg.MaxRadiusKm - cfg.InitialRadiusKm
+		if cfg.RadiusStepKm <= 0 {
+			cfg.RadiusStepKm = 1
+		}
+	}
+	if cfg.LocationTTL <= 0 {
+		cfg.LocationTTL = 2 * time.Minute
+	}
+	if ranking == nil {
+		ranking = &Ranking{fallback: NearestRanker{}}
+	}
+	return &UseCase{repo: repo, profileRepo: profileRepo, requestRepo: requestRepo, tx: tx, outbox: outboxRepo, cfg: cfg, ranking: ranking}
 }
 
-// MatchProviders finds providers within the given radius and category, sorted by distance.
-func (uc *UseCase) MatchProviders(ctx context.Context, criteria domain.MatchCriteria) ([]*domain.Dispatch, error) {
-	providers, err := uc.profileRepo.FindProvidersInRadius(ctx, criteria.Latitude, criteria.Longitude, criteria.RadiusKm, criteria.Category)
+// recordEvent stores the event in the outbox, keyed by the service request
+// and correlated with ctx. Call it inside WithinTx so it commits with the
+// dispatch change.
+func (uc *UseCase) recordEvent(ctx context.Context, topic, requestID string, payload any) error {
+	env, err := events.NewEnvelope(topic, requestID, logger.CorrelationID(ctx), payload)
 	if err != nil {
-		return nil, err
+		return err
 	}
-
-	type ranked struct {
-		provider *profileDomain.ProviderDetails
-		distance float64
+	msg, err := outbox.NewMessage(env)
+	if err != nil {
+		return err
 	}
+	return uc.outbox.Add(ctx, msg)
+}
 
-	var candidates []ranked
-	for _, p := range providers {
-		dist := haversine(criteria.Latitude, criteria.Longitude, p.Latitude, p.Longitude)
-		if dist <= criteria.RadiusKm {
-			candidates = append(candidates, ranked{provider: p, distance: dist})
-		}
+// MatchProviders finds providers within the given radius and category,
+// ordered by the category's ranking.
+func (uc *UseCase) MatchProviders(ctx context.Context, criteria domain.MatchCriteria) ([]*domain.Dispatch, error) {
+	candidates, _, err := uc.rankedCandidates(ctx, criteria.Latitude, criteria.Longitude, criteria.RadiusKm, criteria.Category, nil)
+	if err != nil {
+		return nil, err
 	}
 
-	sort.Slice(candidates, func(i, j int) bool {
-		return candidates[i].distance < candidates[j].distance
-	})
-
 	var dispatches []*domain.Dispatch
 	for _, c := range candidates {
 		d := &domain.Dispatch{
 			ID:         uuid.New().String(),
-			ProviderID: c.provider.ProfileID,
+			ProviderID: c.Provider.ProfileID,
 			Status:     domain.DispatchPending,
-			Distance:   math.Round(c.distance*100) / 100,
-			ExpiresAt:  time.Now().Add(5 * time.Minute),
+			Distance:   math.Round(c.DistanceKm*100) / 100,
+			ExpiresAt:  time.Now().Add(uc.cfg.OfferTTL),
 			CreatedAt:  time.Now(),
 			UpdatedAt:  time.Now(),
 		}
 		dispatches = append(dispatches, d)
 	}
 
-	logger.Info().
+	logger.Ctx(ctx).Info().
 		Int("candidates", len(dispatches)).
 		Float64("radius_km", criteria.RadiusKm).
 		Str("category", criteria.Category).
@@ -71,49 +119,147 @@ func (uc *UseCase) MatchProviders(ctx context.Context, criteria domain.MatchCrit
 	return dispatches, nil
 }
 
+// rankedCandidates returns the providers of category within radiusKm of
+// (lat, lng) and within their own service area, except those in exclude,
+// best first. Distances are from the provider's live location when fresh.
+// Providers at capacity are left out and counted in busy; those with a job
+// starting within BusyWindow come last.
+func (uc *UseCase) rankedCandidates(ctx context.Context, lat, lng, radiusKm float64, category string, exclude map[string]bool) (candidates []Candidate, busy int, err error) {
+	providers, err := uc.profileRepo.FindProvidersInRadius(ctx, lat, lng, radiusKm, category, time.Now().Add(-uc.cfg.LocationTTL))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	for _, p := range providers {
+		if exclude[p.ProfileID] {
+			continue
+		}
+		dist := geo.Distance(lat, lng, p.Latitude, p.Longitude)
+		if dist > radiusKm || p.ServiceArea > 0 && dist > p.ServiceArea {
+			continue
+		}
+		candidates = append(candidates, Candidate{Provider: p, DistanceKm: dist})
+	}
+
+	ranker := uc.ranking.For(category)
+	capacity := uc.capacityFor(category)
+	if (ranker.UsesSignals() || capacity > 0 || uc.cfg.BusyWindow > 0) && len(candidates) > 0 {
+		ids := make([]string, len(candidates))
+		for i, c := range candidates {
+			ids[i] = c.Provider.ProfileID
+		}
+		signals, err := uc.repo.ProviderSignals(ctx, ids)
+		if err != nil {
+			return nil, 0, err
+		}
+		for i := range candidates {
+			candidates[i].Signals = signals[candidates[i].Provider.ProfileID]
+		}
+	}
+
+	if capacity > 0 {
+		free := candidates[:0]
+		for _, c := range candidates {
+			if c.Signals.ActiveJobs >= capacity {
+				busy++
+				continue
+			}
+			free = append(free, c)
+		}
+		candidates = free
+	}
+	ranker.Rank(candidates, radiusKm)
+	if uc.cfg.BusyWindow > 0 {
+		soon := time.Now().Add(uc.cfg.BusyWindow)
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return !candidates[i].hasJobBefore(soon) && candidates[j].hasJobBefore(soon)
+		})
+	}
+	return candidates, busy, nil
+}
+
+// capacityFor returns how many concurrent jobs a provider of category may
+// hold, 0 meaning unlimited.
+func (uc *UseCase) capacityFor(category string) int {
+	if n, ok := uc.cfg.CategoryCapacity[category]; ok {
+		return n
+	}
+	return uc.cfg.Capacity
+}
+
 func (uc *UseCase) CreateDispatch(ctx context.Context, d *domain.Dispatch) error {
 	return uc.repo.Create(ctx, d)
 }
 
-func (uc *UseCase) AcceptDispatch(ctx context.Context, id, providerID string) (*domain.Dispatch, error) {
-	d, err := uc.repo.GetByID(ctx, id)
+func (uc *UseCase) RejectDispatch(ctx context.Context, id, providerID string) (*domain.Dispatch, error) {
+	var d *domain.Dispatch
+	err := uc.tx.WithinTx(ctx, func(ctx context.Context) error {
+		// The offer stays locked until the event is recorded, so an
+		// accept or ExpireStale racing this call waits and then finds
+		// it closed.
+		var err error
+		d, err = uc.repo.GetByIDForUpdate(ctx, id)
+		if err != nil {
+			return err
+		}
+		if d.ProviderID != providerID || !d.IsOpen() {
+			return ErrInvalidAction
+		}
+		now := time.Now()
+		if d.IsExpired(now) {
+			return ErrOfferExpired
+		}
+		d.Status = domain.DispatchRejected
+		d.UpdatedAt = now
+		if err := uc.repo.Update(ctx, d); err != nil {
+			return err
+		}
+		return uc.recordEvent(ctx, events.TopicDispatchRejected, d.RequestID, events.DispatchRejectedEvent{
+			DispatchID:      d.ID,
+			RequestID:       d.RequestID,
+			ProviderID:      d.ProviderID,
+			DistanceKm:      d.Distance,
+			OfferedAt:       d.CreatedAt,
+			RespondedAt:     d.UpdatedAt,
+			TimeToRespondMs: d.UpdatedAt.Sub(d.CreatedAt).Milliseconds(),
+		})
+	})
 	if err != nil {
 		return nil, err
 	}
-	if d.ProviderID != providerID || d.Status != domain.DispatchPending {
-		return nil, ErrInvalidAction
-	}
-	d.Status = domain.DispatchAccepted
-	d.UpdatedAt = time.Now()
-	if err := uc.repo.Update(ctx, d); err != nil {
-		return nil, err
-	}
 	return d, nil
 }
 
-func (uc *UseCase) RejectDispatch(ctx context.Context, id, providerID string) (*domain.Dispatch, error) {
-	d, err := uc.repo.GetByID(ctx, id)
+// ExpireStale expires every open offer past its deadline and records a
+// dispatch.expired event for each, all in one transaction.
+func (uc *UseCase) ExpireStale(ctx context.Context) ([]*domain.Dispatch, error) {
+	var expired []*domain.Dispatch
+	err := uc.tx.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		expired, err = uc.repo.ExpireOld(ctx)
+		if err != nil {
+			return err
+		}
+		for _, d := range expired {
+			err := uc.recordEvent(ctx, events.TopicDispatchExpired, d.RequestID, events.DispatchExpiredEvent{
+				DispatchID: d.ID,
+				RequestID:  d.RequestID,
+				ProviderID: d.ProviderID,
+				DistanceKm: d.Distance,
+				OfferedAt:  d.CreatedAt,
+				ExpiredAt:  d.UpdatedAt,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
-	if d.ProviderID != providerID || d.Status != domain.DispatchPending {
-		return nil, ErrInvalidAction
-	}
-	d.Status = domain.DispatchRejected
-	d.UpdatedAt = time.Now()
-	if err := uc.repo.Update(ctx, d); err != nil {
-		return nil, err
+	if len(expired) > 0 {
+		logger.Ctx(ctx).Info().Int("count", len(expired)).Msg("Expired stale dispatch offers")
 	}
-	return d, nil
-}
-
-func haversine(lat1, lng1, lat2, lng2 float64) float64 {
-	const r = 6371
-	dLat := (lat2 - lat1) * math.Pi / 180
-	dLng := (lng2 - lng1) * math.Pi / 180
-	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
-		math.Cos(lat1*math.Pi/180)*math.Cos(lat2*math.Pi/180)*
-			math.Sin(dLng/2)*math.Sin(dLng/2)
-	c := 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
-	return r * c
+	return expired, nil
 }
@@ -0,0 +1,514 @@
+package dispatch
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	domain "github.com/pitgo/backend/internal/domain/dispatch"
+	"github.com/pitgo/backend/internal/domain/events"
+	"github.com/pitgo/backend/internal/domain/outbox"
+	profileDomain "github.com/pitgo/backend/internal/domain/profile"
+	requestDomain "github.com/pitgo/backend/internal/domain/request"
+	"github.com/pitgo/backend/internal/infrastructure/config"
+	"github.com/pitgo/backend/internal/infrastructure/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeDispatchRepo struct {
+	domain.Repository
+	byID    map[string]*domain.Dispatch
+	expired []*domain.Dispatch
+	waves   map[string]*domain.WaveState
+}
+
+func (f *fakeDispatchRepo) Create(_ context.Context, d *domain.Dispatch) error {
+	f.byID[d.ID] = d
+	return nil
+}
+
+func (f *fakeDispatchRepo) GetByRequestID(_ context.Context, requestID string) ([]*domain.Dispatch, error) {
+	var out []*domain.Dispatch
+	for _, d := range f.byID {
+		if d.RequestID == requestID {
+			out = append(out, d)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeDispatchRepo) LockByRequestID(ctx context.Context, requestID string) ([]*domain.Dispatch, error) {
+	return f.GetByRequestID(ctx, requestID)
+}
+
+func (f *fakeDispatchRepo) LockWave(_ context.Context, requestID string) (*domain.WaveState, error) {
+	w, ok := f.waves[requestID]
+	if !ok {
+		w = &domain.WaveState{RequestID: requestID}
+	}
+	cp := *w
+	return &cp, nil
+}
+
+func (f *fakeDispatchRepo) SaveWave(_ context.Context, w *domain.WaveState) error {
+	f.waves[w.RequestID] = w
+	return nil
+}
+
+func (f *fakeDispatchRepo) ListStalled(ctx context.Context, _ int) ([]string, error) {
+	var ids []string
+	for id, w := range f.waves {
+		if w.ExhaustedAt != nil {
+			continue
+		}
+		offers, _ := f.GetByRequestID(ctx, id)
+		if !slices.ContainsFunc(offers, func(d *domain.Dispatch) bool { return d.IsOpen() || d.Status == domain.DispatchAccepted }) {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func (f *fakeDispatchRepo) ListStalledFor(ctx context.Context, _ string, _ float64, limit int) ([]string, error) {
+	return f.ListStalled(ctx, limit)
+}
+
+// rejectAll answers every open offer of the request with a rejection.
+func (f *fakeDispatchRepo) rejectAll(requestID string) {
+	for _, d := range f.byID {
+		if d.RequestID == requestID && d.IsOpen() {
+			d.Status = domain.DispatchRejected
+		}
+	}
+}
+
+type fakeProfileRepo struct {
+	profileDomain.Repository
+	providers    []*profileDomain.ProviderDetails
+	locatedSince time.Time
+}
+
+func (f *fakeProfileRepo) FindProvidersInRadius(_ context.Context, _, _, _ float64, _ string, locatedSince time.Time) ([]*profileDomain.ProviderDetails, error) {
+	f.locatedSince = locatedSince
+	return f.providers, nil
+}
+
+type fakeRequestRepo struct {
+	requestDomain.Repository
+	req *requestDomain.ServiceRequest
+}
+
+func (f *fakeRequestRepo) GetByID(context.Context, string) (*requestDomain.ServiceRequest, error) {
+	return f.req, nil
+}
+
+func (f *fakeRequestRepo) GetByIDForUpdate(ctx context.Context, id string) (*requestDomain.ServiceRequest, error) {
+	return f.GetByID(ctx, id)
+}
+
+func (f *fakeRequestRepo) Update(_ context.Context, req *requestDomain.ServiceRequest) error {
+	f.req = req
+	return nil
+}
+
+func openRequest() *fakeRequestRepo {
+	return &fakeRequestRepo{req: &requestDomain.ServiceRequest{ID: "req-1", CustomerID: "cust-1", Category: "plumbing", Status: requestDomain.StatusOpen}}
+}
+
+func (f *fakeDispatchRepo) GetByID(_ context.Context, id string) (*domain.Dispatch, error) {
+	d, ok := f.byID[id]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	cp := *d
+	return &cp, nil
+}
+
+func (f *fakeDispatchRepo) LockProvider(context.Context, string) error { return nil }
+
+func (f *fakeDispatchRepo) GetByIDForUpdate(ctx context.Context, id string) (*domain.Dispatch, error) {
+	return f.GetByID(ctx, id)
+}
+
+func (f *fakeDispatchRepo) Update(_ context.Context, d *domain.Dispatch) error {
+	f.byID[d.ID] = d
+	return nil
+}
+
+func (f *fakeDispatchRepo) ExpireOld(context.Context) ([]*domain.Dispatch, error) {
+	return f.expired, nil
+}
+
+type fakeOutbox struct {
+	outbox.Repository
+	msgs []*outbox.Message
+}
+
+func (f *fakeOutbox) Add(_ context.Context, msg *outbox.Message) error {
+	f.msgs = append(f.msgs, msg)
+	return nil
+}
+
+type passthroughTx struct{}
+
+func (passthroughTx) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+func decodeOnly[T any](t *testing.T, msgs []*outbox.Message, topic string) T {
+	t.Helper()
+	require.Len(t, msgs, 1)
+	return decodeAt[T](t, msgs, 0, topic)
+}
+
+func decodeAt[T any](t *testing.T, msgs []*outbox.Message, i int, topic string) T {
+	t.Helper()
+	require.Greater(t, len(msgs), i)
+	env, err := events.UnmarshalEnvelope(msgs[i].Payload)
+	require.NoError(t, err)
+	assert.Equal(t, topic, env.Topic)
+	assert.Equal(t, "req-1", env.AggregateID)
+	evt, err := events.DecodePayload[T](env)
+	require.NoError(t, err)
+	return evt
+}
+
+func TestAcceptDispatch_AssignsRequestAndRejectsSiblings(t *testing.T) {
+	offeredAt := time.Now().Add(-90 * time.Second)
+	repo := &fakeDispatchRepo{byID: map[string]*domain.Dispatch{
+		"d-1": {ID: "d-1", RequestID: "req-1", ProviderID: "prov-1", Status: domain.DispatchSent, Distance: 2.5, CreatedAt: offeredAt},
+		"d-2": {ID: "d-2", RequestID: "req-1", ProviderID: "prov-2", Status: domain.DispatchSent, CreatedAt: offeredAt},
+		"d-3": {ID: "d-3", RequestID: "req-1", ProviderID: "prov-3", Status: domain.DispatchExpired, CreatedAt: offeredAt},
+	}}
+	requests := openRequest()
+	ob := &fakeOutbox{}
+	uc := New(repo, nil, requests, passthroughTx{}, ob, config.DispatchConfig{}, nil)
+
+	ctx := logger.WithCorrelationID(context.Background(), "http-1")
+	d, err := uc.AcceptDispatch(ctx, "d-1", "prov-1")
+	require.NoError(t, err)
+	assert.Equal(t, domain.DispatchAccepted, d.Status)
+	assert.Equal(t, domain.DispatchWithdrawn, repo.byID["d-2"].Status)
+	assert.Equal(t, domain.DispatchExpired, repo.byID["d-3"].Status)
+	assert.Equal(t, requestDomain.StatusAccepted, requests.req.Status)
+	assert.Equal(t, "prov-1", requests.req.ProviderID)
+	assert.NotNil(t, requests.req.AcceptedAt)
+	assert.Equal(t, "http-1", ob.msgs[0].CorrelationID, "correlated with the HTTP request")
+
+	require.Len(t, ob.msgs, 3)
+	evt := decodeAt[events.DispatchAcceptedEvent](t, ob.msgs, 0, events.TopicDispatchAccepted)
+	assert.Equal(t, "prov-1", evt.ProviderID)
+	assert.Equal(t, 2.5, evt.DistanceKm)
+	assert.GreaterOrEqual(t, evt.TimeToRespondMs, int64(90_000))
+	withdrawn := decodeAt[events.DispatchWithdrawnEvent](t, ob.msgs, 1, events.TopicDispatchWithdrawn)
+	assert.Equal(t, "prov-1", withdrawn.TakenBy)
+	assert.Equal(t, []string{"d-2"}, withdrawn.DispatchIDs, "the expired offer was already closed")
+	assert.Equal(t, []string{"prov-2"}, withdrawn.ProviderIDs)
+	reqEvt := decodeAt[events.RequestAcceptedEvent](t, ob.msgs, 2, events.TopicRequestAccepted)
+	assert.Equal(t, "prov-1", reqEvt.ProviderID)
+	assert.Equal(t, "cust-1", reqEvt.CustomerID)
+}
+
+func TestAcceptDispatch_LoserGetsAlreadyTaken(t *testing.T) {
+	repo := &fakeDispatchRepo{byID: map[string]*domain.Dispatch{
+		"d-1": {ID: "d-1", RequestID: "req-1", ProviderID: "prov-1", Status: domain.DispatchSent},
+		"d-2": {ID: "d-2", RequestID: "req-1", ProviderID: "prov-2", Status: domain.DispatchSent},
+	}}
+	ob := &fakeOutbox{}
+	uc := New(repo, nil, openRequest(), passthroughTx{}, ob, config.DispatchConfig{}, nil)
+
+	_, err := uc.AcceptDispatch(context.Background(), "d-1", "prov-1")
+	require.NoError(t, err)
+	_, err = uc.AcceptDispatch(context.Background(), "d-2", "prov-2")
+	assert.ErrorIs(t, err, ErrAlreadyTaken)
+	_, err = uc.AcceptRequest(context.Background(), "req-1", "prov-3")
+	assert.ErrorIs(t, err, ErrAlreadyTaken)
+	assert.Len(t, ob.msgs, 3, "only the winner recorded events")
+}
+
+func TestAcceptRequest_AcceptsOwnOpenOffer(t *testing.T) {
+	repo := &fakeDispatchRepo{byID: map[string]*domain.Dispatch{
+		"d-1": {ID: "d-1", RequestID: "req-1", ProviderID: "prov-1", Status: domain.DispatchSent},
+		"d-2": {ID: "d-2", RequestID: "req-1", ProviderID: "prov-2", Status: domain.DispatchSent},
+	}}
+	uc := New(repo, nil, openRequest(), passthroughTx{}, &fakeOutbox{}, config.DispatchConfig{}, nil)
+
+	req, err := uc.AcceptRequest(context.Background(), "req-1", "prov-2")
+	require.NoError(t, err)
+	assert.Equal(t, "prov-2", req.ProviderID)
+	assert.Equal(t, domain.DispatchAccepted, repo.byID["d-2"].Status)
+	assert.Equal(t, domain.DispatchWithdrawn, repo.byID["d-1"].Status)
+}
+
+func TestAcceptDispatch_ProviderAtCapacity(t *testing.T) {
+	repo := &signalsRepo{
+		fakeDispatchRepo: fakeDispatchRepo{byID: map[string]*domain.Dispatch{
+			"d-1": {ID: "d-1", RequestID: "req-1", ProviderID: "prov-1", Status: domain.DispatchSent, ExpiresAt: time.Now().Add(time.Minute)},
+		}},
+		signals: map[string]domain.ProviderSignals{"prov-1": {ActiveJobs: 1}},
+	}
+	requests := openRequest()
+	ob := &fakeOutbox{}
+	uc := New(repo, nil, requests, passthroughTx{}, ob, config.DispatchConfig{Capacity: 1, CategoryCapacity: map[string]int{"moving": 2}}, nil)
+
+	_, err := uc.AcceptDispatch(context.Background(), "d-1", "prov-1")
+	assert.ErrorIs(t, err, ErrAtCapacity)
+	assert.Equal(t, domain.DispatchSent, repo.byID["d-1"].Status)
+	assert.Equal(t, requestDomain.StatusOpen, requests.req.Status)
+	assert.Empty(t, ob.msgs)
+
+	requests.req.Category = "moving"
+	_, err = uc.AcceptDispatch(context.Background(), "d-1", "prov-1")
+	require.NoError(t, err)
+	assert.Equal(t, "prov-1", requests.req.ProviderID)
+}
+
+func TestRequestCancelled_WithdrawsOpenOffers(t *testing.T) {
+	repo := &fakeDispatchRepo{byID: map[string]*domain.Dispatch{
+		"d-1": {ID: "d-1", RequestID: "req-1", ProviderID: "prov-1", Status: domain.DispatchSent},
+		"d-2": {ID: "d-2", RequestID: "req-1", ProviderID: "prov-2", Status: domain.DispatchRejected},
+	}}
+	ob := &fakeOutbox{}
+	uc := New(repo, nil, nil, passthroughTx{}, ob, config.DispatchConfig{}, nil)
+
+	n, err := uc.RequestCancelled(context.Background(), "req-1")
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, domain.DispatchWithdrawn, repo.byID["d-1"].Status)
+	assert.Equal(t, domain.DispatchRejected, repo.byID["d-2"].Status)
+
+	evt := decodeOnly[events.DispatchWithdrawnEvent](t, ob.msgs, events.TopicDispatchWithdrawn)
+	assert.Equal(t, []string{"prov-1"}, evt.ProviderIDs)
+	assert.Empty(t, evt.TakenBy)
+
+	n, err = uc.RequestCancelled(context.Background(), "req-1")
+	require.NoError(t, err)
+	assert.Zero(t, n, "a redelivered event finds nothing left to withdraw")
+	assert.Len(t, ob.msgs, 1)
+}
+
+func TestRejectDispatch_WrongProviderRecordsNothing(t *testing.T) {
+	repo := &fakeDispatchRepo{byID: map[string]*domain.Dispatch{
+		"d-1": {ID: "d-1", RequestID: "req-1", ProviderID: "prov-1", Status: domain.DispatchSent},
+	}}
+	ob := &fakeOutbox{}
+	uc := New(repo, nil, nil, passthroughTx{}, ob, config.DispatchConfig{}, nil)
+
+	_, err := uc.RejectDispatch(context.Background(), "d-1", "prov-2")
+	assert.ErrorIs(t, err, ErrInvalidAction)
+	assert.Empty(t, ob.msgs)
+}
+
+func TestRejectDispatch_ClosedOfferRecordsNothing(t *testing.T) {
+	repo := &fakeDispatchRepo{byID: map[string]*domain.Dispatch{
+		"d-1": {ID: "d-1", RequestID: "req-1", ProviderID: "prov-1", Status: domain.DispatchSent, ExpiresAt: time.Now().Add(time.Minute)},
+	}}
+	ob := &fakeOutbox{}
+	uc := New(repo, nil, nil, passthroughTx{}, ob, config.DispatchConfig{}, nil)
+
+	_, err := uc.RejectDispatch(context.Background(), "d-1", "prov-1")
+	require.NoError(t, err)
+	assert.Equal(t, domain.DispatchRejected, repo.byID["d-1"].Status)
+
+	_, err = uc.RejectDispatch(context.Background(), "d-1", "prov-1")
+	assert.ErrorIs(t, err, ErrInvalidAction, "a second reject finds the offer closed")
+	require.Len(t, ob.msgs, 1)
+}
+
+func TestExpireStale_RecordsExpiredEventPerOffer(t *testing.T) {
+	now := time.Now()
+	repo := &fakeDispatchRepo{expired: []*domain.Dispatch{
+		{ID: "d-1", RequestID: "req-1", ProviderID: "prov-1", Status: domain.DispatchExpired, Distance: 1.2, CreatedAt: now.Add(-5 * time.Minute), UpdatedAt: now},
+	}}
+	ob := &fakeOutbox{}
+	uc := New(repo, nil, nil, passthroughTx{}, ob, config.DispatchConfig{}, nil)
+
+	expired, err := uc.ExpireStale(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, expired, 1)
+
+	evt := decodeOnly[events.DispatchExpiredEvent](t, ob.msgs, events.TopicDispatchExpired)
+	assert.Equal(t, "d-1", evt.DispatchID)
+	assert.Equal(t, 1.2, evt.DistanceKm)
+}
+
+func TestAcceptDispatch_ExpiredOffer(t *testing.T) {
+	repo := &fakeDispatchRepo{byID: map[string]*domain.Dispatch{
+		"d-1": {ID: "d-1", RequestID: "req-1", ProviderID: "prov-1", Status: domain.DispatchSent, ExpiresAt: time.Now().Add(-time.Second)},
+	}}
+	ob := &fakeOutbox{}
+	uc := New(repo, nil, openRequest(), passthroughTx{}, ob, config.DispatchConfig{}, nil)
+
+	_, err := uc.AcceptDispatch(context.Background(), "d-1", "prov-1")
+	assert.ErrorIs(t, err, ErrOfferExpired)
+	assert.Equal(t, domain.DispatchSent, repo.byID["d-1"].Status)
+	assert.Empty(t, ob.msgs)
+}
+
+// setupWaves serves a request at (0, 0) and providers north of it, about
+// 11 km per 0.1 degree of latitude.
+func setupWaves(latitudes map[string]float64) (*UseCase, *fakeDispatchRepo, *fakeOutbox) {
+	var providers []*profileDomain.ProviderDetails
+	for id, lat := range latitudes {
+		providers = append(providers, &profileDomain.ProviderDetails{ProfileID: id, Latitude: lat, IsOnline: true})
+	}
+	repo := &fakeDispatchRepo{byID: map[string]*domain.Dispatch{}, waves: map[string]*domain.WaveState{}}
+	ob := &fakeOutbox{}
+	uc := New(repo, &fakeProfileRepo{providers: providers},
+		openRequest(), passthroughTx{}, ob,
+		config.DispatchConfig{OfferTTL: time.Minute, WaveSize: 2, InitialRadiusKm: 10, RadiusStepKm: 10, MaxRadiusKm: 30},
+		nil,
+	)
+	return uc, repo, ob
+}
+
+func offeredTo(res *WaveResult) []string {
+	var ids []string
+	for _, d := range res.Offers {
+		ids = append(ids, d.ProviderID)
+	}
+	return ids
+}
+
+func TestNextWave_WidensRadiusToUntriedProviders(t *testing.T) {
+	uc, repo, ob := setupWaves(map[string]float64{"near-1": 0.02, "near-2": 0.05, "near-3": 0.08, "mid": 0.17, "far": 0.26})
+	ctx := context.Background()
+
+	res, err := uc.NextWave(ctx, "req-1")
+	require.NoError(t, err)
+	assert.Equal(t, 1, res.Wave)
+	assert.Equal(t, 10.0, res.RadiusKm)
+	assert.Equal(t, []string{"near-1", "near-2"}, offeredTo(res), "closest first, WaveSize at most")
+	assert.WithinDuration(t, time.Now().Add(time.Minute), res.Offers[0].ExpiresAt, 5*time.Second)
+
+	// Offers still open: nothing to do
+	res, err = uc.NextWave(ctx, "req-1")
+	require.NoError(t, err)
+	assert.Nil(t, res)
+
+	repo.rejectAll("req-1")
+	res, err = uc.NextWave(ctx, "req-1")
+	require.NoError(t, err)
+	assert.Equal(t, 2, res.Wave)
+	assert.Equal(t, 20.0, res.RadiusKm)
+	assert.Equal(t, []string{"near-3", "mid"}, offeredTo(res), "tried providers are skipped")
+
+	repo.rejectAll("req-1")
+	res, err = uc.NextWave(ctx, "req-1")
+	require.NoError(t, err)
+	assert.Equal(t, 3, res.Wave)
+	assert.Equal(t, 30.0, res.RadiusKm)
+	assert.Equal(t, []string{"far"}, offeredTo(res))
+	assert.Equal(t, 3, res.Offers[0].Wave)
+
+	require.Len(t, ob.msgs, 3)
+	env, err := events.UnmarshalEnvelope(ob.msgs[2].Payload)
+	require.NoError(t, err)
+	evt, err := events.DecodePayload[events.DispatchSentEvent](env)
+	require.NoError(t, err)
+	assert.Equal(t, events.TopicDispatchSent, env.Topic)
+	assert.Equal(t, 3, evt.Wave)
+	assert.Equal(t, 30.0, evt.RadiusKm)
+	assert.Equal(t, []string{"far"}, evt.ProviderIDs)
+}
+
+func TestNextWave_RespectsProviderServiceArea(t *testing.T) {
+	profiles := &fakeProfileRepo{providers: []*profileDomain.ProviderDetails{
+		{ProfileID: "stays-local", Latitude: 0.05, ServiceArea: 3},
+		{ProfileID: "travels", Latitude: 0.08, ServiceArea: 25},
+		{ProfileID: "no-area", Latitude: 0.085},
+	}}
+	uc := New(&fakeDispatchRepo{byID: map[string]*domain.Dispatch{}, waves: map[string]*domain.WaveState{}}, profiles,
+		openRequest(), passthroughTx{}, &fakeOutbox{},
+		config.DispatchConfig{WaveSize: 5, InitialRadiusKm: 10, LocationTTL: time.Minute},
+		nil,
+	)
+
+	res, err := uc.NextWave(context.Background(), "req-1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"travels", "no-area"}, offeredTo(res), "the job is 5.6 km from stays-local, outside its 3 km area")
+	assert.WithinDuration(t, time.Now().Add(-time.Minute), profiles.locatedSince, 5*time.Second)
+}
+
+func TestNextWave_SkipsProvidersAtCapacityAndRanksSoonBusyLast(t *testing.T) {
+	soon := time.Now().Add(30 * time.Minute)
+	later := time.Now().Add(24 * time.Hour)
+	repo := &signalsRepo{
+		fakeDispatchRepo: fakeDispatchRepo{byID: map[string]*domain.Dispatch{}, waves: map[string]*domain.WaveState{}},
+		signals: map[string]domain.ProviderSignals{
+			"full":         {ActiveJobs: 2},
+			"starts-soon":  {NextJobAt: &soon},
+			"starts-later": {NextJobAt: &later},
+		},
+	}
+	profiles := &fakeProfileRepo{providers: []*profileDomain.ProviderDetails{
+		{ProfileID: "full", Latitude: 0.01},
+		{ProfileID: "starts-soon", Latitude: 0.02},
+		{ProfileID: "starts-later", Latitude: 0.03},
+		{ProfileID: "idle", Latitude: 0.04},
+	}}
+	uc := New(repo, profiles, openRequest(), passthroughTx{}, &fakeOutbox{},
+		config.DispatchConfig{WaveSize: 5, InitialRadiusKm: 10, Capacity: 1, CategoryCapacity: map[string]int{"cleaning": 3}, BusyWindow: time.Hour},
+		nil,
+	)
+
+	res, err := uc.NextWave(context.Background(), "req-1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"starts-later", "idle", "starts-soon"}, offeredTo(res))
+}
+
+func TestNextWave_WaitsForCapacityInsteadOfExhausting(t *testing.T) {
+	repo := &signalsRepo{
+		fakeDispatchRepo: fakeDispatchRepo{byID: map[string]*domain.Dispatch{}, waves: map[string]*domain.WaveState{}},
+		signals:          map[string]domain.ProviderSignals{"busy": {ActiveJobs: 1}},
+	}
+	ob := &fakeOutbox{}
+	uc := New(repo, &fakeProfileRepo{providers: []*profileDomain.ProviderDetails{{ProfileID: "busy", Latitude: 0.01}}},
+		openRequest(), passthroughTx{}, ob,
+		config.DispatchConfig{WaveSize: 5, InitialRadiusKm: 10, MaxRadiusKm: 20, Capacity: 1},
+		nil,
+	)
+	ctx := context.Background()
+
+	res, err := uc.NextWave(ctx, "req-1")
+	require.NoError(t, err)
+	assert.Nil(t, res)
+	assert.Nil(t, repo.waves["req-1"].ExhaustedAt)
+	assert.Empty(t, ob.msgs)
+
+	// The provider completes its job
+	repo.signals = nil
+	results, err := uc.CapacityReleased(ctx, "busy", 10)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, []string{"busy"}, offeredTo(results[0]))
+}
+
+func TestNextWave_ExhaustedNotifiesOnce(t *testing.T) {
+	uc, repo, ob := setupWaves(map[string]float64{"near": 0.02, "too-far": 0.5})
+	ctx := context.Background()
+
+	_, err := uc.NextWave(ctx, "req-1")
+	require.NoError(t, err)
+	repo.rejectAll("req-1")
+	ob.msgs = nil
+
+	res, err := uc.NextWave(ctx, "req-1")
+	require.NoError(t, err)
+	assert.True(t, res.Exhausted)
+	assert.Empty(t, res.Offers)
+	assert.NotNil(t, repo.waves["req-1"].ExhaustedAt)
+
+	evt := decodeOnly[events.DispatchExhaustedEvent](t, ob.msgs, events.TopicDispatchExhausted)
+	assert.Equal(t, "cust-1", evt.CustomerID)
+	assert.Equal(t, 1, evt.Waves)
+	assert.Equal(t, 1, evt.ProvidersTried)
+	assert.Equal(t, 30.0, evt.RadiusKm)
+
+	res, err = uc.NextWave(ctx, "req-1")
+	require.NoError(t, err)
+	assert.Nil(t, res, "exhausted requests get no further waves")
+}
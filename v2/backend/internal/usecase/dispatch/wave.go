@@ -0,0 +1,214 @@
+package dispatch
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+	domain "github.com/pitgo/backend/internal/domain/dispatch"
+	"github.com/pitgo/backend/internal/domain/events"
+	requestDomain "github.com/pitgo/backend/internal/domain/request"
+	"github.com/pitgo/backend/internal/infrastructure/database"
+	"github.com/pitgo/backend/internal/infrastructure/logger"
+)
+
+// WaveResult describes what NextWave did for a request: either a wave of
+// Offers was sent, or the request is Exhausted.
+type WaveResult struct {
+	Request   *requestDomain.ServiceRequest
+	Wave      int
+	RadiusKm  float64
+	Offers    []*domain.Dispatch
+	Exhausted bool
+}
+
+// NextWave sends the next wave of offers for a request if it needs one: the
+// request is still open and none of its offers is open or accepted. A wave
+// goes to up to WaveSize providers that were not offered the request before,
+// within a radius that grows by RadiusStepKm per wave. When no untried
+// provider is left within MaxRadiusKm the request is marked exhausted and
+// dispatch.exhausted is recorded, unless some of them are only at capacity:
+// then the request waits for CapacityReleased or the sweeper. It returns nil
+// when nothing was sent.
+//
+// The request's wave state is locked for the whole decision, so concurrent
+// callers (replicas, sweeper and worker) never send the same wave twice.
+func (uc *UseCase) NextWave(ctx context.Context, requestID string) (*WaveResult, error) {
+	var res *WaveResult
+	err := uc.tx.WithinTx(ctx, func(ctx context.Context) error {
+		w, err := uc.repo.LockWave(ctx, requestID)
+		if err != nil {
+			return err
+		}
+		if w.ExhaustedAt != nil {
+			return nil
+		}
+		// Locking the request keeps a concurrent accept from landing while
+		// the wave is sent
+		req, err := uc.requestRepo.GetByIDForUpdate(ctx, requestID)
+		if err != nil {
+			return err
+		}
+		if req.Status != requestDomain.StatusOpen {
+			return nil
+		}
+		offers, err := uc.repo.GetByRequestID(ctx, requestID)
+		if err != nil {
+			return err
+		}
+		tried := make(map[string]bool, len(offers))
+		for _, o := range offers {
+			if o.IsOpen() || o.Status == domain.DispatchAccepted {
+				return nil
+			}
+			tried[o.ProviderID] = true
+		}
+
+		now := time.Now()
+		for wave := w.Wave + 1; ; wave++ {
+			radius := uc.radiusFor(wave)
+			candidates, busy, err := uc.rankedCandidates(ctx, req.Latitude, req.Longitude, radius, req.Category, tried)
+			if err != nil {
+				return err
+			}
+			if len(candidates) > uc.cfg.WaveSize {
+				candidates = candidates[:uc.cfg.WaveSize]
+			}
+			if len(candidates) > 0 {
+				res, err = uc.sendWave(ctx, req, wave, radius, candidates, now)
+				if err != nil {
+					return err
+				}
+				w.Wave, w.RadiusKm = wave, radius
+				break
+			}
+			if radius >= uc.cfg.MaxRadiusKm && busy > 0 {
+				logger.Ctx(ctx).Info().
+					Str("request_id", requestID).
+					Int("busy_providers", busy).
+					Msg("Dispatch waiting for provider capacity")
+				break
+			}
+			if radius >= uc.cfg.MaxRadiusKm {
+				w.RadiusKm = radius
+				w.ExhaustedAt = &now
+				res = &WaveResult{Request: req, Wave: w.Wave, RadiusKm: radius, Exhausted: true}
+				err := uc.recordEvent(ctx, events.TopicDispatchExhausted, req.ID, events.DispatchExhaustedEvent{
+					RequestID:      req.ID,
+					CustomerID:     req.CustomerID,
+					Waves:          w.Wave,
+					ProvidersTried: len(tried),
+					RadiusKm:       radius,
+					ExhaustedAt:    now,
+				})
+				if err != nil {
+					return err
+				}
+				break
+			}
+		}
+		w.UpdatedAt = now
+		return uc.repo.SaveWave(ctx, w)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if res != nil {
+		log := logger.Ctx(ctx).Info().
+			Str("request_id", requestID).
+			Int("wave", res.Wave).
+			Float64("radius_km", res.RadiusKm)
+		if res.Exhausted {
+			log.Msg("Dispatch waves exhausted")
+		} else {
+			log.Int("offers", len(res.Offers)).Msg("Dispatch wave sent")
+		}
+	}
+	return res, nil
+}
+
+// CapacityReleased sends the next wave of up to limit stalled requests that
+// providerID could take, right away, now that they finished or lost a job
+// and may take another one. Each wave commits in its own transaction, even
+// when ctx holds one, so a failing request neither rolls back nor holds up
+// the others; it is logged and left to the Sweeper.
+func (uc *UseCase) CapacityReleased(ctx context.Context, providerID string, limit int) ([]*WaveResult, error) {
+	ids, err := uc.repo.ListStalledFor(ctx, providerID, uc.cfg.MaxRadiusKm, limit)
+	if err != nil {
+		return nil, err
+	}
+	var results []*WaveResult
+	waveCtx := database.WithoutTx(ctx)
+	for _, id := range ids {
+		res, err := uc.NextWave(waveCtx, id)
+		if err != nil {
+			logger.Ctx(ctx).Error().Err(err).Str("request_id", id).Msg("Failed to send next dispatch wave")
+			continue
+		}
+		if res != nil {
+			results = append(results, res)
+		}
+	}
+	logger.Ctx(ctx).Info().
+		Str("provider_id", providerID).
+		Int("stalled", len(ids)).
+		Int("waves", len(results)).
+		Msg("Provider capacity released")
+	return results, nil
+}
+
+// StalledRequests returns up to limit open requests that wait for their next wave.
+func (uc *UseCase) StalledRequests(ctx context.Context, limit int) ([]string, error) {
+	return uc.repo.ListStalled(ctx, limit)
+}
+
+func (uc *UseCase) radiusFor(wave int) float64 {
+	return math.Min(uc.cfg.InitialRadiusKm+float64(wave-1)*uc.cfg.RadiusStepKm, uc.cfg.MaxRadiusKm)
+}
+
+func (uc *UseCase) sendWave(ctx context.Context, req *requestDomain.ServiceRequest, wave int, radiusKm float64, candidates []Candidate, now time.Time) (*WaveResult, error) {
+	res := &WaveResult{Request: req, Wave: wave, RadiusKm: radiusKm}
+	providerIDs := make([]string, 0, len(candidates))
+	sent := make([]events.SentOffer, 0, len(candidates))
+	for _, c := range candidates {
+		d := &domain.Dispatch{
+			ID:         uuid.New().String(),
+			RequestID:  req.ID,
+			ProviderID: c.Provider.ProfileID,
+			Status:     domain.DispatchSent,
+			Distance:   math.Round(c.DistanceKm*100) / 100,
+			Wave:       wave,
+			ExpiresAt:  now.Add(uc.cfg.OfferTTL),
+			CreatedAt:  now,
+			UpdatedAt:  now,
+		}
+		if err := uc.repo.Create(ctx, d); err != nil {
+			return nil, err
+		}
+		res.Offers = append(res.Offers, d)
+		providerIDs = append(providerIDs, d.ProviderID)
+		sent = append(sent, events.SentOffer{
+			DispatchID: d.ID,
+			ProviderID: d.ProviderID,
+			DistanceKm: d.Distance,
+			OfferedAt:  d.CreatedAt,
+			ExpiresAt:  d.ExpiresAt,
+		})
+	}
+
+	err := uc.recordEvent(ctx, events.TopicDispatchSent, req.ID, events.DispatchSentEvent{
+		RequestID:   req.ID,
+		CustomerID:  req.CustomerID,
+		ProviderIDs: providerIDs,
+		Count:       len(providerIDs),
+		Wave:        wave,
+		RadiusKm:    radiusKm,
+		Offers:      sent,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
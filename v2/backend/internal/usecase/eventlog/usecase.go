@@ -0,0 +1,97 @@
+package eventlog
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	domain "github.com/pitgo/backend/internal/domain/eventlog"
+	"github.com/pitgo/backend/internal/domain/events"
+	"github.com/pitgo/backend/internal/infrastructure/queue"
+)
+
+const replayPageSize = 500
+
+// RecordingPublisher appends every envelope to the event log before handing
+// it to the wrapped publisher. Payloads that are not envelopes (e.g. dead
+// letters) are published without being logged.
+type RecordingPublisher struct {
+	next queue.Publisher
+	repo domain.Repository
+}
+
+func NewRecordingPublisher(next queue.Publisher, repo domain.Repository) *RecordingPublisher {
+	return &RecordingPublisher{next: next, repo: repo}
+}
+
+func (p *RecordingPublisher) Publish(ctx context.Context, topic string, payload []byte) error {
+	if !queue.IsDeadLetterTopic(topic) {
+		if env, err := events.UnmarshalEnvelope(payload); err == nil && env.EventID != "" {
+			if err := p.repo.Append(ctx, NewEntry(env, payload)); err != nil {
+				return fmt.Errorf("append to event log: %w", err)
+			}
+		}
+	}
+	return p.next.Publish(ctx, topic, payload)
+}
+
+func (p *RecordingPublisher) Close() error { return p.next.Close() }
+
+// NewEntry builds a log entry for env; raw is the serialized envelope.
+func NewEntry(env *events.Envelope, raw []byte) *domain.Entry {
+	return &domain.Entry{
+		EventID:       env.EventID,
+		Topic:         env.Topic,
+		CorrelationID: env.CorrelationID,
+		AggregateID:   env.AggregateID,
+		SchemaVersion: env.SchemaVersion,
+		Envelope:      raw,
+		OccurredAt:    env.Timestamp,
+		RecordedAt:    time.Now(),
+	}
+}
+
+// Replayer reads the event log in order for audits and replays.
+type Replayer struct {
+	repo domain.Repository
+}
+
+func NewReplayer(repo domain.Repository) *Replayer {
+	return &Replayer{repo: repo}
+}
+
+// Replay passes every entry matching f to deliver, in log order, and returns
+// how many were delivered. It stops at the first delivery error.
+func (r *Replayer) Replay(ctx context.Context, f domain.Filter, deliver func(ctx context.Context, e *domain.Entry) error) (int, error) {
+	remaining := f.Limit
+	delivered := 0
+	for {
+		page := f
+		page.Limit = replayPageSize
+		if remaining > 0 && remaining < page.Limit {
+			page.Limit = remaining
+		}
+
+		entries, err := r.repo.Query(ctx, page)
+		if err != nil {
+			return delivered, err
+		}
+		for _, e := range entries {
+			if err := deliver(ctx, e); err != nil {
+				return delivered, fmt.Errorf("event %s (seq %d): %w", e.EventID, e.Seq, err)
+			}
+			delivered++
+			f.AfterSeq = e.Seq
+		}
+
+		if remaining > 0 {
+			remaining -= len(entries)
+			if remaining <= 0 {
+				return delivered, nil
+			}
+		}
+		if len(entries) < page.Limit {
+			return delivered, nil
+		}
+	}
+}
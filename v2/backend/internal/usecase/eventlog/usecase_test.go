@@ -0,0 +1,93 @@
+package eventlog
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	domain "github.com/pitgo/backend/internal/domain/eventlog"
+	"github.com/pitgo/backend/internal/domain/events"
+	"github.com/pitgo/backend/internal/infrastructure/queue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type memoryLog struct {
+	entries []*domain.Entry
+	queries int
+}
+
+func (m *memoryLog) Append(_ context.Context, e *domain.Entry) error {
+	e.Seq = int64(len(m.entries) + 1)
+	m.entries = append(m.entries, e)
+	return nil
+}
+
+func (m *memoryLog) Query(_ context.Context, f domain.Filter) ([]*domain.Entry, error) {
+	m.queries++
+	var out []*domain.Entry
+	for _, e := range m.entries {
+		if e.Seq <= f.AfterSeq {
+			continue
+		}
+		out = append(out, e)
+		if f.Limit > 0 && len(out) == f.Limit {
+			break
+		}
+	}
+	return out, nil
+}
+
+type capturePublisher struct{ topics []string }
+
+func (c *capturePublisher) Publish(_ context.Context, topic string, _ []byte) error {
+	c.topics = append(c.topics, topic)
+	return nil
+}
+
+func (c *capturePublisher) Close() error { return nil }
+
+func TestRecordingPublisher_LogsEnvelopesOnly(t *testing.T) {
+	log := &memoryLog{}
+	next := &capturePublisher{}
+	p := NewRecordingPublisher(next, log)
+
+	env, err := events.NewEnvelope(events.TopicRequestCreated, "req-1", "http-1", events.RequestCreatedEvent{RequestID: "req-1"})
+	require.NoError(t, err)
+	raw, err := env.Marshal()
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	require.NoError(t, p.Publish(ctx, events.TopicRequestCreated, raw))
+	require.NoError(t, p.Publish(ctx, queue.DeadLetterTopic(events.TopicRequestCreated), []byte(`{"topic":"request.created"}`)))
+
+	assert.Equal(t, []string{events.TopicRequestCreated, queue.DeadLetterTopic(events.TopicRequestCreated)}, next.topics)
+	require.Len(t, log.entries, 1)
+	assert.Equal(t, env.EventID, log.entries[0].EventID)
+	assert.Equal(t, "http-1", log.entries[0].CorrelationID)
+	assert.Equal(t, "req-1", log.entries[0].AggregateID)
+}
+
+func TestReplayer_PagesInOrderAndHonoursLimit(t *testing.T) {
+	log := &memoryLog{}
+	for i := 0; i < replayPageSize+10; i++ {
+		require.NoError(t, log.Append(context.Background(), &domain.Entry{EventID: fmt.Sprintf("evt-%d", i)}))
+	}
+	r := NewReplayer(log)
+
+	var seqs []int64
+	n, err := r.Replay(context.Background(), domain.Filter{}, func(_ context.Context, e *domain.Entry) error {
+		seqs = append(seqs, e.Seq)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, replayPageSize+10, n)
+	assert.Equal(t, 2, log.queries)
+	for i, seq := range seqs {
+		assert.Equal(t, int64(i+1), seq)
+	}
+
+	n, err = r.Replay(context.Background(), domain.Filter{AfterSeq: 5, Limit: 3}, func(context.Context, *domain.Entry) error { return nil })
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+}
@@ -0,0 +1,69 @@
+package profile
+
+import (
+	"context"
+	"time"
+
+	domain "github.com/pitgo/backend/internal/domain/profile"
+	"github.com/pitgo/backend/internal/infrastructure/logger"
+)
+
+// LiveLocations is a profile repository that also keeps live locations in an
+// index and matches providers against it. Every location is still saved to
+// the wrapped repository, which matching falls back to while the index is
+// unavailable.
+type LiveLocations struct {
+	domain.Repository
+	index domain.LocationIndex
+}
+
+func NewLiveLocations(repo domain.Repository, index domain.LocationIndex) *LiveLocations {
+	return &LiveLocations{Repository: repo, index: index}
+}
+
+// SaveLocation fails only when the wrapped repository does.
+func (r *LiveLocations) SaveLocation(ctx context.Context, loc *domain.Location) error {
+	if err := r.Repository.SaveLocation(ctx, loc); err != nil {
+		return err
+	}
+	if err := r.index.Save(ctx, loc); err != nil {
+		logger.Ctx(ctx).Warn().Err(err).Str("provider_id", loc.ProfileID).Msg("Failed to index provider location")
+	}
+	return nil
+}
+
+func (r *LiveLocations) FindProvidersInRadius(ctx context.Context, lat, lng, radiusKm float64, category string, locatedSince time.Time) ([]*domain.ProviderDetails, error) {
+	fallback := func(err error) ([]*domain.ProviderDetails, error) {
+		logger.Ctx(ctx).Warn().Err(err).Msg("Location index unavailable; matching on stored locations")
+		return r.Repository.FindProvidersInRadius(ctx, lat, lng, radiusKm, category, locatedSince)
+	}
+
+	near, err := r.index.Nearby(ctx, lat, lng, radiusKm, locatedSince)
+	if err != nil {
+		return fallback(err)
+	}
+	providers, err := r.Repository.FindProvidersAt(ctx, lat, lng, radiusKm, category, near)
+	if err != nil {
+		return nil, err
+	}
+
+	// Providers matched at their profile location may have a fresh location
+	// outside the radius
+	var unlocated []string
+	for _, p := range providers {
+		if p.LocatedAt == nil {
+			unlocated = append(unlocated, p.ProfileID)
+		}
+	}
+	elsewhere, err := r.index.Get(ctx, unlocated, locatedSince)
+	if err != nil {
+		return fallback(err)
+	}
+	matched := providers[:0]
+	for _, p := range providers {
+		if p.LocatedAt != nil || elsewhere[p.ProfileID] == nil {
+			matched = append(matched, p)
+		}
+	}
+	return matched, nil
+}
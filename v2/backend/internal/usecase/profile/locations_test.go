@@ -0,0 +1,97 @@
+package profile
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	domain "github.com/pitgo/backend/internal/domain/profile"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRepo matches every provider of the category, at the live location when
+// one is given.
+type fakeRepo struct {
+	domain.Repository
+	providers []*domain.ProviderDetails
+	saved     []*domain.Location
+	fromDB    bool
+}
+
+func (f *fakeRepo) FindProvidersAt(_ context.Context, _, _, _ float64, _ string, live []*domain.Location) ([]*domain.ProviderDetails, error) {
+	var out []*domain.ProviderDetails
+	for _, p := range f.providers {
+		cp := *p
+		for _, loc := range live {
+			if loc.ProfileID == p.ProfileID {
+				cp.Latitude, cp.Longitude, cp.LocatedAt, cp.IsOnline = loc.Latitude, loc.Longitude, &loc.ReportedAt, true
+			}
+		}
+		out = append(out, &cp)
+	}
+	return out, nil
+}
+
+func (f *fakeRepo) FindProvidersInRadius(context.Context, float64, float64, float64, string, time.Time) ([]*domain.ProviderDetails, error) {
+	f.fromDB = true
+	return f.providers, nil
+}
+
+func (f *fakeRepo) SaveLocation(_ context.Context, loc *domain.Location) error {
+	f.saved = append(f.saved, loc)
+	return nil
+}
+
+type fakeIndex struct {
+	near []*domain.Location
+	all  map[string]*domain.Location
+	err  error
+}
+
+func (f *fakeIndex) Save(context.Context, *domain.Location) error { return f.err }
+
+func (f *fakeIndex) Nearby(context.Context, float64, float64, float64, time.Time) ([]*domain.Location, error) {
+	return f.near, f.err
+}
+
+func (f *fakeIndex) Get(_ context.Context, ids []string, _ time.Time) (map[string]*domain.Location, error) {
+	out := make(map[string]*domain.Location)
+	for _, id := range ids {
+		if loc, ok := f.all[id]; ok {
+			out[id] = loc
+		}
+	}
+	return out, f.err
+}
+
+func TestLiveLocations_MatchesIndexedLocations(t *testing.T) {
+	now := time.Now()
+	here := &domain.Location{ProfileID: "prov-1", Latitude: 1, Longitude: 1, ReportedAt: now}
+	away := &domain.Location{ProfileID: "prov-2", Latitude: 40, Longitude: 40, ReportedAt: now}
+	repo := &fakeRepo{providers: []*domain.ProviderDetails{{ProfileID: "prov-1"}, {ProfileID: "prov-2"}, {ProfileID: "prov-3"}}}
+	index := &fakeIndex{near: []*domain.Location{here}, all: map[string]*domain.Location{"prov-1": here, "prov-2": away}}
+
+	providers, err := NewLiveLocations(repo, index).FindProvidersInRadius(context.Background(), 0, 0, 10, "plumbing", now.Add(-time.Minute))
+	require.NoError(t, err)
+	require.Len(t, providers, 2, "prov-2 is online out of range")
+	assert.Equal(t, "prov-1", providers[0].ProfileID)
+	assert.True(t, providers[0].IsOnline)
+	assert.Equal(t, "prov-3", providers[1].ProfileID)
+	assert.False(t, providers[1].IsOnline, "matched at its profile location")
+	assert.False(t, repo.fromDB)
+}
+
+func TestLiveLocations_FallsBackWhenIndexFails(t *testing.T) {
+	repo := &fakeRepo{providers: []*domain.ProviderDetails{{ProfileID: "prov-1"}}}
+	r := NewLiveLocations(repo, &fakeIndex{err: errors.New("connection refused")})
+
+	providers, err := r.FindProvidersInRadius(context.Background(), 0, 0, 10, "plumbing", time.Now())
+	require.NoError(t, err)
+	assert.Len(t, providers, 1)
+	assert.True(t, repo.fromDB)
+
+	require.NoError(t, r.SaveLocation(context.Background(), &domain.Location{ProfileID: "prov-1"}))
+	assert.Len(t, repo.saved, 1, "still stored for the fallback")
+}
@@ -2,12 +2,15 @@ package profile
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/google/uuid"
 	domain "github.com/pitgo/backend/internal/domain/profile"
 )
 
+var ErrNotProvider = errors.New("profile is not a provider")
+
 type UseCase struct {
 	repo domain.Repository
 }
@@ -54,6 +57,20 @@ func (uc *UseCase) SetProviderDetails(ctx context.Context, details *domain.Provi
 	return uc.repo.CreateProviderDetails(ctx, details)
 }
 
+// FindNearbyProviders matches on the profile coordinates only; dispatch
+// matching also considers live locations.
 func (uc *UseCase) FindNearbyProviders(ctx context.Context, lat, lng, radiusKm float64, category string) ([]*domain.ProviderDetails, error) {
-	return uc.repo.FindProvidersInRadius(ctx, lat, lng, radiusKm, category)
+	return uc.repo.FindProvidersInRadius(ctx, lat, lng, radiusKm, category, time.Now())
+}
+
+// ReportLocation records where provider p is now.
+func (uc *UseCase) ReportLocation(ctx context.Context, p *domain.Profile, lat, lng float64) (*domain.Location, error) {
+	if p.Type != domain.TypeProvider {
+		return nil, ErrNotProvider
+	}
+	loc := &domain.Location{ProfileID: p.ID, Latitude: lat, Longitude: lng, ReportedAt: time.Now()}
+	if err := uc.repo.SaveLocation(ctx, loc); err != nil {
+		return nil, err
+	}
+	return loc, nil
 }
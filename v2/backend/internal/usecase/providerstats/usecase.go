@@ -0,0 +1,106 @@
+package providerstats
+
+import (
+	"context"
+	"time"
+
+	"github.com/pitgo/backend/internal/domain/events"
+	domain "github.com/pitgo/backend/internal/domain/providerstats"
+	"github.com/pitgo/backend/internal/infrastructure/database"
+)
+
+// Topics are the events that move provider stats.
+var Topics = []string{
+	events.TopicDispatchAccepted,
+	events.TopicDispatchRejected,
+	events.TopicDispatchExpired,
+	events.TopicRequestAccepted,
+	events.TopicRequestCancelled,
+}
+
+type UseCase struct {
+	repo domain.Repository
+	tx   database.Transactor
+}
+
+func New(repo domain.Repository, tx database.Transactor) *UseCase {
+	return &UseCase{repo: repo, tx: tx}
+}
+
+// Apply updates the stats of the provider an event is about. Events of other
+// topics, and cancellations of requests nobody had accepted, are ignored.
+// Apply is not idempotent: run it behind the idempotency middleware so a
+// redelivered event is not counted twice.
+func (uc *UseCase) Apply(ctx context.Context, env *events.Envelope) error {
+	var (
+		providerID string
+		update     func(s *domain.Stats)
+	)
+	switch env.Topic {
+	case events.TopicDispatchAccepted:
+		evt, err := events.DecodePayload[events.DispatchAcceptedEvent](env)
+		if err != nil {
+			return err
+		}
+		providerID = evt.ProviderID
+		update = func(s *domain.Stats) {
+			s.OffersAccepted++
+			s.RecordResponse(evt.TimeToRespondMs)
+		}
+	case events.TopicDispatchRejected:
+		evt, err := events.DecodePayload[events.DispatchRejectedEvent](env)
+		if err != nil {
+			return err
+		}
+		providerID = evt.ProviderID
+		update = func(s *domain.Stats) {
+			s.OffersRejected++
+			s.RecordResponse(evt.TimeToRespondMs)
+		}
+	case events.TopicDispatchExpired:
+		evt, err := events.DecodePayload[events.DispatchExpiredEvent](env)
+		if err != nil {
+			return err
+		}
+		providerID = evt.ProviderID
+		update = func(s *domain.Stats) { s.OffersExpired++ }
+	case events.TopicRequestAccepted:
+		evt, err := events.DecodePayload[events.RequestAcceptedEvent](env)
+		if err != nil {
+			return err
+		}
+		providerID = evt.ProviderID
+		update = func(s *domain.Stats) { s.JobsAccepted++ }
+	case events.TopicRequestCancelled:
+		evt, err := events.DecodePayload[events.RequestCancelledEvent](env)
+		if err != nil {
+			return err
+		}
+		providerID = evt.ProviderID
+		update = func(s *domain.Stats) { s.CancelledAfterAccept++ }
+	}
+	if providerID == "" {
+		return nil
+	}
+
+	return uc.tx.WithinTx(ctx, func(ctx context.Context) error {
+		s, err := uc.repo.Lock(ctx, providerID)
+		if err != nil {
+			return err
+		}
+		update(s)
+		s.UpdatedAt = time.Now()
+		return uc.repo.Save(ctx, s)
+	})
+}
+
+func (uc *UseCase) GetByProviderID(ctx context.Context, providerID string) (*domain.Stats, error) {
+	return uc.repo.GetByProviderID(ctx, providerID)
+}
+
+func (uc *UseCase) List(ctx context.Context, filter domain.ListFilter) ([]*domain.Stats, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = 20
+	}
+	return uc.repo.List(ctx, filter)
+}
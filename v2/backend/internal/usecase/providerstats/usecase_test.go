@@ -0,0 +1,84 @@
+package providerstats
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pitgo/backend/internal/domain/events"
+	domain "github.com/pitgo/backend/internal/domain/providerstats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeRepo struct {
+	domain.Repository
+	stats map[string]*domain.Stats
+}
+
+func (f *fakeRepo) Lock(_ context.Context, providerID string) (*domain.Stats, error) {
+	s, ok := f.stats[providerID]
+	if !ok {
+		s = &domain.Stats{ProviderID: providerID}
+	}
+	cp := *s
+	return &cp, nil
+}
+
+func (f *fakeRepo) Save(_ context.Context, s *domain.Stats) error {
+	f.stats[s.ProviderID] = s
+	return nil
+}
+
+type passthroughTx struct{}
+
+func (passthroughTx) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+func apply(t *testing.T, uc *UseCase, topic string, payload any) {
+	t.Helper()
+	env, err := events.NewEnvelope(topic, "req-1", "corr-1", payload)
+	require.NoError(t, err)
+	require.NoError(t, uc.Apply(context.Background(), env))
+}
+
+func TestApply_CountsOfferOutcomes(t *testing.T) {
+	repo := &fakeRepo{stats: map[string]*domain.Stats{}}
+	uc := New(repo, passthroughTx{})
+
+	apply(t, uc, events.TopicDispatchRejected, events.DispatchRejectedEvent{ProviderID: "p1", TimeToRespondMs: 9000})
+	apply(t, uc, events.TopicDispatchAccepted, events.DispatchAcceptedEvent{ProviderID: "p1", TimeToRespondMs: 3000})
+	apply(t, uc, events.TopicDispatchAccepted, events.DispatchAcceptedEvent{ProviderID: "p1", TimeToRespondMs: 5000})
+	apply(t, uc, events.TopicDispatchExpired, events.DispatchExpiredEvent{ProviderID: "p1"})
+	apply(t, uc, events.TopicDispatchExpired, events.DispatchExpiredEvent{ProviderID: "p2"})
+
+	s := repo.stats["p1"]
+	require.NotNil(t, s)
+	assert.Equal(t, 2, s.OffersAccepted)
+	assert.Equal(t, 1, s.OffersRejected)
+	assert.Equal(t, 1, s.OffersExpired)
+	assert.Equal(t, int64(5000), s.MedianResponseMs)
+	assert.Equal(t, []int64{9000, 3000, 5000}, s.RecentResponseMs)
+	assert.InDelta(t, 0.5, s.AcceptanceRate(), 1e-9)
+	assert.InDelta(t, 0.25, s.ExpiryRate(), 1e-9)
+	assert.False(t, s.UpdatedAt.IsZero())
+
+	assert.Equal(t, 1, repo.stats["p2"].OffersExpired)
+}
+
+func TestApply_CountsCancellationsAfterAccept(t *testing.T) {
+	repo := &fakeRepo{stats: map[string]*domain.Stats{}}
+	uc := New(repo, passthroughTx{})
+
+	apply(t, uc, events.TopicRequestAccepted, events.RequestAcceptedEvent{ProviderID: "p1"})
+	apply(t, uc, events.TopicRequestAccepted, events.RequestAcceptedEvent{ProviderID: "p1"})
+	apply(t, uc, events.TopicRequestCancelled, events.RequestCancelledEvent{ProviderID: "p1"})
+	apply(t, uc, events.TopicRequestCancelled, events.RequestCancelledEvent{CustomerID: "c1"})
+
+	s := repo.stats["p1"]
+	require.NotNil(t, s)
+	assert.Equal(t, 2, s.JobsAccepted)
+	assert.Equal(t, 1, s.CancelledAfterAccept)
+	assert.InDelta(t, 0.5, s.CancellationRate(), 1e-9)
+	assert.Len(t, repo.stats, 1, "cancelling an unassigned request touches no provider")
+}
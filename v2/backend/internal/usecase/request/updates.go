@@ -0,0 +1,231 @@
+package request
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	eventlogDomain "github.com/pitgo/backend/internal/domain/eventlog"
+	"github.com/pitgo/backend/internal/domain/events"
+	domain "github.com/pitgo/backend/internal/domain/request"
+)
+
+// ErrCannotResume means the updates missed since an event can't be replayed:
+// the event is unknown or too much happened since. Clients must refetch.
+var ErrCannotResume = errors.New("cannot resume updates")
+
+// Request update types, as streamed to customers
+const (
+	UpdateCreated     = "created"     // Status is open, or scheduled for requests booked ahead
+	UpdateRescheduled = "rescheduled" // ScheduledAt is the new time
+	UpdateOpened      = "opened"      // A scheduled request opened for dispatch
+	UpdateDispatched  = "dispatched"  // A wave of offers went out to Providers providers
+	UpdateExhausted   = "exhausted"   // No provider left to offer it to; the request stays open
+	UpdateAccepted    = "accepted"
+	UpdateUnassigned  = "unassigned" // Taken from ProviderID by an admin; accepted follows when reassigned
+	UpdateStarted     = "started"
+	UpdateCompleted   = "completed"
+	UpdateCancelled   = "cancelled"
+	UpdateReset       = "reset" // Updates were missed; refetch the requests
+)
+
+// UpdateTopics are the events request updates are built from.
+var UpdateTopics = []string{
+	events.TopicRequestCreated,
+	events.TopicRequestRescheduled,
+	events.TopicRequestOpened,
+	events.TopicRequestAccepted,
+	events.TopicRequestUnassigned,
+	events.TopicRequestStarted,
+	events.TopicRequestCompleted,
+	events.TopicRequestCancelled,
+	events.TopicDispatchSent,
+	events.TopicDispatchExhausted,
+}
+
+// Update tells a customer how one of their requests moved on. EventID is the
+// event it was built from; streams resume after it.
+type Update struct {
+	CustomerID  string        `json:"-"`
+	EventID     string        `json:"event_id"`
+	Type        string        `json:"type"`
+	RequestID   string        `json:"request_id"`
+	Status      domain.Status `json:"status"`
+	ProviderID  string        `json:"provider_id,omitempty"`
+	Providers   int           `json:"providers,omitempty"`
+	Wave        int           `json:"wave,omitempty"`
+	ScheduledAt *time.Time    `json:"scheduled_at,omitempty"`
+	At          time.Time     `json:"at"`
+}
+
+// UpdateFor returns the update env means to the request's customer, or nil
+// for topics customers are not told about. CustomerID is empty for
+// dispatch.sent events recorded before it was included.
+func UpdateFor(env *events.Envelope) (*Update, error) {
+	u := &Update{EventID: env.EventID, RequestID: env.AggregateID, At: env.Timestamp}
+	switch env.Topic {
+	case events.TopicRequestCreated:
+		evt, err := events.DecodePayload[events.RequestCreatedEvent](env)
+		if err != nil {
+			return nil, err
+		}
+		u.CustomerID, u.Type, u.Status = evt.CustomerID, UpdateCreated, domain.StatusOpen
+		if evt.DispatchAt != nil {
+			u.Status = domain.StatusScheduled
+		}
+		u.ScheduledAt = &evt.ScheduledAt
+	case events.TopicRequestRescheduled:
+		evt, err := events.DecodePayload[events.RequestRescheduledEvent](env)
+		if err != nil {
+			return nil, err
+		}
+		u.CustomerID, u.Type, u.Status = evt.CustomerID, UpdateRescheduled, domain.StatusScheduled
+		u.ScheduledAt = &evt.ScheduledAt
+	case events.TopicRequestOpened:
+		evt, err := events.DecodePayload[events.RequestOpenedEvent](env)
+		if err != nil {
+			return nil, err
+		}
+		u.CustomerID, u.Type, u.Status = evt.CustomerID, UpdateOpened, domain.StatusOpen
+		u.ScheduledAt = &evt.ScheduledAt
+	case events.TopicDispatchSent:
+		evt, err := events.DecodePayload[events.DispatchSentEvent](env)
+		if err != nil {
+			return nil, err
+		}
+		u.CustomerID, u.Type, u.Status = evt.CustomerID, UpdateDispatched, domain.StatusOpen
+		u.Providers, u.Wave = evt.Count, evt.Wave
+	case events.TopicDispatchExhausted:
+		evt, err := events.DecodePayload[events.DispatchExhaustedEvent](env)
+		if err != nil {
+			return nil, err
+		}
+		u.CustomerID, u.Type, u.Status = evt.CustomerID, UpdateExhausted, domain.StatusOpen
+		u.Wave = evt.Waves
+	case events.TopicRequestAccepted:
+		evt, err := events.DecodePayload[events.RequestAcceptedEvent](env)
+		if err != nil {
+			return nil, err
+		}
+		u.CustomerID, u.Type, u.Status = evt.CustomerID, UpdateAccepted, domain.StatusAccepted
+		u.ProviderID = evt.ProviderID
+	case events.TopicRequestUnassigned:
+		evt, err := events.DecodePayload[events.RequestUnassignedEvent](env)
+		if err != nil {
+			return nil, err
+		}
+		u.CustomerID, u.Type, u.Status = evt.CustomerID, UpdateUnassigned, domain.StatusOpen
+		if evt.AssignedTo != "" {
+			u.Status = domain.StatusAccepted
+		}
+		u.ProviderID = evt.ProviderID
+	case events.TopicRequestStarted:
+		evt, err := events.DecodePayload[events.RequestStartedEvent](env)
+		if err != nil {
+			return nil, err
+		}
+		u.CustomerID, u.Type, u.Status = evt.CustomerID, UpdateStarted, domain.StatusInProgress
+		u.ProviderID = evt.ProviderID
+	case events.TopicRequestCompleted:
+		evt, err := events.DecodePayload[events.RequestCompletedEvent](env)
+		if err != nil {
+			return nil, err
+		}
+		u.CustomerID, u.Type, u.Status = evt.CustomerID, UpdateCompleted, domain.StatusCompleted
+		u.ProviderID = evt.ProviderID
+	case events.TopicRequestCancelled:
+		evt, err := events.DecodePayload[events.RequestCancelledEvent](env)
+		if err != nil {
+			return nil, err
+		}
+		u.CustomerID, u.Type, u.Status = evt.CustomerID, UpdateCancelled, domain.StatusCancelled
+		u.ProviderID = evt.ProviderID
+	default:
+		return nil, nil
+	}
+	return u, nil
+}
+
+const (
+	resumeRequests = 100 // Most recent requests of the customer a resume covers
+	resumeLimit    = 500 // Most updates a resume replays
+	// Log entries before the last event a resume replays too. Relays commit
+	// concurrently, so an entry can become visible after one with a higher seq.
+	resumeOverlap = 100
+)
+
+// Updates replays from the event log the request updates a customer missed.
+type Updates struct {
+	requests domain.Repository
+	log      eventlogDomain.Repository
+}
+
+func NewUpdates(requests domain.Repository, log eventlogDomain.Repository) *Updates {
+	return &Updates{requests: requests, log: log}
+}
+
+// Since returns the updates to the customer's requests logged after the event
+// lastEventID, oldest first. Seqs are not committed in order, so it starts
+// resumeOverlap entries before lastEventID and may repeat updates the client
+// already got; clients skip those by event ID. It returns ErrCannotResume
+// when lastEventID is not in the log or more than resumeLimit updates were
+// missed.
+func (u *Updates) Since(ctx context.Context, customerID, lastEventID string) ([]Update, error) {
+	last, err := u.log.Query(ctx, eventlogDomain.Filter{EventID: lastEventID, Limit: 1})
+	if err != nil {
+		return nil, err
+	}
+	if len(last) == 0 {
+		return nil, ErrCannotResume
+	}
+
+	reqs, err := u.requests.ListByCustomer(ctx, customerID, "", resumeRequests, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(reqs) == 0 {
+		return nil, nil
+	}
+	ids := make([]string, len(reqs))
+	for i, r := range reqs {
+		ids[i] = r.ID
+	}
+
+	entries, err := u.log.Query(ctx, eventlogDomain.Filter{
+		Topics:       UpdateTopics,
+		AggregateIDs: ids,
+		AfterSeq:     max(last[0].Seq-resumeOverlap, 0),
+		Limit:        resumeOverlap + resumeLimit + 1,
+	})
+	if err != nil {
+		return nil, err
+	}
+	missed := 0
+	for _, e := range entries {
+		if e.Seq > last[0].Seq {
+			missed++
+		}
+	}
+	if missed > resumeLimit {
+		return nil, ErrCannotResume
+	}
+
+	updates := make([]Update, 0, len(entries))
+	for _, e := range entries {
+		if e.EventID == lastEventID {
+			continue
+		}
+		env, err := events.UnmarshalEnvelope(e.Envelope)
+		if err != nil {
+			return nil, err
+		}
+		upd, err := UpdateFor(env)
+		if err != nil {
+			return nil, err
+		}
+		if upd != nil {
+			updates = append(updates, *upd)
+		}
+	}
+	return updates, nil
+}
@@ -0,0 +1,143 @@
+package request
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	eventlogDomain "github.com/pitgo/backend/internal/domain/eventlog"
+	"github.com/pitgo/backend/internal/domain/events"
+	domain "github.com/pitgo/backend/internal/domain/request"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (f *fakeRepo) ListByCustomer(_ context.Context, customerID string, _ domain.Status, _, _ int) ([]*domain.ServiceRequest, error) {
+	var out []*domain.ServiceRequest
+	for _, req := range f.byID {
+		if req.CustomerID == customerID {
+			out = append(out, req)
+		}
+	}
+	return out, nil
+}
+
+type memoryLog struct {
+	eventlogDomain.Repository
+	entries []*eventlogDomain.Entry
+}
+
+func (m *memoryLog) record(t *testing.T, topic, requestID string, payload any) string {
+	t.Helper()
+	env, err := events.NewEnvelope(topic, requestID, "", payload)
+	require.NoError(t, err)
+	raw, err := env.Marshal()
+	require.NoError(t, err)
+	m.entries = append(m.entries, &eventlogDomain.Entry{Seq: int64(len(m.entries) + 1), EventID: env.EventID, Topic: topic, AggregateID: requestID, Envelope: raw})
+	return env.EventID
+}
+
+func (m *memoryLog) Query(_ context.Context, f eventlogDomain.Filter) ([]*eventlogDomain.Entry, error) {
+	var out []*eventlogDomain.Entry
+	for _, e := range m.entries {
+		switch {
+		case e.Seq <= f.AfterSeq,
+			f.EventID != "" && e.EventID != f.EventID,
+			len(f.Topics) > 0 && !slices.Contains(f.Topics, e.Topic),
+			len(f.AggregateIDs) > 0 && !slices.Contains(f.AggregateIDs, e.AggregateID):
+			continue
+		}
+		out = append(out, e)
+		if f.Limit > 0 && len(out) == f.Limit {
+			break
+		}
+	}
+	return out, nil
+}
+
+func TestUpdateFor(t *testing.T) {
+	dispatchAt := time.Now().Add(time.Hour)
+	env, err := events.NewEnvelope(events.TopicRequestCreated, "req-1", "", events.RequestCreatedEvent{RequestID: "req-1", CustomerID: "cust-1", DispatchAt: &dispatchAt})
+	require.NoError(t, err)
+	u, err := UpdateFor(env)
+	require.NoError(t, err)
+	assert.Equal(t, UpdateCreated, u.Type)
+	assert.Equal(t, domain.StatusScheduled, u.Status)
+	assert.Equal(t, env.EventID, u.EventID)
+
+	env, err = events.NewEnvelope(events.TopicDispatchSent, "req-1", "", events.DispatchSentEvent{RequestID: "req-1", CustomerID: "cust-1", Count: 3, Wave: 2})
+	require.NoError(t, err)
+	u, err = UpdateFor(env)
+	require.NoError(t, err)
+	assert.Equal(t, UpdateDispatched, u.Type)
+	assert.Equal(t, "cust-1", u.CustomerID)
+	assert.Equal(t, 3, u.Providers)
+	assert.Equal(t, 2, u.Wave)
+
+	env, err = events.NewEnvelope(events.TopicRequestAccepted, "req-1", "", events.RequestAcceptedEvent{RequestID: "req-1", CustomerID: "cust-1", ProviderID: "prov-1"})
+	require.NoError(t, err)
+	u, err = UpdateFor(env)
+	require.NoError(t, err)
+	assert.Equal(t, UpdateAccepted, u.Type)
+	assert.Equal(t, domain.StatusAccepted, u.Status)
+	assert.Equal(t, "prov-1", u.ProviderID)
+
+	env, err = events.NewEnvelope(events.TopicRequestUnassigned, "req-1", "", events.RequestUnassignedEvent{RequestID: "req-1", CustomerID: "cust-1", ProviderID: "prov-1"})
+	require.NoError(t, err)
+	u, err = UpdateFor(env)
+	require.NoError(t, err)
+	assert.Equal(t, UpdateUnassigned, u.Type)
+	assert.Equal(t, domain.StatusOpen, u.Status)
+	assert.Equal(t, "prov-1", u.ProviderID)
+
+	env, err = events.NewEnvelope(events.TopicDispatchRejected, "req-1", "", events.DispatchRejectedEvent{RequestID: "req-1"})
+	require.NoError(t, err)
+	u, err = UpdateFor(env)
+	require.NoError(t, err)
+	assert.Nil(t, u, "customers are not told about single offers")
+}
+
+func TestUpdatesSince_ReplaysOwnRequestsAfterLastEvent(t *testing.T) {
+	repo := &fakeRepo{byID: map[string]*domain.ServiceRequest{
+		"req-1": {ID: "req-1", CustomerID: "cust-1"},
+		"req-2": {ID: "req-2", CustomerID: "cust-2"},
+	}}
+	log := &memoryLog{}
+	log.record(t, events.TopicRequestCreated, "req-1", events.RequestCreatedEvent{RequestID: "req-1", CustomerID: "cust-1"})
+	last := log.record(t, events.TopicDispatchSent, "req-1", events.DispatchSentEvent{RequestID: "req-1", Count: 2, Wave: 1})
+	log.record(t, events.TopicDispatchRejected, "req-1", events.DispatchRejectedEvent{RequestID: "req-1"})
+	log.record(t, events.TopicRequestAccepted, "req-2", events.RequestAcceptedEvent{RequestID: "req-2", CustomerID: "cust-2"})
+	accepted := log.record(t, events.TopicRequestAccepted, "req-1", events.RequestAcceptedEvent{RequestID: "req-1", CustomerID: "cust-1", ProviderID: "prov-1"})
+	u := NewUpdates(repo, log)
+
+	updates, err := u.Since(context.Background(), "cust-1", last)
+	require.NoError(t, err)
+	require.Len(t, updates, 2)
+	assert.Equal(t, UpdateCreated, updates[0].Type, "entries just before the last event are replayed too")
+	assert.Equal(t, accepted, updates[1].EventID)
+	assert.Equal(t, UpdateAccepted, updates[1].Type)
+
+	_, err = u.Since(context.Background(), "cust-1", "evt-unknown")
+	assert.ErrorIs(t, err, ErrCannotResume)
+}
+
+func TestUpdatesSince_ReplaysEntriesCommittedOutOfOrder(t *testing.T) {
+	repo := &fakeRepo{byID: map[string]*domain.ServiceRequest{"req-1": {ID: "req-1", CustomerID: "cust-1"}}}
+	log := &memoryLog{}
+	log.record(t, events.TopicRequestCreated, "req-1", events.RequestCreatedEvent{RequestID: "req-1", CustomerID: "cust-1"})
+	last := log.record(t, events.TopicDispatchSent, "req-1", events.DispatchSentEvent{RequestID: "req-1", Count: 2, Wave: 1})
+	// Given a lower seq by a relay whose transaction committed later
+	cancelled := log.record(t, events.TopicRequestCancelled, "req-1", events.RequestCancelledEvent{RequestID: "req-1", CustomerID: "cust-1"})
+	log.entries[1].Seq, log.entries[2].Seq = 3, 2
+	u := NewUpdates(repo, log)
+
+	updates, err := u.Since(context.Background(), "cust-1", last)
+	require.NoError(t, err)
+	var ids []string
+	for _, upd := range updates {
+		ids = append(ids, upd.EventID)
+	}
+	assert.Contains(t, ids, cancelled)
+	assert.NotContains(t, ids, last)
+}
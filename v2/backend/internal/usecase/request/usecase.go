@@ -6,10 +6,12 @@ import (
 	"time"
 
 	"github.com/google/uuid"
-	domain "github.com/pitgo/backend/internal/domain/request"
 	"github.com/pitgo/backend/internal/domain/events"
+	"github.com/pitgo/backend/internal/domain/outbox"
+	domain "github.com/pitgo/backend/internal/domain/request"
+	"github.com/pitgo/backend/internal/infrastructure/config"
+	"github.com/pitgo/backend/internal/infrastructure/database"
 	"github.com/pitgo/backend/internal/infrastructure/logger"
-	"github.com/pitgo/backend/internal/infrastructure/queue"
 )
 
 var (
@@ -20,28 +22,48 @@ var (
 
 type UseCase struct {
 	repo      domain.Repository
-	publisher queue.Publisher
+	tx        database.Transactor
+	outbox    outbox.Repository
+	scheduler config.SchedulerConfig
 }
 
-func New(repo domain.Repository, publisher queue.Publisher) *UseCase {
-	return &UseCase{repo: repo, publisher: publisher}
+func New(repo domain.Repository, tx database.Transactor, outboxRepo outbox.Repository, scheduler config.SchedulerConfig) *UseCase {
+	return &UseCase{repo: repo, tx: tx, outbox: outboxRepo, scheduler: scheduler}
 }
 
-// publishEvent wraps the payload in a traceable Envelope before publishing.
-func (uc *UseCase) publishEvent(ctx context.Context, topic string, correlationID string, payload any) {
-	env, err := events.NewEnvelope(topic, correlationID, payload)
-	if err != nil {
-		logger.Error().Err(err).Str("topic", topic).Msg("Failed to create event envelope")
-		return
+// dispatchAt returns when dispatch should start for a request of category
+// scheduled at scheduledAt: its category's lead time before.
+func (uc *UseCase) dispatchAt(category string, scheduledAt time.Time) time.Time {
+	lead, ok := uc.scheduler.CategoryLeadTime[category]
+	if !ok {
+		lead = uc.scheduler.LeadTime
 	}
-	data, err := env.Marshal()
+	return scheduledAt.Add(-lead)
+}
+
+// recordEvent wraps the payload in a traceable Envelope, correlated with the
+// request in ctx, and stores it in the outbox. Call it inside WithinTx so the
+// event commits with the state change; the outbox relay publishes it afterwards.
+func (uc *UseCase) recordEvent(ctx context.Context, topic string, aggregateID string, payload any) error {
+	env, err := events.NewEnvelope(topic, aggregateID, logger.CorrelationID(ctx), payload)
 	if err != nil {
-		logger.Error().Err(err).Str("topic", topic).Msg("Failed to marshal event envelope")
-		return
+		return err
 	}
-	if err := uc.publisher.Publish(ctx, topic, data); err != nil {
-		logger.Error().Err(err).Str("topic", topic).Msg("Failed to publish event")
+	msg, err := outbox.NewMessage(env)
+	if err != nil {
+		return err
 	}
+	return uc.outbox.Add(ctx, msg)
+}
+
+// updateWithEvent persists the request and records its event atomically.
+func (uc *UseCase) updateWithEvent(ctx context.Context, req *domain.ServiceRequest, topic string, payload any) error {
+	return uc.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := uc.repo.Update(ctx, req); err != nil {
+			return err
+		}
+		return uc.recordEvent(ctx, topic, req.ID, payload)
+	})
 }
 
 func (uc *UseCase) CreateRequest(
@@ -52,6 +74,7 @@ func (uc *UseCase) CreateRequest(
 	notes string,
 	totalPrice int64,
 ) (*domain.ServiceRequest, error) {
+	now := time.Now()
 	req := &domain.ServiceRequest{
 		ID:          uuid.New().String(),
 		CustomerID:  customerID,
@@ -66,24 +89,39 @@ func (uc *UseCase) CreateRequest(
 		AddressID:   addressID,
 		Latitude:    lat,
 		Longitude:   lng,
-		CreatedAt:   time.Now(),
-		UpdatedAt:   time.Now(),
+		CreatedAt:   now,
+		UpdatedAt:   now,
 	}
-	if err := uc.repo.Create(ctx, req); err != nil {
+	// Requests booked far enough ahead wait for the scheduler
+	if at := uc.dispatchAt(category, scheduledAt); at.After(now) {
+		req.Status = domain.StatusScheduled
+		req.DispatchAt = &at
+	}
+	// Persist request and its typed event together — the event triggers the dispatch worker
+	err := uc.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := uc.repo.Create(ctx, req); err != nil {
+			return err
+		}
+		return uc.recordEvent(ctx, events.TopicRequestCreated, req.ID, events.RequestCreatedEvent{
+			RequestID:   req.ID,
+			CustomerID:  customerID,
+			Category:    category,
+			Description: description,
+			Latitude:    lat,
+			Longitude:   lng,
+			ScheduledAt: scheduledAt,
+			DispatchAt:  req.DispatchAt,
+		})
+	})
+	if err != nil {
 		return nil, err
 	}
 
-	logger.Info().Str("request_id", req.ID).Str("customer_id", customerID).Msg("Request created")
-
-	// Publish typed event with envelope — triggers dispatch worker
-	uc.publishEvent(ctx, events.TopicRequestCreated, req.ID, events.RequestCreatedEvent{
-		RequestID:   req.ID,
-		CustomerID:  customerID,
-		Category:    category,
-		Description: description,
-		Latitude:    lat,
-		Longitude:   lng,
-	})
+	logger.Ctx(ctx).Info().
+		Str("request_id", req.ID).
+		Str("customer_id", customerID).
+		Str("status", string(req.Status)).
+		Msg("Request created")
 
 	return req, nil
 }
@@ -99,29 +137,6 @@ func (uc *UseCase) ListAvailable(ctx context.Context, lat, lng, radiusKm float64
 	return uc.repo.ListAvailable(ctx, lat, lng, radiusKm, category, limit, offset)
 }
 
-func (uc *UseCase) AcceptRequest(ctx context.Context, id, providerID string) (*domain.ServiceRequest, error) {
-	req, err := uc.repo.GetByID(ctx, id)
-	if err != nil {
-		return nil, err
-	}
-	if req.Status != domain.StatusOpen {
-		return nil, ErrInvalidStatus
-	}
-	now := time.Now()
-	req.Status = domain.StatusAccepted
-	req.ProviderID = providerID
-	req.AcceptedAt = &now
-	req.UpdatedAt = now
-	if err := uc.repo.Update(ctx, req); err != nil {
-		return nil, err
-	}
-
-	logger.Info().Str("request_id", id).Str("provider_id", providerID).Msg("Request accepted")
-	uc.publishEvent(ctx, events.TopicRequestAccepted, id, req)
-
-	return req, nil
-}
-
 func (uc *UseCase) StartRequest(ctx context.Context, id, providerID string) (*domain.ServiceRequest, error) {
 	req, err := uc.repo.GetByID(ctx, id)
 	if err != nil {
@@ -134,12 +149,17 @@ func (uc *UseCase) StartRequest(ctx context.Context, id, providerID string) (*do
 	req.Status = domain.StatusInProgress
 	req.StartedAt = &now
 	req.UpdatedAt = now
-	if err := uc.repo.Update(ctx, req); err != nil {
+	evt := events.RequestStartedEvent{
+		RequestID:  req.ID,
+		CustomerID: req.CustomerID,
+		ProviderID: req.ProviderID,
+		StartedAt:  now,
+	}
+	if err := uc.updateWithEvent(ctx, req, events.TopicRequestStarted, evt); err != nil {
 		return nil, err
 	}
 
-	logger.Info().Str("request_id", id).Msg("Request started")
-	uc.publishEvent(ctx, events.TopicRequestStarted, id, req)
+	logger.Ctx(ctx).Info().Str("request_id", id).Msg("Request started")
 
 	return req, nil
 }
@@ -156,41 +176,144 @@ func (uc *UseCase) CompleteRequest(ctx context.Context, id, providerID string) (
 	req.Status = domain.StatusCompleted
 	req.CompletedAt = &now
 	req.UpdatedAt = now
-	if err := uc.repo.Update(ctx, req); err != nil {
+	evt := events.RequestCompletedEvent{
+		RequestID:   req.ID,
+		CustomerID:  req.CustomerID,
+		ProviderID:  req.ProviderID,
+		TotalPrice:  req.TotalPrice,
+		CompletedAt: now,
+	}
+	if err := uc.updateWithEvent(ctx, req, events.TopicRequestCompleted, evt); err != nil {
 		return nil, err
 	}
 
-	logger.Info().Str("request_id", id).Msg("Request completed")
-	uc.publishEvent(ctx, events.TopicRequestCompleted, id, req)
+	logger.Ctx(ctx).Info().Str("request_id", id).Msg("Request completed")
 
 	return req, nil
 }
 
 func (uc *UseCase) CancelRequest(ctx context.Context, id, customerID string) (*domain.ServiceRequest, error) {
-	req, err := uc.repo.GetByID(ctx, id)
+	var req *domain.ServiceRequest
+	err := uc.tx.WithinTx(ctx, func(ctx context.Context) error {
+		// Locked like accept does, so a concurrent accept either commits
+		// first and is seen here, or finds the request cancelled
+		var err error
+		req, err = uc.repo.GetByIDForUpdate(ctx, id)
+		if err != nil {
+			return err
+		}
+		if req.CustomerID != customerID {
+			return ErrNotOwner
+		}
+		if req.Status == domain.StatusCompleted || req.Status == domain.StatusCancelled {
+			return ErrInvalidStatus
+		}
+		now := time.Now()
+		evt := events.RequestCancelledEvent{
+			RequestID:      req.ID,
+			CustomerID:     req.CustomerID,
+			ProviderID:     req.ProviderID,
+			PreviousStatus: string(req.Status),
+			CancelledAt:    now,
+		}
+		req.Status = domain.StatusCancelled
+		req.CancelledAt = &now
+		req.UpdatedAt = now
+		return uc.updateWithEvent(ctx, req, events.TopicRequestCancelled, evt)
+	})
 	if err != nil {
 		return nil, err
 	}
-	if req.CustomerID != customerID {
-		return nil, ErrNotOwner
-	}
-	if req.Status == domain.StatusCompleted || req.Status == domain.StatusCancelled {
-		return nil, ErrInvalidStatus
-	}
-	now := time.Now()
-	req.Status = domain.StatusCancelled
-	req.CancelledAt = &now
-	req.UpdatedAt = now
-	if err := uc.repo.Update(ctx, req); err != nil {
+
+	logger.Ctx(ctx).Info().Str("request_id", id).Msg("Request cancelled")
+
+	return req, nil
+}
+
+// RescheduleRequest moves a request that was not dispatched yet to
+// scheduledAt. If the new time is within the lead time, the scheduler opens
+// the request on its next poll.
+func (uc *UseCase) RescheduleRequest(ctx context.Context, id, customerID string, scheduledAt time.Time) (*domain.ServiceRequest, error) {
+	var req *domain.ServiceRequest
+	err := uc.tx.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		req, err = uc.repo.GetByIDForUpdate(ctx, id)
+		if err != nil {
+			return err
+		}
+		if req.CustomerID != customerID {
+			return ErrNotOwner
+		}
+		if req.Status != domain.StatusScheduled {
+			return ErrInvalidStatus
+		}
+
+		now := time.Now()
+		at := uc.dispatchAt(req.Category, scheduledAt)
+		if at.Before(now) {
+			at = now
+		}
+		evt := events.RequestRescheduledEvent{
+			RequestID:           req.ID,
+			CustomerID:          req.CustomerID,
+			PreviousScheduledAt: req.ScheduledAt,
+			ScheduledAt:         scheduledAt,
+			DispatchAt:          at,
+		}
+		req.ScheduledAt = scheduledAt
+		req.DispatchAt = &at
+		req.UpdatedAt = now
+		return uc.updateWithEvent(ctx, req, events.TopicRequestRescheduled, evt)
+	})
+	if err != nil {
 		return nil, err
 	}
 
-	logger.Info().Str("request_id", id).Msg("Request cancelled")
-	uc.publishEvent(ctx, events.TopicRequestCancelled, id, req)
+	logger.Ctx(ctx).Info().Str("request_id", id).Time("scheduled_at", scheduledAt).Msg("Request rescheduled")
 
 	return req, nil
 }
 
+// OpenDue opens up to limit scheduled requests whose dispatch time has come
+// and records request.opened for each, which starts their dispatch. Requests
+// being opened by another replica are skipped.
+func (uc *UseCase) OpenDue(ctx context.Context, limit int) ([]*domain.ServiceRequest, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	var opened []*domain.ServiceRequest
+	err := uc.tx.WithinTx(ctx, func(ctx context.Context) error {
+		now := time.Now()
+		due, err := uc.repo.LockDue(ctx, now, limit)
+		if err != nil {
+			return err
+		}
+		for _, req := range due {
+			req.Status = domain.StatusOpen
+			req.UpdatedAt = now
+			err := uc.updateWithEvent(ctx, req, events.TopicRequestOpened, events.RequestOpenedEvent{
+				RequestID:   req.ID,
+				CustomerID:  req.CustomerID,
+				Category:    req.Category,
+				ScheduledAt: req.ScheduledAt,
+				OpenedAt:    now,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		opened = due
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(opened) > 0 {
+		logger.Ctx(ctx).Info().Int("count", len(opened)).Msg("Scheduled requests opened for dispatch")
+	}
+	return opened, nil
+}
+
 func (uc *UseCase) ListByCustomer(ctx context.Context, customerID string, status domain.Status, limit, offset int) ([]*domain.ServiceRequest, error) {
 	return uc.repo.ListByCustomer(ctx, customerID, status, limit, offset)
 }
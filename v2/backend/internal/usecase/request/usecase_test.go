@@ -0,0 +1,179 @@
+package request
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pitgo/backend/internal/domain/events"
+	"github.com/pitgo/backend/internal/domain/outbox"
+	domain "github.com/pitgo/backend/internal/domain/request"
+	"github.com/pitgo/backend/internal/infrastructure/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeRepo struct {
+	domain.Repository
+	byID map[string]*domain.ServiceRequest
+}
+
+func (f *fakeRepo) Create(_ context.Context, req *domain.ServiceRequest) error {
+	f.byID[req.ID] = req
+	return nil
+}
+
+func (f *fakeRepo) GetByIDForUpdate(_ context.Context, id string) (*domain.ServiceRequest, error) {
+	req, ok := f.byID[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	cp := *req
+	return &cp, nil
+}
+
+func (f *fakeRepo) Update(_ context.Context, req *domain.ServiceRequest) error {
+	f.byID[req.ID] = req
+	return nil
+}
+
+func (f *fakeRepo) LockDue(_ context.Context, now time.Time, limit int) ([]*domain.ServiceRequest, error) {
+	var due []*domain.ServiceRequest
+	for _, req := range f.byID {
+		if req.Status == domain.StatusScheduled && !req.DispatchAt.After(now) && len(due) < limit {
+			cp := *req
+			due = append(due, &cp)
+		}
+	}
+	return due, nil
+}
+
+type fakeOutbox struct {
+	outbox.Repository
+	topics []string
+	msgs   []*outbox.Message
+}
+
+func (f *fakeOutbox) Add(_ context.Context, msg *outbox.Message) error {
+	f.topics = append(f.topics, msg.Topic)
+	f.msgs = append(f.msgs, msg)
+	return nil
+}
+
+type passthroughTx struct{}
+
+func (passthroughTx) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+func setup() (*UseCase, *fakeRepo, *fakeOutbox) {
+	repo := &fakeRepo{byID: map[string]*domain.ServiceRequest{}}
+	ob := &fakeOutbox{}
+	uc := New(repo, passthroughTx{}, ob, config.SchedulerConfig{
+		LeadTime:         time.Hour,
+		CategoryLeadTime: map[string]time.Duration{"moving": 24 * time.Hour},
+	})
+	return uc, repo, ob
+}
+
+func create(t *testing.T, uc *UseCase, category string, scheduledAt time.Time) *domain.ServiceRequest {
+	t.Helper()
+	req, err := uc.CreateRequest(context.Background(), "cust-1", "svc-1", category, "Leaking kitchen sink", "", "", 0, 0, scheduledAt, "", 100)
+	require.NoError(t, err)
+	return req
+}
+
+func TestCreateRequest_SchedulesByCategoryLeadTime(t *testing.T) {
+	uc, _, ob := setup()
+	inTwoHours := time.Now().Add(2 * time.Hour)
+
+	now := create(t, uc, "plumbing", time.Now())
+	assert.Equal(t, domain.StatusOpen, now.Status)
+	assert.Nil(t, now.DispatchAt)
+
+	later := create(t, uc, "plumbing", inTwoHours)
+	assert.Equal(t, domain.StatusScheduled, later.Status)
+	require.NotNil(t, later.DispatchAt)
+	assert.WithinDuration(t, inTwoHours.Add(-time.Hour), *later.DispatchAt, time.Second)
+
+	// Moving needs a day of notice, so it is dispatched right away
+	moving := create(t, uc, "moving", inTwoHours)
+	assert.Equal(t, domain.StatusOpen, moving.Status)
+
+	require.Len(t, ob.msgs, 3)
+	env, err := events.UnmarshalEnvelope(ob.msgs[1].Payload)
+	require.NoError(t, err)
+	evt, err := events.DecodePayload[events.RequestCreatedEvent](env)
+	require.NoError(t, err)
+	require.NotNil(t, evt.DispatchAt)
+	assert.WithinDuration(t, *later.DispatchAt, *evt.DispatchAt, time.Millisecond)
+}
+
+func TestOpenDue_OpensOnlyDueRequests(t *testing.T) {
+	uc, repo, ob := setup()
+	soon := create(t, uc, "plumbing", time.Now().Add(2*time.Hour))
+	nextWeek := create(t, uc, "plumbing", time.Now().Add(7*24*time.Hour))
+	due := time.Now().Add(-time.Minute)
+	repo.byID[soon.ID].DispatchAt = &due
+	ob.topics = nil
+
+	opened, err := uc.OpenDue(context.Background(), 10)
+	require.NoError(t, err)
+	require.Len(t, opened, 1)
+	assert.Equal(t, soon.ID, opened[0].ID)
+	assert.Equal(t, domain.StatusOpen, repo.byID[soon.ID].Status)
+	assert.Equal(t, domain.StatusScheduled, repo.byID[nextWeek.ID].Status)
+	assert.Equal(t, []string{events.TopicRequestOpened}, ob.topics)
+
+	opened, err = uc.OpenDue(context.Background(), 10)
+	require.NoError(t, err)
+	assert.Empty(t, opened)
+}
+
+func TestRescheduleRequest(t *testing.T) {
+	uc, repo, ob := setup()
+	req := create(t, uc, "plumbing", time.Now().Add(48*time.Hour))
+	ctx := context.Background()
+
+	_, err := uc.RescheduleRequest(ctx, req.ID, "someone-else", time.Now().Add(72*time.Hour))
+	assert.ErrorIs(t, err, ErrNotOwner)
+
+	newTime := time.Now().Add(72 * time.Hour)
+	updated, err := uc.RescheduleRequest(ctx, req.ID, "cust-1", newTime)
+	require.NoError(t, err)
+	assert.Equal(t, newTime, updated.ScheduledAt)
+	assert.WithinDuration(t, newTime.Add(-time.Hour), *updated.DispatchAt, time.Second)
+	assert.Equal(t, events.TopicRequestRescheduled, ob.topics[len(ob.topics)-1])
+
+	// Moved within the lead time: due immediately
+	updated, err = uc.RescheduleRequest(ctx, req.ID, "cust-1", time.Now().Add(30*time.Minute))
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), *updated.DispatchAt, time.Second)
+
+	repo.byID[req.ID].Status = domain.StatusOpen
+	_, err = uc.RescheduleRequest(ctx, req.ID, "cust-1", newTime)
+	assert.ErrorIs(t, err, ErrInvalidStatus, "already dispatched")
+}
+
+func TestCancelRequest_ReportsTheAcceptingProvider(t *testing.T) {
+	uc, repo, ob := setup()
+	req := create(t, uc, "plumbing", time.Now())
+	ctx := context.Background()
+	repo.byID[req.ID].Status, repo.byID[req.ID].ProviderID = domain.StatusAccepted, "prov-1"
+
+	_, err := uc.CancelRequest(ctx, req.ID, "someone-else")
+	assert.ErrorIs(t, err, ErrNotOwner)
+
+	cancelled, err := uc.CancelRequest(ctx, req.ID, "cust-1")
+	require.NoError(t, err)
+	assert.Equal(t, domain.StatusCancelled, cancelled.Status)
+	env, err := events.UnmarshalEnvelope(ob.msgs[len(ob.msgs)-1].Payload)
+	require.NoError(t, err)
+	evt, err := events.DecodePayload[events.RequestCancelledEvent](env)
+	require.NoError(t, err)
+	assert.Equal(t, "prov-1", evt.ProviderID)
+	assert.Equal(t, string(domain.StatusAccepted), evt.PreviousStatus)
+
+	_, err = uc.CancelRequest(ctx, req.ID, "cust-1")
+	assert.ErrorIs(t, err, ErrInvalidStatus)
+}
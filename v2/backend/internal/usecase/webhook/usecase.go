@@ -0,0 +1,298 @@
+package webhook
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pitgo/backend/internal/domain/events"
+	requestDomain "github.com/pitgo/backend/internal/domain/request"
+	domain "github.com/pitgo/backend/internal/domain/webhook"
+	"github.com/pitgo/backend/internal/infrastructure/logger"
+	"github.com/pitgo/backend/internal/infrastructure/queue"
+	webhookInfra "github.com/pitgo/backend/internal/infrastructure/webhook"
+)
+
+// claimLease is how long a claimed delivery is hidden from other replicas
+// while it is being sent. It must comfortably exceed a batch of HTTP timeouts.
+const claimLease = 5 * time.Minute
+
+const maxErrorLength = 500
+
+var (
+	ErrInvalidURL          = errors.New("webhook url must be an absolute http or https URL")
+	ErrInvalidTopic        = errors.New("unknown event topic")
+	ErrNoTopics            = errors.New("at least one topic is required")
+	ErrNoCustomers         = errors.New("at least one customer is required")
+	ErrDeliveryNotFound    = errors.New("delivery not found for this webhook")
+	ErrSubscriptionMissing = errors.New("webhook subscription no longer exists")
+)
+
+// Sender posts a signed payload to a partner endpoint.
+type Sender interface {
+	Send(ctx context.Context, r webhookInfra.Request) (int, error)
+}
+
+type UseCase struct {
+	subs       domain.SubscriptionRepository
+	deliveries domain.DeliveryRepository
+	requests   requestDomain.Repository
+	sender     Sender
+	retry      queue.RetryPolicy
+}
+
+// New wires the webhook use case. requests resolves whose request an event is
+// about. retry.MaxAttempts bounds how often a delivery is attempted before it
+// is marked failed; its backoff spaces the attempts out.
+func New(subs domain.SubscriptionRepository, deliveries domain.DeliveryRepository, requests requestDomain.Repository, sender Sender, retry queue.RetryPolicy) *UseCase {
+	if retry.MaxAttempts < 1 {
+		retry.MaxAttempts = 1
+	}
+	return &UseCase{subs: subs, deliveries: deliveries, requests: requests, sender: sender, retry: retry}
+}
+
+// --- Subscriptions ---
+
+// CreateSubscription registers an endpoint for the requests of customerIDs
+// and generates its signing secret. The secret is only readable on the
+// returned value.
+func (uc *UseCase) CreateSubscription(ctx context.Context, rawURL string, topics, customerIDs []string, description string) (*domain.Subscription, error) {
+	if err := validate(rawURL, topics, customerIDs); err != nil {
+		return nil, err
+	}
+	secret, err := newSecret()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	sub := &domain.Subscription{
+		ID:          uuid.New().String(),
+		URL:         rawURL,
+		Topics:      topics,
+		CustomerIDs: customerIDs,
+		Secret:      secret,
+		Description: description,
+		Active:      true,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := uc.subs.Create(ctx, sub); err != nil {
+		return nil, err
+	}
+	logger.Ctx(ctx).Info().Str("webhook_id", sub.ID).Str("url", sub.URL).Strs("topics", sub.Topics).Msg("Webhook subscription created")
+	return sub, nil
+}
+
+func (uc *UseCase) GetSubscription(ctx context.Context, id string) (*domain.Subscription, error) {
+	return uc.subs.GetByID(ctx, id)
+}
+
+func (uc *UseCase) ListSubscriptions(ctx context.Context) ([]*domain.Subscription, error) {
+	return uc.subs.List(ctx)
+}
+
+// UpdateSubscription saves sub after validation. With rotateSecret a new
+// signing secret is generated and set on sub.
+func (uc *UseCase) UpdateSubscription(ctx context.Context, sub *domain.Subscription, rotateSecret bool) (*domain.Subscription, error) {
+	if err := validate(sub.URL, sub.Topics, sub.CustomerIDs); err != nil {
+		return nil, err
+	}
+	if rotateSecret {
+		secret, err := newSecret()
+		if err != nil {
+			return nil, err
+		}
+		sub.Secret = secret
+	}
+	sub.UpdatedAt = time.Now()
+	if err := uc.subs.Update(ctx, sub); err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+// DeleteSubscription removes the endpoint together with its delivery log.
+func (uc *UseCase) DeleteSubscription(ctx context.Context, id string) error {
+	return uc.subs.Delete(ctx, id)
+}
+
+// --- Delivery log ---
+
+func (uc *UseCase) ListDeliveries(ctx context.Context, filter domain.DeliveryFilter) ([]*domain.Delivery, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = 20
+	}
+	return uc.deliveries.List(ctx, filter)
+}
+
+func (uc *UseCase) GetDelivery(ctx context.Context, subscriptionID, deliveryID string) (*domain.Delivery, error) {
+	d, err := uc.deliveries.GetByID(ctx, deliveryID)
+	if err != nil || d.SubscriptionID != subscriptionID {
+		return nil, ErrDeliveryNotFound
+	}
+	return d, nil
+}
+
+// --- Fan-out and delivery ---
+
+// Fanout queues one pending delivery of env per active subscription that
+// listens to its topic and covers the customer of the request it is about, so
+// partners never hear about other customers' requests. The payload is upcast
+// to the latest schema version so partners only ever see one shape per
+// topic. Redelivered envelopes are ignored thanks to the (subscription,
+// event) unique key.
+func (uc *UseCase) Fanout(ctx context.Context, env *events.Envelope) (int, error) {
+	// Not every payload names the customer; the request always does
+	req, err := uc.requests.GetByID(ctx, env.AggregateID)
+	if err != nil {
+		return 0, fmt.Errorf("look up request %s: %w", env.AggregateID, err)
+	}
+	subs, err := uc.subs.ListActiveByTopic(ctx, env.Topic, req.CustomerID)
+	if err != nil || len(subs) == 0 {
+		return 0, err
+	}
+	if err := events.DefaultRegistry.Upcast(env); err != nil {
+		return 0, err
+	}
+	payload, err := env.Marshal()
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	for _, sub := range subs {
+		d := &domain.Delivery{
+			ID:             uuid.New().String(),
+			SubscriptionID: sub.ID,
+			EventID:        env.EventID,
+			Topic:          env.Topic,
+			Payload:        payload,
+			Status:         domain.DeliveryPending,
+			NextAttemptAt:  now,
+			CreatedAt:      now,
+			UpdatedAt:      now,
+		}
+		if err := uc.deliveries.Create(ctx, d); err != nil {
+			return 0, fmt.Errorf("queue delivery for webhook %s: %w", sub.ID, err)
+		}
+	}
+	return len(subs), nil
+}
+
+// DeliverDue claims up to limit due deliveries and attempts each of them.
+// It returns the number of deliveries claimed.
+func (uc *UseCase) DeliverDue(ctx context.Context, limit int) (int, error) {
+	due, err := uc.deliveries.ClaimDue(ctx, time.Now(), claimLease, limit)
+	if err != nil {
+		return 0, err
+	}
+	var errs []error
+	for _, d := range due {
+		if ctx.Err() != nil {
+			// Unattempted claims expire with their lease and are picked up again
+			errs = append(errs, ctx.Err())
+			break
+		}
+		if err := uc.attempt(ctx, d); err != nil {
+			errs = append(errs, fmt.Errorf("delivery %s: %w", d.ID, err))
+		}
+	}
+	return len(due), errors.Join(errs...)
+}
+
+// Redeliver resets a delivery's attempt budget and sends it right away,
+// whatever its current status.
+func (uc *UseCase) Redeliver(ctx context.Context, subscriptionID, deliveryID string) (*domain.Delivery, error) {
+	d, err := uc.GetDelivery(ctx, subscriptionID, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	d.Status = domain.DeliveryPending
+	d.Attempts = 0
+	d.DeliveredAt = nil
+	if err := uc.attempt(ctx, d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// attempt sends d once and records the outcome on the delivery row. Only
+// repository errors are returned; endpoint failures are scheduled for retry.
+func (uc *UseCase) attempt(ctx context.Context, d *domain.Delivery) error {
+	log := logger.Ctx(ctx).With().Str("delivery_id", d.ID).Str("webhook_id", d.SubscriptionID).Str("topic", d.Topic).Logger()
+
+	sub, err := uc.subs.GetByID(ctx, d.SubscriptionID)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrSubscriptionMissing, err)
+	}
+
+	status, sendErr := uc.sender.Send(ctx, webhookInfra.Request{
+		URL:        sub.URL,
+		Secret:     sub.Secret,
+		Topic:      d.Topic,
+		DeliveryID: d.ID,
+		Body:       d.Payload,
+	})
+
+	now := time.Now()
+	d.Attempts++
+	d.ResponseStatus = status
+	d.UpdatedAt = now
+	switch {
+	case sendErr == nil:
+		d.Status = domain.DeliverySucceeded
+		d.LastError = ""
+		d.DeliveredAt = &now
+		log.Info().Int("status", status).Int("attempt", d.Attempts).Msg("Webhook delivered")
+	case d.Attempts >= uc.retry.MaxAttempts:
+		d.Status = domain.DeliveryFailed
+		d.LastError = truncate(sendErr.Error())
+		log.Error().Err(sendErr).Int("attempt", d.Attempts).Msg("Webhook delivery failed permanently")
+	default:
+		d.Status = domain.DeliveryPending
+		d.LastError = truncate(sendErr.Error())
+		d.NextAttemptAt = now.Add(uc.retry.Backoff(d.Attempts))
+		log.Warn().Err(sendErr).Int("attempt", d.Attempts).Time("next_attempt_at", d.NextAttemptAt).Msg("Webhook delivery failed, will retry")
+	}
+	return uc.deliveries.Update(ctx, d)
+}
+
+func validate(rawURL string, topics, customerIDs []string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ErrInvalidURL
+	}
+	if len(topics) == 0 {
+		return ErrNoTopics
+	}
+	for _, t := range topics {
+		if !slices.Contains(events.Topics, t) {
+			return fmt.Errorf("%w: %s", ErrInvalidTopic, t)
+		}
+	}
+	if len(customerIDs) == 0 {
+		return ErrNoCustomers
+	}
+	return nil
+}
+
+func newSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+func truncate(s string) string {
+	if len(s) > maxErrorLength {
+		return s[:maxErrorLength]
+	}
+	return s
+}
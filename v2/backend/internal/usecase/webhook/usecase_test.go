@@ -0,0 +1,288 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pitgo/backend/internal/domain/events"
+	requestDomain "github.com/pitgo/backend/internal/domain/request"
+	domain "github.com/pitgo/backend/internal/domain/webhook"
+	"github.com/pitgo/backend/internal/infrastructure/queue"
+	webhookInfra "github.com/pitgo/backend/internal/infrastructure/webhook"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type memorySubs struct {
+	domain.SubscriptionRepository
+	byID map[string]*domain.Subscription
+}
+
+func (m *memorySubs) Create(_ context.Context, s *domain.Subscription) error {
+	m.byID[s.ID] = s
+	return nil
+}
+
+func (m *memorySubs) GetByID(_ context.Context, id string) (*domain.Subscription, error) {
+	s, ok := m.byID[id]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return s, nil
+}
+
+func (m *memorySubs) ListActiveByTopic(_ context.Context, topic, customerID string) ([]*domain.Subscription, error) {
+	var out []*domain.Subscription
+	for _, s := range m.byID {
+		if s.Wants(topic) && s.Covers(customerID) {
+			out = append(out, s)
+		}
+	}
+	return out, nil
+}
+
+// requestOwners serves requests req-a and req-b, of customers cust-a and cust-b.
+type requestOwners struct {
+	requestDomain.Repository
+}
+
+func (requestOwners) GetByID(_ context.Context, id string) (*requestDomain.ServiceRequest, error) {
+	switch id {
+	case "req-a":
+		return &requestDomain.ServiceRequest{ID: id, CustomerID: "cust-a"}, nil
+	case "req-b":
+		return &requestDomain.ServiceRequest{ID: id, CustomerID: "cust-b"}, nil
+	}
+	return nil, errors.New("not found")
+}
+
+type memoryDeliveries struct {
+	domain.DeliveryRepository
+	rows []*domain.Delivery
+}
+
+func (m *memoryDeliveries) Create(_ context.Context, d *domain.Delivery) error {
+	for _, r := range m.rows {
+		if r.SubscriptionID == d.SubscriptionID && r.EventID == d.EventID {
+			return nil
+		}
+	}
+	m.rows = append(m.rows, d)
+	return nil
+}
+
+func (m *memoryDeliveries) GetByID(_ context.Context, id string) (*domain.Delivery, error) {
+	for _, r := range m.rows {
+		if r.ID == id {
+			cp := *r
+			return &cp, nil
+		}
+	}
+	return nil, errors.New("not found")
+}
+
+func (m *memoryDeliveries) ClaimDue(_ context.Context, now time.Time, lease time.Duration, limit int) ([]*domain.Delivery, error) {
+	var out []*domain.Delivery
+	for _, r := range m.rows {
+		if r.Status == domain.DeliveryPending && !r.NextAttemptAt.After(now) && len(out) < limit {
+			r.NextAttemptAt = now.Add(lease)
+			cp := *r
+			out = append(out, &cp)
+		}
+	}
+	return out, nil
+}
+
+func (m *memoryDeliveries) Update(_ context.Context, d *domain.Delivery) error {
+	for i, r := range m.rows {
+		if r.ID == d.ID {
+			cp := *d
+			m.rows[i] = &cp
+		}
+	}
+	return nil
+}
+
+// partner is an httptest endpoint that verifies signatures and fails while
+// failing is set.
+type partner struct {
+	*httptest.Server
+	secret   string
+	failing  atomic.Bool
+	received atomic.Int32
+}
+
+func newPartner(t *testing.T) *partner {
+	p := &partner{}
+	p.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if err := webhookInfra.Verify(p.secret, r.Header.Get(webhookInfra.HeaderSignature), body, time.Minute, time.Now()); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		env, err := events.UnmarshalEnvelope(body)
+		if err != nil || env.Topic != r.Header.Get(webhookInfra.HeaderEvent) {
+			http.Error(w, "bad payload", http.StatusBadRequest)
+			return
+		}
+		if p.failing.Load() {
+			http.Error(w, "down for maintenance", http.StatusServiceUnavailable)
+			return
+		}
+		p.received.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(p.Close)
+	return p
+}
+
+var testRetry = queue.RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Minute, MaxBackoff: time.Hour, Multiplier: 2}
+
+func setup(t *testing.T, topics ...string) (*UseCase, *memoryDeliveries, *partner) {
+	t.Helper()
+	deliveries := &memoryDeliveries{}
+	uc := New(&memorySubs{byID: map[string]*domain.Subscription{}}, deliveries, requestOwners{}, webhookInfra.NewSender(time.Second), testRetry)
+	p := newPartner(t)
+	sub, err := uc.CreateSubscription(context.Background(), p.URL, topics, []string{"cust-a"}, "fleet partner")
+	require.NoError(t, err)
+	p.secret = sub.Secret
+	return uc, deliveries, p
+}
+
+// publish fans out an event about req-a, a request of cust-a.
+func publish(t *testing.T, uc *UseCase, topic string) int {
+	t.Helper()
+	return publishFor(t, uc, topic, "req-a")
+}
+
+func publishFor(t *testing.T, uc *UseCase, topic, requestID string) int {
+	t.Helper()
+	env, err := events.NewEnvelope(topic, requestID, "http-1", events.RequestAcceptedEvent{RequestID: requestID, ProviderID: "prov-1"})
+	require.NoError(t, err)
+	n, err := uc.Fanout(context.Background(), env)
+	require.NoError(t, err)
+	return n
+}
+
+func TestCreateSubscription_Validates(t *testing.T) {
+	uc := New(&memorySubs{byID: map[string]*domain.Subscription{}}, &memoryDeliveries{}, requestOwners{}, nil, testRetry)
+	customers := []string{"cust-a"}
+
+	_, err := uc.CreateSubscription(context.Background(), "ftp://partner.example", []string{events.TopicRequestAccepted}, customers, "")
+	assert.ErrorIs(t, err, ErrInvalidURL)
+	_, err = uc.CreateSubscription(context.Background(), "https://partner.example/hook", []string{"request.teleported"}, customers, "")
+	assert.ErrorIs(t, err, ErrInvalidTopic)
+	_, err = uc.CreateSubscription(context.Background(), "https://partner.example/hook", nil, customers, "")
+	assert.ErrorIs(t, err, ErrNoTopics)
+	_, err = uc.CreateSubscription(context.Background(), "https://partner.example/hook", []string{events.TopicRequestAccepted}, nil, "")
+	assert.ErrorIs(t, err, ErrNoCustomers)
+
+	sub, err := uc.CreateSubscription(context.Background(), "https://partner.example/hook", []string{events.TopicRequestAccepted}, customers, "")
+	require.NoError(t, err)
+	assert.True(t, sub.Active)
+	assert.Regexp(t, `^whsec_[0-9a-f]{64}$`, sub.Secret)
+}
+
+func TestFanout_OnlySubscribedTopicsAndOncePerEvent(t *testing.T) {
+	uc, deliveries, _ := setup(t, events.TopicRequestAccepted)
+
+	assert.Equal(t, 0, publish(t, uc, events.TopicRequestStarted))
+	assert.Equal(t, 1, publish(t, uc, events.TopicRequestAccepted))
+	require.Len(t, deliveries.rows, 1)
+
+	// Same envelope delivered twice by the queue
+	env, err := events.UnmarshalEnvelope(deliveries.rows[0].Payload)
+	require.NoError(t, err)
+	_, err = uc.Fanout(context.Background(), env)
+	require.NoError(t, err)
+	assert.Len(t, deliveries.rows, 1)
+}
+
+func TestFanout_OnlyToPartnersOfTheRequestsCustomer(t *testing.T) {
+	uc, deliveries, partnerA := setup(t, events.TopicRequestAccepted)
+	partnerB := newPartner(t)
+	subB, err := uc.CreateSubscription(context.Background(), partnerB.URL, []string{events.TopicRequestAccepted}, []string{"cust-b"}, "other fleet")
+	require.NoError(t, err)
+	partnerB.secret = subB.Secret
+
+	assert.Equal(t, 1, publishFor(t, uc, events.TopicRequestAccepted, "req-a"))
+	require.Len(t, deliveries.rows, 1)
+	assert.NotEqual(t, subB.ID, deliveries.rows[0].SubscriptionID)
+
+	_, err = uc.DeliverDue(context.Background(), 10)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), partnerA.received.Load())
+	assert.Equal(t, int32(0), partnerB.received.Load(), "partner B never hears about partner A's request")
+
+	assert.Equal(t, 1, publishFor(t, uc, events.TopicRequestAccepted, "req-b"))
+	require.Len(t, deliveries.rows, 2)
+	assert.Equal(t, subB.ID, deliveries.rows[1].SubscriptionID)
+}
+
+func TestDeliverDue_SucceedsWithSignedPayload(t *testing.T) {
+	uc, deliveries, p := setup(t, events.TopicRequestAccepted)
+	publish(t, uc, events.TopicRequestAccepted)
+
+	n, err := uc.DeliverDue(context.Background(), 10)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, int32(1), p.received.Load())
+
+	d := deliveries.rows[0]
+	assert.Equal(t, domain.DeliverySucceeded, d.Status)
+	assert.Equal(t, http.StatusOK, d.ResponseStatus)
+	assert.Equal(t, 1, d.Attempts)
+	assert.NotNil(t, d.DeliveredAt)
+}
+
+func TestDeliverDue_RetriesWithBackoffThenFails(t *testing.T) {
+	uc, deliveries, p := setup(t, events.TopicRequestAccepted)
+	p.failing.Store(true)
+	publish(t, uc, events.TopicRequestAccepted)
+
+	before := time.Now()
+	_, err := uc.DeliverDue(context.Background(), 10)
+	require.NoError(t, err)
+
+	d := deliveries.rows[0]
+	assert.Equal(t, domain.DeliveryPending, d.Status)
+	assert.Equal(t, 1, d.Attempts)
+	assert.Equal(t, http.StatusServiceUnavailable, d.ResponseStatus)
+	assert.Contains(t, d.LastError, "down for maintenance")
+	assert.WithinDuration(t, before.Add(time.Minute), d.NextAttemptAt, 5*time.Second)
+
+	// Not due yet
+	n, err := uc.DeliverDue(context.Background(), 10)
+	require.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	// Second and last attempt
+	d.NextAttemptAt = time.Now()
+	_, err = uc.DeliverDue(context.Background(), 10)
+	require.NoError(t, err)
+	assert.Equal(t, domain.DeliveryFailed, deliveries.rows[0].Status)
+	assert.Equal(t, 2, deliveries.rows[0].Attempts)
+}
+
+func TestRedeliver_ResetsAttemptsAndSendsNow(t *testing.T) {
+	uc, deliveries, p := setup(t, events.TopicRequestAccepted)
+	publish(t, uc, events.TopicRequestAccepted)
+	d := deliveries.rows[0]
+	d.Status = domain.DeliveryFailed
+	d.Attempts = testRetry.MaxAttempts
+
+	_, err := uc.Redeliver(context.Background(), "someone-else", d.ID)
+	assert.ErrorIs(t, err, ErrDeliveryNotFound)
+
+	got, err := uc.Redeliver(context.Background(), d.SubscriptionID, d.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.DeliverySucceeded, got.Status)
+	assert.Equal(t, 1, got.Attempts)
+	assert.Equal(t, int32(1), p.received.Load())
+}
@@ -0,0 +1,79 @@
+package deadletter
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	domain "github.com/pitgo/backend/internal/domain/deadletter"
+	"github.com/pitgo/backend/internal/domain/events"
+	"github.com/pitgo/backend/internal/infrastructure/logger"
+	"github.com/pitgo/backend/internal/infrastructure/queue"
+)
+
+// Worker persists dead-lettered messages so admins can inspect and requeue them.
+type Worker struct {
+	consumer queue.Consumer
+	repo     domain.Repository
+}
+
+func NewWorker(consumer queue.Consumer, repo domain.Repository) *Worker {
+	return &Worker{consumer: consumer, repo: repo}
+}
+
+// Register subscribes to the dead-letter topic of every declared event topic.
+// Call this BEFORE starting the queue consumer.
+func (w *Worker) Register() error {
+	for _, topic := range events.Topics {
+		if err := w.consumer.Subscribe(queue.DeadLetterTopic(topic), w.handleDeadLetter); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *Worker) handleDeadLetter(ctx context.Context, msg queue.Message) error {
+	var dl queue.DeadLetter
+	if err := json.Unmarshal(msg.Payload, &dl); err != nil {
+		logger.Ctx(ctx).Error().Err(err).Str("topic", msg.Topic).Msg("Failed to unmarshal dead letter")
+		return err
+	}
+
+	entry := &domain.DeadLetter{
+		ID:        uuid.New().String(),
+		Topic:     dl.Topic,
+		MessageID: dl.MessageID,
+		Envelope:  dl.Envelope,
+		Error:     dl.Error,
+		Attempts:  dl.Attempts,
+		FailedAt:  dl.FailedAt,
+		CreatedAt: time.Now(),
+	}
+	// Best effort: surface the event identifiers for filtering in the admin UI
+	if env, err := events.UnmarshalEnvelope(dl.Envelope); err == nil {
+		entry.EventID = env.EventID
+		entry.CorrelationID = env.CorrelationID
+		ctx = logger.WithCorrelationID(ctx, env.CorrelationID)
+	}
+
+	stored, err := w.repo.Create(ctx, entry)
+	if err != nil {
+		logger.Ctx(ctx).Error().Err(err).Str("topic", dl.Topic).Msg("Failed to store dead letter")
+		return err
+	}
+	if !stored {
+		// Delivered again; the first delivery is stored already
+		logger.Ctx(ctx).Debug().Str("topic", entry.Topic).Str("event_id", entry.EventID).Msg("Dead letter already stored")
+		return nil
+	}
+
+	logger.Ctx(ctx).Warn().
+		Str("dead_letter_id", entry.ID).
+		Str("topic", entry.Topic).
+		Str("event_id", entry.EventID).
+		Int("attempts", entry.Attempts).
+		Str("error", entry.Error).
+		Msg("Dead letter stored")
+	return nil
+}
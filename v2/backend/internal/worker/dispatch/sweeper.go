@@ -0,0 +1,70 @@
+package dispatch
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pitgo/backend/internal/infrastructure/logger"
+	"github.com/pitgo/backend/internal/infrastructure/push"
+	dispatchUC "github.com/pitgo/backend/internal/usecase/dispatch"
+)
+
+// sweepBatch bounds the stalled requests handled per sweep.
+const sweepBatch = 50
+
+// Sweeper expires offers nobody answered in time and sends the next wave for
+// requests left without an open offer, whether their offers expired or were
+// rejected. It is safe to run on every replica: waves are decided under a row
+// lock.
+type Sweeper struct {
+	uc       *dispatchUC.UseCase
+	notifier push.Notifier
+	interval time.Duration
+}
+
+func NewSweeper(uc *dispatchUC.UseCase, notifier push.Notifier, interval time.Duration) *Sweeper {
+	if interval <= 0 {
+		interval = 15 * time.Second
+	}
+	return &Sweeper{uc: uc, notifier: notifier, interval: interval}
+}
+
+// Run sweeps every interval until ctx is cancelled.
+func (s *Sweeper) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.Sweep(ctx); err != nil && ctx.Err() == nil {
+			logger.Error().Err(err).Msg("Dispatch sweep failed")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sweep runs one pass. Events recorded by it share a fresh correlation ID.
+func (s *Sweeper) Sweep(ctx context.Context) error {
+	ctx = logger.WithCorrelationID(ctx, "sweep-"+uuid.New().String())
+
+	if _, err := s.uc.ExpireStale(ctx); err != nil {
+		return err
+	}
+	ids, err := s.uc.StalledRequests(ctx, sweepBatch)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		res, err := s.uc.NextWave(ctx, id)
+		if err != nil {
+			logger.Ctx(ctx).Error().Err(err).Str("request_id", id).Msg("Failed to send next dispatch wave")
+			continue
+		}
+		notifyWave(ctx, s.notifier, res)
+	}
+	return nil
+}
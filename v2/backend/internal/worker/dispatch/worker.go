@@ -2,189 +2,240 @@ package dispatch
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"math"
-	"sort"
 	"time"
 
-	"github.com/google/uuid"
-	dispatchDomain "github.com/pitgo/backend/internal/domain/dispatch"
 	"github.com/pitgo/backend/internal/domain/events"
-	profileDomain "github.com/pitgo/backend/internal/domain/profile"
+	"github.com/pitgo/backend/internal/infrastructure/database"
 	"github.com/pitgo/backend/internal/infrastructure/logger"
 	"github.com/pitgo/backend/internal/infrastructure/push"
 	"github.com/pitgo/backend/internal/infrastructure/queue"
+	dispatchUC "github.com/pitgo/backend/internal/usecase/dispatch"
 )
 
-const maxProvidersPerDispatch = 5
+// Group identifies this worker's subscriptions, e.g. for idempotency markers.
+const Group = "dispatch-worker"
+
+// retryPolicy for request.created: provider lookups fail on transient DB
+// errors, so retry for roughly a minute before dead-lettering.
+var retryPolicy = queue.RetryPolicy{
+	MaxAttempts:    6,
+	InitialBackoff: 500 * time.Millisecond,
+	MaxBackoff:     20 * time.Second,
+	Multiplier:     2,
+	Jitter:         0.2,
+}
+
+// releaseBatch bounds the stalled requests retried when a provider frees up.
+const releaseBatch = 20
 
-// Worker listens for request.created events and dispatches to nearby providers.
+// Worker listens for request.created events, and request.opened events of
+// scheduled requests, and sends the first dispatch wave to nearby providers.
+// Requests an admin unassigned or redispatched get their next wave the same
+// way. When a job is completed or cancelled it sends the waves of stalled
+// requests right away, since its provider may take them. Other later waves
+// are sent by the Sweeper.
 type Worker struct {
-	consumer     queue.Consumer
-	publisher    queue.Publisher
-	profileRepo  profileDomain.Repository
-	dispatchRepo dispatchDomain.Repository
-	notifier     push.Notifier
+	consumer queue.Consumer
+	uc       *dispatchUC.UseCase
+	notifier push.Notifier
 }
 
-func NewWorker(
-	consumer queue.Consumer,
-	publisher queue.Publisher,
-	profileRepo profileDomain.Repository,
-	dispatchRepo dispatchDomain.Repository,
-	notifier push.Notifier,
-) *Worker {
-	return &Worker{
-		consumer:     consumer,
-		publisher:    publisher,
-		profileRepo:  profileRepo,
-		dispatchRepo: dispatchRepo,
-		notifier:     notifier,
-	}
+func NewWorker(consumer queue.Consumer, uc *dispatchUC.UseCase, notifier push.Notifier) *Worker {
+	return &Worker{consumer: consumer, uc: uc, notifier: notifier}
 }
 
-// Register subscribes the worker to relevant event topics.
-// Call this BEFORE starting the queue consumer.
-func (w *Worker) Register() error {
-	return w.consumer.Subscribe(events.TopicRequestCreated, w.handleRequestCreated)
+// Register subscribes the worker to relevant event topics, wrapping each
+// handler with mws. Call this BEFORE starting the queue consumer.
+func (w *Worker) Register(mws ...queue.Middleware) error {
+	if err := w.consumer.Subscribe(events.TopicRequestCreated, queue.Chain(w.handleRequestCreated, mws...), queue.WithRetry(retryPolicy)); err != nil {
+		return err
+	}
+	if err := w.consumer.Subscribe(events.TopicRequestOpened, queue.Chain(w.handleRequestOpened, mws...), queue.WithRetry(retryPolicy)); err != nil {
+		return err
+	}
+	for _, topic := range []string{events.TopicRequestUnassigned, events.TopicRequestRedispatched} {
+		if err := w.consumer.Subscribe(topic, queue.Chain(w.handleIntervention, mws...), queue.WithRetry(retryPolicy)); err != nil {
+			return err
+		}
+	}
+	for _, topic := range []string{events.TopicRequestCompleted, events.TopicRequestCancelled} {
+		if err := w.consumer.Subscribe(topic, queue.Chain(w.handleJobEnded, mws...), queue.WithRetry(retryPolicy)); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (w *Worker) handleRequestCreated(ctx context.Context, msg queue.Message) error {
 	// Unwrap envelope
 	env, err := events.UnmarshalEnvelope(msg.Payload)
 	if err != nil {
-		logger.Error().Err(err).Msg("Failed to unmarshal event envelope")
+		logger.Ctx(ctx).Error().Err(err).Msg("Failed to unmarshal event envelope")
 		return err
 	}
 
-	var evt events.RequestCreatedEvent
-	if err := json.Unmarshal(env.Payload, &evt); err != nil {
-		logger.Error().Err(err).Msg("Failed to unmarshal RequestCreatedEvent")
+	evt, err := events.DecodePayload[events.RequestCreatedEvent](env)
+	if err != nil {
+		logger.Ctx(ctx).Error().Err(err).Msg("Failed to unmarshal RequestCreatedEvent")
 		return err
 	}
 
-	logger.Info().
+	logger.Ctx(ctx).Info().
 		Str("request_id", evt.RequestID).
 		Str("category", evt.Category).
-		Str("correlation_id", env.CorrelationID).
 		Msg("Processing request.created event")
 
-	// 1. Find providers within a generous radius for this category
-	providers, err := w.profileRepo.FindProvidersInRadius(ctx, evt.Latitude, evt.Longitude, 50, evt.Category)
+	if evt.DispatchAt != nil {
+		// Scheduled: dispatched on request.opened
+		return nil
+	}
+	return w.nextWave(ctx, evt.RequestID)
+}
+
+func (w *Worker) handleRequestOpened(ctx context.Context, msg queue.Message) error {
+	env, err := events.UnmarshalEnvelope(msg.Payload)
 	if err != nil {
-		logger.Error().Err(err).Str("request_id", evt.RequestID).Msg("Failed to find providers")
+		logger.Ctx(ctx).Error().Err(err).Msg("Failed to unmarshal event envelope")
 		return err
 	}
-
-	if len(providers) == 0 {
-		logger.Warn().Str("request_id", evt.RequestID).Msg("No eligible providers found")
-		return nil
+	evt, err := events.DecodePayload[events.RequestOpenedEvent](env)
+	if err != nil {
+		logger.Ctx(ctx).Error().Err(err).Msg("Failed to unmarshal RequestOpenedEvent")
+		return err
 	}
 
-	// 2. Score and rank: online first, then by distance
-	type candidate struct {
-		provider *profileDomain.ProviderDetails
-		distance float64
-		online   bool
-	}
+	logger.Ctx(ctx).Info().
+		Str("request_id", evt.RequestID).
+		Str("category", evt.Category).
+		Time("scheduled_at", evt.ScheduledAt).
+		Msg("Processing request.opened event")
 
-	var candidates []candidate
-	for _, p := range providers {
-		dist := haversine(evt.Latitude, evt.Longitude, p.Latitude, p.Longitude)
-		candidates = append(candidates, candidate{
-			provider: p,
-			distance: dist,
-			online:   p.IsOnline,
-		})
+	return w.nextWave(ctx, evt.RequestID)
+}
+
+// handleIntervention sends the next wave of a request an admin reopened or
+// redispatched. Requests reassigned straight to another provider need none.
+func (w *Worker) handleIntervention(ctx context.Context, msg queue.Message) error {
+	env, err := events.UnmarshalEnvelope(msg.Payload)
+	if err != nil {
+		logger.Ctx(ctx).Error().Err(err).Msg("Failed to unmarshal event envelope")
+		return err
 	}
 
-	// Prioritize online providers, then sort by distance
-	sort.Slice(candidates, func(i, j int) bool {
-		if candidates[i].online != candidates[j].online {
-			return candidates[i].online // online first
+	if env.Topic == events.TopicRequestUnassigned {
+		evt, err := events.DecodePayload[events.RequestUnassignedEvent](env)
+		if err != nil {
+			return err
+		}
+		if evt.AssignedTo != "" {
+			return nil
 		}
-		return candidates[i].distance < candidates[j].distance
-	})
-
-	// 3. Limit to top N
-	if len(candidates) > maxProvidersPerDispatch {
-		candidates = candidates[:maxProvidersPerDispatch]
 	}
 
-	// 4. Create Dispatch records and push notifications
-	var notifications []push.Notification
-	var providerIDs []string
-
-	for _, c := range candidates {
-		d := &dispatchDomain.Dispatch{
-			ID:         uuid.New().String(),
-			RequestID:  evt.RequestID,
-			ProviderID: c.provider.ProfileID,
-			Status:     dispatchDomain.DispatchSent,
-			Distance:   math.Round(c.distance*100) / 100,
-			ExpiresAt:  time.Now().Add(5 * time.Minute),
-			CreatedAt:  time.Now(),
-			UpdatedAt:  time.Now(),
-		}
+	logger.Ctx(ctx).Info().
+		Str("request_id", env.AggregateID).
+		Str("topic", env.Topic).
+		Msg("Processing dispatch intervention")
 
-		if err := w.dispatchRepo.Create(ctx, d); err != nil {
-			logger.Error().Err(err).
-				Str("dispatch_id", d.ID).
-				Str("provider_id", c.provider.ProfileID).
-				Msg("Failed to create dispatch record")
-			continue
-		}
+	return w.nextWave(ctx, env.AggregateID)
+}
 
-		providerIDs = append(providerIDs, c.provider.ProfileID)
+// nextWave sends the request's next wave, the first one for new requests.
+// The wave and its dispatch.sent event commit together; a redelivered event
+// finds the wave already sent and does nothing.
+func (w *Worker) nextWave(ctx context.Context, requestID string) error {
+	res, err := w.uc.NextWave(ctx, requestID)
+	if err != nil {
+		logger.Ctx(ctx).Error().Err(err).Str("request_id", requestID).Msg("Failed to send dispatch wave")
+		return err
+	}
+	notifyWave(ctx, w.notifier, res)
+	return nil
+}
 
-		notifications = append(notifications, push.Notification{
-			ProviderID: c.provider.ProfileID,
-			Title:      "Novo pedido de " + evt.Category,
-			Body:       evt.Description,
-			Data: map[string]string{
-				"dispatch_id": d.ID,
-				"request_id":  evt.RequestID,
-				"distance_km": fmt.Sprintf("%.1f", c.distance),
-			},
-		})
+// handleJobEnded releases the capacity of the provider of a completed or
+// cancelled request. Cancelling a request nobody accepted releases nothing,
+// but withdraws the offers still open for it.
+func (w *Worker) handleJobEnded(ctx context.Context, msg queue.Message) error {
+	env, err := events.UnmarshalEnvelope(msg.Payload)
+	if err != nil {
+		logger.Ctx(ctx).Error().Err(err).Msg("Failed to unmarshal event envelope")
+		return err
 	}
 
-	// 5. Send push notifications
-	if len(notifications) > 0 {
-		if err := w.notifier.SendBatch(ctx, notifications); err != nil {
-			logger.Error().Err(err).Str("request_id", evt.RequestID).Msg("Failed to send push notifications")
+	var providerID string
+	switch env.Topic {
+	case events.TopicRequestCompleted:
+		evt, err := events.DecodePayload[events.RequestCompletedEvent](env)
+		if err != nil {
+			return err
+		}
+		providerID = evt.ProviderID
+	case events.TopicRequestCancelled:
+		evt, err := events.DecodePayload[events.RequestCancelledEvent](env)
+		if err != nil {
+			return err
 		}
+		if _, err := w.uc.RequestCancelled(ctx, evt.RequestID); err != nil {
+			logger.Ctx(ctx).Error().Err(err).Str("request_id", evt.RequestID).Msg("Failed to withdraw offers of cancelled request")
+			return err
+		}
+		providerID = evt.ProviderID
+	}
+	if providerID == "" {
+		return nil
 	}
 
-	// 6. Publish dispatch.sent event for downstream consumers
-	sentEvt := events.DispatchSentEvent{
-		RequestID:   evt.RequestID,
-		ProviderIDs: providerIDs,
-		Count:       len(providerIDs),
+	results, err := w.uc.CapacityReleased(ctx, providerID, releaseBatch)
+	if err != nil {
+		logger.Ctx(ctx).Error().Err(err).Str("provider_id", providerID).Msg("Failed to send waves after capacity release")
+		return err
 	}
-	sentEnv, err := events.NewEnvelope(events.TopicDispatchSent, env.CorrelationID, sentEvt)
-	if err == nil {
-		data, _ := sentEnv.Marshal()
-		_ = w.publisher.Publish(ctx, events.TopicDispatchSent, data)
+	for _, res := range results {
+		notifyWave(ctx, w.notifier, res)
 	}
-
-	logger.Info().
-		Str("request_id", evt.RequestID).
-		Int("dispatched", len(providerIDs)).
-		Int("total_candidates", len(providers)).
-		Msg("Dispatch completed")
-
 	return nil
 }
 
-func haversine(lat1, lng1, lat2, lng2 float64) float64 {
-	const r = 6371
-	dLat := (lat2 - lat1) * math.Pi / 180
-	dLng := (lng2 - lng1) * math.Pi / 180
-	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
-		math.Cos(lat1*math.Pi/180)*math.Cos(lat2*math.Pi/180)*
-			math.Sin(dLng/2)*math.Sin(dLng/2)
-	c := 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
-	return r * c
+// notifyWave pushes the offers of a wave to their providers, or tells the
+// customer that no provider was found once the waves are exhausted. Handlers
+// may run inside the idempotency middleware's transaction, so the pushes wait
+// until it commits and are dropped if it rolls back. Push failures are
+// logged only: the offers are already stored.
+func notifyWave(ctx context.Context, notifier push.Notifier, res *dispatchUC.WaveResult) {
+	if res == nil {
+		return
+	}
+	req := res.Request
+
+	var notifications []push.Notification
+	if res.Exhausted {
+		notifications = append(notifications, push.Notification{
+			CustomerID: req.CustomerID,
+			Title:      "Nenhum prestador disponível",
+			Body:       "Não encontramos um prestador de " + req.Category + " perto de você. Tente novamente mais tarde.",
+			Data: map[string]string{
+				"request_id": req.ID,
+			},
+		})
+	}
+	for _, d := range res.Offers {
+		notifications = append(notifications, push.Notification{
+			ProviderID: d.ProviderID,
+			Title:      "Novo pedido de " + req.Category,
+			Body:       req.Description,
+			Data: map[string]string{
+				"dispatch_id": d.ID,
+				"request_id":  req.ID,
+				"distance_km": fmt.Sprintf("%.1f", d.Distance),
+			},
+		})
+	}
+
+	database.AfterCommit(ctx, func() {
+		if err := notifier.SendBatch(ctx, notifications); err != nil {
+			logger.Ctx(ctx).Error().Err(err).Str("request_id", req.ID).Msg("Failed to send push notifications")
+		}
+	})
 }
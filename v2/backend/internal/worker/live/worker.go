@@ -0,0 +1,112 @@
+package live
+
+import (
+	"context"
+	"time"
+
+	"github.com/pitgo/backend/internal/domain/events"
+	liveInfra "github.com/pitgo/backend/internal/infrastructure/live"
+	"github.com/pitgo/backend/internal/infrastructure/logger"
+	"github.com/pitgo/backend/internal/infrastructure/queue"
+	dispatchUC "github.com/pitgo/backend/internal/usecase/dispatch"
+	requestUC "github.com/pitgo/backend/internal/usecase/request"
+)
+
+// Group identifies the relay's subscriptions, e.g. for idempotency markers.
+const Group = "live-relay"
+
+// Channel is the fanout channel relayed events are broadcast on.
+const Channel = "live"
+
+// Topics are the events live streams are built from: provider offer
+// notices and customer request updates.
+var Topics = []string{
+	events.TopicRequestCreated,
+	events.TopicRequestRescheduled,
+	events.TopicRequestOpened,
+	events.TopicRequestAccepted,
+	events.TopicRequestUnassigned,
+	events.TopicRequestStarted,
+	events.TopicRequestCompleted,
+	events.TopicRequestCancelled,
+	events.TopicDispatchSent,
+	events.TopicDispatchExpired,
+	events.TopicDispatchExhausted,
+	events.TopicDispatchWithdrawn,
+}
+
+// Relay rebroadcasts live stream events to every replica: the queue hands
+// each event to one replica only, while the client streaming it may be
+// connected to any of them.
+type Relay struct {
+	consumer queue.Consumer
+	fanout   queue.Fanout
+}
+
+func NewRelay(consumer queue.Consumer, fanout queue.Fanout) *Relay {
+	return &Relay{consumer: consumer, fanout: fanout}
+}
+
+// Register subscribes to Topics, wrapping the handler with mws. Call this
+// BEFORE starting the queue consumer.
+func (r *Relay) Register(mws ...queue.Middleware) error {
+	h := queue.Chain(r.relay, mws...)
+	for _, topic := range Topics {
+		if err := r.consumer.Subscribe(topic, h); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Relay) relay(ctx context.Context, msg queue.Message) error {
+	if err := r.fanout.Broadcast(ctx, Channel, msg.Payload); err != nil {
+		logger.Ctx(ctx).Error().Err(err).Str("topic", msg.Topic).Msg("Failed to broadcast live event")
+		return err
+	}
+	return nil
+}
+
+// Feed publishes the events broadcast on Channel to the streams connected
+// to this replica.
+type Feed struct {
+	fanout queue.Fanout
+	hub    *liveInfra.Hub
+}
+
+func NewFeed(fanout queue.Fanout, hub *liveInfra.Hub) *Feed {
+	return &Feed{fanout: fanout, hub: hub}
+}
+
+// Run listens on Channel until ctx is cancelled.
+func (f *Feed) Run(ctx context.Context) {
+	if err := f.fanout.Listen(ctx, Channel, f.Deliver); err != nil && ctx.Err() == nil {
+		logger.Error().Err(err).Msg("Live feed stopped")
+	}
+}
+
+// Deliver turns one broadcast envelope into stream events.
+func (f *Feed) Deliver(payload []byte) {
+	env, err := events.UnmarshalEnvelope(payload)
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to unmarshal live event envelope")
+		return
+	}
+	notices, err := dispatchUC.OfferNotices(env, time.Now())
+	if err != nil {
+		logger.Error().Err(err).Str("topic", env.Topic).Str("event_id", env.EventID).Msg("Failed to decode live event")
+		return
+	}
+	for _, n := range notices {
+		f.hub.Publish(liveInfra.ProviderKey(n.ProviderID), liveInfra.Event{Type: n.Type, Data: n})
+	}
+
+	u, err := requestUC.UpdateFor(env)
+	if err != nil {
+		logger.Error().Err(err).Str("topic", env.Topic).Str("event_id", env.EventID).Msg("Failed to decode live event")
+		return
+	}
+	if u != nil && u.CustomerID != "" {
+		f.hub.Publish(liveInfra.CustomerKey(u.CustomerID), liveInfra.Event{ID: u.EventID, Type: u.Type, Data: u})
+	}
+}
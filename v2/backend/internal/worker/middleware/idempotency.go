@@ -0,0 +1,98 @@
+package middleware
+
+import (
+	"context"
+	"time"
+
+	"github.com/pitgo/backend/internal/domain/events"
+	"github.com/pitgo/backend/internal/infrastructure/database"
+	"github.com/pitgo/backend/internal/infrastructure/logger"
+	"github.com/pitgo/backend/internal/infrastructure/queue"
+)
+
+// ProcessedStore remembers which events each subscriber group has handled.
+type ProcessedStore interface {
+	// MarkProcessed records eventID for group and reports whether it was new.
+	MarkProcessed(ctx context.Context, group, eventID string) (bool, error)
+	// Unmark forgets eventID so a failed event can be processed again.
+	Unmark(ctx context.Context, group, eventID string) error
+}
+
+// Purger is implemented by stores whose markers do not expire on their own.
+type Purger interface {
+	PurgeProcessed(ctx context.Context, before time.Time) (int64, error)
+}
+
+// Idempotent skips envelopes whose EventID the group has already processed.
+//
+// With a Transactor (Postgres store) the marker is inserted in the same
+// transaction the handler runs in, so it commits or rolls back together with
+// the handler's own repository writes. Without one (Redis store) the marker
+// is set first and removed again if the handler fails.
+func Idempotent(store ProcessedStore, group string, tx database.Transactor) queue.Middleware {
+	return func(next queue.Handler) queue.Handler {
+		return func(ctx context.Context, msg queue.Message) error {
+			env, err := events.UnmarshalEnvelope(msg.Payload)
+			if err != nil || env.EventID == "" {
+				return next(ctx, msg)
+			}
+
+			if tx != nil {
+				return tx.WithinTx(ctx, func(ctx context.Context) error {
+					first, err := store.MarkProcessed(ctx, group, env.EventID)
+					if err != nil {
+						return err
+					}
+					if !first {
+						logDuplicate(ctx, group, msg.Topic, env)
+						return nil
+					}
+					return next(ctx, msg)
+				})
+			}
+
+			first, err := store.MarkProcessed(ctx, group, env.EventID)
+			if err != nil {
+				return err
+			}
+			if !first {
+				logDuplicate(ctx, group, msg.Topic, env)
+				return nil
+			}
+			if err := next(ctx, msg); err != nil {
+				if uErr := store.Unmark(ctx, group, env.EventID); uErr != nil {
+					logger.Ctx(ctx).Error().Err(uErr).Str("group", group).Str("event_id", env.EventID).Msg("Failed to clear processed marker")
+				}
+				return err
+			}
+			return nil
+		}
+	}
+}
+
+func logDuplicate(ctx context.Context, group, topic string, env *events.Envelope) {
+	logger.Ctx(ctx).Info().
+		Str("group", group).
+		Str("topic", topic).
+		Str("event_id", env.EventID).
+		Msg("Skipping already processed event")
+}
+
+// RunPurger deletes markers older than ttl once per interval until ctx is cancelled.
+func RunPurger(ctx context.Context, p Purger, ttl, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := p.PurgeProcessed(ctx, time.Now().Add(-ttl))
+			if err != nil {
+				logger.Error().Err(err).Msg("Failed to purge processed event markers")
+			} else if n > 0 {
+				logger.Info().Int64("purged", n).Msg("Purged processed event markers")
+			}
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/pitgo/backend/internal/domain/events"
+	"github.com/pitgo/backend/internal/infrastructure/queue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type memoryStore struct {
+	mu   sync.Mutex
+	seen map[string]bool
+}
+
+func newMemoryStore() *memoryStore { return &memoryStore{seen: make(map[string]bool)} }
+
+func (s *memoryStore) MarkProcessed(_ context.Context, group, eventID string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := group + ":" + eventID
+	if s.seen[key] {
+		return false, nil
+	}
+	s.seen[key] = true
+	return true, nil
+}
+
+func (s *memoryStore) Unmark(_ context.Context, group, eventID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.seen, group+":"+eventID)
+	return nil
+}
+
+func envelopeMessage(t *testing.T) queue.Message {
+	env, err := events.NewEnvelope(events.TopicRequestCreated, "req-1", "http-1", events.RequestCreatedEvent{RequestID: "req-1"})
+	require.NoError(t, err)
+	data, err := env.Marshal()
+	require.NoError(t, err)
+	return queue.Message{Topic: events.TopicRequestCreated, Payload: data}
+}
+
+func TestIdempotent_SkipsRedelivery(t *testing.T) {
+	store := newMemoryStore()
+	calls := 0
+	h := Idempotent(store, "dispatch-worker", nil)(func(context.Context, queue.Message) error {
+		calls++
+		return nil
+	})
+	msg := envelopeMessage(t)
+
+	require.NoError(t, h(context.Background(), msg))
+	require.NoError(t, h(context.Background(), msg))
+	assert.Equal(t, 1, calls)
+
+	// A different subscriber group still gets the event.
+	other := Idempotent(store, "webhook-worker", nil)(func(context.Context, queue.Message) error {
+		calls++
+		return nil
+	})
+	require.NoError(t, other(context.Background(), msg))
+	assert.Equal(t, 2, calls)
+}
+
+func TestIdempotent_FailedHandlerCanBeRetried(t *testing.T) {
+	store := newMemoryStore()
+	fail := true
+	calls := 0
+	h := Idempotent(store, "dispatch-worker", nil)(func(context.Context, queue.Message) error {
+		calls++
+		if fail {
+			return errors.New("boom")
+		}
+		return nil
+	})
+	msg := envelopeMessage(t)
+
+	assert.Error(t, h(context.Background(), msg))
+	fail = false
+	assert.NoError(t, h(context.Background(), msg))
+	assert.Equal(t, 2, calls)
+}
@@ -0,0 +1,104 @@
+package outbox
+
+import (
+	"context"
+	"time"
+
+	domain "github.com/pitgo/backend/internal/domain/outbox"
+	"github.com/pitgo/backend/internal/infrastructure/config"
+	"github.com/pitgo/backend/internal/infrastructure/database"
+	"github.com/pitgo/backend/internal/infrastructure/logger"
+	"github.com/pitgo/backend/internal/infrastructure/queue"
+)
+
+const purgeInterval = time.Hour
+
+// Relay publishes outbox messages through the queue and marks them sent.
+// Delivery is at-least-once: a crash between Publish and the commit of
+// MarkSent republishes the message on the next poll.
+type Relay struct {
+	tx        database.Transactor
+	repo      domain.Repository
+	publisher queue.Publisher
+	cfg       config.OutboxConfig
+}
+
+func NewRelay(tx database.Transactor, repo domain.Repository, publisher queue.Publisher, cfg config.OutboxConfig) *Relay {
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = 500 * time.Millisecond
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	return &Relay{tx: tx, repo: repo, publisher: publisher, cfg: cfg}
+}
+
+// Run polls the outbox until ctx is cancelled.
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.cfg.PollInterval)
+	defer ticker.Stop()
+	lastPurge := time.Now()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		// Drain full batches back to back, then wait for the next tick.
+		for ctx.Err() == nil {
+			n, err := r.relayBatch(ctx)
+			if err != nil {
+				logger.Error().Err(err).Msg("Outbox relay batch failed")
+				break
+			}
+			if n < r.cfg.BatchSize {
+				break
+			}
+		}
+
+		if r.cfg.Retention > 0 && time.Since(lastPurge) >= purgeInterval {
+			lastPurge = time.Now()
+			purged, err := r.repo.PurgeSent(ctx, time.Now().Add(-r.cfg.Retention))
+			if err != nil {
+				logger.Error().Err(err).Msg("Failed to purge sent outbox messages")
+			} else if purged > 0 {
+				logger.Info().Int64("purged", purged).Msg("Purged sent outbox messages")
+			}
+		}
+	}
+}
+
+// relayBatch publishes one batch of pending messages inside a transaction and
+// returns how many were published. A relay publishes in outbox order and
+// stops at the first publish failure, so it never skips ahead of a message it
+// failed on. With several replicas that does not hold across relays: rows
+// locked by one are skipped by the others, which may publish later events of
+// the same request first. Subscribers must not depend on that order.
+func (r *Relay) relayBatch(ctx context.Context) (int, error) {
+	published := 0
+	err := r.tx.WithinTx(ctx, func(ctx context.Context) error {
+		msgs, err := r.repo.FetchPending(ctx, r.cfg.BatchSize)
+		if err != nil {
+			return err
+		}
+		for _, m := range msgs {
+			if err := r.publisher.Publish(ctx, m.Topic, m.Payload); err != nil {
+				logger.Warn().Err(err).
+					Str("event_id", m.EventID).
+					Str("correlation_id", m.CorrelationID).
+					Str("topic", m.Topic).
+					Int("attempts", m.Attempts+1).
+					Msg("Failed to publish outbox message")
+				return r.repo.MarkFailed(ctx, m.ID, err.Error())
+			}
+			if err := r.repo.MarkSent(ctx, m.ID, time.Now()); err != nil {
+				return err
+			}
+			published++
+		}
+		return nil
+	})
+	return published, err
+}
@@ -0,0 +1,103 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	domain "github.com/pitgo/backend/internal/domain/outbox"
+	"github.com/pitgo/backend/internal/infrastructure/config"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeTx struct{}
+
+func (fakeTx) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error { return fn(ctx) }
+
+type fakeRepo struct {
+	msgs []*domain.Message
+}
+
+func (r *fakeRepo) Add(_ context.Context, m *domain.Message) error {
+	r.msgs = append(r.msgs, m)
+	return nil
+}
+
+func (r *fakeRepo) Requeue(ctx context.Context, m *domain.Message) error {
+	return r.Add(ctx, m)
+}
+
+func (r *fakeRepo) FetchPending(_ context.Context, limit int) ([]*domain.Message, error) {
+	var out []*domain.Message
+	for _, m := range r.msgs {
+		if m.SentAt == nil && len(out) < limit {
+			out = append(out, m)
+		}
+	}
+	return out, nil
+}
+
+func (r *fakeRepo) MarkSent(_ context.Context, id string, sentAt time.Time) error {
+	for _, m := range r.msgs {
+		if m.ID == id {
+			m.SentAt = &sentAt
+			m.Attempts++
+		}
+	}
+	return nil
+}
+
+func (r *fakeRepo) MarkFailed(_ context.Context, id string, reason string) error {
+	for _, m := range r.msgs {
+		if m.ID == id {
+			m.LastError = reason
+			m.Attempts++
+		}
+	}
+	return nil
+}
+
+func (r *fakeRepo) PurgeSent(context.Context, time.Time) (int64, error) { return 0, nil }
+
+type fakePublisher struct {
+	failTopic string
+	published []string
+}
+
+func (p *fakePublisher) Publish(_ context.Context, topic string, _ []byte) error {
+	if topic == p.failTopic {
+		return errors.New("broker unavailable")
+	}
+	p.published = append(p.published, topic)
+	return nil
+}
+
+func (p *fakePublisher) Close() error { return nil }
+
+func TestRelayBatch_StopsAtFirstFailure(t *testing.T) {
+	repo := &fakeRepo{msgs: []*domain.Message{
+		{ID: "1", Topic: "request.created"},
+		{ID: "2", Topic: "request.accepted"},
+		{ID: "3", Topic: "request.started"},
+	}}
+	pub := &fakePublisher{failTopic: "request.accepted"}
+	relay := NewRelay(fakeTx{}, repo, pub, config.OutboxConfig{BatchSize: 10})
+
+	n, err := relay.relayBatch(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, []string{"request.created"}, pub.published)
+	assert.NotNil(t, repo.msgs[0].SentAt)
+	assert.Nil(t, repo.msgs[1].SentAt)
+	assert.Equal(t, "broker unavailable", repo.msgs[1].LastError)
+	assert.Nil(t, repo.msgs[2].SentAt, "later events must wait for the failed one")
+
+	// Once the broker recovers the remaining events go out in order.
+	pub.failTopic = ""
+	n, err = relay.relayBatch(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, []string{"request.created", "request.accepted", "request.started"}, pub.published)
+}
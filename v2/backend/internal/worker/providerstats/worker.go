@@ -0,0 +1,48 @@
+package providerstats
+
+import (
+	"context"
+
+	"github.com/pitgo/backend/internal/domain/events"
+	"github.com/pitgo/backend/internal/infrastructure/logger"
+	"github.com/pitgo/backend/internal/infrastructure/queue"
+	statsUC "github.com/pitgo/backend/internal/usecase/providerstats"
+)
+
+// Group identifies this worker's subscriptions, e.g. for idempotency markers.
+const Group = "provider-stats-worker"
+
+// Worker keeps provider stats up to date as dispatch and request events arrive.
+type Worker struct {
+	consumer queue.Consumer
+	uc       *statsUC.UseCase
+}
+
+func NewWorker(consumer queue.Consumer, uc *statsUC.UseCase) *Worker {
+	return &Worker{consumer: consumer, uc: uc}
+}
+
+// Register subscribes to the topics that move provider stats, wrapping each
+// handler with mws. Call this BEFORE starting the queue consumer.
+func (w *Worker) Register(mws ...queue.Middleware) error {
+	h := queue.Chain(w.handleEvent, mws...)
+	for _, topic := range statsUC.Topics {
+		if err := w.consumer.Subscribe(topic, h); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *Worker) handleEvent(ctx context.Context, msg queue.Message) error {
+	env, err := events.UnmarshalEnvelope(msg.Payload)
+	if err != nil {
+		logger.Ctx(ctx).Error().Err(err).Str("topic", msg.Topic).Msg("Failed to unmarshal event envelope")
+		return err
+	}
+	if err := w.uc.Apply(ctx, env); err != nil {
+		logger.Ctx(ctx).Error().Err(err).Str("topic", env.Topic).Str("event_id", env.EventID).Msg("Failed to update provider stats")
+		return err
+	}
+	return nil
+}
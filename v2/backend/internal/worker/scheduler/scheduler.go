@@ -0,0 +1,60 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pitgo/backend/internal/infrastructure/logger"
+	requestUC "github.com/pitgo/backend/internal/usecase/request"
+)
+
+// Scheduler opens scheduled requests for dispatch once their dispatch time
+// has come. The schedule lives in service_requests, so nothing is lost on
+// restart, and due requests are claimed with SKIP LOCKED, so it is safe to
+// run on every replica.
+type Scheduler struct {
+	uc       *requestUC.UseCase
+	interval time.Duration
+	batch    int
+}
+
+func NewScheduler(uc *requestUC.UseCase, interval time.Duration, batch int) *Scheduler {
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	if batch <= 0 {
+		batch = 50
+	}
+	return &Scheduler{uc: uc, interval: interval, batch: batch}
+}
+
+// Run polls every interval until ctx is cancelled. A full batch is followed
+// by another poll right away.
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		n, err := s.Poll(ctx)
+		if err != nil && ctx.Err() == nil {
+			logger.Error().Err(err).Msg("Request scheduler poll failed")
+		}
+		if err == nil && n == s.batch {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll opens one batch of due requests and returns how many were opened.
+// Events recorded by it share a fresh correlation ID.
+func (s *Scheduler) Poll(ctx context.Context) (int, error) {
+	ctx = logger.WithCorrelationID(ctx, "schedule-"+uuid.New().String())
+	opened, err := s.uc.OpenDue(ctx, s.batch)
+	return len(opened), err
+}
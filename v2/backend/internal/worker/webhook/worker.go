@@ -0,0 +1,84 @@
+package webhook
+
+import (
+	"context"
+	"time"
+
+	"github.com/pitgo/backend/internal/domain/events"
+	"github.com/pitgo/backend/internal/infrastructure/logger"
+	"github.com/pitgo/backend/internal/infrastructure/queue"
+	webhookUC "github.com/pitgo/backend/internal/usecase/webhook"
+)
+
+// Group identifies this worker's subscriptions, e.g. for idempotency markers.
+const Group = "webhook-worker"
+
+// Worker turns published events into webhook deliveries and sends the ones
+// that are due.
+type Worker struct {
+	consumer     queue.Consumer
+	uc           *webhookUC.UseCase
+	pollInterval time.Duration
+	batchSize    int
+}
+
+func NewWorker(consumer queue.Consumer, uc *webhookUC.UseCase, pollInterval time.Duration, batchSize int) *Worker {
+	if pollInterval <= 0 {
+		pollInterval = time.Second
+	}
+	if batchSize <= 0 {
+		batchSize = 20
+	}
+	return &Worker{consumer: consumer, uc: uc, pollInterval: pollInterval, batchSize: batchSize}
+}
+
+// Register subscribes to every declared event topic, wrapping each handler
+// with mws. Call this BEFORE starting the queue consumer.
+func (w *Worker) Register(mws ...queue.Middleware) error {
+	h := queue.Chain(w.handleEvent, mws...)
+	for _, topic := range events.Topics {
+		if err := w.consumer.Subscribe(topic, h); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *Worker) handleEvent(ctx context.Context, msg queue.Message) error {
+	env, err := events.UnmarshalEnvelope(msg.Payload)
+	if err != nil {
+		logger.Ctx(ctx).Error().Err(err).Str("topic", msg.Topic).Msg("Failed to unmarshal event envelope")
+		return err
+	}
+	n, err := w.uc.Fanout(ctx, env)
+	if err != nil {
+		logger.Ctx(ctx).Error().Err(err).Str("topic", env.Topic).Str("event_id", env.EventID).Msg("Failed to queue webhook deliveries")
+		return err
+	}
+	if n > 0 {
+		logger.Ctx(ctx).Debug().Str("topic", env.Topic).Str("event_id", env.EventID).Int("deliveries", n).Msg("Webhook deliveries queued")
+	}
+	return nil
+}
+
+// Run sends due deliveries every poll interval until ctx is cancelled. A full
+// batch is followed immediately by the next one.
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		n, err := w.uc.DeliverDue(ctx, w.batchSize)
+		if err != nil && ctx.Err() == nil {
+			logger.Error().Err(err).Msg("Webhook delivery batch failed")
+		}
+		if n == w.batchSize && err == nil {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
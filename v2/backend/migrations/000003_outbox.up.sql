@@ -0,0 +1,17 @@
+-- Transactional outbox: events are written with the state change that
+-- produced them and published afterwards by the outbox relay.
+CREATE TABLE IF NOT EXISTS outbox (
+    id             UUID PRIMARY KEY,
+    event_id       UUID UNIQUE NOT NULL,
+    topic          VARCHAR(100) NOT NULL,
+    correlation_id VARCHAR(100) NOT NULL DEFAULT '',
+    payload        JSONB NOT NULL,
+    attempts       INTEGER NOT NULL DEFAULT 0,
+    last_error     TEXT,
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    sent_at        TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_outbox_pending
+  ON outbox (created_at)
+  WHERE sent_at IS NULL;
@@ -0,0 +1,17 @@
+-- Messages whose handlers exhausted their retry policy
+CREATE TABLE IF NOT EXISTS dead_letters (
+    id             UUID PRIMARY KEY,
+    topic          VARCHAR(100) NOT NULL,
+    message_id     VARCHAR(100) NOT NULL DEFAULT '',
+    event_id       VARCHAR(100) NOT NULL DEFAULT '',
+    correlation_id VARCHAR(100) NOT NULL DEFAULT '',
+    envelope       JSONB NOT NULL,
+    error          TEXT NOT NULL,
+    attempts       INTEGER NOT NULL,
+    failed_at      TIMESTAMPTZ NOT NULL,
+    requeued_at    TIMESTAMPTZ,
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_dead_letters_topic
+  ON dead_letters (topic, failed_at DESC);
@@ -0,0 +1,10 @@
+-- Idempotency markers: one row per (subscriber group, event) already handled
+CREATE TABLE IF NOT EXISTS processed_events (
+    group_name   VARCHAR(100) NOT NULL,
+    event_id     VARCHAR(100) NOT NULL,
+    processed_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (group_name, event_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_processed_events_processed_at
+  ON processed_events (processed_at);
@@ -0,0 +1,20 @@
+-- Append-only log of every envelope published through the queue
+CREATE TABLE IF NOT EXISTS event_log (
+    seq            BIGSERIAL PRIMARY KEY,
+    event_id       VARCHAR(100) UNIQUE NOT NULL,
+    topic          VARCHAR(100) NOT NULL,
+    correlation_id VARCHAR(100) NOT NULL DEFAULT '',
+    schema_version INTEGER NOT NULL DEFAULT 1,
+    envelope       JSONB NOT NULL,
+    occurred_at    TIMESTAMPTZ NOT NULL,
+    recorded_at    TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_event_log_topic_time
+  ON event_log (topic, occurred_at);
+
+CREATE INDEX IF NOT EXISTS idx_event_log_correlation
+  ON event_log (correlation_id, seq);
+
+CREATE INDEX IF NOT EXISTS idx_event_log_occurred_at
+  ON event_log (occurred_at);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_event_log_aggregate;
+ALTER TABLE event_log DROP COLUMN IF EXISTS aggregate_id;
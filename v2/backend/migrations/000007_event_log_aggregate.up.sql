@@ -0,0 +1,9 @@
+-- Envelopes now carry the HTTP request ID as correlation_id and the service
+-- request ID as aggregate_id. Before this change correlation_id held the
+-- service request ID, so existing rows are backfilled from it.
+ALTER TABLE event_log ADD COLUMN IF NOT EXISTS aggregate_id VARCHAR(100) NOT NULL DEFAULT '';
+
+UPDATE event_log SET aggregate_id = correlation_id WHERE aggregate_id = '';
+
+CREATE INDEX IF NOT EXISTS idx_event_log_aggregate
+  ON event_log (aggregate_id, seq);
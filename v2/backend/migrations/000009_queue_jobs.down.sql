@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS queue_jobs;
+DROP FUNCTION IF EXISTS notify_queue_job();
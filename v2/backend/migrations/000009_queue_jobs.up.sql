@@ -0,0 +1,25 @@
+-- Messages of the postgres queue driver. Rows are deleted once handled;
+-- locked_until is the lease of the replica that claimed the row.
+CREATE TABLE IF NOT EXISTS queue_jobs (
+    id           BIGSERIAL PRIMARY KEY,
+    topic        VARCHAR(100) NOT NULL,
+    payload      BYTEA NOT NULL,
+    attempts     INTEGER NOT NULL DEFAULT 0,
+    locked_until TIMESTAMPTZ NOT NULL DEFAULT '-infinity',
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_queue_jobs_ready
+  ON queue_jobs (topic, locked_until, id);
+
+-- Wake listening consumers when a job commits. The payload is the topic.
+CREATE OR REPLACE FUNCTION notify_queue_job() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify('queue_jobs', NEW.topic);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER queue_jobs_notify
+  AFTER INSERT ON queue_jobs
+  FOR EACH ROW EXECUTE FUNCTION notify_queue_job();
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS dispatch_waves;
+DROP INDEX IF EXISTS idx_dispatches_open_expiry;
+ALTER TABLE dispatches DROP COLUMN IF EXISTS wave;
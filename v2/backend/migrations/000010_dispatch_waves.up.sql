@@ -0,0 +1,20 @@
+-- Offers are sent in waves of widening radius; each offer remembers its wave
+ALTER TABLE dispatches ADD COLUMN IF NOT EXISTS wave INTEGER NOT NULL DEFAULT 1;
+
+CREATE INDEX IF NOT EXISTS idx_dispatches_open_expiry
+  ON dispatches (expires_at)
+  WHERE status IN ('pending', 'sent');
+
+-- Dispatch progress per service request. The row is locked while a wave is
+-- decided so replicas never send two waves at once.
+CREATE TABLE IF NOT EXISTS dispatch_waves (
+    request_id   UUID PRIMARY KEY REFERENCES service_requests(id) ON DELETE CASCADE,
+    wave         INTEGER NOT NULL DEFAULT 0,
+    radius_km    DECIMAL(10,2) NOT NULL DEFAULT 0,
+    exhausted_at TIMESTAMPTZ,
+    updated_at   TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_dispatch_waves_active
+  ON dispatch_waves (updated_at)
+  WHERE exhausted_at IS NULL;
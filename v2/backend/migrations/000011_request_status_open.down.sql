@@ -0,0 +1,5 @@
+ALTER TABLE service_requests DROP CONSTRAINT IF EXISTS service_requests_status_check;
+UPDATE service_requests SET status = 'pending' WHERE status = 'open';
+ALTER TABLE service_requests ADD CONSTRAINT service_requests_status_check
+  CHECK (status IN ('pending', 'accepted', 'in_progress', 'completed', 'cancelled'));
+ALTER TABLE service_requests ALTER COLUMN status SET DEFAULT 'pending';
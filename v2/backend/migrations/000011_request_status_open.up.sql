@@ -0,0 +1,6 @@
+-- 000002 renamed 'pending' requests to 'open' but kept the original CHECK,
+-- which rejects 'open'
+ALTER TABLE service_requests DROP CONSTRAINT IF EXISTS service_requests_status_check;
+ALTER TABLE service_requests ADD CONSTRAINT service_requests_status_check
+  CHECK (status IN ('open', 'accepted', 'in_progress', 'completed', 'cancelled'));
+ALTER TABLE service_requests ALTER COLUMN status SET DEFAULT 'open';
@@ -0,0 +1,13 @@
+-- How each provider responds to offers, updated incrementally from dispatch
+-- and request events by the provider stats worker
+CREATE TABLE IF NOT EXISTS provider_stats (
+    provider_id            UUID PRIMARY KEY REFERENCES profiles(id) ON DELETE CASCADE,
+    offers_accepted        INTEGER NOT NULL DEFAULT 0,
+    offers_rejected        INTEGER NOT NULL DEFAULT 0,
+    offers_expired         INTEGER NOT NULL DEFAULT 0,
+    jobs_accepted          INTEGER NOT NULL DEFAULT 0,
+    cancelled_after_accept INTEGER NOT NULL DEFAULT 0,
+    recent_response_ms     BIGINT[] NOT NULL DEFAULT '{}',
+    median_response_ms     BIGINT NOT NULL DEFAULT 0,
+    updated_at             TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,8 @@
+-- Last position reported by each provider's app. Matching prefers it over
+-- the static provider_details coordinates while it is fresh.
+CREATE TABLE IF NOT EXISTS provider_locations (
+    provider_id UUID PRIMARY KEY REFERENCES profiles(id) ON DELETE CASCADE,
+    latitude    DOUBLE PRECISION NOT NULL,
+    longitude   DOUBLE PRECISION NOT NULL,
+    reported_at TIMESTAMPTZ NOT NULL
+);
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_service_requests_dispatch_due;
+UPDATE service_requests SET status = 'open' WHERE status = 'scheduled';
+ALTER TABLE service_requests DROP COLUMN IF EXISTS dispatch_at;
+ALTER TABLE service_requests DROP CONSTRAINT IF EXISTS service_requests_status_check;
+ALTER TABLE service_requests ADD CONSTRAINT service_requests_status_check
+  CHECK (status IN ('open', 'accepted', 'in_progress', 'completed', 'cancelled'));
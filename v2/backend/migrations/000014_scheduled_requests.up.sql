@@ -0,0 +1,10 @@
+-- Requests booked ahead wait in 'scheduled' until dispatch_at, when the
+-- request scheduler opens them for dispatch
+ALTER TABLE service_requests DROP CONSTRAINT IF EXISTS service_requests_status_check;
+ALTER TABLE service_requests ADD CONSTRAINT service_requests_status_check
+  CHECK (status IN ('scheduled', 'open', 'accepted', 'in_progress', 'completed', 'cancelled'));
+ALTER TABLE service_requests ADD COLUMN IF NOT EXISTS dispatch_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_service_requests_dispatch_due
+  ON service_requests (dispatch_at)
+  WHERE status = 'scheduled';
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_provider_details_cell;
+DROP INDEX IF EXISTS idx_service_requests_open_cell;
+ALTER TABLE provider_details DROP COLUMN IF EXISTS geo_cell;
+ALTER TABLE service_requests DROP COLUMN IF EXISTS geo_cell;
+
+CREATE INDEX IF NOT EXISTS idx_service_requests_location
+  ON service_requests (latitude, longitude)
+  WHERE status = 'open';
@@ -0,0 +1,19 @@
+-- Grid cell of each location, as computed by geo.Cell: 0.1-degree cells
+-- numbered row * 10000 + column. Radius searches look up the cells covering
+-- their bounding box instead of computing the distance to every row.
+ALTER TABLE service_requests ADD COLUMN IF NOT EXISTS geo_cell INTEGER
+  GENERATED ALWAYS AS (floor((latitude + 90) * 10)::int * 10000 + floor((longitude + 180) * 10)::int) STORED;
+
+ALTER TABLE provider_details ADD COLUMN IF NOT EXISTS geo_cell INTEGER
+  GENERATED ALWAYS AS (floor((latitude + 90) * 10)::int * 10000 + floor((longitude + 180) * 10)::int) STORED;
+
+-- Replaces the (latitude, longitude) index, which only narrows searches down
+-- to a band of latitude
+DROP INDEX IF EXISTS idx_service_requests_location;
+
+CREATE INDEX IF NOT EXISTS idx_service_requests_open_cell
+  ON service_requests (geo_cell)
+  WHERE status = 'open';
+
+CREATE INDEX IF NOT EXISTS idx_provider_details_cell
+  ON provider_details (geo_cell);
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS dispatch_interventions;
+UPDATE dispatches SET status = 'rejected' WHERE status = 'revoked';
+ALTER TABLE dispatches DROP CONSTRAINT IF EXISTS dispatches_status_check;
+ALTER TABLE dispatches ADD CONSTRAINT dispatches_status_check
+  CHECK (status IN ('pending', 'sent', 'accepted', 'rejected', 'expired'));
@@ -0,0 +1,21 @@
+-- Offers taken back from a provider when an admin unassigns their request
+ALTER TABLE dispatches DROP CONSTRAINT IF EXISTS dispatches_status_check;
+ALTER TABLE dispatches ADD CONSTRAINT dispatches_status_check
+  CHECK (status IN ('pending', 'sent', 'accepted', 'rejected', 'expired', 'revoked'));
+
+-- Audit trail of admins assigning, unassigning and redispatching requests.
+-- admin_id is the admin's auth user ID and outlives the account.
+CREATE TABLE IF NOT EXISTS dispatch_interventions (
+    id                   UUID PRIMARY KEY,
+    request_id           UUID NOT NULL REFERENCES service_requests(id) ON DELETE CASCADE,
+    action               VARCHAR(20) NOT NULL
+                         CHECK (action IN ('assign', 'unassign', 'redispatch')),
+    admin_id             VARCHAR(100) NOT NULL,
+    provider_id          UUID REFERENCES profiles(id),
+    previous_provider_id UUID REFERENCES profiles(id),
+    reason               TEXT NOT NULL,
+    created_at           TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_dispatch_interventions_request
+  ON dispatch_interventions (request_id, created_at);
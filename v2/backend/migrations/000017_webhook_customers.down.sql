@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_webhook_subscriptions_customers;
+ALTER TABLE webhook_subscriptions DROP COLUMN IF EXISTS customer_ids;
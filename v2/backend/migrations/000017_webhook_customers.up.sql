@@ -0,0 +1,6 @@
+-- Webhooks only hear about the requests of their partner's customer
+-- accounts. Existing subscriptions receive nothing until they are scoped.
+ALTER TABLE webhook_subscriptions ADD COLUMN IF NOT EXISTS customer_ids UUID[] NOT NULL DEFAULT '{}';
+
+CREATE INDEX IF NOT EXISTS idx_webhook_subscriptions_customers
+  ON webhook_subscriptions USING GIN (customer_ids);
@@ -0,0 +1,4 @@
+UPDATE dispatches SET status = 'rejected' WHERE status = 'withdrawn';
+ALTER TABLE dispatches DROP CONSTRAINT IF EXISTS dispatches_status_check;
+ALTER TABLE dispatches ADD CONSTRAINT dispatches_status_check
+  CHECK (status IN ('pending', 'sent', 'accepted', 'rejected', 'expired', 'revoked'));
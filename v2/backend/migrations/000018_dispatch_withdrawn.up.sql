@@ -0,0 +1,13 @@
+-- Offers closed unanswered because another provider took the request. They
+-- were stored as rejected, which made them look declined by their provider;
+-- those rows carry the timestamp the request was accepted at.
+ALTER TABLE dispatches DROP CONSTRAINT IF EXISTS dispatches_status_check;
+ALTER TABLE dispatches ADD CONSTRAINT dispatches_status_check
+  CHECK (status IN ('pending', 'sent', 'accepted', 'rejected', 'expired', 'revoked', 'withdrawn'));
+
+UPDATE dispatches d SET status = 'withdrawn'
+  FROM service_requests sr
+ WHERE sr.id = d.request_id
+   AND d.status = 'rejected'
+   AND sr.provider_id IS DISTINCT FROM d.provider_id
+   AND d.updated_at = sr.accepted_at;
@@ -0,0 +1,15 @@
+-- A dead letter can be delivered more than once (at-least-once queues, a
+-- replica reclaiming it). Keep one open dead letter per event: the oldest.
+-- Once requeued, a new failure of the event is stored again.
+DELETE FROM dead_letters d
+ USING dead_letters older
+ WHERE d.topic = older.topic
+   AND d.event_id = older.event_id
+   AND d.event_id <> ''
+   AND d.requeued_at IS NULL
+   AND older.requeued_at IS NULL
+   AND (older.created_at, older.id) < (d.created_at, d.id);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_dead_letters_open_event
+  ON dead_letters (topic, event_id)
+  WHERE event_id <> '' AND requeued_at IS NULL;